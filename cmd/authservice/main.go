@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/zeebo/errs"
@@ -18,6 +19,8 @@ import (
 	"storj.io/common/fpath"
 	"storj.io/gateway-mt/internal/register"
 	"storj.io/gateway-mt/pkg/auth"
+	"storj.io/gateway-mt/pkg/prommetrics"
+	"storj.io/gateway-mt/pkg/tracing"
 	"storj.io/private/cfgstruct"
 	"storj.io/private/process"
 )
@@ -102,6 +105,24 @@ func cmdRun(cmd *cobra.Command, args []string) (err error) {
 		return errs.New("failed to initialize telemetry batcher: %w", err)
 	}
 
+	var metrics *prommetrics.Server
+	if runCfg.Prometheus.Address != "" {
+		metrics, err = prommetrics.NewServer(log.Named("metrics"), runCfg.Prometheus)
+		if err != nil {
+			return err
+		}
+	}
+
+	tracer, err := tracing.New(ctx, log.Named("tracing"), "authservice", runCfg.OpenTelemetry)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = errs.Combine(err, tracer.Close(closeCtx))
+	}()
+
 	p, err := auth.New(ctx, log, runCfg, confDir)
 	if err != nil {
 		return err
@@ -111,7 +132,24 @@ func cmdRun(cmd *cobra.Command, args []string) (err error) {
 		err = errs.Combine(err, p.Close())
 	}()
 
-	return errs2.IgnoreCanceled(p.Run(ctx))
+	// the metrics server is stopped when the peer stops.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var g errgroup.Group
+
+	if metrics != nil {
+		g.Go(func() error {
+			return metrics.Run(ctx)
+		})
+	}
+
+	g.Go(func() error {
+		defer cancel()
+		return errs2.IgnoreCanceled(p.Run(ctx))
+	})
+
+	return g.Wait()
 }
 
 func cmdMigrationRun(cmd *cobra.Command, _ []string) (err error) {
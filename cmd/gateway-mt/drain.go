@@ -0,0 +1,36 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"go.uber.org/zap"
+
+	"storj.io/gateway-mt/pkg/server"
+)
+
+// drainOnSignal starts draining peer when the process receives one of
+// drainSignals, until ctx is canceled.
+func drainOnSignal(ctx context.Context, log *zap.Logger, peer *server.Peer) error {
+	if len(drainSignals) == 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, drainSignals...)
+	defer signal.Stop(signals)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case sig := <-signals:
+		log.Info("Got a signal to drain", zap.Stringer("signal", sig))
+		peer.Drain()
+		return nil
+	}
+}
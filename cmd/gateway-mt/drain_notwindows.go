@@ -0,0 +1,14 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// drainSignals are the signals that start draining.
+var drainSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,10 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import "os"
+
+// drainSignals are the signals that start draining. Windows has none that
+// fit, so draining is only started through the drain endpoint.
+var drainSignals []os.Signal
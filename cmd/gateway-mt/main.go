@@ -6,10 +6,12 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -20,7 +22,9 @@ import (
 	"storj.io/common/errs2"
 	"storj.io/common/fpath"
 	"storj.io/gateway-mt/pkg/authclient"
+	"storj.io/gateway-mt/pkg/prommetrics"
 	"storj.io/gateway-mt/pkg/server"
+	"storj.io/gateway-mt/pkg/tracing"
 	"storj.io/gateway-mt/pkg/trustedip"
 	"storj.io/private/cfgstruct"
 	"storj.io/private/process"
@@ -115,31 +119,48 @@ func cmdRun(cmd *cobra.Command, _ []string) (err error) {
 		log.Info("Insecurely logging all errors, paths, and headers")
 	}
 
-	var trustedClientIPs trustedip.List
+	corsAllowedOrigins := strings.Split(runCfg.CorsOrigins, ",")
 
-	if runCfg.UseClientIPHeaders {
-		if len(runCfg.ClientTrustedIPSList) > 0 {
-			trustedClientIPs = trustedip.NewList(runCfg.ClientTrustedIPSList...)
-		} else {
-			trustedClientIPs = trustedip.NewListTrustAll()
+	if err := runCfg.Auth.Validate(); err != nil {
+		return err
+	}
+	var metrics *prommetrics.Server
+	if runCfg.Prometheus.Address != "" {
+		metrics, err = prommetrics.NewServer(log.Named("metrics"), runCfg.Prometheus)
+		if err != nil {
+			return err
 		}
-	} else {
-		trustedClientIPs = trustedip.NewListUntrustAll()
 	}
 
-	corsAllowedOrigins := strings.Split(runCfg.CorsOrigins, ",")
-
-	if err := runCfg.Auth.Validate(); err != nil {
+	tracer, err := tracing.New(ctx, log.Named("tracing"), "gateway-mt", runCfg.OpenTelemetry)
+	if err != nil {
 		return err
 	}
-	peer, err := server.New(runCfg, log, trustedClientIPs, corsAllowedOrigins,
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = errs.Combine(err, tracer.Close(closeCtx))
+	}()
+
+	peer, err := server.New(ctx, runCfg, log, trustedClientIPs(runCfg), corsAllowedOrigins,
 		authclient.New(runCfg.Auth), strings.Split(runCfg.DomainName, ","), runCfg.ConcurrentAllowed)
 	if err != nil {
 		return err
 	}
 
+	reloader, err := newReloader(log.Named("reload"), cmd, peer, runCfg)
+	if err != nil {
+		return errs.Combine(err, peer.Close())
+	}
+
 	var g errgroup.Group
 
+	if metrics != nil {
+		g.Go(func() error {
+			return metrics.Run(ctx)
+		})
+	}
+
 	g.Go(func() error {
 		<-ctx.Done()
 		return errs2.IgnoreCanceled(peer.Close())
@@ -149,9 +170,29 @@ func cmdRun(cmd *cobra.Command, _ []string) (err error) {
 		return errs2.IgnoreCanceled(peer.Run(ctx))
 	})
 
+	g.Go(func() error {
+		return errs2.IgnoreCanceled(reloader.Run(ctx))
+	})
+
+	g.Go(func() error {
+		return errs2.IgnoreCanceled(drainOnSignal(ctx, log.Named("drain"), peer))
+	})
+
 	return g.Wait()
 }
 
+// trustedClientIPs returns the list of clients whose headers identifying the
+// IPs of the clients they forward requests of are trusted.
+func trustedClientIPs(config server.Config) trustedip.List {
+	if !config.UseClientIPHeaders {
+		return trustedip.NewListUntrustAll()
+	}
+	if len(config.ClientTrustedIPSList) > 0 {
+		return trustedip.NewList(config.ClientTrustedIPSList...)
+	}
+	return trustedip.NewListTrustAll()
+}
+
 func cmdSetup(cmd *cobra.Command, _ []string) error {
 	setupDir, err := filepath.Abs(confDir)
 	if err != nil {
@@ -0,0 +1,223 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/zeebo/structs"
+	"go.uber.org/zap"
+
+	"storj.io/gateway-mt/pkg/server"
+	"storj.io/private/process"
+)
+
+// configPollInterval is how often the configuration file is checked for
+// changes.
+const configPollInterval = 10 * time.Second
+
+// reloadableKeys are the configuration keys, or prefixes of them ending with a
+// dot, whose changes are applied without restarting.
+var reloadableKeys = []string{
+	"cors-origins",
+	"concurrent-allowed",
+	"client-trusted-ips-list",
+	"use-client-ip-headers",
+	"auth.cache.",
+	"rate-limit.",
+}
+
+func isReloadable(key string) bool {
+	for _, reloadable := range reloadableKeys {
+		if key == reloadable || (strings.HasSuffix(reloadable, ".") && strings.HasPrefix(key, reloadable)) {
+			return true
+		}
+	}
+	return false
+}
+
+// reloader applies the configuration of a running gateway again when it
+// receives SIGHUP or the configuration file changes. The TLS certificates in
+// CertDir are reloaded as well. Changes of settings that can't be applied
+// without restarting are logged.
+type reloader struct {
+	log  *zap.Logger
+	cmd  *cobra.Command
+	peer *server.Peer
+	path string
+
+	config   server.Config
+	settings map[string]string
+	modTime  time.Time
+	size     int64
+}
+
+// newReloader constructs a reloader of the configuration cmd was run with,
+// applying it to peer.
+func newReloader(log *zap.Logger, cmd *cobra.Command, peer *server.Peer, config server.Config) (*reloader, error) {
+	r := &reloader{
+		log:    log,
+		cmd:    cmd,
+		peer:   peer,
+		path:   filepath.Join(os.ExpandEnv(confDir), process.DefaultCfgFilename),
+		config: config,
+	}
+
+	_, settings, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.settings = settings
+	r.modTime, r.size = r.stat()
+
+	return r, nil
+}
+
+// Run reloads the configuration until ctx is canceled.
+func (r *reloader) Run(ctx context.Context) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-signals:
+			r.log.Info("Got SIGHUP, reloading configuration")
+		case <-ticker.C:
+			if modTime, size := r.stat(); modTime.Equal(r.modTime) && size == r.size {
+				continue
+			}
+			r.log.Info("Configuration file changed, reloading configuration", zap.String("Location", r.path))
+		}
+
+		if err := r.reload(); err != nil {
+			r.log.Error("Failed to reload configuration", zap.Error(err))
+		}
+	}
+}
+
+// stat returns the modification time and size of the configuration file,
+// which are zero if it doesn't exist.
+func (r *reloader) stat() (time.Time, int64) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
+
+// load loads the configuration the same way the run command does, returning
+// it and its settings by key.
+func (r *reloader) load() (server.Config, map[string]string, error) {
+	vip := viper.New()
+	if err := vip.BindPFlags(r.cmd.Flags()); err != nil {
+		return server.Config{}, nil, err
+	}
+
+	prefix := os.Getenv("STORJ_ENV_PREFIX")
+	if prefix == "" {
+		prefix = "storj"
+	}
+	vip.SetEnvPrefix(prefix)
+	vip.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	vip.AutomaticEnv()
+
+	if err := process.LoadConfig(r.cmd, vip); err != nil {
+		return server.Config{}, nil, err
+	}
+
+	values := make(map[string]interface{})
+	settings := make(map[string]string)
+	for _, key := range vip.AllKeys() {
+		value := vip.Get(key)
+		// the values of string slice flags are decoded element by element.
+		if list, ok := value.([]string); ok {
+			elements := make([]interface{}, len(list))
+			for i := range list {
+				elements[i] = list[i]
+			}
+			value = elements
+		}
+		values[key] = value
+		settings[key] = fmt.Sprint(value)
+	}
+
+	var config server.Config
+	if result := structs.Decode(values, &config); result.Error != nil {
+		return server.Config{}, nil, Error.Wrap(result.Error)
+	}
+
+	return config, settings, nil
+}
+
+// reload loads the configuration and applies the settings that can be changed
+// without restarting.
+func (r *reloader) reload() error {
+	r.modTime, r.size = r.stat()
+
+	config, settings, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	if err := r.peer.ReloadTLS(); err != nil {
+		return err
+	}
+
+	for key := range keys(r.settings, settings) {
+		if settings[key] == r.settings[key] || isReloadable(key) {
+			continue
+		}
+		r.log.Warn("Configuration change requires a restart to take effect", zap.String("Key", key))
+		// the setting in effect is kept, so it's reported until the
+		// gateway is restarted.
+		settings[key] = r.settings[key]
+	}
+
+	r.peer.SetTrustedIPs(trustedClientIPs(config))
+	r.peer.SetCORSOrigins(strings.Split(config.CorsOrigins, ","))
+	r.peer.SetConcurrentAllowed(config.ConcurrentAllowed)
+	r.peer.SetRateLimits(config.RateLimit)
+	if config.Auth.Cache != r.config.Auth.Cache {
+		r.peer.SetAuthCacheConfig(config.Auth.Cache)
+	}
+
+	r.config.ClientTrustedIPSList = config.ClientTrustedIPSList
+	r.config.UseClientIPHeaders = config.UseClientIPHeaders
+	r.config.CorsOrigins = config.CorsOrigins
+	r.config.ConcurrentAllowed = config.ConcurrentAllowed
+	r.config.RateLimit = config.RateLimit
+	r.config.Auth.Cache = config.Auth.Cache
+	r.settings = settings
+
+	r.log.Info("Configuration reloaded")
+
+	return nil
+}
+
+// keys returns the union of the keys of a and b.
+func keys(a, b map[string]string) map[string]struct{} {
+	union := make(map[string]struct{}, len(a)+len(b))
+	for key := range a {
+		union[key] = struct{}{}
+	}
+	for key := range b {
+		union[key] = struct{}{}
+	}
+	return union
+}
@@ -0,0 +1,77 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/gateway-mt/pkg/server"
+	"storj.io/private/cfgstruct"
+	"storj.io/private/process"
+)
+
+func TestReloader(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	dir := ctx.Dir("config")
+	path := filepath.Join(dir, process.DefaultCfgFilename)
+	confDir = dir
+
+	writeConfig := func(config string) {
+		require.NoError(t, os.WriteFile(path, []byte(config), 0644))
+	}
+	writeConfig("concurrent-allowed: 5\nserver.address: 127.0.0.1:0\n")
+
+	var config server.Config
+	cmd := &cobra.Command{}
+	cmd.Flags().String("config-dir", dir, "")
+	cfgstruct.Bind(cmd.Flags(), &config, cfgstruct.UseReleaseDefaults(), cfgstruct.ConfDir(dir))
+
+	config.Server.Address = "127.0.0.1:0"
+	config.InsecureDisableTLS = true
+	config.ConcurrentAllowed = 5
+
+	peer, err := server.New(ctx, config, zaptest.NewLogger(t), trustedClientIPs(config), []string{"*"}, nil, []string{}, config.ConcurrentAllowed)
+	require.NoError(t, err)
+	defer ctx.Check(peer.Close)
+
+	r, err := newReloader(zaptest.NewLogger(t), cmd, peer, config)
+	require.NoError(t, err)
+	require.Equal(t, "5", r.settings["concurrent-allowed"])
+
+	writeConfig("concurrent-allowed: 7\nserver.address: 127.0.0.1:1\ncors-origins: https://example.com\nclient-trusted-ips-list: [1.2.3.4, 5.6.7.8]\n")
+	require.NoError(t, r.reload())
+
+	require.EqualValues(t, 7, r.config.ConcurrentAllowed)
+	require.Equal(t, "https://example.com", r.config.CorsOrigins)
+	require.Equal(t, []string{"1.2.3.4", "5.6.7.8"}, r.config.ClientTrustedIPSList)
+	// settings that can't be changed live are kept.
+	require.Equal(t, "127.0.0.1:0", r.config.Server.Address)
+	require.Equal(t, "127.0.0.1:0", r.settings["server.address"])
+
+	writeConfig("concurrent-allowed: 7\n")
+	require.NoError(t, r.reload())
+	require.Empty(t, r.config.ClientTrustedIPSList)
+
+	writeConfig("concurrent-allowed: nope\n")
+	require.Error(t, r.reload())
+	require.EqualValues(t, 7, r.config.ConcurrentAllowed)
+}
+
+func TestIsReloadable(t *testing.T) {
+	for _, key := range []string{"cors-origins", "auth.cache.capacity", "rate-limit.ip.requests"} {
+		require.True(t, isReloadable(key), key)
+	}
+	for _, key := range []string{"auth.base-url", "cors-origins-foo", "server.address", "cert-dir"} {
+		require.False(t, isReloadable(key), key)
+	}
+}
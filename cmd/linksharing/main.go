@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -21,6 +22,8 @@ import (
 	"storj.io/gateway-mt/pkg/httpserver"
 	"storj.io/gateway-mt/pkg/linksharing"
 	"storj.io/gateway-mt/pkg/linksharing/sharing"
+	"storj.io/gateway-mt/pkg/prommetrics"
+	"storj.io/gateway-mt/pkg/tracing"
 	"storj.io/private/cfgstruct"
 	"storj.io/private/process"
 	"storj.io/uplink"
@@ -52,6 +55,8 @@ type LinkSharing struct {
 	StandardViewsHTML      bool          `user:"true" help:"serve HTML as text/html instead of text/plain for standard (non-hosting) requests" default:"false"`
 	ConnectionPool         connectionPoolConfig
 	CertMagic              certMagic
+	Prometheus             prommetrics.Config
+	OpenTelemetry          tracing.Config
 }
 
 // connectionPoolConfig is a config struct for configuring RPC connection pool options.
@@ -139,6 +144,24 @@ func cmdRun(cmd *cobra.Command, args []string) (err error) {
 		}
 	}
 
+	var metrics *prommetrics.Server
+	if runCfg.Prometheus.Address != "" {
+		metrics, err = prommetrics.NewServer(log.Named("metrics"), runCfg.Prometheus)
+		if err != nil {
+			return err
+		}
+	}
+
+	tracer, err := tracing.New(ctx, log.Named("tracing"), "linksharing", runCfg.OpenTelemetry)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = errs.Combine(err, tracer.Close(closeCtx))
+	}()
+
 	peer, err := linksharing.New(log, linksharing.Config{
 		Server: httpserver.Config{
 			Name:            "Link Sharing",
@@ -176,6 +199,12 @@ func cmdRun(cmd *cobra.Command, args []string) (err error) {
 
 	var g errgroup.Group
 
+	if metrics != nil {
+		g.Go(func() error {
+			return metrics.Run(ctx)
+		})
+	}
+
 	g.Go(func() error {
 		<-ctx.Done()
 		return errs2.IgnoreCanceled(peer.Close())
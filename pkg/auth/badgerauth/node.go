@@ -14,6 +14,7 @@ import (
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/outcaste-io/badger/v3"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spacemonkeygo/monkit/v3"
 	"github.com/zeebo/errs"
 	"go.uber.org/zap"
@@ -30,6 +31,8 @@ import (
 	"storj.io/gateway-mt/pkg/auth/authdb"
 	"storj.io/gateway-mt/pkg/auth/badgerauth/pb"
 	"storj.io/gateway-mt/pkg/backoff"
+	"storj.io/gateway-mt/pkg/prommetrics"
+	"storj.io/gateway-mt/pkg/tracing"
 )
 
 var (
@@ -40,8 +43,23 @@ var (
 
 	// DialError is an error class for dial failures.
 	DialError = errs.Class("dial")
+
+	// replicationLastSuccess and replicatedRecords are labeled by peer
+	// address, which are limited to the join list.
+	replicationLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "badgerauth_replication_last_success_timestamp_seconds",
+		Help: "Time records were last replicated from a peer; replication lag is the time since.",
+	}, []string{"peer"})
+	replicatedRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "badgerauth_replicated_records_total",
+		Help: "Number of records replicated from a peer.",
+	}, []string{"peer"})
 )
 
+func init() {
+	prommetrics.Registry.MustRegister(replicationLastSuccess, replicatedRecords)
+}
+
 // Config provides options for creating a Node.
 //
 // Keep this in sync with badgerauthtest.setConfigDefaults.
@@ -176,7 +194,7 @@ func New(log *zap.Logger, config Config) (_ *Node, err error) {
 		Manager: rpc.NewDefaultManagerOptions(),
 		Log:     func(err error) { log.Named("network").Debug(err.Error()) },
 	}
-	node.server = drpcserver.NewWithOptions(node.mux, serverOptions)
+	node.server = drpcserver.NewWithOptions(tracing.DRPCHandler(node.mux), serverOptions)
 
 	tcpListener, err := net.Listen("tcp", config.Address)
 	if err != nil {
@@ -347,7 +365,11 @@ func (node *Node) Run(ctx context.Context) error {
 }
 
 // syncAll tries to synchronize all nodes.
-func (node *Node) syncAll(ctx context.Context) error {
+func (node *Node) syncAll(ctx context.Context) (err error) {
+	// each round of replication is a trace of its own.
+	ctx, span := tracing.Start(ctx, "badgerauth.syncAll")
+	defer func() { tracing.End(span, err) }()
+
 	for _, peer := range node.peers {
 		if err := IgnoreDialFailures(peer.Sync(ctx)); err != nil {
 			return Error.Wrap(err)
@@ -601,6 +623,9 @@ func (peer *Peer) syncRecords(ctx context.Context, client pb.DRPCReplicationServ
 
 	peer.log.Debug("inserted new records from this peer", zap.Int("count", len(response.Entries)))
 
+	replicationLastSuccess.WithLabelValues(peer.address).SetToCurrentTime()
+	replicatedRecords.WithLabelValues(peer.address).Add(float64(len(response.Entries)))
+
 	return nil
 }
 
@@ -627,7 +652,7 @@ func (peer *Peer) withClient(ctx context.Context, fn func(ctx context.Context, c
 	// closes the handle to the underlying resource.
 	defer func() { _ = conn.Close() }()
 
-	return fn(ctx, pb.NewDRPCReplicationServiceClient(conn))
+	return fn(tracing.WithDRPCMetadata(ctx), pb.NewDRPCReplicationServiceClient(conn))
 }
 
 // statusUp changes peer status to up.
@@ -28,6 +28,8 @@ import (
 	"storj.io/gateway-mt/pkg/auth/httpauth"
 	"storj.io/gateway-mt/pkg/auth/satellitelist"
 	"storj.io/gateway-mt/pkg/middleware"
+	"storj.io/gateway-mt/pkg/prommetrics"
+	"storj.io/gateway-mt/pkg/tracing"
 	"storj.io/gateway-mt/pkg/trustedip"
 )
 
@@ -59,6 +61,9 @@ type Config struct {
 	CertMagic certMagic
 
 	Node badgerauth.Config
+
+	Prometheus    prommetrics.Config
+	OpenTelemetry tracing.Config
 }
 
 // certMagic is a config struct for configuring CertMagic options.
@@ -152,6 +157,7 @@ func New(ctx context.Context, log *zap.Logger, config Config, configDir string)
 
 	// logging. do not log paths - paths have access keys in them.
 	handler = middleware.AddRequestID(LogResponses(log, LogRequests(log, handler)))
+	handler = prommetrics.InstrumentHandler("as", tracing.Handler("as", handler))
 
 	drpcServer := drpcauth.NewServer(log, adb, endpoint, config.POSTSizeLimit)
 
@@ -11,8 +11,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 
 	"storj.io/common/lrucache"
 	"storj.io/common/testcontext"
@@ -28,8 +30,10 @@ func TestAuthClient_Resolve(t *testing.T) {
 		accessGrant = "thisIsTheAccessGrant" + strconv.Itoa(testrand.Intn(1e6))
 	)
 
+	var traceparent string
 	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		checkRequestMeta(t, r, accessKeyID, token, clientIP)
+		traceparent = r.Header.Get("traceparent")
 
 		_, err := w.Write([]byte(`{"public":true,"access_grant":"` + accessGrant + `"}`))
 		require.NoError(t, err, "response writer error")
@@ -58,6 +62,16 @@ func TestAuthClient_Resolve(t *testing.T) {
 		require.Equal(t, accessGrant, resp.AccessGrant, "response: access_grant")
 		require.True(t, resp.Public, "response: public")
 	})
+	t.Run("Resolve continues the trace of the request", func(t *testing.T) {
+		spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1},
+			SpanID:     trace.SpanID{2},
+			TraceFlags: trace.FlagsSampled,
+		})
+		_, err := svc.Resolve(trace.ContextWithRemoteSpanContext(ctx, spanContext), accessKeyID, clientIP)
+		require.NoError(t, err)
+		require.Equal(t, "00-01000000000000000000000000000000-0200000000000000-01", traceparent)
+	})
 }
 
 func TestAuthClient_ResolveWithCache(t *testing.T) {
@@ -94,12 +108,18 @@ func TestAuthClient_ResolveWithCache(t *testing.T) {
 		ctx := testcontext.New(t)
 		defer ctx.Cleanup()
 
+		hits := testutil.ToFloat64(cacheLookups.WithLabelValues("hit"))
+		misses := testutil.ToFloat64(cacheLookups.WithLabelValues("miss"))
+
 		for i := 0; i < 10; i++ {
 			resp, err := service.ResolveWithCache(ctx, accessKeyID, clientIP)
 			require.NoError(t, err)
 			assert.Equal(t, accessGrant, resp.AccessGrant)
 			assert.Equal(t, true, resp.Public)
 		}
+
+		assert.Equal(t, hits+9, testutil.ToFloat64(cacheLookups.WithLabelValues("hit")))
+		assert.Equal(t, misses+1, testutil.ToFloat64(cacheLookups.WithLabelValues("miss")))
 	})
 
 	t.Run("Not Found", func(t *testing.T) {
@@ -4,40 +4,82 @@
 package authclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
 	"net/url"
 	"path"
+	"sync"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spacemonkeygo/monkit/v3"
 	"github.com/zeebo/errs"
 
 	"storj.io/common/lrucache"
 	"storj.io/gateway-mt/pkg/errdata"
 	"storj.io/gateway-mt/pkg/middleware"
+	"storj.io/gateway-mt/pkg/prommetrics"
+	"storj.io/gateway-mt/pkg/tracing"
 )
 
-var mon = monkit.Package()
+var (
+	mon = monkit.Package()
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "authclient_cache_lookups_total",
+		Help: "Number of auth service responses looked up in the cache, by whether they were cached.",
+	}, []string{"result"})
+)
+
+func init() {
+	prommetrics.Registry.MustRegister(cacheLookups)
+}
 
 // AuthClient communicates with the Auth Service.
 type AuthClient struct {
 	Config
-	// Cache is used for caching authservice's responses.
+	// Cache is used for caching authservice's responses. It must not be
+	// changed directly once the client is in use (see SetCacheConfig).
 	Cache *lrucache.ExpiringLRU
+
+	mu sync.RWMutex
 }
 
 // New returns a new auth client.
 func New(config Config) *AuthClient {
 	return &AuthClient{
 		Config: config,
-		Cache: lrucache.New(lrucache.Options{
-			Expiration: config.Cache.Expiration,
-			Capacity:   config.Cache.Capacity,
-		}),
+		Cache:  newCache(config.Cache),
 	}
 }
 
+func newCache(config AuthServiceCacheConfig) *lrucache.ExpiringLRU {
+	return lrucache.New(lrucache.Options{
+		Expiration: config.Expiration,
+		Capacity:   config.Capacity,
+	})
+}
+
+// SetCacheConfig replaces the cache with an empty one configured by config.
+// Responses cached so far are dropped.
+func (a *AuthClient) SetCacheConfig(config AuthServiceCacheConfig) {
+	cache := newCache(config)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.Config.Cache = config
+	a.Cache = cache
+}
+
+// cache returns the cache in use.
+func (a *AuthClient) cache() *lrucache.ExpiringLRU {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.Cache
+}
+
 // Resolve maps an access key into an auth service response. clientIP is the IP
 // of the client that originated the request and it's required to be sent to the
 // Auth Service.
@@ -61,6 +103,7 @@ func (a *AuthClient) Resolve(ctx context.Context, accessKeyID string, clientIP s
 	req.Header.Set("Authorization", "Bearer "+a.Token)
 	req.Header.Set("Forwarded", "for="+clientIP)
 	middleware.AddRequestIDToHeaders(req)
+	tracing.InjectHTTP(req)
 
 	delay := a.BackOff
 	client := http.Client{
@@ -120,11 +163,16 @@ func (a *AuthClient) Resolve(ctx context.Context, accessKeyID string, clientIP s
 func (a *AuthClient) ResolveWithCache(ctx context.Context, accessKeyID string, clientIP string) (_ AuthServiceResponse, err error) {
 	defer mon.Task()(&ctx)(&err)
 
-	if a.Cache == nil {
+	cache := a.cache()
+	if cache == nil {
 		return a.Resolve(ctx, accessKeyID, clientIP)
 	}
 
-	v, err := a.Cache.Get(accessKeyID, func() (interface{}, error) {
+	result := "hit"
+	defer func() { cacheLookups.WithLabelValues(result).Inc() }()
+
+	v, err := cache.Get(accessKeyID, func() (interface{}, error) {
+		result = "miss"
 		response, err := a.Resolve(ctx, accessKeyID, clientIP)
 
 		switch errdata.GetStatus(err, http.StatusOK) {
@@ -152,6 +200,68 @@ func (a *AuthClient) ResolveWithCache(ctx context.Context, accessKeyID string, c
 	return decResp, response.err
 }
 
+// Register registers accessGrant with the auth service and returns the access
+// key ID of the new access. A public access can be resolved by anyone knowing
+// its access key ID, e.g. to serve it through linksharing.
+//
+// Unlike Resolve, it isn't retried, since every attempt registers a new
+// access.
+func (a *AuthClient) Register(ctx context.Context, accessGrant string, public bool) (_ string, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	reqURL, err := url.Parse(a.BaseURL)
+	if err != nil {
+		return "", errdata.WithStatus(AuthServiceError.Wrap(err), http.StatusInternalServerError)
+	}
+	reqURL.Path = path.Join(reqURL.Path, "/v1/access")
+
+	body, err := json.Marshal(struct {
+		AccessGrant string `json:"access_grant"`
+		Public      bool   `json:"public"`
+	}{
+		AccessGrant: accessGrant,
+		Public:      public,
+	})
+	if err != nil {
+		return "", errdata.WithStatus(AuthServiceError.Wrap(err), http.StatusInternalServerError)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", errdata.WithStatus(AuthServiceError.Wrap(err), http.StatusInternalServerError)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	middleware.AddRequestIDToHeaders(req)
+	tracing.InjectHTTP(req)
+
+	client := http.Client{
+		Timeout:   a.Timeout,
+		Transport: &http.Transport{ResponseHeaderTimeout: a.Timeout},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errdata.WithStatus(AuthServiceError.Wrap(err), http.StatusInternalServerError)
+	}
+	defer func() { err = errs.Combine(err, AuthServiceError.Wrap(resp.Body.Close())) }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errdata.WithStatus(AuthServiceError.New("%s", resp.Status), resp.StatusCode)
+	}
+
+	var registered struct {
+		AccessKeyID string `json:"access_key_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&registered); err != nil {
+		return "", errdata.WithStatus(AuthServiceError.Wrap(err), http.StatusInternalServerError)
+	}
+	if registered.AccessKeyID == "" {
+		return "", errdata.WithStatus(AuthServiceError.New("no access key ID in response"), http.StatusInternalServerError)
+	}
+
+	return registered.AccessKeyID, nil
+}
+
 // GetHealthLive returns the auth service health live status.
 func (a *AuthClient) GetHealthLive(ctx context.Context) (_ bool, err error) {
 	defer mon.Task()(&ctx)(&err)
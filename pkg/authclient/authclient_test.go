@@ -5,6 +5,7 @@ package authclient
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -103,6 +104,41 @@ func TestLoadUserNotFound(t *testing.T) {
 	require.Equal(t, http.StatusUnauthorized, errdata.GetStatus(err, http.StatusOK))
 }
 
+func TestRegister(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/v1/access", r.URL.Path)
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		var request struct {
+			AccessGrant string `json:"access_grant"`
+			Public      bool   `json:"public"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		if request.AccessGrant == "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		require.Equal(t, "myaccessgrant", request.AccessGrant)
+		require.True(t, request.Public)
+
+		_, err := w.Write([]byte(`{"access_key_id":"myaccesskeyid", "secret_key":"mysecretkey", "endpoint":""}`))
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	client, err := GetTestAuthClient(t, ts.URL, "token", 2*time.Second)
+	require.NoError(t, err)
+
+	accessKeyID, err := client.Register(context.Background(), "myaccessgrant", true)
+	require.NoError(t, err)
+	require.Equal(t, "myaccesskeyid", accessKeyID)
+
+	_, err = client.Register(context.Background(), "", true)
+	require.Error(t, err)
+	require.Equal(t, http.StatusUnprocessableEntity, errdata.GetStatus(err, http.StatusOK))
+}
+
 func GetTestAuthClient(t *testing.T, baseURL, token string, timeout time.Duration) (*AuthClient, error) {
 	return New(Config{BaseURL: baseURL, Token: token, Timeout: timeout}), nil
 }
@@ -0,0 +1,80 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package bucketconfig stores configuration documents of buckets (policies,
+// CORS, lifecycle, etc.), which the network has no place for yet, in a
+// database owned by the gateway.
+package bucketconfig
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/zeebo/errs"
+)
+
+var (
+	mon = monkit.Package()
+
+	// Error is a class of bucket configuration database errors.
+	Error = errs.Class("bucket config db")
+)
+
+// Config configures the bucket configuration database.
+type Config struct {
+	DB string `help:"bucket configuration database url (memory://, postgres://... or cockroach://...); all instances of a deployment have to share it, as memory:// only serves a single instance until it restarts" default:"memory://"`
+
+	MaxOpenConns    int           `help:"maximum number of open connections to the bucket configuration database (0 means no limit)" default:"5"`
+	MaxIdleConns    int           `help:"maximum number of idle connections to the bucket configuration database" default:"1"`
+	ConnMaxLifetime time.Duration `help:"maximum lifetime of a connection to the bucket configuration database (0 means no limit)" default:"30m0s"`
+}
+
+// Bucket identifies a bucket of a project.
+type Bucket struct {
+	// Project identifies the project of the bucket, e.g. by its satellite and
+	// the API key it's accessed with.
+	Project string
+	Name    string
+}
+
+// DB stores configuration documents of buckets by their name.
+type DB interface {
+	// Get returns the document name of bucket or nil if it isn't set.
+	Get(ctx context.Context, bucket Bucket, name string) ([]byte, error)
+
+	// Put sets the document name of bucket to data.
+	Put(ctx context.Context, bucket Bucket, name string, data []byte) error
+
+	// Delete removes the document name of bucket. Removing a document that
+	// isn't set isn't an error.
+	Delete(ctx context.Context, bucket Bucket, name string) error
+
+	// DeleteAll removes all documents of bucket and returns their names.
+	DeleteAll(ctx context.Context, bucket Bucket) (names []string, err error)
+
+	// Close closes the database.
+	Close() error
+}
+
+// Open opens the database config.DB points to.
+func Open(ctx context.Context, config Config) (_ DB, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	scheme, rest, ok := strings.Cut(config.DB, "://")
+	if !ok {
+		return nil, Error.New("invalid url: %q", config.DB)
+	}
+
+	switch strings.ToLower(scheme) {
+	case "memory":
+		return NewMemory(), nil
+	case "postgres", "postgresql":
+		return openSQL(ctx, config.DB, config)
+	case "cockroach":
+		// cockroach speaks the protocol of postgres.
+		return openSQL(ctx, "postgres://"+rest, config)
+	}
+	return nil, Error.New("unknown scheme: %q", config.DB)
+}
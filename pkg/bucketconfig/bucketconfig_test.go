@@ -0,0 +1,96 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package bucketconfig_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/testcontext"
+	"storj.io/gateway-mt/pkg/bucketconfig"
+	"storj.io/private/dbutil/pgtest"
+	"storj.io/private/dbutil/tempdb"
+)
+
+func TestOpen(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	for _, url := range []string{"", "memory", "ftp://host/db"} {
+		_, err := bucketconfig.Open(ctx, bucketconfig.Config{DB: url})
+		require.Error(t, err, url)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	db, err := bucketconfig.Open(ctx, bucketconfig.Config{DB: "memory://"})
+	require.NoError(t, err)
+	defer ctx.Check(db.Close)
+
+	testDB(ctx, t, db)
+}
+
+func TestSQL(t *testing.T) {
+	pgtest.Run(t, func(ctx *testcontext.Context, t *testing.T, connstr string) {
+		tempDB, err := tempdb.OpenUnique(ctx, connstr, "bucketconfig")
+		require.NoError(t, err)
+		defer ctx.Check(tempDB.Close)
+
+		db, err := bucketconfig.Open(ctx, bucketconfig.Config{DB: tempDB.ConnStr})
+		require.NoError(t, err)
+		defer ctx.Check(db.Close)
+
+		testDB(ctx, t, db)
+	})
+}
+
+func testDB(ctx *testcontext.Context, t *testing.T, db bucketconfig.DB) {
+	bucket := bucketconfig.Bucket{Project: "project", Name: "bucket"}
+	other := bucketconfig.Bucket{Project: "other", Name: "bucket"}
+
+	data, err := db.Get(ctx, bucket, "policy.json")
+	require.NoError(t, err)
+	require.Nil(t, data)
+
+	require.NoError(t, db.Put(ctx, bucket, "policy.json", []byte("policy")))
+	require.NoError(t, db.Put(ctx, bucket, "cors.xml", []byte("cors")))
+	require.NoError(t, db.Put(ctx, other, "policy.json", []byte("other policy")))
+
+	data, err = db.Get(ctx, bucket, "policy.json")
+	require.NoError(t, err)
+	require.Equal(t, []byte("policy"), data)
+
+	// documents are replaced.
+	require.NoError(t, db.Put(ctx, bucket, "policy.json", []byte("new policy")))
+
+	data, err = db.Get(ctx, bucket, "policy.json")
+	require.NoError(t, err)
+	require.Equal(t, []byte("new policy"), data)
+
+	require.NoError(t, db.Delete(ctx, bucket, "cors.xml"))
+	require.NoError(t, db.Delete(ctx, bucket, "cors.xml"))
+
+	data, err = db.Get(ctx, bucket, "cors.xml")
+	require.NoError(t, err)
+	require.Nil(t, data)
+
+	require.NoError(t, db.Put(ctx, bucket, "tagging.xml", []byte("tagging")))
+
+	names, err := db.DeleteAll(ctx, bucket)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"policy.json", "tagging.xml"}, names)
+
+	data, err = db.Get(ctx, bucket, "policy.json")
+	require.NoError(t, err)
+	require.Nil(t, data)
+
+	// documents of buckets of other projects are kept.
+	data, err = db.Get(ctx, other, "policy.json")
+	require.NoError(t, err)
+	require.Equal(t, []byte("other policy"), data)
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package bucketconfig
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Memory is a DB backed by an in memory map.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[Bucket]map[string][]byte
+}
+
+var _ DB = (*Memory)(nil)
+
+// NewMemory constructs a Memory.
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[Bucket]map[string][]byte),
+	}
+}
+
+// Get implements DB.
+func (m *Memory) Get(ctx context.Context, bucket Bucket, name string) (_ []byte, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.buckets[bucket][name], nil
+}
+
+// Put implements DB.
+func (m *Memory) Put(ctx context.Context, bucket Bucket, name string, data []byte) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	documents, ok := m.buckets[bucket]
+	if !ok {
+		documents = make(map[string][]byte)
+		m.buckets[bucket] = documents
+	}
+	documents[name] = append([]byte(nil), data...)
+	return nil
+}
+
+// Delete implements DB.
+func (m *Memory) Delete(ctx context.Context, bucket Bucket, name string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.buckets[bucket], name)
+	if len(m.buckets[bucket]) == 0 {
+		delete(m.buckets, bucket)
+	}
+	return nil
+}
+
+// DeleteAll implements DB.
+func (m *Memory) DeleteAll(ctx context.Context, bucket Bucket) (names []string, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name := range m.buckets[bucket] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	delete(m.buckets, bucket)
+	return names, nil
+}
+
+// Close implements DB.
+func (m *Memory) Close() error {
+	return nil
+}
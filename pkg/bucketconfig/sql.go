@@ -0,0 +1,114 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package bucketconfig
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	_ "github.com/jackc/pgx/v4/stdlib" // registers the pgx driver.
+	"github.com/zeebo/errs"
+)
+
+// createTable creates the table of the documents.
+const createTable = `CREATE TABLE IF NOT EXISTS bucket_configs (
+	project TEXT NOT NULL,
+	bucket TEXT NOT NULL,
+	name TEXT NOT NULL,
+	data BYTEA NOT NULL,
+	PRIMARY KEY (project, bucket, name)
+)`
+
+// sqlDB is a DB backed by a postgres or cockroach database.
+type sqlDB struct {
+	db *sql.DB
+}
+
+var _ DB = (*sqlDB)(nil)
+
+// openSQL opens the postgres (or cockroach) database with url, limiting its
+// connections as config says, and creates the table of the documents if it
+// doesn't exist.
+func openSQL(ctx context.Context, url string, config Config) (_ *sqlDB, err error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+	if _, err = db.ExecContext(ctx, createTable); err != nil {
+		return nil, Error.Wrap(errs.Combine(err, db.Close()))
+	}
+
+	return &sqlDB{db: db}, nil
+}
+
+// Get implements DB.
+func (s *sqlDB) Get(ctx context.Context, bucket Bucket, name string) (data []byte, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	err = s.db.QueryRowContext(ctx, `
+		SELECT data FROM bucket_configs
+		WHERE project = $1 AND bucket = $2 AND name = $3
+	`, bucket.Project, bucket.Name, name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return data, Error.Wrap(err)
+}
+
+// Put implements DB.
+func (s *sqlDB) Put(ctx context.Context, bucket Bucket, name string, data []byte) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO bucket_configs (project, bucket, name, data) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (project, bucket, name) DO UPDATE SET data = excluded.data
+	`, bucket.Project, bucket.Name, name, data)
+	return Error.Wrap(err)
+}
+
+// Delete implements DB.
+func (s *sqlDB) Delete(ctx context.Context, bucket Bucket, name string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	_, err = s.db.ExecContext(ctx, `
+		DELETE FROM bucket_configs
+		WHERE project = $1 AND bucket = $2 AND name = $3
+	`, bucket.Project, bucket.Name, name)
+	return Error.Wrap(err)
+}
+
+// DeleteAll implements DB.
+func (s *sqlDB) DeleteAll(ctx context.Context, bucket Bucket) (names []string, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	rows, err := s.db.QueryContext(ctx, `
+		DELETE FROM bucket_configs
+		WHERE project = $1 AND bucket = $2
+		RETURNING name
+	`, bucket.Project, bucket.Name)
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+	defer func() { err = errs.Combine(err, Error.Wrap(rows.Close())) }()
+
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, Error.Wrap(err)
+		}
+		names = append(names, name)
+	}
+	return names, Error.Wrap(rows.Err())
+}
+
+// Close implements DB.
+func (s *sqlDB) Close() error {
+	return Error.Wrap(s.db.Close())
+}
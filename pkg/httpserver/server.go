@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/caddyserver/certmagic"
@@ -126,6 +127,11 @@ type Server struct {
 	server          *http.Server
 	serverTLS       *http.Server
 	shutdownTimeout time.Duration
+
+	// tlsOptions is the TLS configuration certificates are reloaded with if
+	// they're loaded from files.
+	tlsOptions   *TLSConfig
+	certificates atomic.Value // []tls.Certificate
 }
 
 // CertMagicOnDemandDecisionFunc is a concrete type for
@@ -184,7 +190,7 @@ func New(log *zap.Logger, handler http.Handler, decisionFunc CertMagicOnDemandDe
 		log = log.With(zap.String("server", config.Name))
 	}
 
-	return &Server{
+	s := &Server{
 		log:             log,
 		name:            config.Name,
 		listener:        listener,
@@ -193,7 +199,50 @@ func New(log *zap.Logger, handler http.Handler, decisionFunc CertMagicOnDemandDe
 		serverTLS:       serverTLS,
 		shutdownTimeout: config.ShutdownTimeout,
 		handler:         handler,
-	}, nil
+	}
+
+	// certificates loaded from files are served through GetCertificate so
+	// ReloadTLS can replace them while the server runs.
+	if tlsConfig != nil && !config.TLSConfig.CertMagic {
+		s.tlsOptions = config.TLSConfig
+		s.certificates.Store(tlsConfig.Certificates)
+		tlsConfig.Certificates = nil
+		tlsConfig.GetCertificate = s.getCertificate
+	}
+
+	return s, nil
+}
+
+// ReloadTLS reloads the certificates of the server from the files they were
+// loaded from. Connections accepted afterwards use the new certificates.
+// Certificates obtained through CertMagic are renewed by it and aren't
+// reloaded.
+func (server *Server) ReloadTLS() error {
+	if server.tlsOptions == nil {
+		return nil
+	}
+
+	certificates, _, err := loadCertificates(server.tlsOptions)
+	if err != nil {
+		return err
+	}
+	server.certificates.Store(certificates)
+	return nil
+}
+
+// getCertificate returns the certificate of those loaded that supports
+// hello, the same way crypto/tls picks from tls.Config.Certificates.
+func (server *Server) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certificates := server.certificates.Load().([]tls.Certificate)
+	if len(certificates) == 0 {
+		return nil, errs.New("no certificates configured")
+	}
+	for i := range certificates {
+		if hello.SupportsCertificate(&certificates[i]) == nil {
+			return &certificates[i], nil
+		}
+	}
+	return &certificates[0], nil
 }
 
 // Run runs the server.
@@ -252,6 +301,14 @@ func (server *Server) Shutdown() (err error) {
 	return group.Wait()
 }
 
+// SetKeepAlivesEnabled controls whether connections are kept alive between
+// requests. Disabling them closes idle connections and those in use once
+// their requests are served.
+func (server *Server) SetKeepAlivesEnabled(v bool) {
+	server.server.SetKeepAlivesEnabled(v)
+	server.serverTLS.SetKeepAlivesEnabled(v)
+}
+
 // Addr returns the public address.
 func (server *Server) Addr() string {
 	return server.listener.Addr().String()
@@ -284,34 +341,46 @@ func configureTLS(log *zap.Logger, handler http.Handler, decisionFunc CertMagicO
 		return configureCertMagic(log, handler, decisionFunc, config)
 	}
 
+	certs, ok, err := loadCertificates(config.TLSConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, handler, nil
+	}
+
 	tlsConfig := BaseTLSConfig()
+	tlsConfig.Certificates = certs
+	return tlsConfig, handler, nil
+}
 
-	if config.TLSConfig.CertDir != "" {
-		certs, err := loadCertsFromDir(config.TLSConfig.CertDir)
+// loadCertificates loads the certificates from the files config refers to. It
+// returns false if config doesn't refer to any.
+func loadCertificates(config *TLSConfig) ([]tls.Certificate, bool, error) {
+	if config.CertDir != "" {
+		certs, err := loadCertsFromDir(config.CertDir)
 		if err != nil {
-			return nil, nil, err
+			return nil, false, err
 		}
-		tlsConfig.Certificates = certs
-		return tlsConfig, handler, nil
+		return certs, true, nil
 	}
 
 	switch {
-	case config.TLSConfig.CertFile != "" && config.TLSConfig.KeyFile != "":
-	case config.TLSConfig.CertFile == "" && config.TLSConfig.KeyFile == "":
-		return nil, handler, nil
-	case config.TLSConfig.CertFile != "" && config.TLSConfig.KeyFile == "":
-		return nil, nil, errs.New("key file must be provided with cert file")
-	case config.TLSConfig.CertFile == "" && config.TLSConfig.KeyFile != "":
-		return nil, nil, errs.New("cert file must be provided with key file")
+	case config.CertFile != "" && config.KeyFile != "":
+	case config.CertFile == "" && config.KeyFile == "":
+		return nil, false, nil
+	case config.CertFile != "" && config.KeyFile == "":
+		return nil, false, errs.New("key file must be provided with cert file")
+	case config.CertFile == "" && config.KeyFile != "":
+		return nil, false, errs.New("cert file must be provided with key file")
 	}
 
-	cert, err := tls.LoadX509KeyPair(config.TLSConfig.CertFile, config.TLSConfig.KeyFile)
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
 	if err != nil {
-		return nil, nil, errs.New("unable to load server keypair: %v", err)
+		return nil, false, errs.New("unable to load server keypair: %v", err)
 	}
 
-	tlsConfig.Certificates = []tls.Certificate{cert}
-	return tlsConfig, handler, nil
+	return []tls.Certificate{cert}, true, nil
 }
 
 func loadCertsFromDir(configDir string) ([]tls.Certificate, error) {
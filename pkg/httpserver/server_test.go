@@ -140,6 +140,49 @@ func TestServer(t *testing.T) {
 	}
 }
 
+func TestServerReloadTLS(t *testing.T) {
+	ctx := testcontext.NewWithTimeout(t, time.Minute)
+	defer ctx.Cleanup()
+
+	certDir := ctx.Dir("certs")
+	writeCert := func(cert *x509.Certificate) {
+		require.NoError(t, os.WriteFile(filepath.Join(certDir, "localhost.key"), []byte(testKey), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(certDir, "localhost.crt"), pkcrypto.CertToPEM(cert), 0644))
+	}
+	writeCert(mustCreateLocalhostCertWithSerial(1))
+
+	s, err := New(zaptest.NewLogger(t), http.NotFoundHandler(), nil, Config{
+		Address:    "127.0.0.1:0",
+		AddressTLS: "127.0.0.1:0",
+		TLSConfig:  &TLSConfig{CertDir: certDir},
+	})
+	require.NoError(t, err)
+	defer ctx.Check(s.Shutdown)
+
+	ctx.Go(func() error {
+		return s.Run(ctx)
+	})
+
+	servedSerial := func() int64 {
+		conn, err := tls.Dial("tcp", s.AddrTLS(), &tls.Config{InsecureSkipVerify: true}) //nolint: gosec // only the certificate served is inspected.
+		require.NoError(t, err)
+		defer func() { require.NoError(t, conn.Close()) }()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+	require.EqualValues(t, 1, servedSerial())
+
+	writeCert(mustCreateLocalhostCertWithSerial(2))
+	require.EqualValues(t, 1, servedSerial())
+
+	require.NoError(t, s.ReloadTLS())
+	require.EqualValues(t, 2, servedSerial())
+
+	// certificates that fail to load are not served.
+	require.NoError(t, os.Remove(filepath.Join(certDir, "localhost.key")))
+	require.Error(t, s.ReloadTLS())
+	require.EqualValues(t, 2, servedSerial())
+}
+
 type serverTestCase struct {
 	Mapper        *objectmap.IPDB
 	HandlerConfig sharing.Config
@@ -203,9 +246,13 @@ func mustSignerFromPEM(keyBytes string) crypto.Signer {
 }
 
 func mustCreateLocalhostCert() *x509.Certificate {
+	return mustCreateLocalhostCertWithSerial(0)
+}
+
+func mustCreateLocalhostCertWithSerial(serial int64) *x509.Certificate {
 	privateKey := mustSignerFromPEM(testKey)
 	tmpl := &x509.Certificate{
-		SerialNumber: big.NewInt(0),
+		SerialNumber: big.NewInt(serial),
 		NotBefore:    time.Now().Add(-time.Hour),
 		NotAfter:     time.Now().Add(time.Hour),
 		DNSNames:     []string{"localhost"},
@@ -18,6 +18,7 @@ import (
 	"storj.io/gateway-mt/pkg/linksharing/objectmap"
 	"storj.io/gateway-mt/pkg/linksharing/sharing"
 	"storj.io/gateway-mt/pkg/server/middleware"
+	"storj.io/gateway-mt/pkg/tracing"
 )
 
 var mon = monkit.Package()
@@ -71,7 +72,7 @@ func New(log *zap.Logger, config Config) (_ *Peer, err error) {
 		return nil, errs.New("unable to create handler: %w", err)
 	}
 
-	handleWithTracing := http.TraceHandler(handle, mon)
+	handleWithTracing := http.TraceHandler(tracing.Handler("linksharing", handle), mon)
 	instrumentedHandle := middleware.Metrics("linksharing", handleWithTracing)
 
 	var decisionFunc httpserver.CertMagicOnDemandDecisionFunc
@@ -4,23 +4,56 @@
 package minio
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
-	"strings"
+	"net/url"
 
+	"github.com/gorilla/mux"
+	"github.com/minio/minio-go/v7/pkg/tags"
+
+	"storj.io/common/memory"
+	"storj.io/gateway-mt/pkg/server/gw"
+	"storj.io/gateway-mt/pkg/server/middleware"
 	"storj.io/minio/cmd"
+	"storj.io/minio/cmd/logger"
+	"storj.io/minio/pkg/bucket/policy"
+	"storj.io/minio/pkg/bucket/versioning"
+	"storj.io/minio/pkg/event"
+)
+
+// maxBucketConfigSize is the maximum size of a bucket configuration document
+// (versioning, lifecycle, etc.) accepted in a request body.
+const maxBucketConfigSize = int64(1 * memory.MiB)
+
+// maxBucketPolicySize is the maximum size of a bucket policy, as in S3.
+const maxBucketPolicySize = int64(20 * memory.KiB)
+
+// minio's policy package has no actions for bucket CORS configuration.
+const (
+	getBucketCorsAction policy.Action = "s3:GetBucketCORS"
+	putBucketCorsAction policy.Action = "s3:PutBucketCORS"
+)
+
+// Nor does it have any for bucket website configuration.
+const (
+	getBucketWebsiteAction    policy.Action = "s3:GetBucketWebsite"
+	putBucketWebsiteAction    policy.Action = "s3:PutBucketWebsite"
+	deleteBucketWebsiteAction policy.Action = "s3:DeleteBucketWebsite"
 )
 
 // objectAPIHandlersWrapper should be used to extend cmd.ObjectAPIHandlers.
 type objectAPIHandlersWrapper struct {
 	core               cmd.ObjectAPIHandlers
-	corsAllowedOrigins []string
+	layer              *gw.MultiTenancyLayer
+	corsAllowedOrigins *CORSOrigins
 }
 
 func (h objectAPIHandlersWrapper) HeadObjectHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	defer mon.Task()(&ctx)(nil)
-	h.core.HeadObjectHandler(w, r)
+	h.core.HeadObjectHandler(w, r.WithContext(gw.WithChecksumMode(ctx, r.Header)))
 }
 
 func (h objectAPIHandlersWrapper) CopyObjectPartHandler(w http.ResponseWriter, r *http.Request) {
@@ -32,6 +65,14 @@ func (h objectAPIHandlersWrapper) CopyObjectPartHandler(w http.ResponseWriter, r
 func (h objectAPIHandlersWrapper) PutObjectPartHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	defer mon.Task()(&ctx)(nil)
+
+	r, checksum, err := verifyChecksum(r.WithContext(ctx))
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+	w = withChecksumHeader(w, checksum)
+
 	h.core.PutObjectPartHandler(w, r)
 }
 
@@ -44,6 +85,18 @@ func (h objectAPIHandlersWrapper) ListObjectPartsHandler(w http.ResponseWriter,
 func (h objectAPIHandlersWrapper) CompleteMultipartUploadHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	defer mon.Task()(&ctx)(nil)
+
+	if err := gw.CheckWritePreconditions(r.Header); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	r, err := compositeChecksum(r)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
 	h.core.CompleteMultipartUploadHandler(w, r)
 }
 
@@ -96,21 +149,27 @@ func (h objectAPIHandlersWrapper) SelectObjectContentHandler(w http.ResponseWrit
 }
 
 func (h objectAPIHandlersWrapper) GetObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "GetObjectRetention")
 	defer mon.Task()(&ctx)(nil)
-	h.core.GetObjectRetentionHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, gw.ErrObjectLockNotSupported), r.URL, false)
 }
 
 func (h objectAPIHandlersWrapper) GetObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "GetObjectLegalHold")
 	defer mon.Task()(&ctx)(nil)
-	h.core.GetObjectLegalHoldHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, gw.ErrObjectLockNotSupported), r.URL, false)
 }
 
 func (h objectAPIHandlersWrapper) GetObjectHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	defer mon.Task()(&ctx)(nil)
-	h.core.GetObjectHandler(w, r)
+	h.core.GetObjectHandler(w, r.WithContext(gw.WithChecksumMode(ctx, r.Header)))
 }
 
 func (h objectAPIHandlersWrapper) CopyObjectHandler(w http.ResponseWriter, r *http.Request) {
@@ -120,20 +179,39 @@ func (h objectAPIHandlersWrapper) CopyObjectHandler(w http.ResponseWriter, r *ht
 }
 
 func (h objectAPIHandlersWrapper) PutObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "PutObjectRetention")
 	defer mon.Task()(&ctx)(nil)
-	h.core.PutObjectRetentionHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, gw.ErrObjectLockNotSupported), r.URL, false)
 }
 
 func (h objectAPIHandlersWrapper) PutObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "PutObjectLegalHold")
 	defer mon.Task()(&ctx)(nil)
-	h.core.PutObjectLegalHoldHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, gw.ErrObjectLockNotSupported), r.URL, false)
 }
 
 func (h objectAPIHandlersWrapper) PutObjectHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	defer mon.Task()(&ctx)(nil)
+
+	if err := gw.CheckWritePreconditions(r.Header); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	r, checksum, err := verifyChecksum(r)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+	w = withChecksumHeader(w, checksum)
+
 	h.core.PutObjectHandler(w, r)
 }
 
@@ -144,21 +222,81 @@ func (h objectAPIHandlersWrapper) DeleteObjectHandler(w http.ResponseWriter, r *
 }
 
 func (h objectAPIHandlersWrapper) GetBucketLocationHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "GetBucketLocation")
 	defer mon.Task()(&ctx)(nil)
-	h.core.GetBucketLocationHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.GetBucketLocationAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	if _, err := h.layer.GetBucketInfo(ctx, bucket); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	// buckets are located in the region of the satellite of the access, and
+	// like in S3, the location of buckets in us-east-1 is empty.
+	var location cmd.LocationResponse
+	if region := middleware.GetRegion(ctx); region != "us-east-1" {
+		location.Location = region
+	}
+
+	cmd.WriteSuccessResponseXML(w, cmd.EncodeResponse(location))
 }
 
 func (h objectAPIHandlersWrapper) GetBucketPolicyHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "GetBucketPolicy")
 	defer mon.Task()(&ctx)(nil)
-	h.core.GetBucketPolicyHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.GetBucketPolicyAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	bucketPolicy, err := h.layer.GetBucketPolicy(ctx, bucket)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	data, err := json.Marshal(bucketPolicy)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessResponseJSON(w, data)
 }
 
 func (h objectAPIHandlersWrapper) GetBucketLifecycleHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "GetBucketLifecycle")
 	defer mon.Task()(&ctx)(nil)
-	h.core.GetBucketLifecycleHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.GetBucketLifecycleAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	config, err := h.layer.GetBucketLifecycleConfig(ctx, bucket)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	cmd.WriteSuccessResponseXML(w, config.Bytes())
 }
 
 func (h objectAPIHandlersWrapper) GetBucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
@@ -168,9 +306,12 @@ func (h objectAPIHandlersWrapper) GetBucketEncryptionHandler(w http.ResponseWrit
 }
 
 func (h objectAPIHandlersWrapper) GetBucketObjectLockConfigHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "GetBucketObjectLockConfig")
 	defer mon.Task()(&ctx)(nil)
-	h.core.GetBucketObjectLockConfigHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, gw.ErrObjectLockNotSupported), r.URL, false)
 }
 
 func (h objectAPIHandlersWrapper) GetBucketReplicationConfigHandler(w http.ResponseWriter, r *http.Request) {
@@ -180,21 +321,57 @@ func (h objectAPIHandlersWrapper) GetBucketReplicationConfigHandler(w http.Respo
 }
 
 func (h objectAPIHandlersWrapper) GetBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "GetBucketVersioning")
 	defer mon.Task()(&ctx)(nil)
-	h.core.GetBucketVersioningHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.GetBucketVersioningAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	config, err := h.layer.GetBucketVersioning(ctx, bucket)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	cmd.WriteSuccessResponseXML(w, cmd.EncodeResponse(config))
 }
 
 func (h objectAPIHandlersWrapper) GetBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "GetBucketNotification")
 	defer mon.Task()(&ctx)(nil)
-	h.core.GetBucketNotificationHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.GetBucketNotificationAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	config, err := h.layer.GetBucketNotificationConfig(ctx, bucket)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	cmd.WriteSuccessResponseXML(w, cmd.EncodeResponse(config))
 }
 
 func (h objectAPIHandlersWrapper) ListenNotificationHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	defer mon.Task()(&ctx)(nil)
-	h.core.ListenNotificationHandler(w, r)
+
+	// events are delivered to the targets the gateway is configured with
+	// rather than streamed to clients, which could be connected to any
+	// replica.
+	cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(cmd.ErrNotImplemented), r.URL, false)
 }
 
 func (h objectAPIHandlersWrapper) GetBucketACLHandler(w http.ResponseWriter, r *http.Request) {
@@ -210,40 +387,145 @@ func (h objectAPIHandlersWrapper) PutBucketACLHandler(w http.ResponseWriter, r *
 }
 
 func (h objectAPIHandlersWrapper) GetBucketCorsHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "GetBucketCors")
 	defer mon.Task()(&ctx)(nil)
-	var sb strings.Builder
-	sb.WriteString("<CORSConfiguration><CORSRule>")
-	for _, o := range h.corsAllowedOrigins {
-		fmt.Fprintf(&sb, "<AllowedOrigin>%s</AllowedOrigin>", o)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, getBucketCorsAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	config, err := h.layer.GetBucketCORSConfig(ctx, bucket)
+	if errors.Is(err, gw.ErrNoSuchCORSConfiguration) {
+		// buckets without a CORS configuration use the global allowed origins.
+		config, err = h.globalCORSConfig(), nil
 	}
-	// CorsHandler's AllowedHeader list is duplicated here
-	allowedMethods := []string{http.MethodGet, http.MethodPut, http.MethodHead, http.MethodPost,
-		http.MethodDelete, http.MethodOptions, http.MethodPatch}
-	for _, o := range allowedMethods {
-		fmt.Fprintf(&sb, "<AllowedMethod>%s</AllowedMethod>", o)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
 	}
-	// CorsHandler's AllowedHeader list is not implemented here, because it includes "*"
-	sb.WriteString("<AllowedHeader>*</AllowedHeader><ExposeHeader>*</ExposeHeader></CORSRule></CORSConfiguration>")
-	cmd.WriteSuccessResponseXML(w, []byte(sb.String()))
+
+	cmd.WriteSuccessResponseXML(w, cmd.EncodeResponse(config))
 }
 
 func (h objectAPIHandlersWrapper) PutBucketCorsHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "PutBucketCors")
 	defer mon.Task()(&ctx)(nil)
-	cmd.WriteErrorResponse(r.Context(), w, cmd.GetAPIError(cmd.ErrNotImplemented), r.URL, false)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, putBucketCorsAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	config, err := gw.ParseCORSConfig(io.LimitReader(r.Body, maxBucketConfigSize))
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	if err = h.layer.SetBucketCORSConfig(ctx, bucket, config); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessResponseHeadersOnly(w)
 }
 
 func (h objectAPIHandlersWrapper) DeleteBucketCorsHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "DeleteBucketCors")
 	defer mon.Task()(&ctx)(nil)
-	cmd.WriteErrorResponse(r.Context(), w, cmd.GetAPIError(cmd.ErrNotImplemented), r.URL, false)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	// like S3, deleting the CORS configuration requires the permission to put
+	// it.
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, putBucketCorsAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	if err := h.layer.DeleteBucketCORSConfig(ctx, bucket); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessNoContent(w)
+}
+
+// globalCORSConfig returns the CORS configuration CorsHandler applies to
+// buckets without one.
+func (h objectAPIHandlersWrapper) globalCORSConfig() *gw.CORSConfig {
+	return &gw.CORSConfig{
+		Rules: []gw.CORSRule{{
+			AllowedOrigins: h.corsAllowedOrigins.Load(),
+			// CorsHandler's AllowedMethods list is duplicated here
+			AllowedMethods: []string{http.MethodGet, http.MethodPut, http.MethodHead, http.MethodPost,
+				http.MethodDelete, http.MethodOptions, http.MethodPatch},
+			// CorsHandler's AllowedHeaders list is not duplicated here, because
+			// it includes "*"
+			AllowedHeaders: []string{"*"},
+			ExposeHeaders:  []string{"*"},
+		}},
+	}
 }
 
 func (h objectAPIHandlersWrapper) GetBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "GetBucketWebsite")
+	defer mon.Task()(&ctx)(nil)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, getBucketWebsiteAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	config, err := h.layer.GetBucketWebsiteConfig(ctx, bucket)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	cmd.WriteSuccessResponseXML(w, cmd.EncodeResponse(config))
+}
+
+func (h objectAPIHandlersWrapper) PutBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := cmd.NewContext(r, w, "PutBucketWebsite")
 	defer mon.Task()(&ctx)(nil)
-	h.core.GetBucketWebsiteHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, putBucketWebsiteAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	config, err := gw.ParseWebsiteConfig(io.LimitReader(r.Body, maxBucketConfigSize))
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	if err = h.layer.SetBucketWebsiteConfig(ctx, bucket, config); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessResponseHeadersOnly(w)
 }
 
 func (h objectAPIHandlersWrapper) GetBucketAccelerateHandler(w http.ResponseWriter, r *http.Request) {
@@ -265,21 +547,68 @@ func (h objectAPIHandlersWrapper) GetBucketLoggingHandler(w http.ResponseWriter,
 }
 
 func (h objectAPIHandlersWrapper) GetBucketTaggingHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "GetBucketTagging")
 	defer mon.Task()(&ctx)(nil)
-	h.core.GetBucketTaggingHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.GetBucketTaggingAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	t, err := h.layer.GetBucketTagging(ctx, bucket)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	cmd.WriteSuccessResponseXML(w, cmd.EncodeResponse(t))
 }
 
 func (h objectAPIHandlersWrapper) DeleteBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "DeleteBucketWebsite")
 	defer mon.Task()(&ctx)(nil)
-	h.core.DeleteBucketWebsiteHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, deleteBucketWebsiteAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	if err := h.layer.DeleteBucketWebsiteConfig(ctx, bucket); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessNoContent(w)
 }
 
 func (h objectAPIHandlersWrapper) DeleteBucketTaggingHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "DeleteBucketTagging")
 	defer mon.Task()(&ctx)(nil)
-	h.core.DeleteBucketTaggingHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	// like S3, deleting the tags requires the permission to put them.
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.PutBucketTaggingAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	if err := h.layer.DeleteBucketTagging(ctx, bucket); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessNoContent(w)
 }
 
 func (h objectAPIHandlersWrapper) ListMultipartUploadsHandler(w http.ResponseWriter, r *http.Request) {
@@ -313,9 +642,30 @@ func (h objectAPIHandlersWrapper) ListObjectsV1Handler(w http.ResponseWriter, r
 }
 
 func (h objectAPIHandlersWrapper) PutBucketLifecycleHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "PutBucketLifecycle")
 	defer mon.Task()(&ctx)(nil)
-	h.core.PutBucketLifecycleHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.PutBucketLifecycleAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	config, err := gw.ParseLifecycleConfig(io.LimitReader(r.Body, maxBucketConfigSize))
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	if err = h.layer.SetBucketLifecycleConfig(ctx, bucket, config); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessResponseHeadersOnly(w)
 }
 
 func (h objectAPIHandlersWrapper) PutBucketReplicationConfigHandler(w http.ResponseWriter, r *http.Request) {
@@ -331,33 +681,127 @@ func (h objectAPIHandlersWrapper) PutBucketEncryptionHandler(w http.ResponseWrit
 }
 
 func (h objectAPIHandlersWrapper) PutBucketPolicyHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "PutBucketPolicy")
 	defer mon.Task()(&ctx)(nil)
-	h.core.PutBucketPolicyHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.PutBucketPolicyAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	if r.ContentLength > maxBucketPolicySize {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(cmd.ErrPolicyTooLarge), r.URL, false)
+		return
+	}
+
+	bucketPolicy, err := gw.ParseBucketPolicy(io.LimitReader(r.Body, maxBucketPolicySize), bucket)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	if err = h.layer.SetBucketPolicy(ctx, bucket, bucketPolicy); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessNoContent(w)
 }
 
 func (h objectAPIHandlersWrapper) PutBucketObjectLockConfigHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "PutBucketObjectLockConfig")
 	defer mon.Task()(&ctx)(nil)
-	h.core.PutBucketObjectLockConfigHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, gw.ErrObjectLockNotSupported), r.URL, false)
 }
 
 func (h objectAPIHandlersWrapper) PutBucketTaggingHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "PutBucketTagging")
 	defer mon.Task()(&ctx)(nil)
-	h.core.PutBucketTaggingHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.PutBucketTaggingAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	t, err := tags.ParseBucketXML(io.LimitReader(r.Body, maxBucketConfigSize))
+	if err != nil {
+		apiErr := cmd.GetAPIError(cmd.ErrMalformedXML)
+		apiErr.Description = err.Error()
+		cmd.WriteErrorResponse(ctx, w, apiErr, r.URL, false)
+		return
+	}
+
+	if err = h.layer.SetBucketTagging(ctx, bucket, t); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessResponseHeadersOnly(w)
 }
 
 func (h objectAPIHandlersWrapper) PutBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "PutBucketVersioning")
 	defer mon.Task()(&ctx)(nil)
-	h.core.PutBucketVersioningHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.PutBucketVersioningAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	config, err := versioning.ParseConfig(io.LimitReader(r.Body, maxBucketConfigSize))
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	if err = h.layer.SetBucketVersioning(ctx, bucket, config); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessResponseHeadersOnly(w)
 }
 
 func (h objectAPIHandlersWrapper) PutBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "PutBucketNotification")
 	defer mon.Task()(&ctx)(nil)
-	h.core.PutBucketNotificationHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.PutBucketNotificationAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	config, err := event.ParseConfig(io.LimitReader(r.Body, maxBucketConfigSize), "", h.layer.NotificationTargets())
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	if err = h.layer.SetBucketNotificationConfig(ctx, bucket, config); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessResponseHeadersOnly(w)
 }
 
 func (h objectAPIHandlersWrapper) PutBucketHandler(w http.ResponseWriter, r *http.Request) {
@@ -385,9 +829,24 @@ func (h objectAPIHandlersWrapper) DeleteMultipleObjectsHandler(w http.ResponseWr
 }
 
 func (h objectAPIHandlersWrapper) DeleteBucketPolicyHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "DeleteBucketPolicy")
 	defer mon.Task()(&ctx)(nil)
-	h.core.DeleteBucketPolicyHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.DeleteBucketPolicyAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	if err := h.layer.DeleteBucketPolicy(ctx, bucket); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessNoContent(w)
 }
 
 func (h objectAPIHandlersWrapper) DeleteBucketReplicationConfigHandler(w http.ResponseWriter, r *http.Request) {
@@ -397,9 +856,26 @@ func (h objectAPIHandlersWrapper) DeleteBucketReplicationConfigHandler(w http.Re
 }
 
 func (h objectAPIHandlersWrapper) DeleteBucketLifecycleHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := cmd.NewContext(r, w, "DeleteBucketLifecycle")
 	defer mon.Task()(&ctx)(nil)
-	h.core.DeleteBucketLifecycleHandler(w, r)
+
+	defer logger.AuditLog(ctx, w, r, nil)
+
+	bucket := mux.Vars(r)["bucket"]
+
+	// like S3, deleting the lifecycle configuration requires the permission
+	// to put it.
+	if _, _, s3Error := cmd.CheckRequestAuthTypeCredential(ctx, r, policy.PutBucketLifecycleAction, bucket, ""); s3Error != cmd.ErrNone {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(s3Error), r.URL, false)
+		return
+	}
+
+	if err := h.layer.DeleteBucketLifecycleConfig(ctx, bucket); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.ToAPIError(ctx, err), r.URL, false)
+		return
+	}
+
+	writeSuccessNoContent(w)
 }
 
 func (h objectAPIHandlersWrapper) DeleteBucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
@@ -425,3 +901,15 @@ func (h objectAPIHandlersWrapper) ListBucketsHandler(w http.ResponseWriter, r *h
 	defer mon.Task()(&ctx)(nil)
 	h.core.ListBucketsHandler(w, r)
 }
+
+// bucketAndObject returns the bucket and the unescaped object name of r.
+func bucketAndObject(r *http.Request) (bucket, object string, err error) {
+	vars := mux.Vars(r)
+
+	object, err = url.PathUnescape(vars["object"])
+	if err != nil {
+		return "", "", cmd.ObjectNameInvalid{Bucket: vars["bucket"], Object: vars["object"]}
+	}
+
+	return vars["bucket"], object, nil
+}
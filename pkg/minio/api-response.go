@@ -5,6 +5,8 @@ package minio
 
 import (
 	"encoding/xml"
+	"net/http"
+	"strconv"
 
 	"storj.io/gateway-mt/pkg/server/gw"
 	"storj.io/minio/cmd"
@@ -53,3 +55,23 @@ func generateListBucketsWithAttributionResponse(info []gw.BucketWithAttributionI
 
 	return response
 }
+
+// writeSuccessResponseHeadersOnly writes success headers with HTTP status 200
+// and no body.
+func writeSuccessResponseHeadersOnly(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeSuccessNoContent writes success headers with HTTP status 204.
+func writeSuccessNoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeSuccessResponseJSON writes success headers with HTTP status 200 and
+// response as JSON.
+func writeSuccessResponseJSON(w http.ResponseWriter, response []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(response)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(response)
+}
@@ -15,27 +15,38 @@ import (
 	xhttp "storj.io/minio/cmd/http"
 )
 
-// RegisterAPIRouter - registers S3 compatible APIs.
-func RegisterAPIRouter(router *mux.Router, layer *gw.MultiTenancyLayer, domainNames []string, concurrentAllowed uint, corsAllowedOrigins []string) {
-	api := objectAPIHandlersWrapper{cmd.ObjectAPIHandlers{
-		ObjectAPI: func() cmd.ObjectLayer { return layer },
-		CacheAPI:  func() cmd.CacheObjectLayer { return nil },
-	}, corsAllowedOrigins}
-
-	// limit the conccurrency of uploads and downloads per macaroon head
-	limit := middleware.NewMacaroonLimiter(concurrentAllowed,
+// NewConcurrencyLimiter constructs the Limiter RegisterAPIRouter limits the
+// concurrency of uploads and downloads per macaroon head with.
+func NewConcurrencyLimiter(concurrentAllowed uint, limiterBackend middleware.LimiterBackend) *middleware.Limiter {
+	var limiter *middleware.Limiter
+	limiter = middleware.NewMacaroonLimiter(concurrentAllowed, limiterBackend,
 		func(w http.ResponseWriter, r *http.Request) {
 			err := cmd.APIError{
 				Code:           "SlowDown",                 // necessary to return a RetryAfter header
 				HTTPStatusCode: http.StatusTooManyRequests, // Minio's ErrSlowDown yields a 503, but 429 seems clearer
-				Description:    fmt.Sprintf("Only %d concurrent uploads or downloads are allowed per credential", concurrentAllowed),
+				Description:    fmt.Sprintf("Only %d concurrent uploads or downloads are allowed per credential", limiter.Allowed()),
 			}
 			cmd.WriteErrorResponse(r.Context(), w, err, r.URL, false)
 		},
-	).Limit
+	)
+	return limiter
+}
+
+// RegisterAPIRouter - registers S3 compatible APIs.
+func RegisterAPIRouter(router *mux.Router, layer *gw.MultiTenancyLayer, domainNames []string, limiter *middleware.Limiter, corsAllowedOrigins *CORSOrigins) {
+	api := objectAPIHandlersWrapper{cmd.ObjectAPIHandlers{
+		ObjectAPI: func() cmd.ObjectLayer { return layer },
+		CacheAPI:  func() cmd.CacheObjectLayer { return nil },
+	}, layer, corsAllowedOrigins}
+
+	// limit the conccurrency of uploads and downloads per macaroon head
+	limit := limiter.Limit
 
 	apiRouter := router.PathPrefix(cmd.SlashSeparator).Subrouter()
 
+	// signatures are verified before requests reach minio.
+	apiRouter.Use(BypassSignatureHandler)
+
 	var routers []*mux.Router
 	for _, domainName := range domainNames {
 		routers = append(routers, apiRouter.Host("{bucket:.+}."+domainName).Subrouter())
@@ -157,9 +168,12 @@ func RegisterAPIRouter(router *mux.Router, layer *gw.MultiTenancyLayer, domainNa
 		// DeleteBucketCors - this is a dummy call.
 		bucket.Methods(http.MethodDelete).HandlerFunc(
 			cmd.MaxClients(cmd.CollectAPIStats("deletebucketcors", cmd.HTTPTraceAll(api.DeleteBucketCorsHandler)))).Queries("cors", "")
-		// GetBucketWebsiteHandler - this is a dummy call.
+		// GetBucketWebsiteHandler
 		bucket.Methods(http.MethodGet).HandlerFunc(
 			cmd.MaxClients(cmd.CollectAPIStats("getbucketwebsite", cmd.HTTPTraceAll(api.GetBucketWebsiteHandler)))).Queries("website", "")
+		// PutBucketWebsiteHandler
+		bucket.Methods(http.MethodPut).HandlerFunc(
+			cmd.MaxClients(cmd.CollectAPIStats("putbucketwebsite", cmd.HTTPTraceAll(api.PutBucketWebsiteHandler)))).Queries("website", "")
 		// GetBucketAccelerateHandler - this is a dummy call.
 		bucket.Methods(http.MethodGet).HandlerFunc(
 			cmd.MaxClients(cmd.CollectAPIStats("getbucketaccelerate", cmd.HTTPTraceAll(api.GetBucketAccelerateHandler)))).Queries("accelerate", "")
@@ -0,0 +1,221 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package minio
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	stdhash "hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"storj.io/gateway-mt/pkg/server/middleware"
+	"storj.io/minio/cmd"
+	"storj.io/minio/pkg/bucket/policy"
+	"storj.io/minio/pkg/bucket/policy/condition"
+	"storj.io/minio/pkg/hash"
+)
+
+// unsignedPayload is the hash of the payload of requests signed without it.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// signatureQueryParams are the query parameters carrying the signature of
+// presigned requests.
+var signatureQueryParams = []string{
+	// AWS Signature Version 4
+	"X-Amz-Algorithm", "X-Amz-Credential", "X-Amz-Date", "X-Amz-Expires",
+	"X-Amz-SignedHeaders", "X-Amz-Signature", "X-Amz-Security-Token",
+	"X-Amz-Content-Sha256",
+	// AWS Signature Version 2
+	"AWSAccessKeyId", "Signature", "Expires",
+}
+
+// BypassSignatureHandler passes requests with credentials, whose signature
+// middleware.VerifySignature verified, on to minio without their signature.
+// minio handles them as anonymous requests, which allowAllPolicyStore allows,
+// instead of verifying their signature again. Requests without credentials
+// are denied.
+//
+// POST policy forms are the exception, as minio verifies their signature
+// whatever the request looks like (see IAMAuthStore).
+func BypassSignatureHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credentials := middleware.GetAccess(r.Context())
+		if credentials == nil || credentials.Error != nil {
+			cmd.WriteErrorResponse(r.Context(), w, cmd.GetAPIError(cmd.ErrAccessDenied), r.URL, false)
+			return
+		}
+
+		r, err := withoutSignature(r)
+		if err != nil {
+			cmd.WriteErrorResponse(r.Context(), w, cmd.ToAPIError(r.Context(), err), r.URL, false)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withoutSignature returns a copy of r without the headers and query
+// parameters carrying its signature.
+//
+// minio only verifies the payload of signed requests against the hash they're
+// signed with, so the body of the copy is verified against it instead.
+func withoutSignature(r *http.Request) (*http.Request, error) {
+	r = r.Clone(r.Context())
+
+	r.Header.Del("Authorization")
+	r.Header.Del("X-Amz-Security-Token")
+
+	if query := r.URL.Query(); query.Has("X-Amz-Credential") || query.Has("AWSAccessKeyId") {
+		if contentSHA256 := query.Get("X-Amz-Content-Sha256"); contentSHA256 != "" && r.Header.Get("X-Amz-Content-Sha256") == "" {
+			r.Header.Set("X-Amz-Content-Sha256", contentSHA256)
+		}
+		for _, param := range signatureQueryParams {
+			query.Del(param)
+		}
+		r.URL.RawQuery = query.Encode()
+		r.RequestURI = r.URL.RequestURI()
+	}
+
+	// the payload of streaming uploads is decoded, with the signature of
+	// each chunk verified, by middleware.VerifySignature.
+	contentSHA256 := r.Header.Get("X-Amz-Content-Sha256")
+	if contentSHA256 == "" || contentSHA256 == unsignedPayload || strings.HasPrefix(contentSHA256, "STREAMING-") {
+		return r, nil
+	}
+
+	sum, err := hex.DecodeString(contentSHA256)
+	if err != nil || len(sum) != sha256.Size {
+		return r, hash.SHA256Mismatch{ExpectedSHA256: contentSHA256}
+	}
+	r.Body = readCloser{
+		Reader: &sha256Reader{
+			r:         r.Body,
+			sum:       sum,
+			hash:      sha256.New(),
+			remaining: r.ContentLength,
+		},
+		Closer: r.Body,
+	}
+
+	return r, nil
+}
+
+// sha256Reader fails with hash.SHA256Mismatch if what's read from it doesn't
+// hash to sum.
+//
+// The hash is verified as soon as remaining bytes are read, as minio stops
+// reading there without waiting for io.EOF.
+type sha256Reader struct {
+	r         io.Reader
+	sum       []byte
+	hash      stdhash.Hash
+	remaining int64
+	verified  bool
+}
+
+func (r *sha256Reader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	_, _ = r.hash.Write(p[:n])
+	r.remaining -= int64(n)
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, err
+	}
+	if !r.verified && (r.remaining == 0 || errors.Is(err, io.EOF)) {
+		r.verified = true
+		if sum := r.hash.Sum(nil); !bytes.Equal(sum, r.sum) {
+			return n, hash.SHA256Mismatch{
+				ExpectedSHA256:   hex.EncodeToString(r.sum),
+				CalculatedSHA256: hex.EncodeToString(sum),
+			}
+		}
+	}
+	return n, err
+}
+
+// allowAllPolicyStore is the object layer minio reads bucket policies from to
+// authorize anonymous requests, which only reach it once
+// BypassSignatureHandler has verified they have credentials.
+type allowAllPolicyStore struct {
+	NotImplementedObjectStore
+}
+
+// allowAllPolicy allows anyone to do anything.
+var allowAllPolicy = policy.Policy{
+	Version: policy.DefaultVersion,
+	Statements: []policy.Statement{
+		policy.NewStatement(
+			policy.Allow,
+			policy.NewPrincipal("*"),
+			// minio's bucket policies have no wildcard for all actions.
+			policy.NewActionSet(
+				policy.AbortMultipartUploadAction,
+				policy.CreateBucketAction,
+				policy.DeleteBucketAction,
+				policy.ForceDeleteBucketAction,
+				policy.DeleteBucketPolicyAction,
+				policy.DeleteObjectAction,
+				policy.GetBucketLocationAction,
+				policy.GetBucketNotificationAction,
+				policy.GetBucketPolicyAction,
+				policy.GetObjectAction,
+				policy.HeadBucketAction,
+				policy.ListAllMyBucketsAction,
+				policy.ListBucketAction,
+				policy.GetBucketPolicyStatusAction,
+				policy.ListBucketVersionsAction,
+				policy.ListBucketMultipartUploadsAction,
+				policy.ListenNotificationAction,
+				policy.ListenBucketNotificationAction,
+				policy.ListMultipartUploadPartsAction,
+				policy.PutBucketNotificationAction,
+				policy.PutBucketPolicyAction,
+				policy.PutObjectAction,
+				policy.GetBucketLifecycleAction,
+				policy.PutBucketLifecycleAction,
+				policy.PutObjectRetentionAction,
+				policy.GetObjectRetentionAction,
+				policy.GetObjectLegalHoldAction,
+				policy.PutObjectLegalHoldAction,
+				policy.PutBucketObjectLockConfigurationAction,
+				policy.GetBucketObjectLockConfigurationAction,
+				policy.PutBucketTaggingAction,
+				policy.GetBucketTaggingAction,
+				policy.GetObjectVersionAction,
+				policy.GetObjectVersionTaggingAction,
+				policy.DeleteObjectVersionAction,
+				policy.DeleteObjectVersionTaggingAction,
+				policy.PutObjectVersionTaggingAction,
+				policy.BypassGovernanceRetentionAction,
+				policy.GetObjectTaggingAction,
+				policy.PutObjectTaggingAction,
+				policy.DeleteObjectTaggingAction,
+				policy.PutBucketEncryptionAction,
+				policy.GetBucketEncryptionAction,
+				policy.PutBucketVersioningAction,
+				policy.GetBucketVersioningAction,
+				policy.GetReplicationConfigurationAction,
+				policy.PutReplicationConfigurationAction,
+				policy.ReplicateObjectAction,
+				policy.ReplicateDeleteAction,
+				policy.ReplicateTagsAction,
+				policy.GetObjectVersionForReplicationAction,
+				policy.RestoreObjectAction,
+			),
+			policy.NewResourceSet(policy.NewResource("*", "")),
+			condition.NewFunctions(),
+		),
+	},
+}
+
+// GetBucketPolicy returns allowAllPolicy for any bucket.
+func (s *allowAllPolicyStore) GetBucketPolicy(ctx context.Context, bucket string) (*policy.Policy, error) {
+	return &allowAllPolicy, nil
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package minio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/gateway-mt/pkg/authclient"
+	"storj.io/gateway-mt/pkg/server/middleware"
+	"storj.io/minio/pkg/bucket/policy"
+)
+
+func TestBypassSignatureHandler(t *testing.T) {
+	const accessKey, secretKey = "AccessKey", "SecretKey"
+
+	// bypass returns r as passed on by BypassSignatureHandler, or nil if it
+	// isn't.
+	bypass := func(r *http.Request, credentials *middleware.Credentials) (*http.Request, int) {
+		var bypassed *http.Request
+		handler := BypassSignatureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bypassed = r
+		}))
+
+		if credentials != nil {
+			r = r.WithContext(middleware.WithCredentials(r.Context(), credentials))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return bypassed, rec.Code
+	}
+
+	credentials := &middleware.Credentials{
+		AccessKey:           accessKey,
+		AuthServiceResponse: authclient.AuthServiceResponse{SecretKey: secretKey},
+	}
+
+	t.Run("without credentials", func(t *testing.T) {
+		r, code := bypass(httptest.NewRequest(http.MethodGet, "http://localhost/bucket/object", nil), nil)
+		require.Nil(t, r)
+		require.Equal(t, http.StatusForbidden, code)
+	})
+
+	t.Run("header", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/bucket/object?tagging", nil)
+		r.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+		r.Header.Set("X-Amz-Security-Token", "token")
+		r, _ = bypass(signer.SignV4(*r, accessKey, secretKey, "", "eu-west-1"), credentials)
+		require.NotNil(t, r)
+		require.Empty(t, r.Header.Values("Authorization"))
+		require.Empty(t, r.Header.Values("X-Amz-Security-Token"))
+		require.Equal(t, "UNSIGNED-PAYLOAD", r.Header.Get("X-Amz-Content-Sha256"))
+		require.Equal(t, "tagging=", r.URL.RawQuery)
+	})
+
+	t.Run("presigned", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPut, "http://localhost/bucket/object?versionId=1&X-Amz-Content-Sha256=e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", nil)
+		r, _ = bypass(signer.PreSignV4(*r, accessKey, secretKey, "", "eu-west-1", 60), credentials)
+		require.NotNil(t, r)
+		require.Equal(t, "versionId=1", r.URL.RawQuery)
+		require.Equal(t, "/bucket/object?versionId=1", r.RequestURI)
+		require.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", r.Header.Get("X-Amz-Content-Sha256"))
+
+		r = httptest.NewRequest(http.MethodGet, "http://localhost/bucket/object?versionId=1", nil)
+		r, _ = bypass(signer.PreSignV2(*r, accessKey, secretKey, time.Now().Add(time.Minute).Unix(), false), credentials)
+		require.NotNil(t, r)
+		require.Equal(t, "versionId=1", r.URL.RawQuery)
+	})
+
+	t.Run("public", func(t *testing.T) {
+		r, _ := bypass(httptest.NewRequest(http.MethodGet, "http://localhost/bucket/object", nil), &middleware.Credentials{
+			AuthServiceResponse: authclient.AuthServiceResponse{Public: true},
+		})
+		require.NotNil(t, r)
+	})
+}
+
+func TestAllowAllPolicy(t *testing.T) {
+	for _, args := range []policy.Args{
+		{Action: policy.ListAllMyBucketsAction},
+		{Action: policy.ListBucketAction, BucketName: "bucket"},
+		{Action: policy.PutObjectAction, BucketName: "bucket", ObjectName: "prefix/object"},
+		{Action: policy.DeleteBucketPolicyAction, BucketName: "bucket"},
+	} {
+		require.True(t, allowAllPolicy.IsAllowed(args), args.Action)
+	}
+}
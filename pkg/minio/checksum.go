@@ -0,0 +1,171 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package minio
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+
+	"storj.io/common/memory"
+	"storj.io/gateway-mt/pkg/server/gw"
+)
+
+// maxCompleteMultipartUploadSize is the maximum size of a
+// CompleteMultipartUpload request body read for the checksums of its parts,
+// enough for 10000 parts.
+const maxCompleteMultipartUploadSize = int64(5 * memory.MiB)
+
+// verifyChecksum returns r with its body verified against the checksum in
+// its headers or its trailer (if any), which is also returned and added to
+// its context for the layer to store. The value of a checksum in the trailer
+// is only set once the body is read.
+func verifyChecksum(r *http.Request) (*http.Request, *gw.Checksum, error) {
+	// bodies with streaming signatures middleware.VerifySignature doesn't
+	// decode are still chunk-encoded here.
+	if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return r, nil, nil
+	}
+
+	checksum, err := gw.ParseChecksum(r.Header)
+	if err != nil {
+		return r, nil, err
+	}
+
+	trailing, err := gw.ParseTrailingChecksum(r.Trailer)
+	switch {
+	case err != nil:
+		return r, nil, err
+	case checksum != nil && trailing != nil:
+		return r, nil, gw.ErrInvalidChecksum
+	case trailing != nil:
+		checksum = trailing
+	case checksum == nil:
+		return r, nil, nil
+	}
+
+	r = r.WithContext(gw.WithChecksum(r.Context(), checksum))
+	r.Body = readCloser{
+		Reader: checksum.Reader(r.Body, r.ContentLength),
+		Closer: r.Body,
+	}
+	return r, checksum, nil
+}
+
+// checksumResponseWriter sets the header with the checksum of the uploaded
+// object or part in the response once it's written, as checksums sent in the
+// trailer of a request are only known by then.
+type checksumResponseWriter struct {
+	http.ResponseWriter
+	checksum    *gw.Checksum
+	wroteHeader bool
+}
+
+// withChecksumHeader returns w setting the header with checksum in the
+// response, if there's one.
+func withChecksumHeader(w http.ResponseWriter, checksum *gw.Checksum) http.ResponseWriter {
+	if checksum == nil {
+		return w
+	}
+	return &checksumResponseWriter{ResponseWriter: w, checksum: checksum}
+}
+
+func (w *checksumResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if statusCode == http.StatusOK && w.checksum.Value != "" {
+			w.Header().Set(w.checksum.Algorithm.Key(), w.checksum.Value)
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *checksumResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher, which minio expects of response writers.
+func (w *checksumResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// completeMultipartUploadChecksums is the part of a CompleteMultipartUpload
+// request body minio ignores.
+type completeMultipartUploadChecksums struct {
+	Parts []struct {
+		ChecksumCRC32  string
+		ChecksumCRC32C string
+		ChecksumSHA1   string
+		ChecksumSHA256 string
+	} `xml:"Part"`
+}
+
+// compositeChecksum returns r with the checksum of the object computed from
+// the checksums of the parts in its body (if any) added to its context for
+// the layer to store.
+//
+// The checksum of each part is verified when it's uploaded, if sent with it.
+func compositeChecksum(r *http.Request) (*http.Request, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxCompleteMultipartUploadSize))
+	if err != nil {
+		return r, err
+	}
+
+	// minio reports malformed or too large bodies itself.
+	r.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(data), r.Body),
+		Closer: r.Body,
+	}
+	var complete completeMultipartUploadChecksums
+	if xml.Unmarshal(data, &complete) != nil || len(complete.Parts) == 0 {
+		return r, nil
+	}
+
+	parts := make(map[gw.ChecksumAlgorithm][]string)
+	for _, part := range complete.Parts {
+		for algorithm, value := range map[gw.ChecksumAlgorithm]string{
+			gw.ChecksumCRC32:  part.ChecksumCRC32,
+			gw.ChecksumCRC32C: part.ChecksumCRC32C,
+			gw.ChecksumSHA1:   part.ChecksumSHA1,
+			gw.ChecksumSHA256: part.ChecksumSHA256,
+		} {
+			if value != "" {
+				parts[algorithm] = append(parts[algorithm], value)
+			}
+		}
+	}
+
+	switch {
+	case len(parts) == 0:
+		return r, nil
+	case len(parts) > 1:
+		return r, gw.ErrInvalidChecksum
+	}
+
+	for algorithm, values := range parts {
+		if len(values) != len(complete.Parts) {
+			return r, gw.ErrInvalidChecksum
+		}
+
+		checksum, err := gw.CompositeChecksum(algorithm, values)
+		if err != nil {
+			return r, err
+		}
+		r = r.WithContext(gw.WithChecksum(r.Context(), checksum))
+	}
+	return r, nil
+}
+
+// readCloser combines a reader and a closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,121 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package minio
+
+import (
+	"encoding/base64"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/gateway-mt/pkg/server/gw"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPut, "http://localhost/bucket/object", strings.NewReader("hello there"))
+	r.Header.Set("X-Amz-Checksum-Crc32", "DUoRhQ==")
+
+	r, checksum, err := verifyChecksum(r)
+	require.NoError(t, err)
+	require.Equal(t, &gw.Checksum{Algorithm: gw.ChecksumCRC32, Value: "DUoRhQ=="}, checksum)
+
+	_, err = io.ReadAll(r.Body)
+	require.ErrorIs(t, err, gw.ErrChecksumMismatch)
+
+	r = httptest.NewRequest(http.MethodPut, "http://localhost/bucket/object", strings.NewReader("hello there"))
+	r.Header.Set("X-Amz-Checksum-Crc32", "DUoRhQ==")
+	r.Header.Set("X-Amz-Content-Sha256", "STREAMING-AWS4-HMAC-SHA256-PAYLOAD")
+
+	_, checksum, err = verifyChecksum(r)
+	require.NoError(t, err)
+	require.Nil(t, checksum)
+}
+
+func TestVerifyTrailingChecksum(t *testing.T) {
+	sum := crc32.NewIEEE()
+	_, _ = sum.Write([]byte("hello there"))
+	value := base64.StdEncoding.EncodeToString(sum.Sum(nil))
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "http://localhost/bucket/object", strings.NewReader("hello there"))
+		r.Trailer = http.Header{"X-Amz-Checksum-Crc32": nil}
+		return r
+	}
+
+	// the value is set in the trailer once the body is read.
+	r := newRequest()
+	r, checksum, err := verifyChecksum(r)
+	require.NoError(t, err)
+	require.Equal(t, gw.ChecksumCRC32, checksum.Algorithm)
+	require.Empty(t, checksum.Value)
+
+	rec := httptest.NewRecorder()
+	w := withChecksumHeader(rec, checksum)
+
+	r.Trailer.Set("X-Amz-Checksum-Crc32", value)
+	_, err = io.ReadAll(r.Body)
+	require.NoError(t, err)
+	require.Equal(t, value, checksum.Value)
+
+	w.WriteHeader(http.StatusOK)
+	require.Equal(t, value, rec.Header().Get("X-Amz-Checksum-Crc32"))
+
+	for _, tt := range []struct {
+		value string
+		err   error
+	}{
+		{value: "DUoRhQ==", err: gw.ErrChecksumMismatch},
+		{value: "", err: gw.ErrInvalidChecksum},
+		{value: "invalid", err: gw.ErrInvalidChecksum},
+	} {
+		r, checksum, err = verifyChecksum(newRequest())
+		require.NoError(t, err)
+		require.NotNil(t, checksum)
+
+		r.Trailer.Set("X-Amz-Checksum-Crc32", tt.value)
+		_, err = io.ReadAll(r.Body)
+		require.ErrorIs(t, err, tt.err, tt.value)
+	}
+
+	r = newRequest()
+	r.Header.Set("X-Amz-Checksum-Crc32", value)
+	_, _, err = verifyChecksum(r)
+	require.ErrorIs(t, err, gw.ErrInvalidChecksum)
+}
+
+func TestCompositeChecksum(t *testing.T) {
+	complete := func(parts string) (*http.Request, error) {
+		body := "<CompleteMultipartUpload>" + parts + "</CompleteMultipartUpload>"
+		r, err := compositeChecksum(httptest.NewRequest(http.MethodPost, "http://localhost/bucket/object?uploadId=1", strings.NewReader(body)))
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, body, string(data))
+		return r, nil
+	}
+
+	_, err := complete(`<Part><PartNumber>1</PartNumber><ETag>a</ETag></Part>`)
+	require.NoError(t, err)
+
+	_, err = complete(`<Part><PartNumber>1</PartNumber><ETag>a</ETag><ChecksumCRC32>DUoRhQ==</ChecksumCRC32></Part><Part><PartNumber>2</PartNumber><ETag>b</ETag><ChecksumCRC32>DUoRhQ==</ChecksumCRC32></Part>`)
+	require.NoError(t, err)
+
+	_, err = complete(`<Part><PartNumber>1</PartNumber><ETag>a</ETag><ChecksumCRC32>DUoRhQ==</ChecksumCRC32></Part><Part><PartNumber>2</PartNumber><ETag>b</ETag></Part>`)
+	require.ErrorIs(t, err, gw.ErrInvalidChecksum)
+
+	_, err = complete(`<Part><PartNumber>1</PartNumber><ETag>a</ETag><ChecksumCRC32>DUoRhQ==</ChecksumCRC32><ChecksumSHA1>Kq5sNclPz7QV2+lfQIuc6R7oRu0=</ChecksumSHA1></Part>`)
+	require.ErrorIs(t, err, gw.ErrInvalidChecksum)
+
+	// malformed bodies are left to minio.
+	_, err = complete(`<Part>`)
+	require.NoError(t, err)
+}
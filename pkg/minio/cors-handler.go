@@ -20,19 +20,71 @@
 package minio
 
 import (
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 
+	"storj.io/gateway-mt/pkg/server/gw"
 	"storj.io/minio/cmd"
 	xhttp "storj.io/minio/cmd/http"
 	"storj.io/minio/cmd/logger"
 	"storj.io/minio/pkg/wildcard"
 )
 
+// errCORSForbidden is returned for preflight requests the CORS configuration
+// of the bucket doesn't allow.
+var errCORSForbidden = cmd.APIError{
+	Code:           "AccessForbidden",
+	Description:    "CORSResponse: This CORS request is not allowed. This is usually because the evalution of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.",
+	HTTPStatusCode: http.StatusForbidden,
+}
+
+// corsHeaders are the response headers CORS handlers set.
+var corsHeaders = []string{
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Allow-Methods",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Expose-Headers",
+	"Access-Control-Max-Age",
+}
+
+// CORSOrigins holds the origins requests are allowed from for buckets without
+// a CORS configuration. They can be replaced while in use.
+type CORSOrigins struct {
+	origins atomic.Value
+}
+
+// NewCORSOrigins creates a new CORSOrigins initially holding origins.
+func NewCORSOrigins(origins []string) *CORSOrigins {
+	o := &CORSOrigins{}
+	o.Store(origins)
+	return o
+}
+
+// Load returns the origins held by o.
+func (o *CORSOrigins) Load() []string {
+	return o.origins.Load().([]string)
+}
+
+// Store replaces the origins held by o.
+func (o *CORSOrigins) Store(origins []string) {
+	o.origins.Store(origins)
+}
+
 // CorsHandler handler for CORS (Cross Origin Resource Sharing).
-func CorsHandler(allowedOrigins []string) mux.MiddlewareFunc {
+//
+// Requests are allowed from allowedOrigins unless the bucket they're for has
+// a CORS configuration. Preflight requests are checked against the
+// configurations of buckets with the same name this instance knows of (see
+// gw.MultiTenancyLayer.PreflightCORSConfigs); actual requests are checked by
+// BucketCorsHandler once their credentials are known.
+func CorsHandler(allowedOrigins *CORSOrigins, layer *gw.MultiTenancyLayer, domainNames []string) mux.MiddlewareFunc {
 	return func(handler http.Handler) http.Handler {
 		commonS3Headers := []string{
 			xhttp.Date,
@@ -56,9 +108,9 @@ func CorsHandler(allowedOrigins []string) mux.MiddlewareFunc {
 			"*",
 		}
 
-		return cors.New(cors.Options{
+		globalHandler := cors.New(cors.Options{
 			AllowOriginFunc: func(origin string) bool {
-				for _, allowedOrigin := range allowedOrigins {
+				for _, allowedOrigin := range allowedOrigins.Load() {
 					if wildcard.MatchSimple(allowedOrigin, origin) {
 						return true
 					}
@@ -78,9 +130,132 @@ func CorsHandler(allowedOrigins []string) mux.MiddlewareFunc {
 			ExposedHeaders:   commonS3Headers,
 			AllowCredentials: true,
 		}).Handler(handler)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isPreflightRequest(r) {
+				if configs := layer.PreflightCORSConfigs(requestBucket(r, domainNames)); len(configs) > 0 {
+					writeCORSPreflightResponse(w, r, configs)
+					return
+				}
+			}
+			globalHandler.ServeHTTP(w, r)
+		})
 	}
 }
 
+// BucketCorsHandler replaces the CORS headers CorsHandler set for an actual
+// request using the global allowed origins with ones from the CORS
+// configuration of its bucket, if it has one. It must run after
+// middleware.AccessKey.
+func BucketCorsHandler(layer *gw.MultiTenancyLayer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin, bucket := r.Header.Get("Origin"), mux.Vars(r)["bucket"]
+			if origin == "" || bucket == "" || isPreflightRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// anything but a configuration (including errors, e.g. for
+			// anonymous requests) leaves the global allowed origins in effect.
+			if config, err := layer.GetBucketCORSConfig(r.Context(), bucket); err == nil {
+				h := w.Header()
+				for _, header := range corsHeaders {
+					h.Del(header)
+				}
+				addVary(h, "Origin")
+
+				if rule := config.Match(origin, r.Method, nil); rule != nil {
+					h.Set("Access-Control-Allow-Origin", origin)
+					h.Set("Access-Control-Allow-Credentials", "true")
+					if len(rule.ExposeHeaders) > 0 {
+						h.Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isPreflightRequest returns whether r is a CORS preflight request.
+func isPreflightRequest(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// writeCORSPreflightResponse answers a preflight request using the first rule
+// of configs that allows it.
+func writeCORSPreflightResponse(w http.ResponseWriter, r *http.Request, configs []*gw.CORSConfig) {
+	origin, method := r.Header.Get("Origin"), r.Header.Get("Access-Control-Request-Method")
+
+	var headers []string
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+
+	h := w.Header()
+	addVary(h, "Origin")
+	addVary(h, "Access-Control-Request-Method")
+	addVary(h, "Access-Control-Request-Headers")
+
+	for _, config := range configs {
+		rule := config.Match(origin, method, headers)
+		if rule == nil {
+			continue
+		}
+
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", "))
+		if len(headers) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		}
+		if len(rule.ExposeHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+		}
+		if rule.MaxAgeSeconds > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(rule.MaxAgeSeconds))
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	cmd.WriteErrorResponse(r.Context(), w, errCORSForbidden, r.URL, false)
+}
+
+// addVary adds header to the Vary header of h unless it's already there.
+func addVary(h http.Header, header string) {
+	for _, value := range h.Values("Vary") {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), header) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", header)
+}
+
+// requestBucket returns the bucket r is addressed to, either virtual-hosted
+// style under one of domainNames or path style.
+func requestBucket(r *http.Request, domainNames []string) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, domainName := range domainNames {
+		if bucket := strings.TrimSuffix(host, "."+domainName); bucket != host {
+			return bucket
+		}
+	}
+
+	bucket, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	return bucket
+}
+
 // CriticalErrorHandler handles critical server failures caused by
 // `panic(logger.ErrCritical)` as done by `logger.CriticalIf`.
 //
@@ -0,0 +1,81 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package minio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/gateway-mt/pkg/server/gw"
+)
+
+func TestRequestBucket(t *testing.T) {
+	domainNames := []string{"gateway.example.com"}
+
+	for _, tt := range []struct {
+		url    string
+		bucket string
+	}{
+		{url: "http://gateway.example.com/bucket/object", bucket: "bucket"},
+		{url: "http://gateway.example.com/bucket", bucket: "bucket"},
+		{url: "http://gateway.example.com/", bucket: ""},
+		{url: "http://bucket.gateway.example.com/object", bucket: "bucket"},
+		{url: "http://bucket.gateway.example.com:7777/object", bucket: "bucket"},
+		{url: "http://localhost:7777/bucket/a/b", bucket: "bucket"},
+	} {
+		require.Equal(t, tt.bucket, requestBucket(httptest.NewRequest(http.MethodOptions, tt.url, nil), domainNames), tt.url)
+	}
+}
+
+func TestWriteCORSPreflightResponse(t *testing.T) {
+	configs := []*gw.CORSConfig{{
+		Rules: []gw.CORSRule{{
+			AllowedOrigins: []string{"https://*.example.com"},
+			AllowedMethods: []string{http.MethodGet, http.MethodPut},
+			AllowedHeaders: []string{"*"},
+			ExposeHeaders:  []string{"ETag"},
+			MaxAgeSeconds:  60,
+		}},
+	}}
+
+	preflight := func(origin, method, headers string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, "http://localhost/bucket/object", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", method)
+		r.Header.Set("Access-Control-Request-Headers", headers)
+		require.True(t, isPreflightRequest(r))
+
+		w := httptest.NewRecorder()
+		writeCORSPreflightResponse(w, r, configs)
+		return w
+	}
+
+	w := preflight("https://app.example.com", http.MethodPut, "content-type, x-amz-date")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "GET, PUT", w.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "content-type, x-amz-date", w.Header().Get("Access-Control-Allow-Headers"))
+	require.Equal(t, "ETag", w.Header().Get("Access-Control-Expose-Headers"))
+	require.Equal(t, "60", w.Header().Get("Access-Control-Max-Age"))
+
+	w = preflight("https://other.com", http.MethodPut, "")
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = preflight("https://app.example.com", http.MethodDelete, "")
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{}
+	h.Set("Vary", "Origin, Accept-Encoding")
+
+	addVary(h, "origin")
+	addVary(h, "Access-Control-Request-Method")
+
+	require.Equal(t, []string{"Origin, Accept-Encoding", "Access-Control-Request-Method"}, h.Values("Vary"))
+}
@@ -10,8 +10,6 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/spacemonkeygo/monkit/v3"
-
 	"storj.io/gateway-mt/pkg/errdata"
 	"storj.io/gateway-mt/pkg/server/middleware"
 	minio "storj.io/minio/cmd"
@@ -19,12 +17,16 @@ import (
 	"storj.io/minio/pkg/auth"
 )
 
-var mon = monkit.Package()
-
 const identityPrefix string = "config/iam/users/"
 const identitySuffix string = "/identity.json"
 
 // IAMAuthStore implements ObjectLayer for use by Minio's IAMObjectStore.
+//
+// Minio verifies the signature of POST policy forms itself, even though
+// middleware.VerifySignature already did, so it's given the secret key of the
+// credentials of the request to verify them with. Other requests don't reach
+// Minio with a signature (see BypassSignatureHandler).
+//
 // Minio doesn't use the full ObjectLayer interface, so we only implement GetObject.
 // If using Minio's Admin APIs, we'd also need DeleteObject, PutObject, and GetObjectInfo.
 type IAMAuthStore struct {
@@ -95,68 +97,3 @@ func (iamOS *IAMAuthStore) GetObjectNInfo(ctx context.Context, bucket, object st
 		},
 	})
 }
-
-// NotImplementedObjectStore implements the ObjectLayer interface, but returns NotImplemented for all receivers.
-type NotImplementedObjectStore struct {
-	minio.GatewayUnsupported
-}
-
-// DeleteBucket is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) DeleteBucket(ctx context.Context, bucket string, forceDelete bool) (err error) {
-	return minio.NotImplemented{}
-}
-
-// DeleteObject is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) DeleteObject(ctx context.Context, bucket, object string, opts minio.ObjectOptions) (objInfo minio.ObjectInfo, err error) {
-	return minio.ObjectInfo{Bucket: bucket, Name: object}, minio.NotImplemented{}
-}
-
-// DeleteObjects is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) DeleteObjects(ctx context.Context, bucket string, objects []minio.ObjectToDelete, opts minio.ObjectOptions) ([]minio.DeletedObject, []error) {
-	return []minio.DeletedObject{}, []error{minio.NotImplemented{}}
-}
-
-// GetBucketInfo is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) GetBucketInfo(ctx context.Context, bucket string) (bucketInfo minio.BucketInfo, err error) {
-	return minio.BucketInfo{}, minio.NotImplemented{}
-}
-
-// GetObjectInfo is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) GetObjectInfo(ctx context.Context, bucket, object string, opts minio.ObjectOptions) (objInfo minio.ObjectInfo, err error) {
-	return minio.ObjectInfo{Bucket: bucket, Name: object}, minio.NotImplemented{}
-}
-
-// GetObjectNInfo is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) GetObjectNInfo(ctx context.Context, bucket, object string, rs *minio.HTTPRangeSpec, h http.Header, lockType minio.LockType, opts minio.ObjectOptions) (reader *minio.GetObjectReader, err error) {
-	return nil, minio.NotImplemented{}
-}
-
-// ListBuckets is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) ListBuckets(ctx context.Context) (buckets []minio.BucketInfo, err error) {
-	return []minio.BucketInfo{}, minio.NotImplemented{}
-}
-
-// ListObjects is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) ListObjects(ctx context.Context, bucket, prefix, marker, delimiter string, maxKeys int) (result minio.ListObjectsInfo, err error) {
-	return minio.ListObjectsInfo{}, minio.NotImplemented{}
-}
-
-// MakeBucketWithLocation is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) MakeBucketWithLocation(ctx context.Context, bucket string, opts minio.BucketOptions) error {
-	return minio.NotImplemented{}
-}
-
-// PutObject is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) PutObject(ctx context.Context, bucket, object string, data *minio.PutObjReader, opts minio.ObjectOptions) (objInfo minio.ObjectInfo, err error) {
-	return minio.ObjectInfo{Bucket: bucket, Name: object}, minio.NotImplemented{}
-}
-
-// Shutdown is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) Shutdown(context.Context) error {
-	return minio.NotImplemented{}
-}
-
-// StorageInfo is unimplemented, but required to meet the ObjectLayer interface.
-func (iamOS *NotImplementedObjectStore) StorageInfo(ctx context.Context) (minio.StorageInfo, []error) {
-	return minio.StorageInfo{}, []error{minio.NotImplemented{}}
-}
@@ -0,0 +1,65 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package minio
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"storj.io/gateway-mt/pkg/server/gw"
+	"storj.io/gateway-mt/pkg/server/middleware"
+	"storj.io/gateway-mt/pkg/trustedip"
+	"storj.io/minio/cmd"
+)
+
+// PublicReadHandler serves anonymous GET and HEAD object requests allowed by
+// a bucket policy with the credentials of the public access
+// gw.MultiTenancyLayer.PublicReadCredentials returns for them. It must be
+// chained after middleware.VerifySignature, as the requests aren't signed.
+func PublicReadHandler(layer *gw.MultiTenancyLayer, trustedIPs *trustedip.Atomic) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			if middleware.GetAccess(ctx) != nil || !isObjectReadRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bucket, object, err := bucketAndObject(r)
+			if err != nil || bucket == "" || object == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			credentials, err := layer.PublicReadCredentials(ctx, bucket, object, trustedip.GetClientIP(trustedIPs.Load(), r))
+			if err != nil {
+				cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(cmd.ErrInternalError), r.URL, false)
+				return
+			}
+			if credentials == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(middleware.WithCredentials(ctx, credentials)))
+		})
+	}
+}
+
+// isObjectReadRequest returns whether r is a GetObject or HeadObject request
+// rather than a request for a subresource of the object (tagging, etc.).
+func isObjectReadRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	for key := range r.URL.Query() {
+		if key != "versionId" && key != "partNumber" && !strings.HasPrefix(key, "response-") {
+			return false
+		}
+	}
+	return true
+}
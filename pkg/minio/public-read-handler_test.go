@@ -0,0 +1,29 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package minio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsObjectReadRequest(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		url    string
+		read   bool
+	}{
+		{method: http.MethodGet, url: "http://localhost/bucket/object", read: true},
+		{method: http.MethodHead, url: "http://localhost/bucket/object?versionId=1", read: true},
+		{method: http.MethodGet, url: "http://localhost/bucket/object?partNumber=1&response-content-type=text/plain", read: true},
+		{method: http.MethodGet, url: "http://localhost/bucket/object?tagging", read: false},
+		{method: http.MethodGet, url: "http://localhost/bucket/object?uploadId=1", read: false},
+		{method: http.MethodPut, url: "http://localhost/bucket/object", read: false},
+	} {
+		require.Equal(t, tt.read, isObjectReadRequest(httptest.NewRequest(tt.method, tt.url, nil)), tt.method+" "+tt.url)
+	}
+}
@@ -8,12 +8,16 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/spacemonkeygo/monkit/v3"
+
 	"storj.io/minio/cmd"
 	"storj.io/minio/cmd/config/policy/opa"
 	xhttp "storj.io/minio/cmd/http"
 	xnet "storj.io/minio/pkg/net"
 )
 
+var mon = monkit.Package()
+
 type allowAllOPA struct{}
 
 func (s allowAllOPA) RoundTrip(r *http.Request) (*http.Response, error) {
@@ -34,8 +38,9 @@ func StartMinio(secureConn bool) {
 	cmd.GlobalCLIContext.StrictS3Compat = true
 	cmd.GlobalIsTLS = secureConn
 
-	// wire up dummy object layer
-	cmd.SetObjectLayer(&NotImplementedObjectStore{})
+	// wire up dummy object layer, which Minio only reads the policies it
+	// authorizes anonymous requests with from (see BypassSignatureHandler)
+	cmd.SetObjectLayer(&allowAllPolicyStore{})
 
 	// wire up Auth layer
 	iamSys := cmd.NewIAMSys()
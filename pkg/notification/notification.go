@@ -0,0 +1,299 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package notification delivers S3 bucket notification events to webhooks
+// and queues.
+package notification
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/minio/pkg/event"
+	"storj.io/minio/pkg/event/target"
+)
+
+var (
+	mon = monkit.Package()
+
+	// Error is a class of notification errors.
+	Error = errs.Class("notification")
+)
+
+const (
+	webhookTargetName = "webhook"
+	queueTargetName   = "queue"
+)
+
+// Config configures Notifier.
+type Config struct {
+	Webhooks         []string      `help:"webhook targets of bucket notifications as id=url pairs (comma separated); bucket notification configurations refer to them as arn:minio:sqs::<id>:webhook"`
+	Queues           []string      `help:"local queue targets of bucket notifications as id=path pairs (comma separated), appending events to the file at path; bucket notification configurations refer to them as arn:minio:sqs::<id>:queue"`
+	SpoolDir         string        `help:"directory events are kept in until they are delivered" default:"$CONFDIR/notifications"`
+	SpoolLimit       uint64        `help:"maximum number of undelivered events kept per target; events are dropped when it's reached" default:"100000"`
+	Timeout          time.Duration `help:"timeout of a delivery of an event" default:"10s"`
+	RetryInterval    time.Duration `help:"how long the first retry of a failed delivery waits" default:"1s"`
+	MaxRetryInterval time.Duration `help:"how long retries of failed deliveries wait at most; the wait doubles with every failure until then" default:"5m0s"`
+}
+
+// deliverer delivers events to a target.
+type deliverer interface {
+	deliver(ctx context.Context, records []event.Event) error
+	close() error
+}
+
+// Notifier spools events for their targets and delivers them, retrying
+// failed deliveries until they succeed.
+type Notifier struct {
+	log     *zap.Logger
+	config  Config
+	targets *event.TargetList
+	spooled []*spooledTarget
+}
+
+// New constructs a Notifier for the targets of config.
+func New(log *zap.Logger, config Config) (_ *Notifier, err error) {
+	n := &Notifier{
+		log:     log,
+		config:  config,
+		targets: event.NewTargetList(),
+	}
+
+	defer func() {
+		if err != nil {
+			err = errs.Combine(err, n.Close())
+		}
+	}()
+
+	client := &http.Client{Timeout: config.Timeout}
+
+	for _, webhook := range config.Webhooks {
+		id, url, err := parsePair(webhook)
+		if err != nil {
+			return nil, err
+		}
+		if err = n.add(event.TargetID{ID: id, Name: webhookTargetName}, &webhookDeliverer{client: client, url: url}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, queue := range config.Queues {
+		id, path, err := parsePair(queue)
+		if err != nil {
+			return nil, err
+		}
+		if err = n.add(event.TargetID{ID: id, Name: queueTargetName}, &queueDeliverer{path: path}); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+// parsePair parses an id=value pair of the configuration of a target.
+func parsePair(pair string) (id, value string, err error) {
+	id, value, ok := strings.Cut(pair, "=")
+	if !ok || id == "" || value == "" || strings.Contains(id, ":") {
+		return "", "", Error.New("invalid target %q: must be an id=value pair", pair)
+	}
+	return id, value, nil
+}
+
+func (n *Notifier) add(id event.TargetID, deliverer deliverer) error {
+	store := newSpool(filepath.Join(n.config.SpoolDir, id.ID+"-"+id.Name), n.config.SpoolLimit)
+	if err := store.Open(); err != nil {
+		return Error.New("opening spool of %s: %w", id, err)
+	}
+
+	spooled := &spooledTarget{
+		id:        id,
+		store:     store,
+		deliverer: deliverer,
+		spooled:   make(chan struct{}, 1),
+	}
+	if err := n.targets.Add(spooled); err != nil {
+		return Error.Wrap(errs.Combine(err, deliverer.close()))
+	}
+	n.spooled = append(n.spooled, spooled)
+	return nil
+}
+
+// Targets returns the targets bucket notification configurations can refer
+// to.
+func (n *Notifier) Targets() *event.TargetList {
+	return n.targets
+}
+
+// Notify spools ev for the targets of targetIDs. Targets that don't exist are
+// skipped, as bucket notification configurations can outlive them.
+func (n *Notifier) Notify(ctx context.Context, ev event.Event, targetIDs event.TargetIDSet) {
+	targets := n.targets.TargetMap()
+	for id := range targetIDs {
+		target, ok := targets[id]
+		if !ok {
+			mon.Counter("notification_unknown_target").Inc(1)
+			continue
+		}
+		if err := target.Save(ev); err != nil {
+			mon.Counter("notification_dropped").Inc(1)
+			n.log.Error("failed to spool event", zap.Stringer("target", id), zap.Error(err))
+		}
+	}
+}
+
+// Run delivers spooled events until ctx is canceled.
+func (n *Notifier) Run(ctx context.Context) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	var wg sync.WaitGroup
+	for _, spooled := range n.spooled {
+		spooled := spooled
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.deliver(ctx, spooled)
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// deliver delivers the events spooled for target until ctx is canceled,
+// waiting longer after every failure.
+func (n *Notifier) deliver(ctx context.Context, target *spooledTarget) {
+	var retryInterval time.Duration
+	for {
+		// the spool is checked right away so events spooled before a
+		// restart are delivered.
+		if err := target.deliverAll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			switch {
+			case retryInterval == 0:
+				retryInterval = n.config.RetryInterval
+			case retryInterval*2 > n.config.MaxRetryInterval:
+				retryInterval = n.config.MaxRetryInterval
+			default:
+				retryInterval *= 2
+			}
+
+			mon.Counter("notification_delivery_failed").Inc(1)
+			n.log.Warn("failed to deliver events", zap.Stringer("target", target.id), zap.Duration("retry in", retryInterval), zap.Error(err))
+
+			timer := time.NewTimer(retryInterval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+			continue
+		}
+
+		retryInterval = 0
+
+		select {
+		case <-target.spooled:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Close closes all targets.
+func (n *Notifier) Close() error {
+	var group errs.Group
+	for _, target := range n.targets.Targets() {
+		group.Add(target.Close())
+	}
+	return Error.Wrap(group.Err())
+}
+
+// spooledTarget is an event.Target keeping events in a store until they are
+// delivered.
+type spooledTarget struct {
+	id        event.TargetID
+	store     target.Store
+	deliverer deliverer
+
+	// spooled is signaled when events are added to the store.
+	spooled chan struct{}
+}
+
+// ID implements event.Target.
+func (t *spooledTarget) ID() event.TargetID { return t.id }
+
+// IsActive implements event.Target. Targets are always active as events are
+// kept until they can be delivered.
+func (t *spooledTarget) IsActive() (bool, error) { return true, nil }
+
+// HasQueueStore implements event.Target.
+func (t *spooledTarget) HasQueueStore() bool { return true }
+
+// Save implements event.Target by adding ev to the store.
+func (t *spooledTarget) Save(ev event.Event) error {
+	if err := t.store.Put(ev); err != nil {
+		return Error.Wrap(err)
+	}
+
+	select {
+	case t.spooled <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Send implements event.Target by delivering the event stored under key.
+func (t *spooledTarget) Send(key string) error {
+	return t.send(context.Background(), key)
+}
+
+func (t *spooledTarget) send(ctx context.Context, key string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	ev, err := t.store.Get(key)
+	if err != nil {
+		return Error.Wrap(err)
+	}
+
+	if err = t.deliverer.deliver(ctx, []event.Event{ev}); err != nil {
+		return Error.Wrap(err)
+	}
+
+	return Error.Wrap(t.store.Del(key))
+}
+
+// deliverAll delivers the stored events in the order they were stored,
+// stopping at the first failure.
+func (t *spooledTarget) deliverAll(ctx context.Context) error {
+	keys, err := t.store.List()
+	if err != nil {
+		return Error.Wrap(err)
+	}
+
+	for _, key := range keys {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = t.send(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements event.Target.
+func (t *spooledTarget) Close() error {
+	return t.deliverer.close()
+}
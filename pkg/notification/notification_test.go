@@ -0,0 +1,215 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/minio/pkg/event"
+)
+
+func testConfig(ctx *testcontext.Context) Config {
+	return Config{
+		SpoolDir:         ctx.Dir("spool"),
+		SpoolLimit:       100,
+		Timeout:          time.Second,
+		RetryInterval:    time.Millisecond,
+		MaxRetryInterval: 10 * time.Millisecond,
+	}
+}
+
+func testEvent(key string) event.Event {
+	return event.Event{
+		EventVersion: "2.1",
+		EventSource:  "aws:s3",
+		EventName:    event.ObjectCreatedPut,
+		S3: event.Metadata{
+			Bucket: event.Bucket{Name: "bucket"},
+			Object: event.Object{Key: key},
+		},
+	}
+}
+
+func TestParsePair(t *testing.T) {
+	id, value, err := parsePair("hook=http://example.com/a=b")
+	require.NoError(t, err)
+	require.Equal(t, "hook", id)
+	require.Equal(t, "http://example.com/a=b", value)
+
+	for _, pair := range []string{"", "hook", "=http://example.com", "hook=", "a:b=http://example.com"} {
+		_, _, err = parsePair(pair)
+		require.Error(t, err, pair)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	var (
+		mu       sync.Mutex
+		failures = 2
+		received []message
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var m message
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&m))
+		received = append(received, m)
+	}))
+	defer server.Close()
+
+	config := testConfig(ctx)
+	config.Webhooks = []string{"hook=" + server.URL}
+
+	notifier, err := New(zaptest.NewLogger(t), config)
+	require.NoError(t, err)
+	defer ctx.Check(notifier.Close)
+
+	targetID := event.TargetID{ID: "hook", Name: "webhook"}
+	require.True(t, notifier.Targets().Exists(targetID))
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx.Go(func() error {
+		_ = notifier.Run(runCtx)
+		return nil
+	})
+
+	notifier.Notify(ctx, testEvent("first"), event.NewTargetIDSet(targetID))
+	notifier.Notify(ctx, testEvent("second"), event.NewTargetIDSet(targetID, event.TargetID{ID: "unknown", Name: "webhook"}))
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 2
+	}, 5*time.Second, time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, "first", received[0].Records[0].S3.Object.Key)
+	require.Equal(t, "second", received[1].Records[0].S3.Object.Key)
+	require.Equal(t, event.ObjectCreatedPut, received[0].Records[0].EventName)
+}
+
+func TestSpoolSurvivesRestart(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	path := ctx.File("queue.jsonl")
+
+	config := testConfig(ctx)
+	config.Queues = []string{"ingest=" + path}
+	targetID := event.TargetID{ID: "ingest", Name: "queue"}
+
+	// events spooled by a notifier that never ran...
+	notifier, err := New(zaptest.NewLogger(t), config)
+	require.NoError(t, err)
+	notifier.Notify(ctx, testEvent("spooled"), event.NewTargetIDSet(targetID))
+	require.NoError(t, notifier.Close())
+
+	spooled, err := os.ReadDir(filepath.Join(config.SpoolDir, "ingest-queue"))
+	require.NoError(t, err)
+	require.Len(t, spooled, 1)
+
+	// ...are delivered by the next one.
+	notifier, err = New(zaptest.NewLogger(t), config)
+	require.NoError(t, err)
+	defer ctx.Check(notifier.Close)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx.Go(func() error {
+		_ = notifier.Run(runCtx)
+		return nil
+	})
+
+	var lines []string
+	require.Eventually(t, func() bool {
+		// the file is created before anything is written to it.
+		data, err := os.ReadFile(path)
+		if err != nil || !strings.HasSuffix(string(data), "\n") {
+			return false
+		}
+		lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+		return len(lines) == 1
+	}, 5*time.Second, time.Millisecond)
+
+	var m message
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &m))
+	require.Equal(t, "spooled", m.Records[0].S3.Object.Key)
+	require.Contains(t, lines[0], `"eventName":"s3:ObjectCreated:Put"`)
+
+	require.Eventually(t, func() bool {
+		spooled, err := os.ReadDir(filepath.Join(config.SpoolDir, "ingest-queue"))
+		return err == nil && len(spooled) == 0
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestDuplicateTarget(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	config := testConfig(ctx)
+	config.Queues = []string{"ingest=" + ctx.File("a.jsonl"), "ingest=" + ctx.File("b.jsonl")}
+
+	_, err := New(zaptest.NewLogger(t), config)
+	require.Error(t, err)
+}
+
+func TestSpoolOrder(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	dir := ctx.Dir("spool")
+
+	s := newSpool(dir, 100)
+	require.NoError(t, s.Open())
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, s.Put(testEvent(key)))
+	}
+
+	keys, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	require.NoError(t, s.Del(keys[0]))
+
+	// the sequence continues after a restart.
+	s = newSpool(dir, 3)
+	require.NoError(t, s.Open())
+	require.NoError(t, s.Put(testEvent("d")))
+	require.Error(t, s.Put(testEvent("e")))
+
+	keys, err = s.List()
+	require.NoError(t, err)
+
+	var spooled []string
+	for _, key := range keys {
+		ev, err := s.Get(key)
+		require.NoError(t, err)
+		spooled = append(spooled, ev.S3.Object.Key)
+	}
+	require.Equal(t, []string{"b", "c", "d"}, spooled)
+}
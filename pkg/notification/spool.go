@@ -0,0 +1,150 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"storj.io/minio/pkg/event"
+	"storj.io/minio/pkg/event/target"
+)
+
+// spoolExt is the extension of the files of spooled events.
+const spoolExt = ".event"
+
+// spool is a target.Store keeping events in files of a directory.
+//
+// Unlike minio's QueueStore, which names files by UUIDs and lists them by
+// their modification time, events are named by a sequence number, so that
+// they are listed in the order they were put even if the file system can't
+// tell them apart by time.
+type spool struct {
+	dir   string
+	limit uint64
+
+	mu      sync.Mutex
+	next    uint64
+	entries uint64
+}
+
+var _ target.Store = (*spool)(nil)
+
+func newSpool(dir string, limit uint64) *spool {
+	return &spool{dir: dir, limit: limit}
+}
+
+// Open implements target.Store by creating the directory of the spool and
+// continuing the sequence of the events in it.
+func (s *spool) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o770); err != nil {
+		return err
+	}
+
+	keys, err := s.list()
+	if err != nil {
+		return err
+	}
+
+	s.entries = uint64(len(keys))
+	s.next = 0
+	for _, key := range keys {
+		if seq, err := strconv.ParseUint(key, 10, 64); err == nil && seq >= s.next {
+			s.next = seq + 1
+		}
+	}
+	return nil
+}
+
+// Put implements target.Store.
+func (s *spool) Put(ev event.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries >= s.limit {
+		return Error.New("spool limit of %d events reached", s.limit)
+	}
+
+	// events are written to a temporary file first, so that List never
+	// returns partially written ones.
+	key := fmt.Sprintf("%020d", s.next)
+	tmp := filepath.Join(s.dir, key+".tmp")
+	if err := os.WriteFile(tmp, data, 0o660); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path(key)); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	s.next++
+	s.entries++
+	return nil
+}
+
+// Get implements target.Store.
+func (s *spool) Get(key string) (ev event.Event, err error) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return ev, err
+	}
+	return ev, json.Unmarshal(data, &ev)
+}
+
+// Del implements target.Store.
+func (s *spool) Del(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(key)); err != nil {
+		return err
+	}
+	if s.entries > 0 {
+		s.entries--
+	}
+	return nil
+}
+
+// List implements target.Store by returning the keys of the spooled events
+// in the order they were put.
+func (s *spool) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list()
+}
+
+func (s *spool) list() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && strings.HasSuffix(name, spoolExt) {
+			keys = append(keys, strings.TrimSuffix(name, spoolExt))
+		}
+	}
+	// keys are zero-padded, so they sort like their sequence numbers.
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *spool) path(key string) string {
+	return filepath.Join(s.dir, key+spoolExt)
+}
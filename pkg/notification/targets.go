@@ -0,0 +1,105 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/minio/pkg/event"
+)
+
+// message is the body of a notification, following the S3 event message
+// structure.
+type message struct {
+	Records []event.Event `json:"Records"`
+}
+
+// webhookDeliverer delivers events by posting them to url.
+type webhookDeliverer struct {
+	client *http.Client
+	url    string
+}
+
+func (d *webhookDeliverer) deliver(ctx context.Context, records []event.Event) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	body, err := json.Marshal(message{Records: records})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errs.Combine(err, resp.Body.Close()) }()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errs.New("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+func (d *webhookDeliverer) close() error { return nil }
+
+// queueDeliverer delivers events by appending them to the file at path as
+// JSON lines. It stands in for a message queue.
+type queueDeliverer struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (d *queueDeliverer) deliver(ctx context.Context, records []event.Event) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	line, err := json.Marshal(message{Records: records})
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		d.file, err = os.OpenFile(d.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err = d.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return d.file.Sync()
+}
+
+func (d *queueDeliverer) close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
@@ -0,0 +1,198 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package prommetrics serves metrics in the Prometheus exposition format.
+//
+// Metrics are only added here (and not reported through monkit) if their
+// labels are bounded, so series don't multiply with e.g. macaroon heads.
+package prommetrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/webhelp.v1/whmon"
+)
+
+var (
+	mon = monkit.Package()
+
+	// Error is an error class for prommetrics errors.
+	Error = errs.Class("prommetrics")
+
+	// Registry is the registry of the metrics served.
+	Registry = prometheus.NewRegistry()
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+}
+
+// LatencyBuckets are the histogram buckets of latencies, from 5ms to about
+// 3 minutes, long enough for large uploads and downloads.
+var LatencyBuckets = prometheus.ExponentialBuckets(0.005, 2, 16)
+
+// Config configures Server.
+type Config struct {
+	Address string `help:"address to serve Prometheus metrics on (empty to disable)" default:""`
+}
+
+// Server serves the metrics in Registry at /metrics.
+type Server struct {
+	log      *zap.Logger
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewServer constructs a Server listening on the configured address.
+func NewServer(log *zap.Logger, config Config) (*Server, error) {
+	listener, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+
+	return &Server{
+		log:      log,
+		listener: listener,
+		server:   &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second},
+	}, nil
+}
+
+// Run serves the metrics until ctx is canceled.
+func (s *Server) Run(ctx context.Context) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	var group errgroup.Group
+
+	group.Go(func() error {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return s.server.Shutdown(shutdownCtx)
+	})
+
+	group.Go(func() error {
+		s.log.Info("Prometheus metrics server started", zap.Stringer("addr", s.listener.Addr()))
+		if err := s.server.Serve(s.listener); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+
+	return Error.Wrap(group.Wait())
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// HTTPMetrics are the metrics of the requests an HTTP server serves, labeled
+// by API, method and status code.
+type HTTPMetrics struct {
+	Requests        *prometheus.CounterVec
+	ResponseTime    *prometheus.HistogramVec
+	TimeToHeader    *prometheus.HistogramVec
+	TimeToFirstByte *prometheus.HistogramVec
+	BytesWritten    *prometheus.CounterVec
+}
+
+var httpMetrics struct {
+	mu       sync.Mutex
+	byPrefix map[string]*HTTPMetrics
+}
+
+// NewHTTPMetrics returns the HTTPMetrics with names starting with prefix,
+// registering them with Registry if they aren't already.
+func NewHTTPMetrics(prefix string) *HTTPMetrics {
+	httpMetrics.mu.Lock()
+	defer httpMetrics.mu.Unlock()
+
+	if m, ok := httpMetrics.byPrefix[prefix]; ok {
+		return m
+	}
+
+	labels := []string{"api", "method", "status_code"}
+	histogram := func(name, help string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    prefix + "_" + name,
+			Help:    help,
+			Buckets: LatencyBuckets,
+		}, labels)
+	}
+
+	m := &HTTPMetrics{
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prefix + "_requests_total",
+			Help: "Number of requests served.",
+		}, labels),
+		ResponseTime:    histogram("response_time_seconds", "Time taken to serve requests."),
+		TimeToHeader:    histogram("time_to_header_seconds", "Time taken to write the response header."),
+		TimeToFirstByte: histogram("time_to_first_byte_seconds", "Time taken to write the first byte of the response body."),
+		BytesWritten: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prefix + "_bytes_written_total",
+			Help: "Number of response body bytes written.",
+		}, labels),
+	}
+	Registry.MustRegister(m.Requests, m.ResponseTime, m.TimeToHeader, m.TimeToFirstByte, m.BytesWritten)
+
+	if httpMetrics.byPrefix == nil {
+		httpMetrics.byPrefix = make(map[string]*HTTPMetrics)
+	}
+	httpMetrics.byPrefix[prefix] = m
+	return m
+}
+
+// InstrumentHandler records the request counts, response times and bytes
+// written of next with the HTTPMetrics with names starting with prefix. Their
+// API label is empty.
+func InstrumentHandler(prefix string, next http.Handler) http.Handler {
+	m := NewHTTPMetrics(prefix)
+	return whmon.MonitorResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+
+		rw := w.(whmon.ResponseWriter)
+		labels := prometheus.Labels{"api": "", "method": Method(r.Method), "status_code": StatusCode(rw.StatusCode())}
+		m.Requests.With(labels).Inc()
+		m.ResponseTime.With(labels).Observe(time.Since(start).Seconds())
+		m.BytesWritten.With(labels).Add(float64(rw.Written()))
+	}))
+}
+
+// Method returns m if it's a known HTTP method and "unknown" otherwise.
+func Method(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	default:
+		return "unknown"
+	}
+}
+
+// StatusCode returns code as a label value, or "unknown" if it's not a valid
+// HTTP status code.
+func StatusCode(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(code)
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package prommetrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/gateway-mt/pkg/prommetrics"
+)
+
+func TestServer(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	server, err := prommetrics.NewServer(zaptest.NewLogger(t), prommetrics.Config{Address: "127.0.0.1:0"})
+	require.NoError(t, err)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	ctx.Go(func() error {
+		return server.Run(runCtx)
+	})
+	defer cancel()
+
+	handler := prommetrics.InstrumentHandler("test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	for _, method := range []string{http.MethodGet, http.MethodGet, "BREW"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+server.Addr()+"/metrics", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Contains(t, string(body), `test_requests_total{api="",method="GET",status_code="404"} 2`)
+	require.Contains(t, string(body), `test_requests_total{api="",method="unknown",status_code="404"} 1`)
+	require.Contains(t, string(body), `test_response_time_seconds_count{api="",method="GET",status_code="404"} 2`)
+	require.Contains(t, string(body), `test_bytes_written_total{api="",method="GET",status_code="404"} 20`)
+	require.Contains(t, string(body), "go_goroutines")
+}
@@ -8,6 +8,12 @@ import (
 
 	"storj.io/common/memory"
 	"storj.io/gateway-mt/pkg/authclient"
+	"storj.io/gateway-mt/pkg/bucketconfig"
+	"storj.io/gateway-mt/pkg/notification"
+	"storj.io/gateway-mt/pkg/prommetrics"
+	"storj.io/gateway-mt/pkg/server/gw"
+	"storj.io/gateway-mt/pkg/server/middleware"
+	"storj.io/gateway-mt/pkg/tracing"
 	"storj.io/gateway/miniogw"
 )
 
@@ -23,17 +29,32 @@ type Config struct {
 	CertDir              string   `help:"directory path to search for TLS certificates" default:"$CONFDIR/certs"`
 	InsecureDisableTLS   bool     `help:"listen using insecure connections" releaseDefault:"false" devDefault:"true"`
 	DomainName           string   `help:"comma-separated domain suffixes to serve on" releaseDefault:"" devDefault:"localhost"`
-	CorsOrigins          string   `help:"list of domains (comma separated) other than the gateway's domain, from which a browser should permit loading resources requested from the gateway" default:"*"`
+	CorsOrigins          string   `help:"list of domains (comma separated) other than the gateway's domain, from which a browser should permit loading resources requested from the gateway, for buckets without a CORS configuration" default:"*"`
 	EncodeInMemory       bool     `help:"tells libuplink to perform in-memory encoding on file upload" releaseDefault:"true" devDefault:"true"`
 	ClientTrustedIPSList []string `help:"list of clients IPs (without port and comma separated) which are trusted; usually used when the service run behinds gateways, load balancers, etc."`
 	UseClientIPHeaders   bool     `help:"use the headers sent by the client to identify its IP. When true the list of IPs set by --client-trusted-ips-list, when not empty, is used" default:"true"`
 	InsecureLogAll       bool     `help:"insecurely log all errors, paths, and headers" default:"false"`
 	ConcurrentAllowed    uint     `help:"number of allowed concurrent uploads or downloads per macaroon head" default:"500"` // see S3 CLI's max_concurrent_requests
 
-	Auth            authclient.Config
-	S3Compatibility miniogw.S3CompatibilityConfig
-	Client          ClientConfig
-	ConnectionPool  ConnectionPoolConfig
+	Auth              authclient.Config
+	S3Compatibility   miniogw.S3CompatibilityConfig
+	Client            ClientConfig
+	ConnectionPool    ConnectionPoolConfig
+	ProjectCache      gw.ProjectCacheConfig
+	BucketConfig      bucketconfig.Config
+	BucketConfigCache gw.BucketConfigCacheConfig
+	ObjectCache       gw.ObjectCacheConfig
+	RateLimit         middleware.RateLimitConfig
+	AccessLog         middleware.AccessLogConfig
+	Notification      notification.Config
+	Health            HealthConfig
+	Drain             DrainConfig
+	Presign           PresignConfig
+	Website           WebsiteConfig
+	Region            middleware.RegionConfig
+	ConcurrentCluster middleware.ClusterLimiterConfig
+	Prometheus        prommetrics.Config
+	OpenTelemetry     tracing.Config
 }
 
 // ConnectionPoolConfig is a config struct for configuring RPC connection pool
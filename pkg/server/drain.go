@@ -0,0 +1,181 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"storj.io/gateway-mt/pkg/prommetrics"
+	"storj.io/minio/cmd"
+)
+
+var (
+	drainingGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gmt_draining",
+		Help: "Whether the gateway is draining (1) or not (0).",
+	})
+	inFlightGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gmt_in_flight_requests",
+		Help: "Number of requests in progress.",
+	})
+)
+
+func init() {
+	prommetrics.Registry.MustRegister(drainingGauge, inFlightGauge)
+}
+
+// DrainConfig configures draining, during which the gateway lets the requests
+// in progress finish before it shuts down.
+type DrainConfig struct {
+	Address        string        `help:"local address to serve the drain endpoint on; POST /drain starts draining, GET /drain reports its progress (empty to disable)" default:""`
+	Deadline       time.Duration `help:"how long requests in progress are waited for to finish when shutting down" default:"10s"`
+	ReportInterval time.Duration `help:"how often the progress of draining is logged" default:"10s"`
+}
+
+// errDraining is returned for requests starting new multipart uploads while
+// draining. SlowDown is retried by S3 clients.
+var errDraining = cmd.APIError{
+	Code:           "SlowDown",
+	Description:    "The gateway is shutting down. Please retry the request.",
+	HTTPStatusCode: http.StatusServiceUnavailable,
+}
+
+// drainer counts the requests in progress and, once draining, rejects those
+// starting new multipart uploads.
+type drainer struct {
+	log    *zap.Logger
+	config DrainConfig
+
+	inFlight int64 // accessed atomically
+	draining int32 // accessed atomically
+
+	once    sync.Once
+	started chan struct{}
+	since   time.Time
+}
+
+func newDrainer(log *zap.Logger, config DrainConfig) *drainer {
+	if config.ReportInterval <= 0 {
+		config.ReportInterval = 10 * time.Second
+	}
+	return &drainer{
+		log:     log,
+		config:  config,
+		started: make(chan struct{}),
+	}
+}
+
+// Drain starts draining. It's idempotent.
+func (d *drainer) Drain() {
+	d.once.Do(func() {
+		d.since = time.Now()
+		atomic.StoreInt32(&d.draining, 1)
+		drainingGauge.Set(1)
+		close(d.started)
+		d.log.Info("Draining", zap.Int64("in-flight requests", d.InFlight()))
+	})
+}
+
+// Draining returns whether draining started.
+func (d *drainer) Draining() bool {
+	return atomic.LoadInt32(&d.draining) == 1
+}
+
+// InFlight returns the number of requests in progress.
+func (d *drainer) InFlight() int64 {
+	return atomic.LoadInt64(&d.inFlight)
+}
+
+// Run reports the progress of draining once it starts, until no requests are
+// in progress or ctx is canceled.
+func (d *drainer) Run(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-d.started:
+	}
+
+	ticker := time.NewTicker(d.config.ReportInterval)
+	defer ticker.Stop()
+
+	for {
+		inFlight := d.InFlight()
+		if inFlight == 0 {
+			d.log.Info("Drained", zap.Duration("duration", time.Since(d.since)))
+			return nil
+		}
+		d.log.Info("Draining", zap.Int64("in-flight requests", inFlight), zap.Duration("duration", time.Since(d.since)))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Handler counts the requests next serves and, while draining, rejects those
+// starting new multipart uploads with a retryable error.
+func (d *drainer) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.Draining() && isNewMultipartUpload(r) {
+			cmd.WriteErrorResponse(r.Context(), w, errDraining, r.URL, false)
+			return
+		}
+
+		inFlightGauge.Set(float64(atomic.AddInt64(&d.inFlight, 1)))
+		defer func() { inFlightGauge.Set(float64(atomic.AddInt64(&d.inFlight, -1))) }()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isNewMultipartUpload returns whether r creates a multipart upload.
+func isNewMultipartUpload(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	_, ok := r.URL.Query()["uploads"]
+	return ok
+}
+
+// drainStatus is the body of the responses of the drain endpoint.
+type drainStatus struct {
+	Draining bool       `json:"draining"`
+	Since    *time.Time `json:"since,omitempty"`
+	InFlight int64      `json:"in_flight_requests"`
+}
+
+func (d *drainer) status() drainStatus {
+	status := drainStatus{
+		Draining: d.Draining(),
+		InFlight: d.InFlight(),
+	}
+	if status.Draining {
+		since := d.since
+		status.Since = &since
+	}
+	return status
+}
+
+// serveDrain serves the drain endpoint.
+func (s *Peer) serveDrain(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.Drain()
+		writeJSON(w, http.StatusAccepted, s.drainer.status())
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.drainer.status())
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
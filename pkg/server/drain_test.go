@@ -0,0 +1,120 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"golang.org/x/sync/errgroup"
+
+	"storj.io/common/testcontext"
+)
+
+func TestDrainer(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	d := newDrainer(zaptest.NewLogger(t), DrainConfig{ReportInterval: time.Millisecond})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := d.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bucket/upload" {
+			close(started)
+			<-release
+		}
+	}))
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/bucket/key?uploads").Code)
+
+	// an upload is in progress while draining...
+	ctx.Go(func() error {
+		serve(http.MethodPut, "/bucket/upload?partNumber=1&uploadId=a")
+		return nil
+	})
+	<-started
+	require.EqualValues(t, 1, d.InFlight())
+
+	d.Drain()
+	d.Drain()
+	require.True(t, d.Draining())
+
+	drained := make(chan error, 1)
+	go func() { drained <- d.Run(ctx) }()
+
+	// ...new multipart uploads are rejected with a retryable error...
+	rec := serve(http.MethodPost, "/bucket/key?uploads")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "<Code>SlowDown</Code>")
+
+	// ...but other requests are served.
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/bucket/key?uploadId=a").Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/bucket/key").Code)
+
+	status := d.status()
+	require.True(t, status.Draining)
+	require.NotNil(t, status.Since)
+	require.EqualValues(t, 1, status.InFlight)
+
+	select {
+	case <-drained:
+		t.Fatal("drained with a request in progress")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-drained)
+	require.Zero(t, d.InFlight())
+}
+
+func TestHealthDraining(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	h, err := newHealthChecker(zaptest.NewLogger(t), HealthConfig{}, nil, nil)
+	require.NoError(t, err)
+
+	d := newDrainer(zaptest.NewLogger(t), DrainConfig{})
+	h.draining = d.Draining
+
+	code, _ := serveReady(t, h)
+	require.Equal(t, http.StatusOK, code)
+
+	d.Drain()
+	code, report := serveReady(t, h)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, healthStatusDraining, report.Status)
+}
+
+func TestServeUntilCanceled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	drainServer := &http.Server{Handler: http.NotFoundHandler()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var group errgroup.Group
+	serveUntilCanceled(ctx, &group, drainServer, listener)
+
+	response, err := http.Get("http://" + listener.Addr().String() + "/drain")
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	// the server stops once ctx is canceled, without being closed.
+	cancel()
+	require.NoError(t, group.Wait())
+}
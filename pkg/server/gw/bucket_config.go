@@ -0,0 +1,184 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/common/grant"
+	"storj.io/common/macaroon"
+	"storj.io/common/storj"
+	"storj.io/gateway-mt/pkg/bucketconfig"
+	"storj.io/gateway/miniogw"
+	minio "storj.io/minio/cmd"
+	"storj.io/uplink"
+)
+
+// ErrBucketConfig is a class of errors reading or writing bucket configuration
+// documents.
+var ErrBucketConfig = errs.Class("bucket config")
+
+// BucketConfigCacheConfig configures the cache of bucket configuration
+// documents.
+type BucketConfigCacheConfig struct {
+	Capacity   int           `help:"maximum number of bucket configurations kept in the cache (0 disables the cache)" default:"10000"`
+	Expiration time.Duration `help:"how long a bucket configuration is cached; changes made through other instances apply after at most this long" default:"1m0s"`
+}
+
+// bucketConfig is a cached configuration document. data is nil if the
+// document doesn't exist.
+type bucketConfig struct {
+	data []byte
+}
+
+// configBucket returns the bucket of the configuration documents of bucket
+// for access.
+//
+// Access grants don't identify the project of the bucket, so documents are
+// kept for the satellite and the API key access was derived from. They apply
+// to all access grants derived from that API key, whatever their encryption
+// key.
+func configBucket(access *grant.Access, bucket string) (bucketconfig.Bucket, error) {
+	nodeURL, err := storj.ParseNodeURL(access.SatelliteAddress)
+	if err != nil {
+		return bucketconfig.Bucket{}, ErrAccessGrant.Wrap(err)
+	}
+	return bucketconfig.Bucket{
+		Project: strings.ToLower(nodeURL.Address) + "/" + hex.EncodeToString(access.APIKey.Head()),
+		Name:    bucket,
+	}, nil
+}
+
+// authorizeBucketConfig returns the bucket of the configuration documents of
+// bucket if the access grant of ctx is allowed to change them, which takes
+// being allowed to write to bucket without a prefix restriction.
+func (l *MultiTenancyLayer) authorizeBucketConfig(ctx context.Context, project *uplink.Project, bucket string) (_ bucketconfig.Bucket, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	access, err := grant.ParseAccess(getAccessGrant(ctx))
+	if err != nil {
+		return bucketconfig.Bucket{}, ErrAccessGrant.Wrap(err)
+	}
+
+	// the satellite checks the API key of the access grant, so that its
+	// caveats can be trusted below.
+	if _, err = project.StatBucket(ctx, bucket); err != nil {
+		return bucketconfig.Bucket{}, miniogw.ConvertError(err, bucket, "")
+	}
+
+	if !allowedToWriteBucket(ctx, access.APIKey, bucket, time.Now()) {
+		return bucketconfig.Bucket{}, minio.PrefixAccessDenied{Bucket: bucket}
+	}
+
+	return configBucket(access, bucket)
+}
+
+// allowedToWriteBucket returns whether apiKey is allowed to write anywhere in
+// bucket at now. Caveats restricting the paths of bucket to a prefix only allow
+// writes of an empty path if the prefix is empty, so checking one covers that.
+func allowedToWriteBucket(ctx context.Context, apiKey *macaroon.APIKey, bucket string, now time.Time) bool {
+	action := macaroon.Action{Op: macaroon.ActionWrite, Bucket: []byte(bucket), Time: now}
+	allowed, err := apiKey.GetAllowedBuckets(ctx, action)
+	if err != nil {
+		return false
+	}
+	_, ok := allowed.Buckets[bucket]
+	return allowed.All || ok
+}
+
+func (l *MultiTenancyLayer) bucketConfigCacheKey(ctx context.Context, bucket, name string) string {
+	return projectCacheKey(getAccessGrant(ctx), bucket+"/"+name)
+}
+
+// getBucketConfig returns the configuration document name of bucket or nil if
+// it isn't set.
+func (l *MultiTenancyLayer) getBucketConfig(ctx context.Context, project *uplink.Project, bucket, name string) (_ []byte, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	config, err := l.bucketConfigs.Get(l.bucketConfigCacheKey(ctx, bucket, name), func() (interface{}, error) {
+		access, err := grant.ParseAccess(getAccessGrant(ctx))
+		if err != nil {
+			return nil, ErrAccessGrant.Wrap(err)
+		}
+
+		key, err := configBucket(access, bucket)
+		if err != nil {
+			return nil, err
+		}
+
+		// documents of buckets that don't exist don't apply, e.g. if the
+		// bucket was deleted through an access grant of another API key.
+		if _, err = project.StatBucket(ctx, bucket); err != nil {
+			return nil, miniogw.ConvertError(err, bucket, "")
+		}
+
+		data, err := l.bucketConfigDB.Get(ctx, key, name)
+		return bucketConfig{data: data}, ErrBucketConfig.Wrap(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return config.(bucketConfig).data, nil
+}
+
+// putBucketConfig sets the configuration document name of bucket to data.
+func (l *MultiTenancyLayer) putBucketConfig(ctx context.Context, project *uplink.Project, bucket, name string, data []byte) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	defer l.bucketConfigs.Delete(l.bucketConfigCacheKey(ctx, bucket, name))
+
+	key, err := l.authorizeBucketConfig(ctx, project, bucket)
+	if err != nil {
+		return err
+	}
+
+	return ErrBucketConfig.Wrap(l.bucketConfigDB.Put(ctx, key, name, data))
+}
+
+// deleteBucketConfig removes the configuration document name of bucket.
+func (l *MultiTenancyLayer) deleteBucketConfig(ctx context.Context, project *uplink.Project, bucket, name string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	defer l.bucketConfigs.Delete(l.bucketConfigCacheKey(ctx, bucket, name))
+
+	key, err := l.authorizeBucketConfig(ctx, project, bucket)
+	if err != nil {
+		return err
+	}
+
+	return ErrBucketConfig.Wrap(l.bucketConfigDB.Delete(ctx, key, name))
+}
+
+// deleteAllBucketConfig removes all configuration documents of bucket, which
+// has just been deleted, so that they don't apply to a new bucket with its
+// name.
+func (l *MultiTenancyLayer) deleteAllBucketConfig(ctx context.Context, bucket string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	access, err := grant.ParseAccess(getAccessGrant(ctx))
+	if err != nil {
+		return ErrAccessGrant.Wrap(err)
+	}
+
+	key, err := configBucket(access, bucket)
+	if err != nil {
+		return err
+	}
+
+	names, err := l.bucketConfigDB.DeleteAll(ctx, key)
+	for _, name := range names {
+		l.bucketConfigs.Delete(l.bucketConfigCacheKey(ctx, bucket, name))
+	}
+	if err != nil {
+		return ErrBucketConfig.Wrap(err)
+	}
+
+	return l.deletePublicRead(ctx, bucket)
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/grant"
+	"storj.io/common/macaroon"
+	"storj.io/common/storj"
+)
+
+func TestConfigBucket(t *testing.T) {
+	apiKey, err := macaroon.NewAPIKey([]byte("secret"))
+	require.NoError(t, err)
+	otherAPIKey, err := macaroon.NewAPIKey([]byte("other secret"))
+	require.NoError(t, err)
+
+	readOnly, err := apiKey.Restrict(macaroon.Caveat{DisallowWrites: true})
+	require.NoError(t, err)
+
+	access := func(satelliteAddress string, apiKey *macaroon.APIKey, encryptionKey byte) *grant.Access {
+		encAccess := grant.NewEncryptionAccessWithDefaultKey(&storj.Key{encryptionKey})
+		return &grant.Access{SatelliteAddress: satelliteAddress, APIKey: apiKey, EncAccess: encAccess}
+	}
+
+	expected, err := configBucket(access("us1.storj.io:7777", apiKey, 1), "bucket")
+	require.NoError(t, err)
+	require.Equal(t, "bucket", expected.Name)
+
+	// access grants derived from the same API key share the configuration,
+	// whatever their encryption key.
+	for _, access := range []*grant.Access{
+		access("us1.storj.io:7777", apiKey, 2),
+		access("12EayRS2V1kEsWESU9QMRseFhdxYxKicsiFmxrsLZHeLUtdps3S@US1.storj.io:7777", readOnly, 1),
+	} {
+		actual, err := configBucket(access, "bucket")
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	}
+
+	// and no others do.
+	for _, access := range []*grant.Access{
+		access("us1.storj.io:7777", otherAPIKey, 1),
+		access("eu1.storj.io:7777", apiKey, 1),
+	} {
+		actual, err := configBucket(access, "bucket")
+		require.NoError(t, err)
+		require.NotEqual(t, expected, actual)
+	}
+
+	actual, err := configBucket(access("us1.storj.io:7777", apiKey, 1), "other")
+	require.NoError(t, err)
+	require.NotEqual(t, expected, actual)
+}
+
+func TestAllowedToWriteBucket(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	apiKey, err := macaroon.NewAPIKey([]byte("secret"))
+	require.NoError(t, err)
+
+	restrict := func(apiKey *macaroon.APIKey, caveat macaroon.Caveat) *macaroon.APIKey {
+		restricted, err := apiKey.Restrict(caveat)
+		require.NoError(t, err)
+		return restricted
+	}
+	paths := func(prefixes ...string) macaroon.Caveat {
+		var caveat macaroon.Caveat
+		for _, prefix := range prefixes {
+			caveat.AllowedPaths = append(caveat.AllowedPaths, &macaroon.Caveat_Path{Bucket: []byte("bucket"), EncryptedPathPrefix: []byte(prefix)})
+		}
+		return caveat
+	}
+	expired := now.Add(-time.Hour)
+
+	for _, tt := range []struct {
+		name    string
+		apiKey  *macaroon.APIKey
+		allowed bool
+	}{
+		{"unrestricted", apiKey, true},
+		{"bucket", restrict(apiKey, paths("")), true},
+		{"bucket and prefix", restrict(apiKey, paths("", "prefix")), true},
+		{"read only", restrict(apiKey, macaroon.Caveat{DisallowWrites: true}), false},
+		{"expired", restrict(apiKey, macaroon.Caveat{NotAfter: &expired}), false},
+		{"prefix", restrict(apiKey, paths("prefix")), false},
+		{"bucket then prefix", restrict(restrict(apiKey, paths("")), paths("prefix")), false},
+		{"other bucket", restrict(apiKey, macaroon.Caveat{AllowedPaths: []*macaroon.Caveat_Path{{Bucket: []byte("other")}}}), false},
+	} {
+		require.Equal(t, tt.allowed, allowedToWriteBucket(ctx, tt.apiKey, "bucket", now), tt.name)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/gateway-mt/pkg/server/middleware"
+	minio "storj.io/minio/cmd"
+	"storj.io/minio/pkg/bucket/policy"
+	"storj.io/uplink"
+)
+
+// policyConfigName is the name of the bucket configuration document holding
+// the bucket policy.
+const policyConfigName = "policy.json"
+
+var (
+	errPolicyConditions = policy.Errorf("Condition is not supported in bucket policies")
+	errPolicyAnonymous  = policy.Errorf("Bucket policies can only allow anonymous access to s3:GetObject")
+)
+
+// ParseBucketPolicy parses and validates the policy of bucket.
+//
+// Policies are enforced (see checkBucketPolicy) for a subset of requests and
+// without request context, so statements with conditions are rejected.
+// Statements can only allow anonymous access to read objects, which is served
+// with a public access (see PublicReadCredentials).
+func ParseBucketPolicy(r io.Reader, bucket string) (*policy.Policy, error) {
+	p, err := policy.ParseConfig(r, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Version == "" {
+		return nil, policy.Errorf("Version must be set")
+	}
+
+	for _, statement := range p.Statements {
+		if len(statement.Conditions) > 0 {
+			return nil, errPolicyConditions
+		}
+		if statement.Effect == policy.Allow && statement.Principal.Match("") {
+			for action := range statement.Actions {
+				if action != policy.GetObjectAction {
+					return nil, errPolicyAnonymous
+				}
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// bucketPolicyAllows returns whether p allows the request of action on object
+// in bucket with accessKey, which is empty for anonymous requests.
+//
+// Access grants already authorize requests made with them, so requests with
+// an access key are only checked against Deny statements (as the bucket's
+// owner would be in S3), with the access key ID as the principal. Anonymous
+// requests also need an Allow statement.
+func bucketPolicyAllows(p *policy.Policy, accessKey string, action policy.Action, bucket, object string) bool {
+	return p.IsAllowed(policy.Args{
+		AccountName: accessKey,
+		Action:      action,
+		BucketName:  bucket,
+		ObjectName:  object,
+		IsOwner:     accessKey != "",
+	})
+}
+
+// GetBucketPolicy returns the policy of bucket.
+func (l *MultiTenancyLayer) GetBucketPolicy(ctx context.Context, bucket string) (_ *policy.Policy, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, "", policy.GetBucketPolicyAction); err != nil {
+		return nil, l.log(ctx, err)
+	}
+
+	p, err := l.bucketPolicy(ctx, project, bucket)
+	if err == nil && p == nil {
+		err = minio.BucketPolicyNotFound{Bucket: bucket}
+	}
+	return p, l.log(ctx, err)
+}
+
+// SetBucketPolicy sets the policy of bucket.
+func (l *MultiTenancyLayer) SetBucketPolicy(ctx context.Context, bucket string, p *policy.Policy) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, "", policy.PutBucketPolicyAction); err != nil {
+		return l.log(ctx, err)
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return l.log(ctx, ErrBucketConfig.Wrap(err))
+	}
+
+	// anonymous reads the policy allows are served once it's set, so setting
+	// it fails if they can't be.
+	key, err := l.authorizeBucketConfig(ctx, project, bucket)
+	if err != nil {
+		return l.log(ctx, err)
+	}
+	if err = l.setPublicRead(ctx, key, p); err != nil {
+		return l.log(ctx, err)
+	}
+
+	return l.log(ctx, l.putBucketConfig(ctx, project, bucket, policyConfigName, data))
+}
+
+// DeleteBucketPolicy removes the policy of bucket.
+func (l *MultiTenancyLayer) DeleteBucketPolicy(ctx context.Context, bucket string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, "", policy.DeleteBucketPolicyAction); err != nil {
+		return l.log(ctx, err)
+	}
+
+	if err = l.deleteBucketConfig(ctx, project, bucket, policyConfigName); err != nil {
+		return l.log(ctx, err)
+	}
+	return l.log(ctx, l.deletePublicRead(ctx, bucket))
+}
+
+// checkBucketPolicy returns an access denied error if the policy of bucket (if
+// any) doesn't allow the request of action on object with the credentials in
+// ctx.
+func (l *MultiTenancyLayer) checkBucketPolicy(ctx context.Context, project *uplink.Project, bucket, object string, action policy.Action) error {
+	p, err := l.bucketPolicy(ctx, project, bucket)
+	if err != nil || p == nil {
+		return err
+	}
+
+	var accessKey string
+	if credentials := middleware.GetAccess(ctx); credentials != nil {
+		accessKey = credentials.AccessKey
+	}
+
+	if !bucketPolicyAllows(p, accessKey, action, bucket, object) {
+		return minio.PrefixAccessDenied{Bucket: bucket, Object: object}
+	}
+	return nil
+}
+
+// bucketPolicy returns the policy of bucket or nil if it isn't set.
+func (l *MultiTenancyLayer) bucketPolicy(ctx context.Context, project *uplink.Project, bucket string) (*policy.Policy, error) {
+	data, err := l.getBucketConfig(ctx, project, bucket, policyConfigName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	p, err := ParseBucketPolicy(bytes.NewReader(data), bucket)
+	if err != nil {
+		return nil, ErrBucketConfig.Wrap(err)
+	}
+	return p, nil
+}
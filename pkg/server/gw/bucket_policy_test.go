@@ -0,0 +1,83 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/minio/pkg/bucket/policy"
+)
+
+func TestParseBucketPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		policy string
+		valid  bool
+	}{
+		{
+			name:   "valid",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:DeleteObject","Resource":"arn:aws:s3:::bucket/logs/*"}]}`,
+			valid:  true,
+		},
+		{
+			name:   "anonymous read",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/public/*"}]}`,
+			valid:  true,
+		},
+		{
+			name:   "anonymous write",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":["s3:GetObject","s3:PutObject"],"Resource":"arn:aws:s3:::bucket/public/*"}]}`,
+		},
+		{
+			name:   "no version",
+			policy: `{"Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`,
+		},
+		{
+			name:   "other bucket",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::other/*"}]}`,
+		},
+		{
+			name:   "condition",
+			policy: `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*","Condition":{"IpAddress":{"aws:SourceIp":"10.0.0.0/8"}}}]}`,
+		},
+		{
+			name:   "malformed",
+			policy: `{"Version":"2012-10-17","Statement":[`,
+		},
+	} {
+		_, err := ParseBucketPolicy(strings.NewReader(tt.policy), "bucket")
+		if tt.valid {
+			require.NoError(t, err, tt.name)
+		} else {
+			require.Error(t, err, tt.name)
+		}
+	}
+}
+
+func TestBucketPolicyAllows(t *testing.T) {
+	p, err := ParseBucketPolicy(strings.NewReader(`{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/public/*"},
+			{"Effect": "Allow", "Principal": {"AWS": ["otherkey"]}, "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"},
+			{"Effect": "Deny", "Principal": {"AWS": ["readonlykey"]}, "Action": ["s3:PutObject", "s3:DeleteObject"], "Resource": "arn:aws:s3:::bucket/*"}
+		]
+	}`), "bucket")
+	require.NoError(t, err)
+
+	require.True(t, bucketPolicyAllows(p, "", policy.GetObjectAction, "bucket", "public/object"))
+	require.False(t, bucketPolicyAllows(p, "", policy.GetObjectAction, "bucket", "private/object"))
+	require.False(t, bucketPolicyAllows(p, "", policy.PutObjectAction, "bucket", "public/object"))
+
+	require.True(t, bucketPolicyAllows(p, "readonlykey", policy.GetObjectAction, "bucket", "private/object"))
+	require.False(t, bucketPolicyAllows(p, "readonlykey", policy.PutObjectAction, "bucket", "private/object"))
+	require.False(t, bucketPolicyAllows(p, "readonlykey", policy.DeleteObjectAction, "bucket", "public/object"))
+
+	// without a Deny statement, requests are allowed by their access grant.
+	require.True(t, bucketPolicyAllows(p, "otherkey", policy.DeleteObjectAction, "bucket", "private/object"))
+	require.True(t, bucketPolicyAllows(p, "anykey", policy.PutObjectAction, "bucket", "private/object"))
+}
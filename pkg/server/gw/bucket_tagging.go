@@ -0,0 +1,78 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+
+	"github.com/minio/minio-go/v7/pkg/tags"
+	"github.com/zeebo/errs"
+
+	minio "storj.io/minio/cmd"
+)
+
+// taggingConfigName is the name of the bucket configuration document holding
+// the tags of the bucket.
+const taggingConfigName = "tagging.xml"
+
+// GetBucketTagging returns the tags of bucket.
+func (l *MultiTenancyLayer) GetBucketTagging(ctx context.Context, bucket string) (_ *tags.Tags, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	data, err := l.getBucketConfig(ctx, project, bucket, taggingConfigName)
+	if err != nil {
+		return nil, l.log(ctx, err)
+	}
+	if data == nil {
+		return nil, l.log(ctx, minio.BucketTaggingNotFound{Bucket: bucket})
+	}
+
+	t, err := tags.ParseBucketXML(bytes.NewReader(data))
+	if err != nil {
+		return nil, l.log(ctx, ErrBucketConfig.Wrap(err))
+	}
+	return t, nil
+}
+
+// SetBucketTagging sets the tags of bucket.
+func (l *MultiTenancyLayer) SetBucketTagging(ctx context.Context, bucket string, t *tags.Tags) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	data, err := xml.Marshal(t)
+	if err != nil {
+		return l.log(ctx, ErrBucketConfig.Wrap(err))
+	}
+
+	return l.log(ctx, l.putBucketConfig(ctx, project, bucket, taggingConfigName, data))
+}
+
+// DeleteBucketTagging removes the tags of bucket.
+func (l *MultiTenancyLayer) DeleteBucketTagging(ctx context.Context, bucket string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	return l.log(ctx, l.deleteBucketConfig(ctx, project, bucket, taggingConfigName))
+}
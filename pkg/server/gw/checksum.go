@@ -0,0 +1,297 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"context"
+	"crypto/sha1" //nolint: gosec // SHA-1 is one of the checksums S3 supports.
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	miniogo "github.com/minio/minio-go/v7"
+)
+
+// ChecksumAlgorithm is an algorithm of the x-amz-checksum-* headers.
+type ChecksumAlgorithm string
+
+// Checksum algorithms supported by S3.
+const (
+	ChecksumCRC32  ChecksumAlgorithm = "CRC32"
+	ChecksumCRC32C ChecksumAlgorithm = "CRC32C"
+	ChecksumSHA1   ChecksumAlgorithm = "SHA1"
+	ChecksumSHA256 ChecksumAlgorithm = "SHA256"
+)
+
+// checksumAlgorithms are the supported checksum algorithms.
+var checksumAlgorithms = []ChecksumAlgorithm{ChecksumCRC32, ChecksumCRC32C, ChecksumSHA1, ChecksumSHA256}
+
+// checksumKeyPrefix is the prefix of the headers (and the object metadata
+// keys) holding checksums.
+const checksumKeyPrefix = "x-amz-checksum-"
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrInvalidChecksum occurs when a checksum header can't be parsed.
+	ErrInvalidChecksum = miniogo.ErrorResponse{
+		Code:       "InvalidRequest",
+		StatusCode: http.StatusBadRequest,
+		Message:    "Value for x-amz-checksum header is invalid.",
+	}
+
+	// errMultipleChecksums occurs when a request has checksums of more than
+	// one algorithm.
+	errMultipleChecksums = miniogo.ErrorResponse{
+		Code:       "InvalidRequest",
+		StatusCode: http.StatusBadRequest,
+		Message:    "Expecting a single x-amz-checksum- header. Multiple checksum types are not allowed.",
+	}
+
+	// ErrChecksumMismatch occurs when the checksum of an uploaded object (or
+	// part) doesn't match the checksum sent with it.
+	ErrChecksumMismatch = miniogo.ErrorResponse{
+		Code:       "BadDigest",
+		StatusCode: http.StatusBadRequest,
+		Message:    "The checksum you specified did not match the calculated checksum.",
+	}
+)
+
+// Key returns the name of the header and the object metadata key holding
+// checksums of algorithm a.
+func (a ChecksumAlgorithm) Key() string {
+	return checksumKeyPrefix + strings.ToLower(string(a))
+}
+
+// New returns a new hash computing checksums of algorithm a.
+func (a ChecksumAlgorithm) New() hash.Hash {
+	switch a {
+	case ChecksumCRC32:
+		return crc32.NewIEEE()
+	case ChecksumCRC32C:
+		return crc32.New(crc32cTable)
+	case ChecksumSHA1:
+		return sha1.New() //nolint: gosec // SHA-1 is one of the checksums S3 supports.
+	default:
+		return sha256.New()
+	}
+}
+
+// ParseChecksumAlgorithm parses the value of an x-amz-sdk-checksum-algorithm
+// or x-amz-checksum-algorithm header.
+func ParseChecksumAlgorithm(value string) (ChecksumAlgorithm, error) {
+	for _, algorithm := range checksumAlgorithms {
+		if strings.EqualFold(value, string(algorithm)) {
+			return algorithm, nil
+		}
+	}
+	return "", ErrInvalidChecksum
+}
+
+// Checksum is a checksum sent with an upload.
+type Checksum struct {
+	Algorithm ChecksumAlgorithm
+	Value     string
+
+	// trailer is the trailer of the request if the checksum is sent in it,
+	// in which case Value is only set once the body is read.
+	trailer http.Header
+	// metadata is the metadata of the object being uploaded, which the
+	// checksum is added to once it's read from the trailer and verified.
+	metadata map[string]string
+}
+
+// ParseChecksum returns the checksum in h or nil if there's none.
+func ParseChecksum(h http.Header) (*Checksum, error) {
+	var checksum *Checksum
+	for _, algorithm := range checksumAlgorithms {
+		value := h.Get(algorithm.Key())
+		if value == "" {
+			continue
+		}
+		if checksum != nil {
+			return nil, errMultipleChecksums
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(decoded) != algorithm.New().Size() {
+			return nil, ErrInvalidChecksum
+		}
+		checksum = &Checksum{Algorithm: algorithm, Value: value}
+	}
+
+	if sdkAlgorithm := h.Get("X-Amz-Sdk-Checksum-Algorithm"); sdkAlgorithm != "" {
+		algorithm, err := ParseChecksumAlgorithm(sdkAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		if checksum != nil && checksum.Algorithm != algorithm {
+			return nil, ErrInvalidChecksum
+		}
+	}
+
+	return checksum, nil
+}
+
+// ParseTrailingChecksum returns the checksum declared in trailer, the
+// trailer of a request, to be sent after the body, or nil if there's none.
+func ParseTrailingChecksum(trailer http.Header) (*Checksum, error) {
+	var checksum *Checksum
+	for _, algorithm := range checksumAlgorithms {
+		if _, ok := trailer[http.CanonicalHeaderKey(algorithm.Key())]; !ok {
+			continue
+		}
+		if checksum != nil {
+			return nil, errMultipleChecksums
+		}
+		checksum = &Checksum{Algorithm: algorithm, trailer: trailer}
+	}
+	return checksum, nil
+}
+
+// Reader returns a reader reading from r, which is expected to have size bytes
+// (or -1 if it's unknown), that fails with ErrChecksumMismatch once they're
+// read unless their checksum is c.
+//
+// The checksum is verified as soon as size bytes are read, as minio stops
+// reading there without waiting for io.EOF. A checksum sent in the trailer
+// has to be in it by then.
+func (c *Checksum) Reader(r io.Reader, size int64) io.Reader {
+	return &checksumReader{
+		r:         r,
+		checksum:  c,
+		hash:      c.Algorithm.New(),
+		remaining: size,
+	}
+}
+
+type checksumReader struct {
+	r         io.Reader
+	checksum  *Checksum
+	hash      hash.Hash
+	remaining int64
+	verified  bool
+}
+
+func (r *checksumReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	_, _ = r.hash.Write(p[:n])
+	r.remaining -= int64(n)
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, err
+	}
+	if !r.verified && (r.remaining == 0 || errors.Is(err, io.EOF)) {
+		r.verified = true
+		if err := r.checksum.verify(r.hash.Sum(nil)); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+// verify returns ErrChecksumMismatch unless sum is the checksum c, whose
+// value is taken from the trailer if it's sent in it.
+func (c *Checksum) verify(sum []byte) error {
+	if c.trailer != nil {
+		value := c.trailer.Get(c.Algorithm.Key())
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(decoded) != len(sum) {
+			return ErrInvalidChecksum
+		}
+		c.Value = value
+	}
+
+	if base64.StdEncoding.EncodeToString(sum) != c.Value {
+		return ErrChecksumMismatch
+	}
+	if c.trailer != nil && c.metadata != nil {
+		c.metadata[c.Algorithm.Key()] = c.Value
+	}
+	return nil
+}
+
+// CompositeChecksum returns the checksum of a multipart upload, which is the
+// checksum of the checksums of its parts followed by the number of parts.
+func CompositeChecksum(algorithm ChecksumAlgorithm, parts []string) (*Checksum, error) {
+	h := algorithm.New()
+	for _, part := range parts {
+		decoded, err := base64.StdEncoding.DecodeString(part)
+		if err != nil || len(decoded) != h.Size() {
+			return nil, ErrInvalidChecksum
+		}
+		_, _ = h.Write(decoded)
+	}
+	return &Checksum{
+		Algorithm: algorithm,
+		Value:     base64.StdEncoding.EncodeToString(h.Sum(nil)) + "-" + strconv.Itoa(len(parts)),
+	}, nil
+}
+
+type checksumKey struct{}
+
+// WithChecksum returns ctx with the checksum of the object being uploaded
+// for PutObject and CompleteMultipartUpload to store.
+func WithChecksum(ctx context.Context, checksum *Checksum) context.Context {
+	return context.WithValue(ctx, checksumKey{}, checksum)
+}
+
+type checksumModeKey struct{}
+
+// WithChecksumMode returns ctx marking whether GetObjectNInfo and
+// GetObjectInfo should return the stored checksum of the object, as requested
+// by the x-amz-checksum-mode header in h.
+func WithChecksumMode(ctx context.Context, h http.Header) context.Context {
+	if !strings.EqualFold(h.Get("X-Amz-Checksum-Mode"), "ENABLED") {
+		return ctx
+	}
+	return context.WithValue(ctx, checksumModeKey{}, true)
+}
+
+// addChecksum adds the checksum in ctx (if any) to metadata and returns
+// whether there was one. Checksums sent in the trailer of the request are
+// left to addTrailingChecksum.
+func addChecksum(ctx context.Context, metadata map[string]string) bool {
+	checksum, ok := ctx.Value(checksumKey{}).(*Checksum)
+	if !ok || checksum == nil || checksum.trailer != nil {
+		return false
+	}
+	metadata[checksum.Algorithm.Key()] = checksum.Value
+	return true
+}
+
+// addTrailingChecksum makes the checksum in ctx be added to metadata once
+// it's read from the trailer of the request and verified, if it's sent in it.
+// That happens as the last of the body is read, so before the object is
+// committed with metadata.
+func addTrailingChecksum(ctx context.Context, metadata map[string]string) {
+	checksum, ok := ctx.Value(checksumKey{}).(*Checksum)
+	if !ok || checksum == nil || checksum.trailer == nil {
+		return
+	}
+	checksum.metadata = metadata
+}
+
+// filterChecksums returns metadata without the stored checksums of the
+// object, unless ctx requests them and whole is true (checksums aren't
+// returned for ranges).
+func filterChecksums(ctx context.Context, metadata map[string]string, whole bool) map[string]string {
+	if enabled, _ := ctx.Value(checksumModeKey{}).(bool); enabled && whole {
+		return metadata
+	}
+
+	filtered := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if !strings.HasPrefix(k, checksumKeyPrefix) {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
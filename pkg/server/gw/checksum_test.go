@@ -0,0 +1,165 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksum(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		headers  map[string]string
+		checksum *Checksum
+		valid    bool
+	}{
+		{
+			name:  "none",
+			valid: true,
+		},
+		{
+			name:     "crc32",
+			headers:  map[string]string{"X-Amz-Checksum-Crc32": "DUoRhQ=="},
+			checksum: &Checksum{Algorithm: ChecksumCRC32, Value: "DUoRhQ=="},
+			valid:    true,
+		},
+		{
+			name:     "sdk algorithm",
+			headers:  map[string]string{"X-Amz-Sdk-Checksum-Algorithm": "sha1", "X-Amz-Checksum-Sha1": "Kq5sNclPz7QV2+lfQIuc6R7oRu0="},
+			checksum: &Checksum{Algorithm: ChecksumSHA1, Value: "Kq5sNclPz7QV2+lfQIuc6R7oRu0="},
+			valid:    true,
+		},
+		{
+			name:    "sdk algorithm mismatch",
+			headers: map[string]string{"X-Amz-Sdk-Checksum-Algorithm": "CRC32C", "X-Amz-Checksum-Sha1": "Kq5sNclPz7QV2+lfQIuc6R7oRu0="},
+		},
+		{
+			name:    "unknown sdk algorithm",
+			headers: map[string]string{"X-Amz-Sdk-Checksum-Algorithm": "MD5"},
+		},
+		{
+			name:    "wrong length",
+			headers: map[string]string{"X-Amz-Checksum-Sha256": "DUoRhQ=="},
+		},
+		{
+			name:    "not base64",
+			headers: map[string]string{"X-Amz-Checksum-Crc32": "0d4a1185"},
+		},
+		{
+			name:    "multiple",
+			headers: map[string]string{"X-Amz-Checksum-Crc32": "DUoRhQ==", "X-Amz-Checksum-Crc32c": "yZRlqg=="},
+		},
+	} {
+		h := http.Header{}
+		for k, v := range tt.headers {
+			h.Set(k, v)
+		}
+
+		checksum, err := ParseChecksum(h)
+		if !tt.valid {
+			require.Error(t, err, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		require.Equal(t, tt.checksum, checksum, tt.name)
+	}
+}
+
+func TestParseTrailingChecksum(t *testing.T) {
+	checksum, err := ParseTrailingChecksum(nil)
+	require.NoError(t, err)
+	require.Nil(t, checksum)
+
+	trailer := http.Header{"X-Amz-Checksum-Crc32c": nil}
+	checksum, err = ParseTrailingChecksum(trailer)
+	require.NoError(t, err)
+	require.Equal(t, &Checksum{Algorithm: ChecksumCRC32C, trailer: trailer}, checksum)
+
+	// the checksum is only stored once its value is read from the trailer and
+	// verified, as the last of the body is read.
+	metadata := map[string]string{}
+	ctx := WithChecksum(context.Background(), checksum)
+	require.False(t, addChecksum(ctx, metadata))
+	addTrailingChecksum(ctx, metadata)
+	require.Empty(t, metadata)
+
+	reader := checksum.Reader(strings.NewReader("hello world"), 11)
+	_, err = reader.Read(make([]byte, 5))
+	require.NoError(t, err)
+	require.Empty(t, metadata)
+
+	trailer.Set("X-Amz-Checksum-Crc32c", "yZRlqg==")
+	_, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"x-amz-checksum-crc32c": "yZRlqg=="}, metadata)
+
+	// mismatching checksums aren't stored.
+	trailer = http.Header{"X-Amz-Checksum-Crc32c": nil}
+	checksum, err = ParseTrailingChecksum(trailer)
+	require.NoError(t, err)
+	metadata = map[string]string{}
+	addTrailingChecksum(WithChecksum(context.Background(), checksum), metadata)
+	trailer.Set("X-Amz-Checksum-Crc32c", "yZRlqg==")
+	_, err = io.ReadAll(checksum.Reader(strings.NewReader("hello there"), 11))
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+	require.Empty(t, metadata)
+
+	_, err = ParseTrailingChecksum(http.Header{"X-Amz-Checksum-Crc32": nil, "X-Amz-Checksum-Sha1": nil})
+	require.Error(t, err)
+}
+
+func TestChecksumReader(t *testing.T) {
+	for _, checksum := range []*Checksum{
+		{Algorithm: ChecksumCRC32, Value: "DUoRhQ=="},
+		{Algorithm: ChecksumCRC32C, Value: "yZRlqg=="},
+		{Algorithm: ChecksumSHA1, Value: "Kq5sNclPz7QV2+lfQIuc6R7oRu0="},
+		{Algorithm: ChecksumSHA256, Value: "uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek="},
+	} {
+		for _, size := range []int64{11, -1} {
+			data, err := io.ReadAll(checksum.Reader(strings.NewReader("hello world"), size))
+			require.NoError(t, err, checksum.Algorithm)
+			require.Equal(t, "hello world", string(data))
+
+			_, err = io.ReadAll(checksum.Reader(strings.NewReader("hello there"), size))
+			require.ErrorIs(t, err, ErrChecksumMismatch, checksum.Algorithm)
+		}
+	}
+
+	// minio stops reading once it has read the declared size.
+	checksum := &Checksum{Algorithm: ChecksumCRC32, Value: "DUoRhQ=="}
+	_, err := io.ReadAll(io.LimitReader(checksum.Reader(strings.NewReader("hello there"), 11), 11))
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestCompositeChecksum(t *testing.T) {
+	checksum, err := CompositeChecksum(ChecksumCRC32, []string{"DUoRhQ==", "DUoRhQ=="})
+	require.NoError(t, err)
+	require.Equal(t, ChecksumCRC32, checksum.Algorithm)
+	require.True(t, strings.HasSuffix(checksum.Value, "-2"), checksum.Value)
+
+	_, err = CompositeChecksum(ChecksumSHA256, []string{"DUoRhQ=="})
+	require.Error(t, err)
+}
+
+func TestFilterChecksums(t *testing.T) {
+	metadata := map[string]string{
+		"content-type":         "text/plain",
+		"x-amz-checksum-crc32": "DUoRhQ==",
+	}
+
+	ctx := context.Background()
+	require.Equal(t, map[string]string{"content-type": "text/plain"}, filterChecksums(ctx, metadata, true))
+
+	h := http.Header{}
+	h.Set("X-Amz-Checksum-Mode", "ENABLED")
+	ctx = WithChecksumMode(ctx, h)
+	require.Equal(t, metadata, filterChecksums(ctx, metadata, true))
+	require.Equal(t, map[string]string{"content-type": "text/plain"}, filterChecksums(ctx, metadata, false))
+}
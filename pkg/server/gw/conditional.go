@@ -0,0 +1,25 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"net/http"
+
+	minio "storj.io/minio/cmd"
+)
+
+// CheckWritePreconditions returns NotImplemented if h has write preconditions
+// (If-Match or If-None-Match headers) of a PutObject or
+// CompleteMultipartUpload request.
+//
+// The network can't commit an object on the condition of what it replaces, and
+// checking the condition before committing wouldn't stop another writer (e.g.
+// through another instance of the gateway) from committing in between, so
+// conditional writes are refused rather than not be honored.
+func CheckWritePreconditions(h http.Header) error {
+	if h.Get("If-Match") != "" || h.Get("If-None-Match") != "" {
+		return minio.NotImplemented{Message: "Conditional writes (If-Match and If-None-Match) are not supported"}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	minio "storj.io/minio/cmd"
+)
+
+func TestCheckWritePreconditions(t *testing.T) {
+	require.NoError(t, CheckWritePreconditions(http.Header{}))
+
+	for _, name := range []string{"If-Match", "If-None-Match"} {
+		h := http.Header{}
+		h.Set(name, "*")
+		require.IsType(t, minio.NotImplemented{}, CheckWritePreconditions(h), name)
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/zeebo/errs"
+
+	"storj.io/minio/pkg/wildcard"
+	"storj.io/uplink"
+)
+
+// corsConfigName is the name of the bucket configuration document holding the
+// CORS configuration.
+const corsConfigName = "cors.xml"
+
+// maxCORSRules is the maximum number of rules of a CORS configuration.
+const maxCORSRules = 100
+
+// ErrNoSuchCORSConfiguration occurs when a client attempts to get the CORS
+// configuration of a bucket that has none set.
+var ErrNoSuchCORSConfiguration = miniogo.ErrorResponse{
+	Code:       "NoSuchCORSConfiguration",
+	StatusCode: http.StatusNotFound,
+	Message:    "The CORS configuration does not exist.",
+}
+
+// corsMethods are the methods CORS rules can allow.
+var corsMethods = []string{http.MethodGet, http.MethodPut, http.MethodHead, http.MethodPost, http.MethodDelete}
+
+// CORSConfig is a bucket CORS configuration.
+type CORSConfig struct {
+	XMLName xml.Name   `xml:"CORSConfiguration"`
+	Rules   []CORSRule `xml:"CORSRule"`
+}
+
+// CORSRule is a rule of a bucket CORS configuration.
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  int      `xml:"MaxAgeSeconds,omitempty"`
+}
+
+// invalidCORSConfig returns an error for an invalid CORS configuration.
+func invalidCORSConfig(format string, args ...interface{}) error {
+	return miniogo.ErrorResponse{
+		Code:       "MalformedXML",
+		StatusCode: http.StatusBadRequest,
+		Message:    fmt.Sprintf(format, args...),
+	}
+}
+
+// ParseCORSConfig parses and validates a CORS configuration.
+func ParseCORSConfig(r io.Reader) (*CORSConfig, error) {
+	var config CORSConfig
+	if err := xml.NewDecoder(r).Decode(&config); err != nil {
+		return nil, err
+	}
+
+	if len(config.Rules) == 0 {
+		return nil, invalidCORSConfig("CORS configuration should have at least one rule")
+	}
+	if len(config.Rules) > maxCORSRules {
+		return nil, invalidCORSConfig("CORS configuration allows a maximum of %d rules", maxCORSRules)
+	}
+
+	for _, rule := range config.Rules {
+		if len(rule.AllowedOrigins) == 0 || len(rule.AllowedMethods) == 0 {
+			return nil, invalidCORSConfig("CORS rules must have at least one AllowedOrigin and AllowedMethod")
+		}
+		for _, origin := range rule.AllowedOrigins {
+			if strings.Count(origin, "*") > 1 {
+				return nil, invalidCORSConfig("AllowedOrigin %q can not have more than one wildcard", origin)
+			}
+		}
+		for _, header := range rule.AllowedHeaders {
+			if strings.Count(header, "*") > 1 {
+				return nil, invalidCORSConfig("AllowedHeader %q can not have more than one wildcard", header)
+			}
+		}
+		for _, method := range rule.AllowedMethods {
+			if !containsString(corsMethods, method) {
+				return nil, miniogo.ErrorResponse{
+					Code:       "InvalidRequest",
+					StatusCode: http.StatusBadRequest,
+					Message:    "Found unsupported HTTP method in CORS config. Unsupported method is " + method,
+				}
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+// Match returns the first rule allowing a request from origin using method
+// and sending headers, or nil if no rule allows it.
+func (config *CORSConfig) Match(origin, method string, headers []string) *CORSRule {
+	for i, rule := range config.Rules {
+		if rule.allows(origin, method, headers) {
+			return &config.Rules[i]
+		}
+	}
+	return nil
+}
+
+func (rule *CORSRule) allows(origin, method string, headers []string) bool {
+	if !containsString(rule.AllowedMethods, method) {
+		return false
+	}
+
+	allowedOrigin := false
+	for _, allowed := range rule.AllowedOrigins {
+		if wildcard.MatchSimple(allowed, origin) {
+			allowedOrigin = true
+			break
+		}
+	}
+	if !allowedOrigin {
+		return false
+	}
+
+	for _, header := range headers {
+		allowedHeader := false
+		for _, allowed := range rule.AllowedHeaders {
+			if wildcard.MatchSimple(strings.ToLower(allowed), strings.ToLower(header)) {
+				allowedHeader = true
+				break
+			}
+		}
+		if !allowedHeader {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// corsConfigSet is the set of CORS configurations of buckets with the same
+// name, keyed by the access grant they were read with.
+type corsConfigSet struct {
+	mu      sync.Mutex
+	configs map[string]*CORSConfig
+}
+
+// GetBucketCORSConfig returns the CORS configuration of bucket.
+func (l *MultiTenancyLayer) GetBucketCORSConfig(ctx context.Context, bucket string) (_ *CORSConfig, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	config, err := l.bucketCORSConfig(ctx, project, bucket)
+	if err != nil {
+		return nil, l.log(ctx, err)
+	}
+
+	l.rememberCORSConfig(ctx, bucket, config)
+
+	if config == nil {
+		return nil, l.log(ctx, ErrNoSuchCORSConfiguration)
+	}
+	return config, nil
+}
+
+// SetBucketCORSConfig sets the CORS configuration of bucket.
+func (l *MultiTenancyLayer) SetBucketCORSConfig(ctx context.Context, bucket string, config *CORSConfig) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	data, err := xml.Marshal(config)
+	if err != nil {
+		return l.log(ctx, ErrBucketConfig.Wrap(err))
+	}
+
+	if err = l.putBucketConfig(ctx, project, bucket, corsConfigName, data); err != nil {
+		return l.log(ctx, err)
+	}
+
+	l.rememberCORSConfig(ctx, bucket, config)
+	return nil
+}
+
+// DeleteBucketCORSConfig removes the CORS configuration of bucket.
+func (l *MultiTenancyLayer) DeleteBucketCORSConfig(ctx context.Context, bucket string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.deleteBucketConfig(ctx, project, bucket, corsConfigName); err != nil {
+		return l.log(ctx, err)
+	}
+
+	l.rememberCORSConfig(ctx, bucket, nil)
+	return nil
+}
+
+// PreflightCORSConfigs returns the CORS configurations of buckets named
+// bucket this instance has seen.
+//
+// Preflight requests carry no credentials, so there's no way to tell which
+// project's bucket they're for or to read its configuration, which is
+// encrypted. Instead, they're checked against the configurations of all
+// buckets with that name read or set through this instance. The actual
+// request that follows is checked against the configuration of its bucket.
+func (l *MultiTenancyLayer) PreflightCORSConfigs(bucket string) []*CORSConfig {
+	value, cached := l.corsConfigs.GetCached(bucket)
+	if !cached {
+		return nil
+	}
+
+	set := value.(*corsConfigSet)
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	configs := make([]*CORSConfig, 0, len(set.configs))
+	for _, config := range set.configs {
+		configs = append(configs, config)
+	}
+	return configs
+}
+
+// rememberCORSConfig records config as the CORS configuration of bucket as
+// seen with the access grant in ctx for PreflightCORSConfigs.
+func (l *MultiTenancyLayer) rememberCORSConfig(ctx context.Context, bucket string, config *CORSConfig) {
+	value, err := l.corsConfigs.Get(bucket, func() (interface{}, error) {
+		return &corsConfigSet{configs: make(map[string]*CORSConfig)}, nil
+	})
+	if err != nil {
+		return
+	}
+
+	set := value.(*corsConfigSet)
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	key := projectCacheKey(getAccessGrant(ctx), "")
+	if config == nil {
+		delete(set.configs, key)
+	} else {
+		set.configs[key] = config
+	}
+}
+
+// bucketCORSConfig returns the CORS configuration of bucket or nil if it isn't
+// set.
+func (l *MultiTenancyLayer) bucketCORSConfig(ctx context.Context, project *uplink.Project, bucket string) (*CORSConfig, error) {
+	data, err := l.getBucketConfig(ctx, project, bucket, corsConfigName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	config, err := ParseCORSConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrBucketConfig.Wrap(err)
+	}
+	return config, nil
+}
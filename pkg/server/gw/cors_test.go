@@ -0,0 +1,89 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCORSConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		rules string
+		valid bool
+	}{
+		{
+			name:  "valid",
+			rules: `<CORSRule><AllowedOrigin>https://*.example.com</AllowedOrigin><AllowedMethod>GET</AllowedMethod><AllowedHeader>*</AllowedHeader><MaxAgeSeconds>60</MaxAgeSeconds></CORSRule>`,
+			valid: true,
+		},
+		{
+			name:  "no rules",
+			rules: ``,
+		},
+		{
+			name:  "no origin",
+			rules: `<CORSRule><AllowedMethod>GET</AllowedMethod></CORSRule>`,
+		},
+		{
+			name:  "no method",
+			rules: `<CORSRule><AllowedOrigin>*</AllowedOrigin></CORSRule>`,
+		},
+		{
+			name:  "unsupported method",
+			rules: `<CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>PATCH</AllowedMethod></CORSRule>`,
+		},
+		{
+			name:  "two wildcards",
+			rules: `<CORSRule><AllowedOrigin>https://*.*.example.com</AllowedOrigin><AllowedMethod>GET</AllowedMethod></CORSRule>`,
+		},
+	} {
+		config, err := ParseCORSConfig(strings.NewReader("<CORSConfiguration>" + tt.rules + "</CORSConfiguration>"))
+		if !tt.valid {
+			require.Error(t, err, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+
+		data, err := xml.Marshal(config)
+		require.NoError(t, err)
+
+		roundTripped, err := ParseCORSConfig(strings.NewReader(string(data)))
+		require.NoError(t, err)
+		require.Equal(t, config, roundTripped)
+	}
+
+	_, err := ParseCORSConfig(strings.NewReader("<CORSConfiguration><CORSRule>"))
+	require.Error(t, err)
+}
+
+func TestCORSConfigMatch(t *testing.T) {
+	config := &CORSConfig{
+		Rules: []CORSRule{
+			{
+				ID:             "read",
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{http.MethodGet, http.MethodHead},
+			},
+			{
+				ID:             "write",
+				AllowedOrigins: []string{"https://*.example.com"},
+				AllowedMethods: []string{http.MethodPut, http.MethodGet},
+				AllowedHeaders: []string{"Content-Type", "x-amz-*"},
+			},
+		},
+	}
+
+	require.Equal(t, "read", config.Match("https://other.com", http.MethodGet, nil).ID)
+	require.Nil(t, config.Match("https://other.com", http.MethodPut, nil))
+	require.Equal(t, "write", config.Match("https://app.example.com", http.MethodPut, nil).ID)
+	require.Equal(t, "write", config.Match("https://app.example.com", http.MethodGet, []string{"content-type", "X-Amz-Date"}).ID)
+	require.Nil(t, config.Match("https://app.example.com", http.MethodPut, []string{"Authorization"}))
+	require.Nil(t, config.Match("https://app.example.com", http.MethodDelete, nil))
+}
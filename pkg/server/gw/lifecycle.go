@@ -0,0 +1,327 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/gateway/miniogw"
+	minio "storj.io/minio/cmd"
+	xhttp "storj.io/minio/cmd/http"
+	"storj.io/minio/pkg/bucket/lifecycle"
+	"storj.io/uplink"
+)
+
+const (
+	// lifecycleConfigName is the name of the bucket configuration document
+	// holding the lifecycle configuration.
+	lifecycleConfigName = "lifecycle.xml"
+
+	// objectExpiresKey is the metadata key storj.io/gateway reads the
+	// expiration time of new objects from.
+	objectExpiresKey = "X-Amz-Meta-Storj-Expires"
+)
+
+var (
+	errLifecycleTransition = lifecycle.Errorf("Transition actions are not supported")
+
+	errLifecycleNoncurrentVersion = lifecycle.Errorf("NoncurrentVersion actions are not supported without versioning")
+
+	errLifecycleAbortWithTags = lifecycle.Errorf("AbortIncompleteMultipartUpload cannot be specified with Tags")
+
+	errLifecycleInvalidDaysAfterInitiation = lifecycle.Errorf("DaysAfterInitiation must be a positive integer")
+)
+
+// abortOnlyExpiration stands in for the expiration of rules that only abort
+// incomplete multipart uploads when validating them, as minio's lifecycle
+// package requires every rule to have an action it knows.
+var abortOnlyExpiration = func() (expiration lifecycle.Expiration) {
+	if err := xml.Unmarshal([]byte("<Expiration><Days>1</Days></Expiration>"), &expiration); err != nil {
+		panic(err)
+	}
+	return expiration
+}()
+
+// LifecycleConfig is a bucket lifecycle configuration.
+//
+// Expiration rules are enforced by setting the expiration time of new objects
+// when they are uploaded, so changing the configuration doesn't affect
+// existing objects. AbortIncompleteMultipartUpload rules are enforced when
+// multipart uploads are started or listed.
+type LifecycleConfig struct {
+	lifecycle                       lifecycle.Lifecycle
+	abortIncompleteMultipartUploads []abortIncompleteMultipartUploadRule
+
+	data []byte
+}
+
+// abortIncompleteMultipartUploadRule is an enabled rule aborting multipart
+// uploads under prefix days after they were started.
+type abortIncompleteMultipartUploadRule struct {
+	prefix string
+	days   int
+}
+
+// lifecycleAbortActions holds the AbortIncompleteMultipartUpload actions of
+// the rules of a lifecycle configuration, which minio's lifecycle package
+// ignores.
+type lifecycleAbortActions struct {
+	Rules []struct {
+		AbortIncompleteMultipartUpload *struct {
+			DaysAfterInitiation int `xml:"DaysAfterInitiation"`
+		} `xml:"AbortIncompleteMultipartUpload"`
+	} `xml:"Rule"`
+}
+
+// ParseLifecycleConfig parses and validates a lifecycle configuration.
+func ParseLifecycleConfig(r io.Reader) (*LifecycleConfig, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lc, err := lifecycle.ParseLifecycleConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var actions lifecycleAbortActions
+	if err = xml.Unmarshal(data, &actions); err != nil {
+		return nil, err
+	}
+	if len(actions.Rules) != len(lc.Rules) {
+		return nil, lifecycle.Errorf("The XML you provided was not well-formed or did not validate against our published schema")
+	}
+
+	config := &LifecycleConfig{
+		lifecycle: *lc,
+		data:      data,
+	}
+
+	validated := lifecycle.Lifecycle{Rules: make([]lifecycle.Rule, len(lc.Rules))}
+	copy(validated.Rules, lc.Rules)
+
+	for i, rule := range lc.Rules {
+		if !rule.Transition.IsNull() {
+			return nil, errLifecycleTransition
+		}
+		if !rule.NoncurrentVersionExpiration.IsDaysNull() || !rule.NoncurrentVersionTransition.IsDaysNull() {
+			return nil, errLifecycleNoncurrentVersion
+		}
+
+		abort := actions.Rules[i].AbortIncompleteMultipartUpload
+		if abort == nil {
+			continue
+		}
+
+		if rule.Tags() != "" {
+			return nil, errLifecycleAbortWithTags
+		}
+		if abort.DaysAfterInitiation <= 0 {
+			return nil, errLifecycleInvalidDaysAfterInitiation
+		}
+		if rule.Expiration.IsNull() {
+			validated.Rules[i].Expiration = abortOnlyExpiration
+		}
+
+		if rule.Status == lifecycle.Enabled {
+			config.abortIncompleteMultipartUploads = append(config.abortIncompleteMultipartUploads, abortIncompleteMultipartUploadRule{
+				prefix: rule.GetPrefix(),
+				days:   abort.DaysAfterInitiation,
+			})
+		}
+	}
+
+	if err = validated.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Bytes returns the configuration document as it was set.
+func (config *LifecycleConfig) Bytes() []byte {
+	return config.data
+}
+
+// expires returns the time at which an object with the given name and tags
+// created at now expires, or the zero time if it doesn't. Rules whose
+// expiration date has already passed are ignored, as objects can't be
+// uploaded already expired.
+func (config *LifecycleConfig) expires(object, tags string, now time.Time) time.Time {
+	lc := lifecycle.Lifecycle{}
+	for _, rule := range config.lifecycle.Rules {
+		if rule.Expiration.IsDateNull() || rule.Expiration.Date.After(now) {
+			lc.Rules = append(lc.Rules, rule)
+		}
+	}
+
+	_, expires := lc.PredictExpiryTime(lifecycle.ObjectOpts{
+		Name:     object,
+		UserTags: tags,
+		ModTime:  now,
+		IsLatest: true,
+	})
+	return expires
+}
+
+// abortsUpload returns whether a multipart upload of object started at
+// created should be aborted at now.
+func (config *LifecycleConfig) abortsUpload(object string, created, now time.Time) bool {
+	for _, rule := range config.abortIncompleteMultipartUploads {
+		if strings.HasPrefix(object, rule.prefix) && !now.Before(lifecycle.ExpectedExpiryTime(created, rule.days)) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBucketLifecycleConfig returns the lifecycle configuration of bucket.
+func (l *MultiTenancyLayer) GetBucketLifecycleConfig(ctx context.Context, bucket string) (_ *LifecycleConfig, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	config, err := l.lifecycleConfig(ctx, project, bucket)
+	if err == nil && config == nil {
+		err = minio.BucketLifecycleNotFound{Bucket: bucket}
+	}
+	return config, l.log(ctx, err)
+}
+
+// SetBucketLifecycleConfig sets the lifecycle configuration of bucket.
+func (l *MultiTenancyLayer) SetBucketLifecycleConfig(ctx context.Context, bucket string, config *LifecycleConfig) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	return l.log(ctx, l.putBucketConfig(ctx, project, bucket, lifecycleConfigName, config.Bytes()))
+}
+
+// DeleteBucketLifecycleConfig removes the lifecycle configuration of bucket.
+func (l *MultiTenancyLayer) DeleteBucketLifecycleConfig(ctx context.Context, bucket string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	return l.log(ctx, l.deleteBucketConfig(ctx, project, bucket, lifecycleConfigName))
+}
+
+// lifecycleConfig returns the lifecycle configuration of bucket or nil if it
+// isn't set.
+func (l *MultiTenancyLayer) lifecycleConfig(ctx context.Context, project *uplink.Project, bucket string) (*LifecycleConfig, error) {
+	data, err := l.getBucketConfig(ctx, project, bucket, lifecycleConfigName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	config, err := ParseLifecycleConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrBucketConfig.Wrap(err)
+	}
+	return config, nil
+}
+
+// applyLifecycleExpiration sets the expiration time in the metadata of a new
+// object to the one the lifecycle configuration of bucket (if any) gives it,
+// unless the client asked for an earlier one.
+func (l *MultiTenancyLayer) applyLifecycleExpiration(ctx context.Context, project *uplink.Project, bucket, object string, metadata map[string]string) (err error) {
+	config, err := l.lifecycleConfig(ctx, project, bucket)
+	if err != nil || config == nil {
+		return err
+	}
+
+	tags, ok := metadata[xhttp.AmzObjectTagging]
+	if !ok {
+		tags = metadata["s3:tags"]
+	}
+
+	now := time.Now()
+
+	expires := config.expires(object, tags, now)
+	if expires.IsZero() {
+		return nil
+	}
+
+	if requested := metadata[objectExpiresKey]; requested != "" && requested != "none" {
+		// invalid expiration times are left for storj.io/gateway to reject.
+		t, err := parseExpiration(requested, now)
+		if err != nil || t.Before(expires) {
+			return nil
+		}
+	}
+
+	metadata[objectExpiresKey] = expires.UTC().Format(time.RFC3339)
+	return nil
+}
+
+// abortIncompleteMultipartUploads aborts the multipart uploads in bucket that
+// the lifecycle configuration of bucket (if any) expires.
+//
+// Uploads of a bucket are checked at most once per bucket configuration cache
+// expiration for each access grant, as it requires listing all of them.
+func (l *MultiTenancyLayer) abortIncompleteMultipartUploads(ctx context.Context, project *uplink.Project, bucket string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	config, err := l.lifecycleConfig(ctx, project, bucket)
+	if err != nil || config == nil || len(config.abortIncompleteMultipartUploads) == 0 {
+		return err
+	}
+
+	_, err = l.lifecycleSweeps.Get(l.bucketConfigCacheKey(ctx, bucket, lifecycleConfigName), func() (interface{}, error) {
+		now := time.Now()
+
+		it := project.ListUploads(ctx, bucket, &uplink.ListUploadsOptions{Recursive: true, System: true})
+		for it.Next() {
+			upload := it.Item()
+			if !config.abortsUpload(upload.Key, upload.System.Created, now) {
+				continue
+			}
+
+			if err := project.AbortUpload(ctx, bucket, upload.Key, upload.UploadID); err != nil && !errors.Is(err, uplink.ErrUploadIDInvalid) {
+				return nil, miniogw.ConvertError(err, bucket, upload.Key)
+			}
+			mon.Counter("lifecycle_aborted_uploads").Inc(1)
+		}
+		if err := it.Err(); err != nil {
+			return nil, miniogw.ConvertError(err, bucket, "")
+		}
+
+		return now, nil
+	})
+	return err
+}
+
+// parseExpiration parses an expiration time requested by the client the same
+// way storj.io/gateway does.
+func parseExpiration(value string, now time.Time) (time.Time, error) {
+	if strings.HasPrefix(value, "+") {
+		d, err := time.ParseDuration(value)
+		return now.Add(d), err
+	}
+	return time.Parse(time.RFC3339, value)
+}
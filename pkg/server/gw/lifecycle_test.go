@@ -0,0 +1,129 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/minio/pkg/bucket/lifecycle"
+)
+
+func parseLifecycleConfig(t *testing.T, rules string) *LifecycleConfig {
+	config, err := ParseLifecycleConfig(strings.NewReader("<LifecycleConfiguration>" + rules + "</LifecycleConfiguration>"))
+	require.NoError(t, err)
+	return config
+}
+
+func TestParseLifecycleConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		rules string
+		valid bool
+	}{
+		{
+			name:  "expiration days",
+			rules: `<Rule><Status>Enabled</Status><Filter><Prefix>logs/</Prefix></Filter><Expiration><Days>7</Days></Expiration></Rule>`,
+			valid: true,
+		},
+		{
+			name:  "expiration date with tags",
+			rules: `<Rule><Status>Enabled</Status><Filter><Tag><Key>k</Key><Value>v</Value></Tag></Filter><Expiration><Date>2030-01-01T00:00:00Z</Date></Expiration></Rule>`,
+			valid: true,
+		},
+		{
+			name:  "abort only",
+			rules: `<Rule><ID>abort</ID><Status>Enabled</Status><Prefix></Prefix><AbortIncompleteMultipartUpload><DaysAfterInitiation>1</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule>`,
+			valid: true,
+		},
+		{
+			name:  "no rules",
+			rules: ``,
+		},
+		{
+			name:  "no action",
+			rules: `<Rule><Status>Enabled</Status><Prefix></Prefix></Rule>`,
+		},
+		{
+			name:  "abort with tags",
+			rules: `<Rule><Status>Enabled</Status><Filter><Tag><Key>k</Key><Value>v</Value></Tag></Filter><AbortIncompleteMultipartUpload><DaysAfterInitiation>1</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule>`,
+		},
+		{
+			name:  "abort without days",
+			rules: `<Rule><Status>Enabled</Status><Prefix></Prefix><AbortIncompleteMultipartUpload></AbortIncompleteMultipartUpload></Rule>`,
+		},
+		{
+			name:  "transition",
+			rules: `<Rule><Status>Enabled</Status><Prefix></Prefix><Transition><Days>1</Days><StorageClass>GLACIER</StorageClass></Transition></Rule>`,
+		},
+	} {
+		data := "<LifecycleConfiguration>" + tt.rules + "</LifecycleConfiguration>"
+
+		config, err := ParseLifecycleConfig(strings.NewReader(data))
+		if !tt.valid {
+			require.Error(t, err, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		require.Equal(t, data, string(config.Bytes()), tt.name)
+	}
+
+	_, err := ParseLifecycleConfig(strings.NewReader("<LifecycleConfiguration><Rule>"))
+	require.Error(t, err)
+
+	_, err = ParseLifecycleConfig(strings.NewReader(`<LifecycleConfiguration><Rule><Status>Enabled</Status><Prefix></Prefix><Transition><Days>1</Days></Transition></Rule></LifecycleConfiguration>`))
+	require.ErrorAs(t, err, &lifecycle.Error{})
+}
+
+func TestLifecycleConfigExpires(t *testing.T) {
+	now := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	config := parseLifecycleConfig(t, ``+
+		`<Rule><ID>logs</ID><Status>Enabled</Status><Filter><Prefix>logs/</Prefix></Filter><Expiration><Days>7</Days></Expiration></Rule>`+
+		`<Rule><ID>tmp</ID><Status>Enabled</Status><Filter><Tag><Key>tmp</Key><Value>yes</Value></Tag></Filter><Expiration><Days>1</Days></Expiration></Rule>`+
+		`<Rule><ID>disabled</ID><Status>Disabled</Status><Prefix></Prefix><Expiration><Days>1</Days></Expiration></Rule>`+
+		`<Rule><ID>past</ID><Status>Enabled</Status><Filter><Prefix>old/</Prefix></Filter><Expiration><Date>2023-01-01T00:00:00Z</Date></Expiration></Rule>`+
+		`<Rule><ID>future</ID><Status>Enabled</Status><Filter><Prefix>new/</Prefix></Filter><Expiration><Date>2023-02-01T00:00:00Z</Date></Expiration></Rule>`,
+	)
+
+	require.Zero(t, config.expires("object", "", now))
+	require.Zero(t, config.expires("old/object", "", now))
+	require.Equal(t, time.Date(2023, 1, 18, 0, 0, 0, 0, time.UTC), config.expires("logs/object", "", now))
+	require.Equal(t, time.Date(2023, 1, 12, 0, 0, 0, 0, time.UTC), config.expires("object", "tmp=yes", now))
+	require.Equal(t, time.Date(2023, 1, 12, 0, 0, 0, 0, time.UTC), config.expires("logs/object", "a=b&tmp=yes", now))
+	require.Equal(t, time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), config.expires("new/object", "", now))
+}
+
+func TestLifecycleConfigAbortsUpload(t *testing.T) {
+	created := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	config := parseLifecycleConfig(t, ``+
+		`<Rule><ID>scratch</ID><Status>Enabled</Status><Filter><Prefix>scratch/</Prefix></Filter><AbortIncompleteMultipartUpload><DaysAfterInitiation>1</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule>`+
+		`<Rule><ID>disabled</ID><Status>Disabled</Status><Prefix></Prefix><AbortIncompleteMultipartUpload><DaysAfterInitiation>1</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule>`,
+	)
+
+	require.False(t, config.abortsUpload("scratch/object", created, created.Add(time.Hour)))
+	require.True(t, config.abortsUpload("scratch/object", created, created.Add(36*time.Hour)))
+	require.False(t, config.abortsUpload("object", created, created.Add(36*time.Hour)))
+
+	require.Zero(t, config.expires("scratch/object", "", created))
+}
+
+func TestParseExpiration(t *testing.T) {
+	now := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	expires, err := parseExpiration("+1h", now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(time.Hour), expires)
+
+	expires, err = parseExpiration("2023-01-11T00:00:00Z", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2023, 1, 11, 0, 0, 0, 0, time.UTC), expires)
+
+	_, err = parseExpiration("tomorrow", now)
+	require.Error(t, err)
+}
@@ -6,6 +6,7 @@ package gw
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"reflect"
 	"time"
@@ -16,25 +17,53 @@ import (
 	"github.com/zeebo/errs"
 
 	"storj.io/common/errs2"
+	"storj.io/common/lrucache"
 	"storj.io/common/rpc/rpcpool"
+	"storj.io/common/rpc/rpcstatus"
 	"storj.io/common/useragent"
+	"storj.io/gateway-mt/pkg/authclient"
+	"storj.io/gateway-mt/pkg/bucketconfig"
+	"storj.io/gateway-mt/pkg/notification"
 	"storj.io/gateway-mt/pkg/server/gwlog"
 	"storj.io/gateway-mt/pkg/server/middleware"
 	"storj.io/gateway/miniogw"
 	minio "storj.io/minio/cmd"
+	xhttp "storj.io/minio/cmd/http"
 	"storj.io/minio/cmd/logger"
 	"storj.io/minio/pkg/auth"
+	"storj.io/minio/pkg/bucket/policy"
+	"storj.io/minio/pkg/bucket/versioning"
+	"storj.io/minio/pkg/event"
 	"storj.io/private/version"
 	"storj.io/uplink"
 	"storj.io/uplink/private/bucket"
 	"storj.io/uplink/private/transport"
 )
 
+// nullVersionID is the version ID S3 uses for objects in unversioned buckets.
+const nullVersionID = "null"
+
 var (
 	mon = monkit.Package()
 
 	gatewayUserAgent = "Gateway-MT/" + version.Build.Version.String()
 
+	// ErrVersioningNotSupported occurs when a client attempts to change the
+	// versioning state of a bucket, which the network doesn't support yet.
+	ErrVersioningNotSupported = miniogo.ErrorResponse{
+		Code:       "NotImplemented",
+		StatusCode: http.StatusNotImplemented,
+		Message:    "Bucket versioning is not supported.",
+	}
+
+	// ErrObjectLockNotSupported occurs when a client attempts to use object
+	// lock, which the network can't enforce yet.
+	ErrObjectLockNotSupported = miniogo.ErrorResponse{
+		Code:       "NotImplemented",
+		StatusCode: http.StatusNotImplemented,
+		Message:    "Object Lock is not supported.",
+	}
+
 	// ErrAccessGrant occurs when failing to parse the access grant from the
 	// request.
 	ErrAccessGrant = errs.Class("access grant")
@@ -48,16 +77,52 @@ var (
 )
 
 // NewMultiTenantLayer initializes and returns new MultiTenancyLayer. A properly
-// closed object layer will also close connectionPool.
-func NewMultiTenantLayer(gateway minio.Gateway, connectionPool *rpcpool.Pool, config uplink.Config, insecureLogAll bool) (*MultiTenancyLayer, error) {
+// closed object layer will also close connectionPool, all cached projects and
+// bucketConfigDB, which keeps the configuration documents of buckets.
+//
+// Events about objects are published through notifier, which may be nil if no
+// notification targets are configured.
+//
+// Anonymous reads allowed by bucket policies are served with public accesses
+// registered with and resolved through authClient. They aren't served if it's
+// nil.
+func NewMultiTenantLayer(gateway minio.Gateway, connectionPool *rpcpool.Pool, config uplink.Config, projectCache ProjectCacheConfig, bucketConfigDB bucketconfig.DB, bucketConfigCache BucketConfigCacheConfig, objectCache ObjectCacheConfig, authClient *authclient.AuthClient, notifier *notification.Notifier, insecureLogAll bool) (*MultiTenancyLayer, error) {
 	layer, err := gateway.NewGatewayLayer(auth.Credentials{})
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := newObjectCache(objectCache)
+	if err != nil {
+		return nil, err
+	}
 
 	return &MultiTenancyLayer{
 		layer:          layer,
 		connectionPool: connectionPool,
+		projects:       newProjectCache(projectCache),
+		objects:        objects,
+		bucketConfigDB: bucketConfigDB,
+		bucketConfigs: lrucache.New(lrucache.Options{
+			Expiration: bucketConfigCache.Expiration,
+			Capacity:   bucketConfigCache.Capacity,
+		}),
+		lifecycleSweeps: lrucache.New(lrucache.Options{
+			Expiration: bucketConfigCache.Expiration,
+			Capacity:   bucketConfigCache.Capacity,
+		}),
+		corsConfigs: lrucache.New(lrucache.Options{
+			Capacity: bucketConfigCache.Capacity,
+		}),
+		authClient: authClient,
+		publicReads: lrucache.New(lrucache.Options{
+			Expiration: bucketConfigCache.Expiration,
+			Capacity:   bucketConfigCache.Capacity,
+		}),
+		notifier:       notifier,
 		config:         config,
 		insecureLogAll: insecureLogAll,
-	}, err
+	}, nil
 }
 
 // MultiTenancyLayer implements multi-tenant minio.ObjectLayer that logs
@@ -67,6 +132,30 @@ type MultiTenancyLayer struct {
 
 	layer          minio.ObjectLayer
 	connectionPool *rpcpool.Pool
+	projects       *projectCache
+	bucketConfigDB bucketconfig.DB
+	bucketConfigs  *lrucache.ExpiringLRU
+
+	// lifecycleSweeps holds the buckets whose incomplete multipart uploads
+	// were recently checked against their lifecycle configuration.
+	lifecycleSweeps *lrucache.ExpiringLRU
+
+	// corsConfigs holds the CORS configurations seen for each bucket name to
+	// check preflight requests with.
+	corsConfigs *lrucache.ExpiringLRU
+
+	// authClient registers and resolves the public accesses anonymous reads
+	// are served with, and publicReads holds the publicRead of each bucket
+	// name.
+	authClient  *authclient.AuthClient
+	publicReads *lrucache.ExpiringLRU
+
+	// objects holds the contents of small objects read through the gateway,
+	// if the object cache is enabled.
+	objects *objectCache
+
+	// notifier publishes bucket notification events, if configured.
+	notifier *notification.Notifier
 
 	config         uplink.Config
 	insecureLogAll bool
@@ -107,6 +196,12 @@ func (l *MultiTenancyLayer) log(ctx context.Context, err error) error {
 	// minio as much as we can help it.
 	if log, ok := gwlog.FromContext(ctx); ok {
 		copyReqInfo(log, reqInfo)
+
+		// minio doesn't see the credentials of requests, as their signature
+		// is verified before they reach it.
+		if credentials := middleware.GetAccess(ctx); credentials != nil {
+			log.AccessKey = credentials.AccessKey
+		}
 	}
 
 	return err
@@ -130,17 +225,17 @@ func copyReqInfo(dst *gwlog.Log, src *logger.ReqInfo) {
 
 // Shutdown is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).Shutdown.
 func (l *MultiTenancyLayer) Shutdown(ctx context.Context) error {
-	return l.log(ctx, l.connectionPool.Close())
+	return l.log(ctx, errs.Combine(l.projects.Close(), l.objects.Close(), l.bucketConfigDB.Close(), l.connectionPool.Close()))
 }
 
 // StorageInfo is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).StorageInfo.
 func (l *MultiTenancyLayer) StorageInfo(ctx context.Context) (minio.StorageInfo, []error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.StorageInfo{}, []error{err}
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	info, errors := l.layer.StorageInfo(miniogw.WithUplinkProject(ctx, project))
 
@@ -153,12 +248,18 @@ func (l *MultiTenancyLayer) StorageInfo(ctx context.Context) (minio.StorageInfo,
 
 // MakeBucketWithLocation is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).MakeBucketWithLocation.
 func (l *MultiTenancyLayer) MakeBucketWithLocation(ctx context.Context, bucket string, opts minio.BucketOptions) error {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	// the network can't keep objects from being deleted or overwritten yet,
+	// so buckets with object lock can't be created rather than not be locked.
+	if opts.LockEnabled {
+		return l.log(ctx, ErrObjectLockNotSupported)
+	}
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	return l.log(ctx, l.layer.MakeBucketWithLocation(miniogw.WithUplinkProject(ctx, project), bucket, opts))
 }
@@ -175,12 +276,12 @@ func (l *MultiTenancyLayer) GetBucketInfo(ctx context.Context, bucket string) (b
 		return minio.BucketInfo{}, minio.NotImplemented{Message: "GetBucketInfo (anonymous)"}
 	}
 
-	project, err := l.openProject(ctx, accessGrant)
+	project, release, err := l.openProject(ctx, accessGrant)
 	if err != nil {
 		return minio.BucketInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	bucketInfo, err = l.layer.GetBucketInfo(miniogw.WithUplinkProject(ctx, project), bucket)
 	return bucketInfo, l.log(ctx, err)
@@ -188,12 +289,12 @@ func (l *MultiTenancyLayer) GetBucketInfo(ctx context.Context, bucket string) (b
 
 // ListBuckets is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).ListBuckets.
 func (l *MultiTenancyLayer) ListBuckets(ctx context.Context) (buckets []minio.BucketInfo, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	buckets, err = l.layer.ListBuckets(miniogw.WithUplinkProject(ctx, project))
 	return buckets, l.log(ctx, err)
@@ -211,12 +312,12 @@ type BucketWithAttributionInfo struct {
 func (l *MultiTenancyLayer) ListBucketsWithAttribution(ctx context.Context) (buckets []BucketWithAttributionInfo, err error) {
 	defer mon.Task()(&ctx)(&err)
 
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	it := bucket.ListBucketsWithAttribution(ctx, project, nil)
 
@@ -233,24 +334,35 @@ func (l *MultiTenancyLayer) ListBucketsWithAttribution(ctx context.Context) (buc
 
 // DeleteBucket is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).DeleteBucket.
 func (l *MultiTenancyLayer) DeleteBucket(ctx context.Context, bucket string, forceDelete bool) error {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, "", policy.DeleteBucketAction); err != nil {
+		return l.log(ctx, err)
+	}
 
-	return l.log(ctx, l.layer.DeleteBucket(miniogw.WithUplinkProject(ctx, project), bucket, forceDelete))
+	if err = l.layer.DeleteBucket(miniogw.WithUplinkProject(ctx, project), bucket, forceDelete); err != nil {
+		return l.log(ctx, err)
+	}
+	return l.log(ctx, l.deleteAllBucketConfig(ctx, bucket))
 }
 
 // ListObjects is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).ListObjects.
 func (l *MultiTenancyLayer) ListObjects(ctx context.Context, bucket, prefix, marker, delimiter string, maxKeys int) (result minio.ListObjectsInfo, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.ListObjectsInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, "", policy.ListBucketAction); err != nil {
+		return minio.ListObjectsInfo{}, l.log(ctx, err)
+	}
 
 	result, err = l.layer.ListObjects(miniogw.WithUplinkProject(ctx, project), bucket, prefix, marker, delimiter, maxKeys)
 	return result, l.log(ctx, err)
@@ -258,93 +370,375 @@ func (l *MultiTenancyLayer) ListObjects(ctx context.Context, bucket, prefix, mar
 
 // ListObjectsV2 is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).ListObjectsV2.
 func (l *MultiTenancyLayer) ListObjectsV2(ctx context.Context, bucket, prefix, continuationToken, delimiter string, maxKeys int, fetchOwner bool, startAfter string) (result minio.ListObjectsV2Info, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.ListObjectsV2Info{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, "", policy.ListBucketAction); err != nil {
+		return minio.ListObjectsV2Info{}, l.log(ctx, err)
+	}
 
 	result, err = l.layer.ListObjectsV2(miniogw.WithUplinkProject(ctx, project), bucket, prefix, continuationToken, delimiter, maxKeys, fetchOwner, startAfter)
 	return result, l.log(ctx, err)
 }
 
+// ListObjectVersions lists objects in bucket as object versions.
+//
+// The network keeps a single version of each object, so every object is
+// listed as the latest and only ("null") version, which is also how S3 lists
+// objects in buckets that never had versioning enabled.
+func (l *MultiTenancyLayer) ListObjectVersions(ctx context.Context, bucket, prefix, marker, versionMarker, delimiter string, maxKeys int) (result minio.ListObjectVersionsInfo, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	// There's only one version per key, so continuing after the null version
+	// of the marker key is the same as continuing after the marker key.
+	if versionMarker != "" && versionMarker != nullVersionID {
+		return minio.ListObjectVersionsInfo{}, l.log(ctx, minio.InvalidArgument{
+			Bucket: bucket,
+			Err:    errs.New("invalid version-id-marker %q", versionMarker),
+		})
+	}
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return minio.ListObjectVersionsInfo{}, err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, "", policy.ListBucketVersionsAction); err != nil {
+		return minio.ListObjectVersionsInfo{}, l.log(ctx, err)
+	}
+
+	list, err := l.layer.ListObjects(miniogw.WithUplinkProject(ctx, project), bucket, prefix, marker, delimiter, maxKeys)
+	if err != nil {
+		return minio.ListObjectVersionsInfo{}, l.log(ctx, err)
+	}
+	return listObjectsToVersions(list), nil
+}
+
+// listObjectsToVersions converts list to a listing of null versions.
+func listObjectsToVersions(list minio.ListObjectsInfo) minio.ListObjectVersionsInfo {
+	result := minio.ListObjectVersionsInfo{
+		IsTruncated: list.IsTruncated,
+		NextMarker:  list.NextMarker,
+		Objects:     make([]minio.ObjectInfo, 0, len(list.Objects)),
+		Prefixes:    list.Prefixes,
+	}
+
+	for _, object := range list.Objects {
+		object.VersionID = nullVersionID
+		object.IsLatest = true
+		result.Objects = append(result.Objects, object)
+	}
+
+	if result.IsTruncated && len(result.Objects) > 0 {
+		last := result.Objects[len(result.Objects)-1].Name
+		if result.NextMarker == "" {
+			result.NextMarker = last
+		}
+		if result.NextMarker == last {
+			result.NextVersionIDMarker = nullVersionID
+		}
+	}
+
+	return result
+}
+
 // GetObjectNInfo is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).GetObjectNInfo.
 func (l *MultiTenancyLayer) GetObjectNInfo(ctx context.Context, bucket, object string, rs *minio.HTTPRangeSpec, h http.Header, lockType minio.LockType, opts minio.ObjectOptions) (reader *minio.GetObjectReader, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err = checkVersionID(bucket, object, opts.VersionID); err != nil {
+		return nil, l.log(ctx, err)
+	}
+
+	if h.Get(xhttp.AmzCopySource) != "" {
+		reader, err = l.getCopySourceNInfo(ctx, bucket, object, rs, h, lockType, opts)
+		return reader, l.log(ctx, err)
+	}
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, object, policy.GetObjectAction); err != nil {
+		return nil, l.log(ctx, err)
+	}
+
+	whole := rs == nil && opts.PartNumber == 0
+
+	rs, limit := suffixRange(rs)
+
+	var cached bool
+	if opts.PartNumber == 0 {
+		reader, err = l.getCachedObject(ctx, project, bucket, object, rs, opts)
+		if err != nil {
+			return nil, l.log(ctx, err)
+		}
+		cached = reader != nil
+	}
+
+	if !cached {
+		reader, err = l.layer.GetObjectNInfo(miniogw.WithUplinkProject(ctx, project), bucket, object, rs, h, lockType, opts)
+		if err != nil {
+			return nil, l.log(ctx, err)
+		}
+	}
+
+	reader.ObjInfo.VersionID = opts.VersionID
+	reader.ObjInfo.UserDefined = filterChecksums(ctx, reader.ObjInfo.UserDefined, whole)
 
-	reader, err = l.layer.GetObjectNInfo(miniogw.WithUplinkProject(ctx, project), bucket, object, rs, h, lockType, opts)
+	switch {
+	case limit >= 0:
+		reader, err = limitObjectReader(reader, limit)
+	case whole && !cached:
+		reader, err = l.cacheObjectReader(ctx, reader, bucket, object)
+	}
 	return reader, l.log(ctx, err)
 }
 
+// suffixRange returns rs as a range storj.io/gateway supports and how many
+// bytes of it to read, which is -1 for all of them.
+//
+// S3 Select reads the footer of Parquet objects with ranges of a given length
+// starting some bytes before the end, which storj.io/gateway only supports as
+// suffixes (reading until the end), so they're read as suffixes limited to
+// their length.
+func suffixRange(rs *minio.HTTPRangeSpec) (*minio.HTTPRangeSpec, int64) {
+	if rs == nil || !rs.IsSuffixLength || rs.End == -1 {
+		return rs, -1
+	}
+	return &minio.HTTPRangeSpec{IsSuffixLength: true, Start: rs.Start, End: -1}, rs.End - rs.Start + 1
+}
+
+// limitObjectReader returns a reader reading at most n bytes from reader.
+func limitObjectReader(reader *minio.GetObjectReader, n int64) (*minio.GetObjectReader, error) {
+	return minio.NewGetObjectReaderFromReader(io.LimitReader(reader, n), reader.ObjInfo, minio.ObjectOptions{}, func() { _ = reader.Close() })
+}
+
+// getCopySourceNInfo returns a reader for the source of CopyObject and
+// CopyObjectPart requests. The object is only stat'ed up front (which is all
+// minio needs to evaluate x-amz-copy-source-if-* preconditions); the download
+// is opened on the first read, so copies done server-side by the satellite
+// never download the source.
+func (l *MultiTenancyLayer) getCopySourceNInfo(ctx context.Context, bucket, object string, rs *minio.HTTPRangeSpec, h http.Header, lockType minio.LockType, opts minio.ObjectOptions) (_ *minio.GetObjectReader, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, object, policy.GetObjectAction); err != nil {
+		return nil, errs.Combine(err, release())
+	}
+
+	ctx = miniogw.WithUplinkProject(ctx, project)
+
+	info, err := l.layer.GetObjectInfo(ctx, bucket, object, minio.ObjectOptions{})
+	if err != nil {
+		return nil, errs.Combine(err, release())
+	}
+	info.VersionID = opts.VersionID
+	// minio copies UserTags over to the destination unless the tagging
+	// directive is REPLACE.
+	info.UserTags = info.UserDefined["s3:tags"]
+
+	source := &lazyObjectReader{
+		open: func() (io.ReadCloser, error) {
+			return l.layer.GetObjectNInfo(ctx, bucket, object, rs, h, lockType, minio.ObjectOptions{})
+		},
+	}
+
+	return minio.NewGetObjectReaderFromReader(source, info, opts, func() {
+		_ = errs.Combine(source.Close(), release())
+	})
+}
+
 // GetObjectInfo is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).GetObjectInfo.
 func (l *MultiTenancyLayer) GetObjectInfo(ctx context.Context, bucket, object string, opts minio.ObjectOptions) (objInfo minio.ObjectInfo, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err = checkVersionID(bucket, object, opts.VersionID); err != nil {
+		return minio.ObjectInfo{}, l.log(ctx, err)
+	}
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.ObjectInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, object, policy.GetObjectAction); err != nil {
+		return minio.ObjectInfo{}, l.log(ctx, err)
+	}
 
 	objInfo, err = l.layer.GetObjectInfo(miniogw.WithUplinkProject(ctx, project), bucket, object, opts)
+	objInfo.VersionID = opts.VersionID
+	objInfo.UserDefined = filterChecksums(ctx, objInfo.UserDefined, opts.PartNumber == 0)
 	return objInfo, l.log(ctx, err)
 }
 
 // PutObject is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).PutObject.
 func (l *MultiTenancyLayer) PutObject(ctx context.Context, bucket, object string, data *minio.PutObjReader, opts minio.ObjectOptions) (objInfo minio.ObjectInfo, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.ObjectInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, object, policy.PutObjectAction); err != nil {
+		return minio.ObjectInfo{}, l.log(ctx, err)
+	}
+
+	if opts.UserDefined == nil {
+		opts.UserDefined = make(map[string]string)
+	}
+	if err = l.applyLifecycleExpiration(ctx, project, bucket, object, opts.UserDefined); err != nil {
+		return minio.ObjectInfo{}, l.log(ctx, err)
+	}
+	// the checksum is verified while uploading, before the object is
+	// committed.
+	addChecksum(ctx, opts.UserDefined)
+	addTrailingChecksum(ctx, opts.UserDefined)
 
 	objInfo, err = l.layer.PutObject(miniogw.WithUplinkProject(ctx, project), bucket, object, data, opts)
+	if err == nil {
+		l.objects.invalidate(bucket, object)
+		l.notify(ctx, project, bucket, object, event.ObjectCreatedPut, objInfo)
+	}
 
 	return objInfo, l.log(ctx, err)
 }
 
 // CopyObject is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).CopyObject.
+//
+// The copy is done server-side by the satellite, which only copies metadata.
+// If server-side copy is unavailable (disabled in the gateway or not supported
+// by the satellite), the source is streamed through the gateway instead.
 func (l *MultiTenancyLayer) CopyObject(ctx context.Context, srcBucket, srcObject, destBucket, destObject string, srcInfo minio.ObjectInfo, srcOpts, destOpts minio.ObjectOptions) (objInfo minio.ObjectInfo, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.ObjectInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, destBucket, destObject, policy.PutObjectAction); err != nil {
+		return minio.ObjectInfo{}, l.log(ctx, err)
+	}
 
-	objInfo, err = l.layer.CopyObject(miniogw.WithUplinkProject(ctx, project), srcBucket, srcObject, destBucket, destObject, srcInfo, srcOpts, destOpts)
+	if srcInfo.UserDefined == nil {
+		srcInfo.UserDefined = make(map[string]string)
+	}
+	// a server-side copy keeps the expiration time of the source, so this
+	// only applies to streamed copies.
+	if err = l.applyLifecycleExpiration(ctx, project, destBucket, destObject, srcInfo.UserDefined); err != nil {
+		return minio.ObjectInfo{}, l.log(ctx, err)
+	}
+
+	ctx = miniogw.WithUplinkProject(ctx, project)
+
+	objInfo, err = l.layer.CopyObject(ctx, srcBucket, srcObject, destBucket, destObject, srcInfo, srcOpts, destOpts)
+	if srcInfo.PutObjReader != nil && serverSideCopyUnsupported(err) {
+		mon.Counter("copy_object_streaming_fallback").Inc(1)
+
+		destOpts.UserDefined = copyMetadata(srcInfo.UserDefined)
+		objInfo, err = l.layer.PutObject(ctx, destBucket, destObject, srcInfo.PutObjReader, destOpts)
+	}
+	if err == nil {
+		l.objects.invalidate(destBucket, destObject)
+		l.notify(ctx, project, destBucket, destObject, event.ObjectCreatedCopy, objInfo)
+	}
 	return objInfo, l.log(ctx, err)
 }
 
+// CopyObjectPart uploads a range of srcObject as a part of a multipart upload
+// (UploadPartCopy).
+//
+// The satellite has no server-side part copy, so the range is always streamed
+// through the gateway from the reader minio opened with GetObjectNInfo.
+func (l *MultiTenancyLayer) CopyObjectPart(ctx context.Context, srcBucket, srcObject, destBucket, destObject, uploadID string, partID int, startOffset, length int64, srcInfo minio.ObjectInfo, srcOpts, dstOpts minio.ObjectOptions) (info minio.PartInfo, err error) {
+	if srcInfo.PutObjReader == nil {
+		return minio.PartInfo{}, l.log(ctx, minio.NotImplemented{Message: "CopyObjectPart"})
+	}
+
+	return l.PutObjectPart(ctx, destBucket, destObject, uploadID, partID, srcInfo.PutObjReader, dstOpts)
+}
+
 // DeleteObject is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).DeleteObject.
 func (l *MultiTenancyLayer) DeleteObject(ctx context.Context, bucket, object string, opts minio.ObjectOptions) (objInfo minio.ObjectInfo, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err = checkVersionID(bucket, object, opts.VersionID); err != nil {
+		return minio.ObjectInfo{}, l.log(ctx, err)
+	}
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.ObjectInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, object, policy.DeleteObjectAction); err != nil {
+		return minio.ObjectInfo{}, l.log(ctx, err)
+	}
 
 	objInfo, err = l.layer.DeleteObject(miniogw.WithUplinkProject(ctx, project), bucket, object, opts)
+	objInfo.VersionID = opts.VersionID
+	if err == nil {
+		l.objects.invalidate(bucket, object)
+		l.notify(ctx, project, bucket, object, event.ObjectRemovedDelete, objInfo)
+	}
 	return objInfo, l.log(ctx, err)
 }
 
 // DeleteObjects is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).DeleteObjects.
 func (l *MultiTenancyLayer) DeleteObjects(ctx context.Context, bucket string, objects []minio.ObjectToDelete, opts minio.ObjectOptions) (deleted []minio.DeletedObject, errors []error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return nil, []error{err}
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
+
+	deleted, errors = make([]minio.DeletedObject, len(objects)), make([]error, len(objects))
+
+	// objects that name a version other than the null version can't exist,
+	// so only pass the remaining ones down, remembering their original
+	// position.
+	var (
+		toDelete []minio.ObjectToDelete
+		indices  []int
+	)
+	for i, object := range objects {
+		if err := checkVersionID(bucket, object.ObjectName, object.VersionID); err != nil {
+			errors[i] = err
+			continue
+		}
+		if err := l.checkBucketPolicy(ctx, project, bucket, object.ObjectName, policy.DeleteObjectAction); err != nil {
+			errors[i] = err
+			continue
+		}
+		toDelete = append(toDelete, object)
+		indices = append(indices, i)
+	}
 
-	deleted, errors = l.layer.DeleteObjects(miniogw.WithUplinkProject(ctx, project), bucket, objects, opts)
+	if len(toDelete) > 0 {
+		d, e := l.layer.DeleteObjects(miniogw.WithUplinkProject(ctx, project), bucket, toDelete, opts)
+		for j, i := range indices {
+			deleted[i], errors[i] = d[j], e[j]
+			if errors[i] == nil {
+				deleted[i].VersionID = toDelete[j].VersionID
+				l.objects.invalidate(bucket, toDelete[j].ObjectName)
+				l.notify(ctx, project, bucket, toDelete[j].ObjectName, event.ObjectRemovedDelete, minio.ObjectInfo{VersionID: deleted[i].VersionID})
+			}
+		}
+	}
 
 	for _, err := range errors {
 		_ = l.log(ctx, err)
@@ -355,12 +749,15 @@ func (l *MultiTenancyLayer) DeleteObjects(ctx context.Context, bucket string, ob
 
 // ListMultipartUploads is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).ListMultipartUploads.
 func (l *MultiTenancyLayer) ListMultipartUploads(ctx context.Context, bucket, prefix, keyMarker, uploadIDMarker, delimiter string, maxUploads int) (result minio.ListMultipartsInfo, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.ListMultipartsInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
+
+	// failing to abort expired uploads shouldn't fail listing them.
+	_ = l.abortIncompleteMultipartUploads(ctx, project, bucket)
 
 	result, err = l.layer.ListMultipartUploads(miniogw.WithUplinkProject(ctx, project), bucket, prefix, keyMarker, uploadIDMarker, delimiter, maxUploads)
 	return result, l.log(ctx, err)
@@ -368,12 +765,26 @@ func (l *MultiTenancyLayer) ListMultipartUploads(ctx context.Context, bucket, pr
 
 // NewMultipartUpload is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).NewMultipartUpload.
 func (l *MultiTenancyLayer) NewMultipartUpload(ctx context.Context, bucket, object string, opts minio.ObjectOptions) (uploadID string, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return "", err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if err = l.checkBucketPolicy(ctx, project, bucket, object, policy.PutObjectAction); err != nil {
+		return "", l.log(ctx, err)
+	}
+
+	if opts.UserDefined == nil {
+		opts.UserDefined = make(map[string]string)
+	}
+	if err = l.applyLifecycleExpiration(ctx, project, bucket, object, opts.UserDefined); err != nil {
+		return "", l.log(ctx, err)
+	}
+
+	// failing to abort expired uploads shouldn't fail starting a new one.
+	_ = l.abortIncompleteMultipartUploads(ctx, project, bucket)
 
 	uploadID, err = l.layer.NewMultipartUpload(miniogw.WithUplinkProject(ctx, project), bucket, object, opts)
 	return uploadID, l.log(ctx, err)
@@ -381,12 +792,12 @@ func (l *MultiTenancyLayer) NewMultipartUpload(ctx context.Context, bucket, obje
 
 // PutObjectPart is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).PutObjectPart.
 func (l *MultiTenancyLayer) PutObjectPart(ctx context.Context, bucket, object, uploadID string, partID int, data *minio.PutObjReader, opts minio.ObjectOptions) (info minio.PartInfo, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.PartInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	info, err = l.layer.PutObjectPart(miniogw.WithUplinkProject(ctx, project), bucket, object, uploadID, partID, data, opts)
 	return info, l.log(ctx, err)
@@ -394,12 +805,12 @@ func (l *MultiTenancyLayer) PutObjectPart(ctx context.Context, bucket, object, u
 
 // GetMultipartInfo is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).GetMultipartInfo.
 func (l *MultiTenancyLayer) GetMultipartInfo(ctx context.Context, bucket string, object string, uploadID string, opts minio.ObjectOptions) (info minio.MultipartInfo, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.MultipartInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	info, err = l.layer.GetMultipartInfo(miniogw.WithUplinkProject(ctx, project), bucket, object, uploadID, opts)
 	return info, l.log(ctx, err)
@@ -407,12 +818,12 @@ func (l *MultiTenancyLayer) GetMultipartInfo(ctx context.Context, bucket string,
 
 // ListObjectParts is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).ListObjectParts.
 func (l *MultiTenancyLayer) ListObjectParts(ctx context.Context, bucket, object, uploadID string, partNumberMarker int, maxParts int, opts minio.ObjectOptions) (result minio.ListPartsInfo, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.ListPartsInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	result, err = l.layer.ListObjectParts(miniogw.WithUplinkProject(ctx, project), bucket, object, uploadID, partNumberMarker, maxParts, opts)
 	return result, l.log(ctx, err)
@@ -420,26 +831,39 @@ func (l *MultiTenancyLayer) ListObjectParts(ctx context.Context, bucket, object,
 
 // AbortMultipartUpload is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).AbortMultipartUpload.
 func (l *MultiTenancyLayer) AbortMultipartUpload(ctx context.Context, bucket, object, uploadID string, opts minio.ObjectOptions) error {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	return l.log(ctx, l.layer.AbortMultipartUpload(miniogw.WithUplinkProject(ctx, project), bucket, object, uploadID, opts))
 }
 
 // CompleteMultipartUpload is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).CompleteMultipartUpload.
 func (l *MultiTenancyLayer) CompleteMultipartUpload(ctx context.Context, bucket, object, uploadID string, uploadedParts []minio.CompletePart, opts minio.ObjectOptions) (objInfo minio.ObjectInfo, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.ObjectInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	objInfo, err = l.layer.CompleteMultipartUpload(miniogw.WithUplinkProject(ctx, project), bucket, object, uploadID, uploadedParts, opts)
+	if err != nil {
+		return minio.ObjectInfo{}, l.log(ctx, err)
+	}
+
+	// the metadata of the object is the metadata of the upload, so its
+	// checksum (computed from the checksums of the parts) is added after.
+	if addChecksum(ctx, objInfo.UserDefined) {
+		err = miniogw.ConvertError(project.UpdateObjectMetadata(ctx, bucket, object, objInfo.UserDefined, nil), bucket, object)
+	}
+	if err == nil {
+		l.objects.invalidate(bucket, object)
+		l.notify(ctx, project, bucket, object, event.ObjectCreatedCompleteMultipartUpload, objInfo)
+	}
 	return objInfo, l.log(ctx, err)
 }
 
@@ -450,12 +874,12 @@ func (l *MultiTenancyLayer) IsTaggingSupported() bool {
 
 // PutObjectTags is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).PutObjectTags.
 func (l *MultiTenancyLayer) PutObjectTags(ctx context.Context, bucketName, objectPath string, tags string, opts minio.ObjectOptions) (minio.ObjectInfo, error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.ObjectInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	objInfo, err := l.layer.PutObjectTags(miniogw.WithUplinkProject(ctx, project), bucketName, objectPath, tags, opts)
 
@@ -464,12 +888,12 @@ func (l *MultiTenancyLayer) PutObjectTags(ctx context.Context, bucketName, objec
 
 // GetObjectTags is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).GetObjectTags.
 func (l *MultiTenancyLayer) GetObjectTags(ctx context.Context, bucketName, objectPath string, opts minio.ObjectOptions) (t *tags.Tags, err error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	t, err = l.layer.GetObjectTags(miniogw.WithUplinkProject(ctx, project), bucketName, objectPath, opts)
 	return t, l.log(ctx, err)
@@ -477,18 +901,108 @@ func (l *MultiTenancyLayer) GetObjectTags(ctx context.Context, bucketName, objec
 
 // DeleteObjectTags is a multi-tenant wrapping of storj.io/gateway.(*gatewayLayer).DeleteObjectTags.
 func (l *MultiTenancyLayer) DeleteObjectTags(ctx context.Context, bucketName, objectPath string, opts minio.ObjectOptions) (minio.ObjectInfo, error) {
-	project, err := l.openProject(ctx, getAccessGrant(ctx))
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
 	if err != nil {
 		return minio.ObjectInfo{}, err
 	}
 
-	defer func() { err = errs.Combine(err, project.Close()) }()
+	defer func() { err = errs.Combine(err, release()) }()
 
 	objInfo, err := l.layer.DeleteObjectTags(miniogw.WithUplinkProject(ctx, project), bucketName, objectPath, opts)
 
 	return objInfo, l.log(ctx, err)
 }
 
+// GetBucketVersioning returns the versioning configuration of bucket.
+//
+// Buckets on the network are never versioned, so the configuration never has
+// a status, which S3 clients interpret as "versioning was never enabled".
+func (l *MultiTenancyLayer) GetBucketVersioning(ctx context.Context, bucket string) (_ *versioning.Versioning, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if _, err = l.GetBucketInfo(ctx, bucket); err != nil {
+		return nil, err
+	}
+
+	return &versioning.Versioning{XMLNS: "http://s3.amazonaws.com/doc/2006-03-01/"}, nil
+}
+
+// SetBucketVersioning changes the versioning state of bucket.
+//
+// The network doesn't support object versioning yet, so after checking that
+// bucket exists it always returns ErrVersioningNotSupported.
+func (l *MultiTenancyLayer) SetBucketVersioning(ctx context.Context, bucket string, config *versioning.Versioning) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if _, err = l.GetBucketInfo(ctx, bucket); err != nil {
+		return err
+	}
+
+	return l.log(ctx, ErrVersioningNotSupported)
+}
+
+// checkVersionID returns minio.VersionNotFound for any versionID other than
+// the null version. The network keeps a single version of every object, which
+// S3 addresses as the "null" version.
+func checkVersionID(bucket, object, versionID string) error {
+	if versionID == "" || versionID == nullVersionID {
+		return nil
+	}
+	return minio.VersionNotFound{Bucket: bucket, Object: object, VersionID: versionID}
+}
+
+// serverSideCopyUnsupported reports whether err means that the object
+// couldn't be copied server-side and has to be streamed instead.
+func serverSideCopyUnsupported(err error) bool {
+	return errors.As(err, &minio.NotImplemented{}) || rpcstatus.Code(err) == rpcstatus.Unimplemented
+}
+
+// copyMetadata returns the metadata to upload a streamed copy with. minio
+// passes the destination's tags in X-Amz-Tagging, so the source's tags are
+// dropped if the tagging directive is REPLACE.
+func copyMetadata(userDefined map[string]string) map[string]string {
+	metadata := make(map[string]string, len(userDefined))
+	for k, v := range userDefined {
+		metadata[k] = v
+	}
+
+	if metadata[xhttp.AmzTagDirective] == "REPLACE" {
+		delete(metadata, "s3:tags")
+	}
+	delete(metadata, xhttp.AmzTagDirective)
+	// the ETag is calculated from the uploaded data.
+	delete(metadata, "s3:etag")
+
+	return metadata
+}
+
+// lazyObjectReader opens the underlying reader on the first read.
+type lazyObjectReader struct {
+	open func() (io.ReadCloser, error)
+
+	reader io.ReadCloser
+	err    error
+}
+
+// Read opens the underlying reader if necessary and reads from it.
+func (r *lazyObjectReader) Read(p []byte) (int, error) {
+	if r.reader == nil && r.err == nil {
+		r.reader, r.err = r.open()
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.reader.Read(p)
+}
+
+// Close closes the underlying reader if it has been opened.
+func (r *lazyObjectReader) Close() error {
+	if r.reader == nil {
+		return nil
+	}
+	return r.reader.Close()
+}
+
 func getAccessGrant(ctx context.Context) string {
 	credentials := middleware.GetAccess(ctx)
 	if credentials == nil || credentials.AccessKey == "" {
@@ -497,28 +1011,35 @@ func getAccessGrant(ctx context.Context) string {
 	return credentials.AccessGrant
 }
 
-func (l *MultiTenancyLayer) openProject(ctx context.Context, accessKey string) (_ *uplink.Project, err error) {
+// openProject returns a project for accessKey, reusing a cached project if
+// possible. The returned release function must be called once the project is
+// no longer needed.
+func (l *MultiTenancyLayer) openProject(ctx context.Context, accessKey string) (_ *uplink.Project, release func() error, err error) {
 	defer mon.Task()(&ctx)(&err)
 
 	// this happens when an anonymous request hits the gateway endpoint, e.g.
 	// accessing http://localhost:20010 directly.
 	if accessKey == "" {
-		return nil, ErrAccessKeyEmpty
+		return nil, nil, ErrAccessKeyEmpty
 	}
 
-	access, err := uplink.ParseAccess(accessKey)
-	if err != nil {
-		return nil, ErrAccessGrant.Wrap(err)
-	}
+	userAgent := getUserAgent(ctx)
+
+	return l.projects.get(ctx, projectCacheKey(accessKey, userAgent), func() (*uplink.Project, error) {
+		access, err := uplink.ParseAccess(accessKey)
+		if err != nil {
+			return nil, ErrAccessGrant.Wrap(err)
+		}
 
-	return l.setupProject(ctx, access)
+		return l.setupProject(ctx, access, userAgent)
+	})
 }
 
-func (l *MultiTenancyLayer) setupProject(ctx context.Context, access *uplink.Access) (_ *uplink.Project, err error) {
+func (l *MultiTenancyLayer) setupProject(ctx context.Context, access *uplink.Access, userAgent string) (_ *uplink.Project, err error) {
 	defer mon.Task()(&ctx)(&err)
 
 	config := l.config
-	config.UserAgent = getUserAgent(ctx)
+	config.UserAgent = userAgent
 
 	err = transport.SetConnectionPool(ctx, &config, l.connectionPool)
 	if err != nil {
@@ -4,18 +4,27 @@
 package gw
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 
 	miniogo "github.com/minio/minio-go/v7"
 	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
 
+	"storj.io/common/rpc/rpcstatus"
 	"storj.io/gateway-mt/pkg/server/gwlog"
 	"storj.io/gateway/miniogw"
 	minio "storj.io/minio/cmd"
+	xhttp "storj.io/minio/cmd/http"
 	"storj.io/minio/cmd/logger"
+	"storj.io/minio/pkg/s3select"
 	"storj.io/uplink"
 )
 
@@ -65,7 +74,7 @@ func TestLogUnexpectedErrorsOnly(t *testing.T) {
 	for i, tc := range tests {
 		log := gwlog.New()
 		ctx := log.WithContext(context.Background())
-		require.Error(t, (&MultiTenancyLayer{minio.GatewayUnsupported{}, nil, nil, uplink.Config{}, false}).log(ctx, tc.input))
+		require.Error(t, (&MultiTenancyLayer{insecureLogAll: false}).log(ctx, tc.input))
 		require.Equal(t, tc.expected, log.TagValue("error"), i)
 	}
 }
@@ -83,15 +92,221 @@ func TestLogAllErrors(t *testing.T) {
 	for i, tc := range tests {
 		log := gwlog.New()
 		ctx := log.WithContext(context.Background())
-		require.Error(t, (&MultiTenancyLayer{minio.GatewayUnsupported{}, nil, nil, uplink.Config{}, true}).log(ctx, tc.input))
+		require.Error(t, (&MultiTenancyLayer{insecureLogAll: true}).log(ctx, tc.input))
 		require.Equal(t, tc.expected, log.TagValue("error"), i)
 	}
 }
 
 func TestInvalidAccessGrant(t *testing.T) {
-	layer := &MultiTenancyLayer{minio.GatewayUnsupported{}, nil, nil, uplink.Config{}, true}
+	layer := &MultiTenancyLayer{insecureLogAll: true}
 	_, err := layer.ListBuckets(context.Background())
 	require.Error(t, err)
 	require.IsType(t, miniogo.ErrorResponse{}, err)
 	require.Equal(t, http.StatusUnauthorized, miniogo.ToErrorResponse(err).StatusCode)
 }
+
+func TestCheckVersionID(t *testing.T) {
+	require.NoError(t, checkVersionID("bucket", "object", ""))
+	require.NoError(t, checkVersionID("bucket", "object", "null"))
+
+	err := checkVersionID("bucket", "object", "c8b0d8b6-3b8e-4b8a-9d8a-6f4d0b8d1a2e")
+	require.Equal(t, minio.VersionNotFound{Bucket: "bucket", Object: "object", VersionID: "c8b0d8b6-3b8e-4b8a-9d8a-6f4d0b8d1a2e"}, err)
+}
+
+func TestListObjectsToVersions(t *testing.T) {
+	result := listObjectsToVersions(minio.ListObjectsInfo{
+		IsTruncated: true,
+		Objects:     []minio.ObjectInfo{{Name: "a"}, {Name: "b"}},
+		Prefixes:    []string{"p/"},
+	})
+
+	require.True(t, result.IsTruncated)
+	require.Equal(t, "b", result.NextMarker)
+	require.Equal(t, "null", result.NextVersionIDMarker)
+	require.Equal(t, []string{"p/"}, result.Prefixes)
+	require.Len(t, result.Objects, 2)
+	for _, object := range result.Objects {
+		require.Equal(t, "null", object.VersionID)
+		require.True(t, object.IsLatest)
+	}
+
+	// listing ended on a prefix, so there's no version to continue from.
+	result = listObjectsToVersions(minio.ListObjectsInfo{
+		IsTruncated: true,
+		NextMarker:  "p/",
+		Objects:     []minio.ObjectInfo{{Name: "a"}},
+		Prefixes:    []string{"p/"},
+	})
+	require.Equal(t, "p/", result.NextMarker)
+	require.Empty(t, result.NextVersionIDMarker)
+
+	result = listObjectsToVersions(minio.ListObjectsInfo{})
+	require.False(t, result.IsTruncated)
+	require.Empty(t, result.Objects)
+}
+
+func TestServerSideCopyUnsupported(t *testing.T) {
+	require.False(t, serverSideCopyUnsupported(nil))
+	require.False(t, serverSideCopyUnsupported(errors.New("some error")))
+	require.False(t, serverSideCopyUnsupported(minio.ObjectNotFound{}))
+	require.True(t, serverSideCopyUnsupported(minio.NotImplemented{Message: "CopyObject"}))
+	require.True(t, serverSideCopyUnsupported(rpcstatus.Error(rpcstatus.Unimplemented, "unknown rpc")))
+}
+
+func TestCopyMetadata(t *testing.T) {
+	src := map[string]string{
+		"content-type": "text/plain",
+		"s3:etag":      "abc",
+		"s3:tags":      "a=b",
+	}
+
+	metadata := copyMetadata(src)
+	require.Equal(t, map[string]string{
+		"content-type": "text/plain",
+		"s3:tags":      "a=b",
+	}, metadata)
+	require.Equal(t, "abc", src["s3:etag"], "source metadata must not be modified")
+
+	metadata = copyMetadata(map[string]string{
+		"s3:tags":              "a=b",
+		xhttp.AmzTagDirective:  "REPLACE",
+		xhttp.AmzObjectTagging: "c=d",
+	})
+	require.Equal(t, map[string]string{xhttp.AmzObjectTagging: "c=d"}, metadata)
+}
+
+func TestLazyObjectReader(t *testing.T) {
+	var opened int
+	open := func() (io.ReadCloser, error) {
+		opened++
+		return io.NopCloser(strings.NewReader("data")), nil
+	}
+
+	unread := &lazyObjectReader{open: open}
+	require.NoError(t, unread.Close())
+	require.Zero(t, opened)
+
+	read := &lazyObjectReader{open: open}
+	data, err := io.ReadAll(read)
+	require.NoError(t, err)
+	require.Equal(t, "data", string(data))
+	require.NoError(t, read.Close())
+	require.Equal(t, 1, opened)
+
+	failing := &lazyObjectReader{open: func() (io.ReadCloser, error) {
+		opened++
+		return nil, errors.New("open failed")
+	}}
+	_, err = failing.Read(make([]byte, 1))
+	require.Error(t, err)
+	_, err = failing.Read(make([]byte, 1))
+	require.Error(t, err)
+	require.NoError(t, failing.Close())
+	require.Equal(t, 2, opened)
+}
+
+func TestLimitObjectReader(t *testing.T) {
+	var closed bool
+	reader, err := minio.NewGetObjectReaderFromReader(strings.NewReader("PAR1data"), minio.ObjectInfo{Name: "object"}, minio.ObjectOptions{}, func() { closed = true })
+	require.NoError(t, err)
+
+	limited, err := limitObjectReader(reader, 4)
+	require.NoError(t, err)
+	require.Equal(t, "object", limited.ObjInfo.Name)
+
+	data, err := io.ReadAll(limited)
+	require.NoError(t, err)
+	require.Equal(t, "PAR1", string(data))
+
+	require.NoError(t, limited.Close())
+	require.True(t, closed)
+}
+
+func TestSelectParquet(t *testing.T) {
+	t.Setenv("MINIO_API_SELECT_PARQUET", "on")
+
+	data, err := os.ReadFile("testdata/lineitem_shipdate.parquet")
+	require.NoError(t, err)
+
+	// download reads a range of the object like storj.io/gateway does, which
+	// doesn't support suffix ranges that don't read until the end.
+	download := func(rs *minio.HTTPRangeSpec) ([]byte, error) {
+		size := int64(len(data))
+		switch {
+		case !rs.IsSuffixLength && rs.Start >= 0 && rs.End >= rs.Start && rs.End < size:
+			return data[rs.Start : rs.End+1], nil
+		case rs.IsSuffixLength && rs.Start < 0 && -rs.Start <= size && rs.End == -1:
+			return data[size+rs.Start:], nil
+		}
+		return nil, errs.New("unsupported range %+v", *rs)
+	}
+
+	var suffixes int
+	// getObject reads the object like minio's SelectObjectContentHandler
+	// does with GetObjectNInfo.
+	getObject := func(offset, length int64) (io.ReadCloser, error) {
+		if length > 0 {
+			length--
+		}
+		rs, limit := suffixRange(&minio.HTTPRangeSpec{IsSuffixLength: offset < 0, Start: offset, End: offset + length})
+		if limit >= 0 {
+			suffixes++
+		}
+
+		body, err := download(rs)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := minio.NewGetObjectReaderFromReader(bytes.NewReader(body), minio.ObjectInfo{Size: int64(len(data))}, minio.ObjectOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if limit >= 0 {
+			return limitObjectReader(reader, limit)
+		}
+		return reader, nil
+	}
+
+	s3Select, err := s3select.NewS3Select(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<SelectObjectContentRequest>
+	<Expression>SELECT * FROM S3Object LIMIT 3</Expression>
+	<ExpressionType>SQL</ExpressionType>
+	<InputSerialization><Parquet></Parquet></InputSerialization>
+	<OutputSerialization><JSON></JSON></OutputSerialization>
+</SelectObjectContentRequest>`))
+	require.NoError(t, err)
+	require.NoError(t, s3Select.Open(getObject))
+	defer s3Select.Close()
+
+	// the footer is read with a range ending before the end of the object.
+	require.NotZero(t, suffixes)
+
+	rec := httptest.NewRecorder()
+	s3Select.Evaluate(rec)
+
+	results, err := miniogo.NewSelectResults(rec.Result(), "bucket")
+	require.NoError(t, err)
+	records, err := io.ReadAll(results)
+	require.NoError(t, err)
+	require.Equal(t, `{"shipdate":"1996-03-13T"}
+{"shipdate":"1996-04-12T"}
+{"shipdate":"1996-01-29T"}
+`, string(records))
+}
+
+func TestSuffixRange(t *testing.T) {
+	for _, rs := range []*minio.HTTPRangeSpec{
+		nil,
+		{Start: 0, End: 9},
+		{Start: 10, End: -1},
+		{IsSuffixLength: true, Start: -10, End: -1},
+	} {
+		got, limit := suffixRange(rs)
+		require.Equal(t, rs, got)
+		require.EqualValues(t, -1, limit)
+	}
+
+	got, limit := suffixRange(&minio.HTTPRangeSpec{IsSuffixLength: true, Start: -10, End: -7})
+	require.Equal(t, &minio.HTTPRangeSpec{IsSuffixLength: true, Start: -10, End: -1}, got)
+	require.EqualValues(t, 4, limit)
+}
@@ -0,0 +1,184 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/gateway-mt/pkg/server/middleware"
+	minio "storj.io/minio/cmd"
+	xhttp "storj.io/minio/cmd/http"
+	"storj.io/minio/cmd/logger"
+	"storj.io/minio/pkg/event"
+	"storj.io/uplink"
+)
+
+// notificationConfigName is the name of the bucket configuration document
+// holding the notification configuration.
+const notificationConfigName = "notification.xml"
+
+// NotificationTargets returns the targets bucket notification configurations
+// can refer to.
+func (l *MultiTenancyLayer) NotificationTargets() *event.TargetList {
+	if l.notifier == nil {
+		return event.NewTargetList()
+	}
+	return l.notifier.Targets()
+}
+
+// GetBucketNotificationConfig returns the notification configuration of
+// bucket, which is empty if it isn't set.
+func (l *MultiTenancyLayer) GetBucketNotificationConfig(ctx context.Context, bucket string) (_ *event.Config, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	config, err := l.bucketNotificationConfig(ctx, project, bucket)
+	if err != nil {
+		return nil, l.log(ctx, err)
+	}
+	if config == nil {
+		config = &event.Config{XMLNS: "http://s3.amazonaws.com/doc/2006-03-01/"}
+	}
+	return config, nil
+}
+
+// SetBucketNotificationConfig sets the notification configuration of bucket.
+// Setting a configuration without destinations removes it.
+func (l *MultiTenancyLayer) SetBucketNotificationConfig(ctx context.Context, bucket string, config *event.Config) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	if len(config.QueueList) == 0 {
+		return l.log(ctx, l.deleteBucketConfig(ctx, project, bucket, notificationConfigName))
+	}
+
+	data, err := xml.Marshal(config)
+	if err != nil {
+		return l.log(ctx, ErrBucketConfig.Wrap(err))
+	}
+
+	return l.log(ctx, l.putBucketConfig(ctx, project, bucket, notificationConfigName, data))
+}
+
+// bucketNotificationConfig returns the notification configuration of bucket
+// or nil if it isn't set.
+func (l *MultiTenancyLayer) bucketNotificationConfig(ctx context.Context, project *uplink.Project, bucket string) (*event.Config, error) {
+	data, err := l.getBucketConfig(ctx, project, bucket, notificationConfigName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	// the configuration isn't validated against the current targets, as they
+	// might have changed since it was set.
+	var config event.Config
+	if err = xml.NewDecoder(bytes.NewReader(data)).Decode(&config); err != nil {
+		return nil, ErrBucketConfig.Wrap(err)
+	}
+	return &config, nil
+}
+
+// notify publishes an event named name about object, described by objInfo, to
+// the destinations of the notification configuration of bucket it matches.
+//
+// The request the event is about has succeeded at this point, so failures are
+// only recorded.
+func (l *MultiTenancyLayer) notify(ctx context.Context, project *uplink.Project, bucket, object string, name event.Name, objInfo minio.ObjectInfo) {
+	if l.notifier == nil {
+		return
+	}
+
+	var err error
+	defer mon.Task()(&ctx)(&err)
+
+	config, err := l.bucketNotificationConfig(ctx, project, bucket)
+	if err != nil {
+		mon.Counter("notification_config_failed").Inc(1)
+		if reqInfo := logger.GetReqInfo(ctx); reqInfo != nil {
+			reqInfo.SetTags("notification error", err.Error())
+		}
+		return
+	}
+	if config == nil {
+		return
+	}
+
+	objInfo.Name = object
+	for _, queue := range config.QueueList {
+		targetIDs := queue.ToRulesMap()[name].Match(objInfo.Name)
+		if len(targetIDs) == 0 {
+			continue
+		}
+		l.notifier.Notify(ctx, newEvent(ctx, name, queue.ID, bucket, objInfo), targetIDs)
+	}
+}
+
+// newEvent returns an event named name about objInfo in the S3 event message
+// structure.
+func newEvent(ctx context.Context, name event.Name, configurationID, bucket string, objInfo minio.ObjectInfo) event.Event {
+	now := time.Now().UTC()
+
+	ev := event.Event{
+		EventVersion:      "2.1",
+		EventSource:       "aws:s3",
+		EventTime:         now.Format(event.AMZTimeFormat),
+		EventName:         name,
+		RequestParameters: map[string]string{},
+		ResponseElements:  map[string]string{},
+		S3: event.Metadata{
+			SchemaVersion:   "1.0",
+			ConfigurationID: configurationID,
+			Bucket: event.Bucket{
+				Name: bucket,
+				ARN:  "arn:aws:s3:::" + bucket,
+			},
+			Object: event.Object{
+				Key:       url.QueryEscape(objInfo.Name),
+				VersionID: objInfo.VersionID,
+				Sequencer: fmt.Sprintf("%X", now.UnixNano()),
+			},
+		},
+	}
+
+	if name != event.ObjectRemovedDelete {
+		ev.S3.Object.Size = objInfo.Size
+		ev.S3.Object.ETag = objInfo.ETag
+		ev.S3.Object.ContentType = objInfo.ContentType
+	}
+
+	ev.AwsRegion = middleware.GetRegion(ctx)
+
+	if credentials := middleware.GetAccess(ctx); credentials != nil {
+		ev.UserIdentity.PrincipalID = credentials.AccessKey
+	}
+
+	if reqInfo := logger.GetReqInfo(ctx); reqInfo != nil {
+		ev.RequestParameters["sourceIPAddress"] = reqInfo.RemoteHost
+		ev.ResponseElements[xhttp.AmzRequestID] = reqInfo.RequestID
+		ev.Source = event.Source{
+			Host:      reqInfo.RemoteHost,
+			UserAgent: reqInfo.UserAgent,
+		}
+	}
+
+	return ev
+}
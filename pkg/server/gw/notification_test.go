@@ -0,0 +1,51 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	minio "storj.io/minio/cmd"
+	xhttp "storj.io/minio/cmd/http"
+	"storj.io/minio/cmd/logger"
+	"storj.io/minio/pkg/event"
+)
+
+func TestNewEvent(t *testing.T) {
+	ctx := logger.SetReqInfo(context.Background(), &logger.ReqInfo{
+		RemoteHost: "1.2.3.4",
+		RequestID:  "ABC123",
+		UserAgent:  "aws-cli/2.0",
+	})
+
+	objInfo := minio.ObjectInfo{
+		Name:        "dir/my key",
+		Size:        11,
+		ETag:        "5eb63bbbe01eeed093cb22bb8f5acdc3",
+		ContentType: "text/plain",
+	}
+
+	ev := newEvent(ctx, event.ObjectCreatedPut, "ingest", "bucket", objInfo)
+	require.Equal(t, "aws:s3", ev.EventSource)
+	require.Equal(t, "ingest", ev.S3.ConfigurationID)
+	require.Equal(t, "arn:aws:s3:::bucket", ev.S3.Bucket.ARN)
+	require.Equal(t, "dir%2Fmy+key", ev.S3.Object.Key)
+	require.EqualValues(t, 11, ev.S3.Object.Size)
+	require.Equal(t, objInfo.ETag, ev.S3.Object.ETag)
+	require.Equal(t, "1.2.3.4", ev.RequestParameters["sourceIPAddress"])
+	require.Equal(t, "ABC123", ev.ResponseElements[xhttp.AmzRequestID])
+
+	data, err := json.Marshal(ev)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"eventName":"s3:ObjectCreated:Put"`)
+
+	// removed objects have no size or ETag.
+	ev = newEvent(ctx, event.ObjectRemovedDelete, "ingest", "bucket", objInfo)
+	require.Zero(t, ev.S3.Object.Size)
+	require.Empty(t, ev.S3.Object.ETag)
+}
@@ -0,0 +1,374 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/common/memory"
+	"storj.io/gateway/miniogw"
+	minio "storj.io/minio/cmd"
+	"storj.io/uplink"
+)
+
+// ObjectCacheConfig configures the read-through cache of small objects.
+type ObjectCacheConfig struct {
+	Capacity      memory.Size `help:"maximum total size of the objects kept in the object cache (0 disables the cache)" default:"0B"`
+	MaxObjectSize memory.Size `help:"maximum size of an object to be kept in the object cache" default:"1MiB"`
+	Dir           string      `help:"directory to keep cached objects in (empty to keep them in memory)" default:""`
+}
+
+// objectCache is a bounded cache of the contents of small objects, kept in
+// memory or in a directory.
+//
+// Entries are stored by the scope of the access grant that read them, bucket
+// and key, together with the ETag, creation time and size of the object they
+// were read from. A cached copy is only served to requests with the same
+// access grant and only while these still match the object.
+type objectCache struct {
+	capacity      int64
+	maxObjectSize int64
+	dir           string // empty if objects are kept in memory
+
+	mu      sync.Mutex
+	next    uint64
+	size    int64
+	objects map[objectName]map[string]*cachedObject // by scope
+	lru     *list.List                              // front is the most recently used entry
+}
+
+type objectName struct {
+	bucket, object string
+}
+
+type cachedObject struct {
+	name  objectName
+	scope string
+
+	etag    string
+	modTime time.Time
+	size    int64
+
+	data []byte // nil if kept in a file
+	path string
+	elem *list.Element
+}
+
+// newObjectCache returns a cache configured by config, which is nil if the
+// cache is disabled. A cache kept on disk uses a new directory in config.Dir,
+// which is removed on Close.
+func newObjectCache(config ObjectCacheConfig) (*objectCache, error) {
+	if config.Capacity <= 0 {
+		return nil, nil
+	}
+
+	cache := &objectCache{
+		capacity:      config.Capacity.Int64(),
+		maxObjectSize: config.MaxObjectSize.Int64(),
+		objects:       make(map[objectName]map[string]*cachedObject),
+		lru:           list.New(),
+	}
+
+	if config.Dir != "" {
+		if err := os.MkdirAll(config.Dir, 0700); err != nil {
+			return nil, errs.Wrap(err)
+		}
+		dir, err := os.MkdirTemp(config.Dir, "objects-")
+		if err != nil {
+			return nil, errs.Wrap(err)
+		}
+		cache.dir = dir
+	}
+
+	return cache, nil
+}
+
+// objectCacheScope returns the scope objects read with accessGrant are cached
+// in. The access grant is hashed so that the cache doesn't keep serialized
+// grants around.
+func objectCacheScope(accessGrant string) string {
+	sum := sha256.Sum256([]byte(accessGrant))
+	return hex.EncodeToString(sum[:])
+}
+
+// fits returns whether an object of the given size can be cached.
+func (c *objectCache) fits(size int64) bool {
+	return c != nil && size >= 0 && size <= c.maxObjectSize && size <= c.capacity
+}
+
+// has returns whether there is a cached copy of object in scope, regardless
+// of whether it's up to date.
+func (c *objectCache) has(scope, bucket, object string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.objects[objectName{bucket, object}][scope]
+	return ok
+}
+
+// get returns the cached copy of object in scope if it was read from the
+// object described by info. Outdated copies are removed.
+func (c *objectCache) get(scope, bucket, object string, info minio.ObjectInfo) (_ []byte, ok bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	entry, ok := c.objects[objectName{bucket, object}][scope]
+	if !ok {
+		c.mu.Unlock()
+		return nil, false
+	}
+	if entry.etag != info.ETag || !entry.modTime.Equal(info.ModTime) || entry.size != info.Size {
+		c.removeLocked(entry)
+		c.mu.Unlock()
+		removeCachedFile(entry)
+		return nil, false
+	}
+	c.lru.MoveToFront(entry.elem)
+	c.mu.Unlock()
+
+	if entry.path == "" {
+		return entry.data, true
+	}
+
+	// the file might be removed by an eviction in the meantime, which only
+	// makes this a miss.
+	data, err := os.ReadFile(entry.path)
+	if err != nil || int64(len(data)) != entry.size {
+		return nil, false
+	}
+	return data, true
+}
+
+// put caches data as the contents of object in scope, described by info.
+func (c *objectCache) put(scope, bucket, object string, info minio.ObjectInfo, data []byte) (err error) {
+	if !c.fits(info.Size) || int64(len(data)) != info.Size {
+		return nil
+	}
+
+	entry := &cachedObject{
+		name:    objectName{bucket, object},
+		scope:   scope,
+		etag:    info.ETag,
+		modTime: info.ModTime,
+		size:    info.Size,
+	}
+
+	if c.dir == "" {
+		entry.data = append([]byte(nil), data...)
+	} else {
+		c.mu.Lock()
+		c.next++
+		entry.path = filepath.Join(c.dir, strconv.FormatUint(c.next, 10))
+		c.mu.Unlock()
+
+		if err := os.WriteFile(entry.path, data, 0600); err != nil {
+			return errs.Combine(err, os.Remove(entry.path))
+		}
+	}
+
+	var toRemove []*cachedObject
+
+	c.mu.Lock()
+	if old, ok := c.objects[entry.name][scope]; ok {
+		c.removeLocked(old)
+		toRemove = append(toRemove, old)
+	}
+
+	scopes, ok := c.objects[entry.name]
+	if !ok {
+		scopes = make(map[string]*cachedObject)
+		c.objects[entry.name] = scopes
+	}
+	scopes[scope] = entry
+	entry.elem = c.lru.PushFront(entry)
+	c.size += entry.size
+
+	for c.size > c.capacity {
+		evicted := c.lru.Back().Value.(*cachedObject)
+		c.removeLocked(evicted)
+		toRemove = append(toRemove, evicted)
+
+		mon.Counter("object_cache_eviction").Inc(1)
+	}
+
+	mon.IntVal("object_cache_size").Observe(c.size)
+	c.mu.Unlock()
+
+	for _, entry := range toRemove {
+		removeCachedFile(entry)
+	}
+
+	return nil
+}
+
+// invalidate removes the cached copies of object in all scopes.
+func (c *objectCache) invalidate(bucket, object string) {
+	if c == nil {
+		return
+	}
+
+	var toRemove []*cachedObject
+
+	c.mu.Lock()
+	for _, entry := range c.objects[objectName{bucket, object}] {
+		c.removeLocked(entry)
+		toRemove = append(toRemove, entry)
+	}
+	c.mu.Unlock()
+
+	for _, entry := range toRemove {
+		removeCachedFile(entry)
+	}
+}
+
+// removeLocked removes entry from the cache. Its file, if any, has to be
+// removed with removeCachedFile.
+func (c *objectCache) removeLocked(entry *cachedObject) {
+	c.lru.Remove(entry.elem)
+	c.size -= entry.size
+
+	scopes := c.objects[entry.name]
+	delete(scopes, entry.scope)
+	if len(scopes) == 0 {
+		delete(c.objects, entry.name)
+	}
+}
+
+func removeCachedFile(entry *cachedObject) {
+	if entry.path != "" {
+		_ = os.Remove(entry.path)
+	}
+}
+
+// Close removes all entries and the directory they were kept in.
+func (c *objectCache) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.objects = make(map[objectName]map[string]*cachedObject)
+	c.lru.Init()
+	c.size = 0
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return nil
+	}
+	return errs.Wrap(os.RemoveAll(c.dir))
+}
+
+// getCachedObject returns a reader of the cached copy of object, or nil if
+// there is no up-to-date one.
+//
+// The object is stat'ed first, so the satellite still checks that the access
+// grant allows reading it, and the cached copy is only served if it was read
+// from the object as it currently is.
+func (l *MultiTenancyLayer) getCachedObject(ctx context.Context, project *uplink.Project, bucket, object string, rs *minio.HTTPRangeSpec, opts minio.ObjectOptions) (_ *minio.GetObjectReader, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	scope := objectCacheScope(getAccessGrant(ctx))
+	if !l.objects.has(scope, bucket, object) {
+		mon.Counter("object_cache_miss").Inc(1)
+		return nil, nil
+	}
+
+	info, err := l.layer.GetObjectInfo(miniogw.WithUplinkProject(ctx, project), bucket, object, minio.ObjectOptions{})
+	if err != nil {
+		if errors.As(err, &minio.ObjectNotFound{}) {
+			l.objects.invalidate(bucket, object)
+		}
+		return nil, err
+	}
+
+	data, ok := l.objects.get(scope, bucket, object, info)
+	if !ok {
+		mon.Counter("object_cache_miss").Inc(1)
+		return nil, nil
+	}
+
+	mon.Counter("object_cache_hit").Inc(1)
+
+	if rs != nil {
+		offset, length, err := rs.GetOffsetLength(info.Size)
+		if err != nil {
+			return nil, err
+		}
+		data = data[offset : offset+length]
+	}
+
+	return minio.NewGetObjectReaderFromReader(bytes.NewReader(data), info, opts)
+}
+
+// cacheObjectReader returns a reader reading reader that caches the object
+// once it was read whole.
+func (l *MultiTenancyLayer) cacheObjectReader(ctx context.Context, reader *minio.GetObjectReader, bucket, object string) (*minio.GetObjectReader, error) {
+	info := reader.ObjInfo
+	if !l.objects.fits(info.Size) {
+		return reader, nil
+	}
+
+	scope := objectCacheScope(getAccessGrant(ctx))
+
+	caching := &cachingReader{
+		reader: reader,
+		limit:  info.Size,
+		done: func(data []byte) {
+			if err := l.objects.put(scope, bucket, object, info, data); err != nil {
+				_ = l.log(ctx, err)
+			}
+		},
+	}
+
+	return minio.NewGetObjectReaderFromReader(caching, info, minio.ObjectOptions{}, func() { _ = reader.Close() })
+}
+
+// cachingReader keeps what's read through it and passes it to done once the
+// underlying reader is read until EOF, unless more than limit bytes were read.
+type cachingReader struct {
+	reader io.Reader
+	limit  int64
+	done   func(data []byte)
+
+	buf bytes.Buffer
+}
+
+func (r *cachingReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	if r.done == nil {
+		return n, err
+	}
+
+	if int64(r.buf.Len()+n) > r.limit {
+		r.done, r.buf = nil, bytes.Buffer{}
+		return n, err
+	}
+	_, _ = r.buf.Write(p[:n])
+
+	if errors.Is(err, io.EOF) {
+		done := r.done
+		r.done = nil
+		done(r.buf.Bytes())
+	}
+	return n, err
+}
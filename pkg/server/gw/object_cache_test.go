@@ -0,0 +1,138 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/memory"
+	"storj.io/common/testcontext"
+	minio "storj.io/minio/cmd"
+)
+
+func TestObjectCacheDisabled(t *testing.T) {
+	cache, err := newObjectCache(ObjectCacheConfig{MaxObjectSize: memory.MiB})
+	require.NoError(t, err)
+	require.Nil(t, cache)
+
+	require.False(t, cache.fits(1))
+	require.False(t, cache.has("scope", "bucket", "key"))
+	require.NoError(t, cache.put("scope", "bucket", "key", minio.ObjectInfo{Size: 1}, []byte("a")))
+	_, ok := cache.get("scope", "bucket", "key", minio.ObjectInfo{Size: 1})
+	require.False(t, ok)
+	cache.invalidate("bucket", "key")
+	require.NoError(t, cache.Close())
+}
+
+func TestObjectCache(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	for _, tt := range []struct {
+		name string
+		dir  string
+	}{
+		{name: "memory"},
+		{name: "disk", dir: ctx.Dir("objects")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := newObjectCache(ObjectCacheConfig{Capacity: 10, MaxObjectSize: 5, Dir: tt.dir})
+			require.NoError(t, err)
+
+			info := minio.ObjectInfo{ETag: "etag", ModTime: time.Now(), Size: 3}
+
+			require.NoError(t, cache.put("a", "bucket", "key", info, []byte("abc")))
+			require.True(t, cache.has("a", "bucket", "key"))
+			require.False(t, cache.has("b", "bucket", "key"))
+
+			data, ok := cache.get("a", "bucket", "key", info)
+			require.True(t, ok)
+			require.Equal(t, "abc", string(data))
+
+			// copies are only served in the scope they were read in...
+			_, ok = cache.get("b", "bucket", "key", info)
+			require.False(t, ok)
+
+			// ...and while they were read from the same object.
+			changed := info
+			changed.ModTime = info.ModTime.Add(time.Second)
+			_, ok = cache.get("a", "bucket", "key", changed)
+			require.False(t, ok)
+			require.False(t, cache.has("a", "bucket", "key"))
+
+			// objects that are too large or whose size doesn't match aren't
+			// cached.
+			require.False(t, cache.fits(6))
+			require.NoError(t, cache.put("a", "bucket", "large", minio.ObjectInfo{Size: 6}, []byte("abcdef")))
+			require.False(t, cache.has("a", "bucket", "large"))
+			require.NoError(t, cache.put("a", "bucket", "short", minio.ObjectInfo{Size: 4}, []byte("abc")))
+			require.False(t, cache.has("a", "bucket", "short"))
+
+			// writes invalidate the copies in all scopes.
+			require.NoError(t, cache.put("a", "bucket", "key", info, []byte("abc")))
+			require.NoError(t, cache.put("b", "bucket", "key", info, []byte("abc")))
+			cache.invalidate("bucket", "key")
+			require.False(t, cache.has("a", "bucket", "key"))
+			require.False(t, cache.has("b", "bucket", "key"))
+			require.Zero(t, cache.size)
+
+			// the least recently used copies are evicted.
+			for _, key := range []string{"1", "2", "3"} {
+				require.NoError(t, cache.put("a", "bucket", key, info, []byte("abc")))
+			}
+			_, ok = cache.get("a", "bucket", "1", info)
+			require.True(t, ok)
+			require.NoError(t, cache.put("a", "bucket", "4", info, []byte("abc")))
+
+			require.True(t, cache.has("a", "bucket", "1"))
+			require.False(t, cache.has("a", "bucket", "2"))
+			require.EqualValues(t, 9, cache.size)
+
+			if tt.dir != "" {
+				files, err := os.ReadDir(cache.dir)
+				require.NoError(t, err)
+				require.Len(t, files, 3)
+			}
+
+			require.NoError(t, cache.Close())
+			require.False(t, cache.has("a", "bucket", "1"))
+
+			if tt.dir != "" {
+				_, err = os.Stat(cache.dir)
+				require.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+}
+
+func TestCachingReader(t *testing.T) {
+	var cached []byte
+	reader := &cachingReader{
+		reader: iotest.OneByteReader(bytes.NewReader([]byte("abc"))),
+		limit:  3,
+		done:   func(data []byte) { cached = data },
+	}
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "abc", string(data))
+	require.Equal(t, "abc", string(cached))
+
+	cached = nil
+	reader = &cachingReader{
+		reader: bytes.NewReader([]byte("abcd")),
+		limit:  3,
+		done:   func(data []byte) { cached = data },
+	}
+	data, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "abcd", string(data))
+	require.Nil(t, cached)
+}
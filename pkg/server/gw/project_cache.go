@@ -0,0 +1,256 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/uplink"
+)
+
+// ProjectCacheConfig configures the cache of open uplink projects.
+type ProjectCacheConfig struct {
+	Capacity   int           `help:"maximum number of open projects kept in the project cache (0 disables the cache)" default:"1000"`
+	Expiration time.Duration `help:"how long an unused project is kept in the project cache" default:"1m0s"`
+}
+
+// projectCache is a bounded, expiring cache of open uplink projects keyed by
+// the hash of the access grant (and the user agent the project was opened
+// with).
+//
+// Projects are reference counted so that projects used by in-flight requests
+// are only closed once the last user releases them, even if they have been
+// evicted in the meantime.
+type projectCache struct {
+	capacity   int
+	expiration time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	closed  bool
+	entries map[string]*cachedProject
+	lru     *list.List // front is the most recently used entry
+}
+
+type cachedProject struct {
+	key      string
+	project  *uplink.Project
+	refs     int
+	lastUsed time.Time
+	evicted  bool
+	elem     *list.Element
+}
+
+func newProjectCache(config ProjectCacheConfig) *projectCache {
+	return &projectCache{
+		capacity:   config.Capacity,
+		expiration: config.Expiration,
+		now:        time.Now,
+		entries:    make(map[string]*cachedProject),
+		lru:        list.New(),
+	}
+}
+
+// projectCacheKey returns the key under which a project opened for
+// accessGrant and userAgent is stored. The access grant is hashed so that the
+// cache doesn't keep serialized grants around.
+func projectCacheKey(accessGrant, userAgent string) string {
+	h := sha256.New()
+	_, _ = h.Write([]byte(accessGrant))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(userAgent))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns the project stored under key or opens (and stores) a new one
+// using open. The returned release function must be called once the caller
+// is done with the project.
+//
+// It only returns an error if open fails. Projects evicted along the way
+// belong to other requests, so failing to close them is monitored instead.
+func (c *projectCache) get(ctx context.Context, key string, open func() (*uplink.Project, error)) (_ *uplink.Project, release func() error, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if c == nil || c.capacity <= 0 {
+		project, err := open()
+		if err != nil {
+			return nil, nil, err
+		}
+		return project, project.Close, nil
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		project, err := open()
+		if err != nil {
+			return nil, nil, err
+		}
+		return project, project.Close, nil
+	}
+	toClose := c.expireLocked()
+	if entry, ok := c.entries[key]; ok {
+		entry.refs++
+		entry.lastUsed = c.now()
+		c.lru.MoveToFront(entry.elem)
+		c.mu.Unlock()
+
+		mon.Counter("project_cache_hit").Inc(1)
+
+		closeEvicted(toClose)
+
+		return entry.project, c.releaseFunc(entry), nil
+	}
+	c.mu.Unlock()
+
+	mon.Counter("project_cache_miss").Inc(1)
+
+	// opening a project doesn't dial anything, but we still don't want to
+	// hold the lock while doing it.
+	project, err := open()
+	if err != nil {
+		closeEvicted(toClose)
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		closeEvicted(toClose)
+		return project, project.Close, nil
+	}
+	if entry, ok := c.entries[key]; ok {
+		// somebody else opened the same project concurrently; use theirs.
+		entry.refs++
+		entry.lastUsed = c.now()
+		c.lru.MoveToFront(entry.elem)
+		c.mu.Unlock()
+
+		closeEvicted(append(toClose, project))
+
+		return entry.project, c.releaseFunc(entry), nil
+	}
+
+	entry := &cachedProject{
+		key:      key,
+		project:  project,
+		refs:     1,
+		lastUsed: c.now(),
+	}
+	entry.elem = c.lru.PushFront(entry)
+	c.entries[key] = entry
+
+	for c.lru.Len() > c.capacity {
+		if p := c.evictLocked(c.lru.Back().Value.(*cachedProject)); p != nil {
+			toClose = append(toClose, p)
+		}
+	}
+
+	mon.IntVal("project_cache_size").Observe(int64(c.lru.Len()))
+	c.mu.Unlock()
+
+	closeEvicted(toClose)
+
+	return project, c.releaseFunc(entry), nil
+}
+
+// releaseFunc returns a function that drops a reference to entry and closes
+// its project if the entry has been evicted and nobody uses it anymore.
+func (c *projectCache) releaseFunc(entry *cachedProject) func() error {
+	var once sync.Once
+	return func() (err error) {
+		once.Do(func() {
+			c.mu.Lock()
+			entry.refs--
+			closeNow := entry.evicted && entry.refs == 0
+			c.mu.Unlock()
+
+			if closeNow {
+				err = entry.project.Close()
+			}
+		})
+		return err
+	}
+}
+
+// expireLocked evicts all entries that haven't been used for longer than the
+// configured expiration and returns projects that can be closed right away.
+func (c *projectCache) expireLocked() (toClose []*uplink.Project) {
+	if c.expiration <= 0 {
+		return nil
+	}
+	deadline := c.now().Add(-c.expiration)
+	for e := c.lru.Back(); e != nil; {
+		entry := e.Value.(*cachedProject)
+		if !entry.lastUsed.Before(deadline) {
+			break
+		}
+		e = e.Prev()
+		if p := c.evictLocked(entry); p != nil {
+			toClose = append(toClose, p)
+		}
+	}
+	return toClose
+}
+
+// evictLocked removes entry from the cache. It returns entry's project if it
+// isn't used by anybody and can be closed.
+func (c *projectCache) evictLocked(entry *cachedProject) *uplink.Project {
+	c.lru.Remove(entry.elem)
+	delete(c.entries, entry.key)
+	entry.evicted = true
+
+	mon.Counter("project_cache_eviction").Inc(1)
+
+	if entry.refs == 0 {
+		return entry.project
+	}
+	return nil
+}
+
+// Close evicts all entries and closes projects that aren't in use. Projects
+// still in use are closed when they are released. Projects requested after
+// Close are not cached.
+func (c *projectCache) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.closed = true
+	var toClose []*uplink.Project
+	for c.lru.Len() > 0 {
+		if p := c.evictLocked(c.lru.Back().Value.(*cachedProject)); p != nil {
+			toClose = append(toClose, p)
+		}
+	}
+	c.mu.Unlock()
+
+	return closeProjects(toClose)
+}
+
+// closeEvicted closes projects that aren't needed anymore on behalf of a
+// request that doesn't own them, counting failures instead of returning them.
+func closeEvicted(projects []*uplink.Project) {
+	for _, p := range projects {
+		if err := p.Close(); err != nil {
+			mon.Counter("project_cache_close_error").Inc(1)
+		}
+	}
+}
+
+func closeProjects(projects []*uplink.Project) error {
+	var group errs.Group
+	for _, p := range projects {
+		group.Add(p.Close())
+	}
+	return group.Err()
+}
@@ -0,0 +1,209 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
+
+	"storj.io/common/grant"
+	"storj.io/common/macaroon"
+	"storj.io/common/testcontext"
+	"storj.io/uplink"
+)
+
+func newTestAccessGrant(t *testing.T) string {
+	apiKey, err := macaroon.NewAPIKey([]byte("secret"))
+	require.NoError(t, err)
+
+	access := grant.Access{
+		SatelliteAddress: "1SYXsAycDPUu4z2ZksJD5fh5nTDcH3vCFHnpcVye5XuL1NrYV@127.0.0.1:7777",
+		APIKey:           apiKey,
+		EncAccess:        grant.NewEncryptionAccess(),
+	}
+
+	serialized, err := access.Serialize()
+	require.NoError(t, err)
+
+	return serialized
+}
+
+type countingOpener struct {
+	t      *testing.T
+	ctx    context.Context
+	access string
+
+	mu     sync.Mutex
+	opened int
+}
+
+func (o *countingOpener) open() (*uplink.Project, error) {
+	o.mu.Lock()
+	o.opened++
+	o.mu.Unlock()
+
+	access, err := uplink.ParseAccess(o.access)
+	require.NoError(o.t, err)
+
+	return uplink.Config{}.OpenProject(o.ctx, access)
+}
+
+func TestProjectCacheHit(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	cache := newProjectCache(ProjectCacheConfig{Capacity: 10, Expiration: time.Minute})
+	opener := &countingOpener{t: t, ctx: ctx, access: newTestAccessGrant(t)}
+
+	p1, release1, err := cache.get(ctx, "a", opener.open)
+	require.NoError(t, err)
+	p2, release2, err := cache.get(ctx, "a", opener.open)
+	require.NoError(t, err)
+
+	require.Same(t, p1, p2)
+	require.Equal(t, 1, opener.opened)
+	require.Equal(t, 2, cache.entries["a"].refs)
+
+	require.NoError(t, release1())
+	require.NoError(t, release1()) // releasing twice must not drop two references
+	require.Equal(t, 1, cache.entries["a"].refs)
+	require.NoError(t, release2())
+	require.Equal(t, 0, cache.entries["a"].refs)
+
+	_, release3, err := cache.get(ctx, "b", opener.open)
+	require.NoError(t, err)
+	require.NoError(t, release3())
+	require.Equal(t, 2, opener.opened)
+
+	require.NoError(t, cache.Close())
+	require.Empty(t, cache.entries)
+}
+
+func TestProjectCacheCapacity(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	cache := newProjectCache(ProjectCacheConfig{Capacity: 2, Expiration: time.Minute})
+	opener := &countingOpener{t: t, ctx: ctx, access: newTestAccessGrant(t)}
+
+	_, releaseA, err := cache.get(ctx, "a", opener.open)
+	require.NoError(t, err)
+	_, releaseB, err := cache.get(ctx, "b", opener.open)
+	require.NoError(t, err)
+	require.NoError(t, releaseB())
+
+	// "a" is still in use, but it's the least recently used entry, so it's
+	// evicted and closed only once it's released.
+	_, releaseC, err := cache.get(ctx, "c", opener.open)
+	require.NoError(t, err)
+	require.NoError(t, releaseC())
+
+	require.Len(t, cache.entries, 2)
+	require.NotContains(t, cache.entries, "a")
+
+	require.NoError(t, releaseA())
+
+	_, releaseA, err = cache.get(ctx, "a", opener.open)
+	require.NoError(t, err)
+	require.NoError(t, releaseA())
+	require.Equal(t, 4, opener.opened)
+	require.NotContains(t, cache.entries, "b")
+}
+
+func TestProjectCacheExpiration(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	now := time.Now()
+
+	cache := newProjectCache(ProjectCacheConfig{Capacity: 10, Expiration: time.Minute})
+	cache.now = func() time.Time { return now }
+	opener := &countingOpener{t: t, ctx: ctx, access: newTestAccessGrant(t)}
+
+	_, release, err := cache.get(ctx, "a", opener.open)
+	require.NoError(t, err)
+	require.NoError(t, release())
+
+	now = now.Add(30 * time.Second)
+
+	_, release, err = cache.get(ctx, "a", opener.open)
+	require.NoError(t, err)
+	require.NoError(t, release())
+	require.Equal(t, 1, opener.opened)
+
+	now = now.Add(2 * time.Minute)
+
+	_, release, err = cache.get(ctx, "a", opener.open)
+	require.NoError(t, err)
+	require.NoError(t, release())
+	require.Equal(t, 2, opener.opened)
+}
+
+func TestProjectCacheOpenError(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	now := time.Now()
+
+	cache := newProjectCache(ProjectCacheConfig{Capacity: 10, Expiration: time.Minute})
+	cache.now = func() time.Time { return now }
+	opener := &countingOpener{t: t, ctx: ctx, access: newTestAccessGrant(t)}
+
+	_, release, err := cache.get(ctx, "a", opener.open)
+	require.NoError(t, err)
+	require.NoError(t, release())
+
+	now = now.Add(2 * time.Minute)
+
+	// "a" is expired along the way, but only the error of open is returned.
+	openErr := errs.New("open failed")
+	_, _, err = cache.get(ctx, "b", func() (*uplink.Project, error) { return nil, openErr })
+	require.ErrorIs(t, err, openErr)
+	require.Empty(t, cache.entries)
+}
+
+func TestProjectCacheDisabledAndClosed(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	opener := &countingOpener{t: t, ctx: ctx, access: newTestAccessGrant(t)}
+
+	disabled := newProjectCache(ProjectCacheConfig{})
+	for i := 0; i < 2; i++ {
+		_, release, err := disabled.get(ctx, "a", opener.open)
+		require.NoError(t, err)
+		require.NoError(t, release())
+	}
+	require.Equal(t, 2, opener.opened)
+	require.Empty(t, disabled.entries)
+
+	cache := newProjectCache(ProjectCacheConfig{Capacity: 10, Expiration: time.Minute})
+
+	_, inFlight, err := cache.get(ctx, "a", opener.open)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Close())
+	require.Empty(t, cache.entries)
+
+	// the in-flight project is closed on release.
+	require.NoError(t, inFlight())
+
+	_, release, err := cache.get(ctx, "a", opener.open)
+	require.NoError(t, err)
+	require.NoError(t, release())
+	require.Empty(t, cache.entries)
+	require.Equal(t, 4, opener.opened)
+}
+
+func TestProjectCacheKey(t *testing.T) {
+	require.Equal(t, projectCacheKey("grant", "ua"), projectCacheKey("grant", "ua"))
+	require.NotEqual(t, projectCacheKey("grant", "ua"), projectCacheKey("grant", "other"))
+	require.NotEqual(t, projectCacheKey("grant", "ua"), projectCacheKey("grant2", "ua"))
+	require.NotContains(t, projectCacheKey("grant", "ua"), "grant")
+}
@@ -0,0 +1,255 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	miniogo "github.com/minio/minio-go/v7"
+
+	"storj.io/common/grant"
+	"storj.io/gateway-mt/pkg/authclient"
+	"storj.io/gateway-mt/pkg/bucketconfig"
+	"storj.io/gateway-mt/pkg/errdata"
+	"storj.io/gateway-mt/pkg/server/middleware"
+	"storj.io/minio/pkg/bucket/policy"
+	"storj.io/uplink"
+)
+
+// publicReadProject is the project the public read documents of bucket names
+// are kept for. Projects of buckets are named after their satellite and API
+// key (see configBucket), so it can't be mistaken for one.
+const publicReadProject = "public"
+
+// publicReadConfigName is the name of the document recording which bucket
+// with a name anonymous requests read from.
+const publicReadConfigName = "public-read.json"
+
+var (
+	// ErrPublicReadConflict occurs when a client attempts to allow anonymous
+	// reads of a bucket while a bucket with the same name of another project
+	// allows them.
+	ErrPublicReadConflict = miniogo.ErrorResponse{
+		Code:       "OperationAborted",
+		StatusCode: http.StatusConflict,
+		Message:    "A bucket with this name of another project already allows anonymous reads.",
+	}
+
+	// ErrPublicReadNotSupported occurs when a client attempts to allow
+	// anonymous reads of a bucket through a gateway without an auth service
+	// to register public accesses with.
+	ErrPublicReadNotSupported = miniogo.ErrorResponse{
+		Code:       "NotImplemented",
+		StatusCode: http.StatusNotImplemented,
+		Message:    "Bucket policies allowing anonymous reads are not supported.",
+	}
+)
+
+// publicReadDocument records the bucket of a project whose policy allows
+// anonymous reads, and the public access they're served with.
+type publicReadDocument struct {
+	Project     string `json:"project"`
+	AccessKeyID string `json:"access_key_id"`
+}
+
+// publicRead is a cached public read document together with the policy of
+// its bucket. It's zero if anonymous reads of the bucket aren't allowed.
+type publicRead struct {
+	accessKeyID string
+	policy      *policy.Policy
+}
+
+// publicReadPrefixes returns the prefixes of the keys p allows anonymous reads
+// of in bucket. Prefixes are cut after their last slash, as accesses can't be
+// shared for part of a path component, and the policy is checked against
+// every anonymous request anyway.
+func publicReadPrefixes(p *policy.Policy, bucket string) []string {
+	seen := make(map[string]bool)
+	var prefixes []string
+	for _, statement := range p.Statements {
+		if statement.Effect != policy.Allow || !statement.Principal.Match("") || !statement.Actions.Contains(policy.GetObjectAction) {
+			continue
+		}
+		for resource := range statement.Resources {
+			if !strings.HasPrefix(resource.Pattern, bucket+"/") {
+				continue
+			}
+			pattern := strings.TrimPrefix(resource.Pattern, bucket+"/")
+			if i := strings.IndexAny(pattern, "*?"); i >= 0 {
+				pattern = pattern[:i]
+			}
+			prefix := pattern[:strings.LastIndexByte(pattern, '/')+1]
+			if !seen[prefix] {
+				seen[prefix] = true
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+	return prefixes
+}
+
+// setPublicRead registers a public access to serve the anonymous reads p
+// allows of bucket with, which key identifies, or stops serving them if p
+// allows none.
+//
+// Anonymous requests only name the bucket, so a bucket name can only allow
+// them for a single project.
+func (l *MultiTenancyLayer) setPublicRead(ctx context.Context, key bucketconfig.Bucket, p *policy.Policy) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	prefixes := publicReadPrefixes(p, key.Name)
+	if len(prefixes) == 0 {
+		return l.deletePublicRead(ctx, key.Name)
+	}
+	if l.authClient == nil {
+		return ErrPublicReadNotSupported
+	}
+
+	defer l.publicReads.Delete(key.Name)
+
+	existing, err := l.publicReadDocument(ctx, key.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Project != key.Project {
+		return ErrPublicReadConflict
+	}
+
+	access, err := uplink.ParseAccess(getAccessGrant(ctx))
+	if err != nil {
+		return ErrAccessGrant.Wrap(err)
+	}
+
+	sharePrefixes := make([]uplink.SharePrefix, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		sharePrefixes = append(sharePrefixes, uplink.SharePrefix{Bucket: key.Name, Prefix: prefix})
+	}
+	shared, err := access.Share(uplink.Permission{AllowDownload: true}, sharePrefixes...)
+	if err != nil {
+		return ErrAccessGrant.Wrap(err)
+	}
+	serialized, err := shared.Serialize()
+	if err != nil {
+		return ErrAccessGrant.Wrap(err)
+	}
+
+	accessKeyID, err := l.authClient.Register(ctx, serialized, true)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(publicReadDocument{Project: key.Project, AccessKeyID: accessKeyID})
+	if err != nil {
+		return ErrBucketConfig.Wrap(err)
+	}
+
+	publicReadKey := bucketconfig.Bucket{Project: publicReadProject, Name: key.Name}
+	return ErrBucketConfig.Wrap(l.bucketConfigDB.Put(ctx, publicReadKey, publicReadConfigName, data))
+}
+
+// deletePublicRead stops serving anonymous reads of bucket if it's the bucket
+// of the project of the access grant of ctx that allows them.
+func (l *MultiTenancyLayer) deletePublicRead(ctx context.Context, bucket string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	defer l.publicReads.Delete(bucket)
+
+	access, err := grant.ParseAccess(getAccessGrant(ctx))
+	if err != nil {
+		return ErrAccessGrant.Wrap(err)
+	}
+
+	key, err := configBucket(access, bucket)
+	if err != nil {
+		return err
+	}
+
+	existing, err := l.publicReadDocument(ctx, bucket)
+	if err != nil || existing == nil || existing.Project != key.Project {
+		return err
+	}
+
+	publicReadKey := bucketconfig.Bucket{Project: publicReadProject, Name: bucket}
+	return ErrBucketConfig.Wrap(l.bucketConfigDB.Delete(ctx, publicReadKey, publicReadConfigName))
+}
+
+// publicReadDocument returns the public read document of bucket or nil if no
+// bucket with its name allows anonymous reads.
+func (l *MultiTenancyLayer) publicReadDocument(ctx context.Context, bucket string) (*publicReadDocument, error) {
+	data, err := l.bucketConfigDB.Get(ctx, bucketconfig.Bucket{Project: publicReadProject, Name: bucket}, publicReadConfigName)
+	if err != nil || data == nil {
+		return nil, ErrBucketConfig.Wrap(err)
+	}
+
+	var document publicReadDocument
+	if err = json.Unmarshal(data, &document); err != nil {
+		return nil, ErrBucketConfig.Wrap(err)
+	}
+	return &document, nil
+}
+
+// PublicReadCredentials returns the credentials to serve an anonymous read of
+// object in bucket by a client at clientIP with, or nil if it isn't allowed.
+//
+// Anonymous requests carry no access grant to read the policy of bucket (or
+// the object) with. Instead, they're served with the public access registered
+// when a bucket with that name was given a policy allowing them, which is
+// resolved through the auth service like websites are. The credentials have
+// no secret key, as the request isn't signed.
+func (l *MultiTenancyLayer) PublicReadCredentials(ctx context.Context, bucket, object, clientIP string) (_ *middleware.Credentials, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	if l.authClient == nil {
+		return nil, nil
+	}
+
+	value, err := l.publicReads.Get(bucket, func() (interface{}, error) {
+		document, err := l.publicReadDocument(ctx, bucket)
+		if err != nil || document == nil {
+			return publicRead{}, err
+		}
+
+		data, err := l.bucketConfigDB.Get(ctx, bucketconfig.Bucket{Project: document.Project, Name: bucket}, policyConfigName)
+		if err != nil || data == nil {
+			return publicRead{}, ErrBucketConfig.Wrap(err)
+		}
+
+		p, err := ParseBucketPolicy(bytes.NewReader(data), bucket)
+		if err != nil {
+			return publicRead{}, ErrBucketConfig.Wrap(err)
+		}
+		return publicRead{accessKeyID: document.AccessKeyID, policy: p}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	read := value.(publicRead)
+	if read.policy == nil || !bucketPolicyAllows(read.policy, "", policy.GetObjectAction, bucket, object) {
+		return nil, nil
+	}
+
+	authResponse, err := l.authClient.ResolveWithCache(ctx, read.accessKeyID, clientIP)
+	if err != nil {
+		if errdata.GetStatus(err, http.StatusInternalServerError) >= http.StatusInternalServerError {
+			return nil, err
+		}
+		return nil, nil
+	}
+	if !authResponse.Public {
+		return nil, nil
+	}
+
+	return &middleware.Credentials{
+		AccessKey: read.accessKeyID,
+		AuthServiceResponse: authclient.AuthServiceResponse{
+			AccessGrant: authResponse.AccessGrant,
+			Public:      true,
+		},
+	}, nil
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/lrucache"
+	"storj.io/gateway-mt/pkg/authclient"
+	"storj.io/gateway-mt/pkg/bucketconfig"
+)
+
+func TestPublicReadPrefixes(t *testing.T) {
+	p, err := ParseBucketPolicy(strings.NewReader(`{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": ["arn:aws:s3:::bucket/public/*", "arn:aws:s3:::bucket/public/images/*"]},
+			{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/docs/*.pdf"},
+			{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/read?e.txt"},
+			{"Effect": "Allow", "Principal": {"AWS": ["key"]}, "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/private/*"},
+			{"Effect": "Deny", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/public/secret/*"}
+		]
+	}`), "bucket")
+	require.NoError(t, err)
+
+	require.ElementsMatch(t, []string{"public/", "public/images/", "docs/", ""}, publicReadPrefixes(p, "bucket"))
+
+	p, err = ParseBucketPolicy(strings.NewReader(`{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Effect": "Deny", "Principal": "*", "Action": "s3:DeleteObject", "Resource": "arn:aws:s3:::bucket/*"}
+		]
+	}`), "bucket")
+	require.NoError(t, err)
+
+	require.Empty(t, publicReadPrefixes(p, "bucket"))
+}
+
+func TestPublicReadCredentials(t *testing.T) {
+	ctx := context.Background()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/access/publickey":
+			_, _ = w.Write([]byte(`{"public":true, "secret_key":"secret", "access_grant":"publicgrant"}`))
+		case "/v1/access/privatekey":
+			_, _ = w.Write([]byte(`{"public":false, "secret_key":"secret", "access_grant":"privategrant"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	db := bucketconfig.NewMemory()
+	layer := &MultiTenancyLayer{
+		bucketConfigDB: db,
+		authClient:     authclient.New(authclient.Config{BaseURL: ts.URL, Token: "token"}),
+		publicReads:    lrucache.New(lrucache.Options{}),
+	}
+
+	put := func(project, bucket, name, data string) {
+		require.NoError(t, db.Put(ctx, bucketconfig.Bucket{Project: project, Name: bucket}, name, []byte(data)))
+	}
+
+	put(publicReadProject, "bucket", publicReadConfigName, `{"project":"project","access_key_id":"publickey"}`)
+	put("project", "bucket", policyConfigName, `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/public/*"}]}`)
+
+	put(publicReadProject, "private", publicReadConfigName, `{"project":"project","access_key_id":"privatekey"}`)
+	put("project", "private", policyConfigName, `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::private/*"}]}`)
+
+	// the policy of the bucket of another project with the name doesn't
+	// apply.
+	put(publicReadProject, "other", publicReadConfigName, `{"project":"project","access_key_id":"publickey"}`)
+	put("other project", "other", policyConfigName, `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::other/*"}]}`)
+
+	credentials, err := layer.PublicReadCredentials(ctx, "bucket", "public/object", "127.0.0.1")
+	require.NoError(t, err)
+	require.NotNil(t, credentials)
+	require.Equal(t, "publickey", credentials.AccessKey)
+	require.Equal(t, "publicgrant", credentials.AccessGrant)
+	require.Empty(t, credentials.SecretKey)
+
+	for _, tt := range []struct{ bucket, object string }{
+		{bucket: "bucket", object: "private/object"},
+		{bucket: "private", object: "object"},
+		{bucket: "other", object: "object"},
+		{bucket: "unknown", object: "object"},
+	} {
+		credentials, err := layer.PublicReadCredentials(ctx, tt.bucket, tt.object, "127.0.0.1")
+		require.NoError(t, err, tt.bucket)
+		require.Nil(t, credentials, tt.bucket)
+	}
+}
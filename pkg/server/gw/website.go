@@ -0,0 +1,274 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/zeebo/errs"
+
+	"storj.io/uplink"
+)
+
+// websiteConfigName is the name of the bucket configuration document holding
+// the website configuration.
+const websiteConfigName = "website.xml"
+
+// maxWebsiteRoutingRules is the maximum number of routing rules of a website
+// configuration, as in S3.
+const maxWebsiteRoutingRules = 50
+
+// ErrNoSuchWebsiteConfiguration occurs when a client attempts to get the
+// website configuration of a bucket that has none set.
+var ErrNoSuchWebsiteConfiguration = miniogo.ErrorResponse{
+	Code:       "NoSuchWebsiteConfiguration",
+	StatusCode: http.StatusNotFound,
+	Message:    "The specified bucket does not have a website configuration",
+}
+
+// WebsiteConfig is a bucket website configuration.
+type WebsiteConfig struct {
+	XMLName       xml.Name              `xml:"WebsiteConfiguration"`
+	IndexDocument *WebsiteIndexDocument `xml:"IndexDocument,omitempty"`
+	ErrorDocument *WebsiteErrorDocument `xml:"ErrorDocument,omitempty"`
+	RoutingRules  []WebsiteRoutingRule  `xml:"RoutingRules>RoutingRule,omitempty"`
+
+	// RedirectAllRequestsTo isn't supported, it's only parsed to reject
+	// configurations using it.
+	RedirectAllRequestsTo *struct{} `xml:"RedirectAllRequestsTo,omitempty"`
+}
+
+// WebsiteIndexDocument is the document served for requests for a "directory"
+// of a website, i.e. for keys ending with a slash.
+type WebsiteIndexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+// WebsiteErrorDocument is the document served when a request for a website
+// fails.
+type WebsiteErrorDocument struct {
+	Key string `xml:"Key"`
+}
+
+// WebsiteRoutingRule redirects the requests for a website matching its
+// condition.
+type WebsiteRoutingRule struct {
+	Condition *WebsiteCondition `xml:"Condition,omitempty"`
+	Redirect  WebsiteRedirect   `xml:"Redirect"`
+}
+
+// WebsiteCondition is the condition of a routing rule. Rules without an error
+// code apply before the requested object is looked up, and rules with one
+// only if looking it up failed with that code.
+type WebsiteCondition struct {
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+	HTTPErrorCodeReturnedEquals int    `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+// WebsiteRedirect is where a routing rule redirects requests to.
+type WebsiteRedirect struct {
+	Protocol             string  `xml:"Protocol,omitempty"`
+	HostName             string  `xml:"HostName,omitempty"`
+	ReplaceKeyPrefixWith *string `xml:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       *string `xml:"ReplaceKeyWith,omitempty"`
+	HTTPRedirectCode     int     `xml:"HttpRedirectCode,omitempty"`
+}
+
+// invalidWebsiteConfig returns an error for an invalid website configuration.
+func invalidWebsiteConfig(format string, args ...interface{}) error {
+	return miniogo.ErrorResponse{
+		Code:       "InvalidArgument",
+		StatusCode: http.StatusBadRequest,
+		Message:    fmt.Sprintf(format, args...),
+	}
+}
+
+// ParseWebsiteConfig parses and validates a website configuration.
+func ParseWebsiteConfig(r io.Reader) (*WebsiteConfig, error) {
+	var config WebsiteConfig
+	if err := xml.NewDecoder(r).Decode(&config); err != nil {
+		return nil, err
+	}
+
+	if config.RedirectAllRequestsTo != nil {
+		return nil, miniogo.ErrorResponse{
+			Code:       "NotImplemented",
+			StatusCode: http.StatusNotImplemented,
+			Message:    "RedirectAllRequestsTo is not supported.",
+		}
+	}
+
+	if config.IndexDocument == nil {
+		return nil, invalidWebsiteConfig("A value for IndexDocument Suffix must be provided")
+	}
+	if suffix := config.IndexDocument.Suffix; suffix == "" || strings.Contains(suffix, "/") {
+		return nil, invalidWebsiteConfig("The IndexDocument Suffix is not well formed")
+	}
+	if config.ErrorDocument != nil && config.ErrorDocument.Key == "" {
+		return nil, invalidWebsiteConfig("The ErrorDocument Key is not well formed")
+	}
+
+	if len(config.RoutingRules) > maxWebsiteRoutingRules {
+		return nil, invalidWebsiteConfig("The number of routing rules must not exceed the allowed limit of %d rules", maxWebsiteRoutingRules)
+	}
+	for _, rule := range config.RoutingRules {
+		if condition := rule.Condition; condition != nil {
+			if condition.KeyPrefixEquals == "" && condition.HTTPErrorCodeReturnedEquals == 0 {
+				return nil, invalidWebsiteConfig("Condition cannot be empty. To redirect all requests without a condition, the condition element shouldn't be present.")
+			}
+			if code := condition.HTTPErrorCodeReturnedEquals; code != 0 && (code < 400 || code > 599) {
+				return nil, invalidWebsiteConfig("The provided HTTP error code (%d) is not valid. Valid codes are 4XX or 5XX.", code)
+			}
+		}
+
+		redirect := rule.Redirect
+		if redirect.Protocol == "" && redirect.HostName == "" && redirect.ReplaceKeyPrefixWith == nil && redirect.ReplaceKeyWith == nil && redirect.HTTPRedirectCode == 0 {
+			return nil, invalidWebsiteConfig("Redirect cannot be empty")
+		}
+		if redirect.Protocol != "" && redirect.Protocol != "http" && redirect.Protocol != "https" {
+			return nil, invalidWebsiteConfig("Invalid protocol, protocol can be http or https. If not defined the protocol will be selected automatically.")
+		}
+		if redirect.ReplaceKeyPrefixWith != nil && redirect.ReplaceKeyWith != nil {
+			return nil, invalidWebsiteConfig("You can only define ReplaceKeyPrefix or ReplaceKey but not both.")
+		}
+		if code := redirect.HTTPRedirectCode; code != 0 && (code < 300 || code > 399) {
+			return nil, invalidWebsiteConfig("The provided HTTP redirect code (%d) is not valid. Valid codes are 3XX except 300.", code)
+		}
+	}
+
+	return &config, nil
+}
+
+// IndexKey returns the key of the object served for key, which is the index
+// document of the "directory" key if it ends with a slash (or is empty).
+func (config *WebsiteConfig) IndexKey(key string) string {
+	if key == "" || strings.HasSuffix(key, "/") {
+		return key + config.IndexDocument.Suffix
+	}
+	return key
+}
+
+// Route returns the first routing rule applying to a request for key or nil
+// if none does. errorCode is the HTTP status code looking the object up
+// failed with, or 0 before it's looked up.
+func (config *WebsiteConfig) Route(key string, errorCode int) *WebsiteRoutingRule {
+	for i, rule := range config.RoutingRules {
+		condition := rule.Condition
+		if condition == nil {
+			condition = &WebsiteCondition{}
+		}
+		if condition.HTTPErrorCodeReturnedEquals == errorCode && strings.HasPrefix(key, condition.KeyPrefixEquals) {
+			return &config.RoutingRules[i]
+		}
+	}
+	return nil
+}
+
+// Location returns the URL the rule redirects a request for key made with
+// scheme to host to, and the status code to redirect it with.
+func (rule *WebsiteRoutingRule) Location(scheme, host, key string) (string, int) {
+	redirect := rule.Redirect
+
+	switch {
+	case redirect.ReplaceKeyWith != nil:
+		key = *redirect.ReplaceKeyWith
+	case redirect.ReplaceKeyPrefixWith != nil:
+		var prefix string
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+		key = *redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+	if redirect.Protocol != "" {
+		scheme = redirect.Protocol
+	}
+	if redirect.HostName != "" {
+		host = redirect.HostName
+	}
+
+	code := redirect.HTTPRedirectCode
+	if code == 0 {
+		code = http.StatusMovedPermanently
+	}
+
+	location := url.URL{Scheme: scheme, Host: host, Path: "/" + key}
+	return location.String(), code
+}
+
+// GetBucketWebsiteConfig returns the website configuration of bucket.
+func (l *MultiTenancyLayer) GetBucketWebsiteConfig(ctx context.Context, bucket string) (_ *WebsiteConfig, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	config, err := l.bucketWebsiteConfig(ctx, project, bucket)
+	if err != nil {
+		return nil, l.log(ctx, err)
+	}
+	if config == nil {
+		return nil, l.log(ctx, ErrNoSuchWebsiteConfiguration)
+	}
+	return config, nil
+}
+
+// SetBucketWebsiteConfig sets the website configuration of bucket.
+func (l *MultiTenancyLayer) SetBucketWebsiteConfig(ctx context.Context, bucket string, config *WebsiteConfig) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	data, err := xml.Marshal(config)
+	if err != nil {
+		return l.log(ctx, ErrBucketConfig.Wrap(err))
+	}
+
+	return l.log(ctx, l.putBucketConfig(ctx, project, bucket, websiteConfigName, data))
+}
+
+// DeleteBucketWebsiteConfig removes the website configuration of bucket.
+func (l *MultiTenancyLayer) DeleteBucketWebsiteConfig(ctx context.Context, bucket string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	project, release, err := l.openProject(ctx, getAccessGrant(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errs.Combine(err, release()) }()
+
+	return l.log(ctx, l.deleteBucketConfig(ctx, project, bucket, websiteConfigName))
+}
+
+// bucketWebsiteConfig returns the website configuration of bucket or nil if
+// it isn't set.
+func (l *MultiTenancyLayer) bucketWebsiteConfig(ctx context.Context, project *uplink.Project, bucket string) (*WebsiteConfig, error) {
+	data, err := l.getBucketConfig(ctx, project, bucket, websiteConfigName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	config, err := ParseWebsiteConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrBucketConfig.Wrap(err)
+	}
+	return config, nil
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package gw
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWebsiteConfig(t *testing.T) {
+	const index = `<IndexDocument><Suffix>index.html</Suffix></IndexDocument>`
+
+	for _, tt := range []struct {
+		name   string
+		config string
+		valid  bool
+	}{
+		{
+			name:   "index",
+			config: index,
+			valid:  true,
+		},
+		{
+			name:   "routing rules",
+			config: index + `<ErrorDocument><Key>404.html</Key></ErrorDocument><RoutingRules><RoutingRule><Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition><Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect></RoutingRule><RoutingRule><Condition><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition><Redirect><HostName>example.com</HostName><Protocol>https</Protocol><HttpRedirectCode>302</HttpRedirectCode></Redirect></RoutingRule></RoutingRules>`,
+			valid:  true,
+		},
+		{
+			name:   "no index",
+			config: `<ErrorDocument><Key>404.html</Key></ErrorDocument>`,
+		},
+		{
+			name:   "index with slash",
+			config: `<IndexDocument><Suffix>a/index.html</Suffix></IndexDocument>`,
+		},
+		{
+			name:   "empty error document",
+			config: index + `<ErrorDocument><Key></Key></ErrorDocument>`,
+		},
+		{
+			name:   "redirect all requests",
+			config: `<RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo>`,
+		},
+		{
+			name:   "empty condition",
+			config: index + `<RoutingRules><RoutingRule><Condition></Condition><Redirect><HostName>example.com</HostName></Redirect></RoutingRule></RoutingRules>`,
+		},
+		{
+			name:   "empty redirect",
+			config: index + `<RoutingRules><RoutingRule><Redirect></Redirect></RoutingRule></RoutingRules>`,
+		},
+		{
+			name:   "both replacements",
+			config: index + `<RoutingRules><RoutingRule><Redirect><ReplaceKeyWith>a</ReplaceKeyWith><ReplaceKeyPrefixWith>b</ReplaceKeyPrefixWith></Redirect></RoutingRule></RoutingRules>`,
+		},
+		{
+			name:   "invalid protocol",
+			config: index + `<RoutingRules><RoutingRule><Redirect><Protocol>ftp</Protocol></Redirect></RoutingRule></RoutingRules>`,
+		},
+		{
+			name:   "invalid redirect code",
+			config: index + `<RoutingRules><RoutingRule><Redirect><HostName>example.com</HostName><HttpRedirectCode>200</HttpRedirectCode></Redirect></RoutingRule></RoutingRules>`,
+		},
+		{
+			name:   "invalid error code",
+			config: index + `<RoutingRules><RoutingRule><Condition><HttpErrorCodeReturnedEquals>200</HttpErrorCodeReturnedEquals></Condition><Redirect><HostName>example.com</HostName></Redirect></RoutingRule></RoutingRules>`,
+		},
+	} {
+		config, err := ParseWebsiteConfig(strings.NewReader(`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` + tt.config + "</WebsiteConfiguration>"))
+		if !tt.valid {
+			require.Error(t, err, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+
+		data, err := xml.Marshal(config)
+		require.NoError(t, err)
+
+		roundTripped, err := ParseWebsiteConfig(strings.NewReader(string(data)))
+		require.NoError(t, err)
+		require.Equal(t, config.IndexDocument, roundTripped.IndexDocument, tt.name)
+		require.Equal(t, config.ErrorDocument, roundTripped.ErrorDocument, tt.name)
+		require.Equal(t, config.RoutingRules, roundTripped.RoutingRules, tt.name)
+	}
+}
+
+func TestWebsiteConfigRouting(t *testing.T) {
+	config, err := ParseWebsiteConfig(strings.NewReader(`<WebsiteConfiguration>
+		<IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+		<RoutingRules>
+			<RoutingRule>
+				<Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition>
+				<Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect>
+			</RoutingRule>
+			<RoutingRule>
+				<Condition><KeyPrefixEquals>old/</KeyPrefixEquals></Condition>
+				<Redirect><ReplaceKeyPrefixWith></ReplaceKeyPrefixWith><HttpRedirectCode>302</HttpRedirectCode></Redirect>
+			</RoutingRule>
+			<RoutingRule>
+				<Condition><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition>
+				<Redirect><Protocol>https</Protocol><HostName>example.com</HostName><ReplaceKeyWith>missing.html</ReplaceKeyWith></Redirect>
+			</RoutingRule>
+		</RoutingRules>
+	</WebsiteConfiguration>`))
+	require.NoError(t, err)
+
+	require.Equal(t, "index.html", config.IndexKey(""))
+	require.Equal(t, "a/index.html", config.IndexKey("a/"))
+	require.Equal(t, "a", config.IndexKey("a"))
+
+	require.Nil(t, config.Route("index.html", 0))
+	require.Nil(t, config.Route("documents/a.html", 0))
+
+	location, code := config.Route("docs/a.html", 0).Location("http", "site.local", "docs/a.html")
+	require.Equal(t, "http://site.local/documents/a.html", location)
+	require.Equal(t, http.StatusMovedPermanently, code)
+
+	location, code = config.Route("old/a b.html", 0).Location("http", "site.local", "old/a b.html")
+	require.Equal(t, "http://site.local/a%20b.html", location)
+	require.Equal(t, http.StatusFound, code)
+
+	// rules with an error code only apply once the object is looked up.
+	require.Nil(t, config.Route("a.html", 0))
+	location, code = config.Route("a.html", http.StatusNotFound).Location("http", "site.local", "a.html")
+	require.Equal(t, "https://example.com/missing.html", location)
+	require.Equal(t, http.StatusMovedPermanently, code)
+	require.Nil(t, config.Route("a.html", http.StatusForbidden))
+}
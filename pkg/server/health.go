@@ -0,0 +1,307 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/rpc/rpcpool"
+	"storj.io/common/storj"
+	"storj.io/common/sync2"
+	"storj.io/drpc"
+	"storj.io/gateway-mt/pkg/authclient"
+)
+
+// HealthError is a class of dependency check errors.
+var HealthError = errs.Class("health")
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+	healthStatusUnknown     = "unknown"
+	healthStatusDraining    = "draining"
+)
+
+// HealthConfig configures the checks of the dependencies readiness depends
+// on.
+type HealthConfig struct {
+	Interval          time.Duration `help:"how often the dependencies readiness depends on are checked; probes report the results of the last checks" default:"10s"`
+	Timeout           time.Duration `help:"timeout of a check of a dependency" default:"5s"`
+	Satellites        []string      `help:"satellites readiness depends on accepting TCP connections, as node URLs or addresses (comma separated)"`
+	MaxPoolSaturation float64       `help:"ratio of open connections, storage node connections included, to the connection pool capacity above which the gateway isn't ready (0 disables the check)" default:"0"`
+}
+
+// dependencyCheck is a check of a dependency.
+type dependencyCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// healthResult is the result of the checks of a dependency.
+type healthResult struct {
+	Status      string     `json:"status"`
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+}
+
+// healthReport is the body of readiness probe responses.
+type healthReport struct {
+	Status       string                  `json:"status"`
+	Dependencies map[string]healthResult `json:"dependencies"`
+}
+
+// healthChecker checks the dependencies of the gateway periodically, so
+// readiness probes only report the results of the last checks.
+type healthChecker struct {
+	log    *zap.Logger
+	config HealthConfig
+	checks []dependencyCheck
+	now    func() time.Time
+
+	// draining reports whether the gateway is draining, which makes it not
+	// ready regardless of its dependencies.
+	draining func() bool
+
+	mu      sync.Mutex
+	results map[string]healthResult
+}
+
+// newHealthChecker constructs a healthChecker checking that authClient's
+// authservice is live (if authClient isn't nil), that pool isn't saturated
+// and that the configured satellites can be dialed.
+func newHealthChecker(log *zap.Logger, config HealthConfig, authClient *authclient.AuthClient, pool *connectionCounter) (*healthChecker, error) {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	h := &healthChecker{
+		log:     log,
+		config:  config,
+		now:     time.Now,
+		results: make(map[string]healthResult),
+	}
+
+	if authClient != nil {
+		h.add("authservice", func(ctx context.Context) error {
+			_, err := authClient.GetHealthLive(ctx)
+			return err
+		})
+	}
+
+	if config.MaxPoolSaturation > 0 && pool != nil {
+		h.add("connection_pool", func(ctx context.Context) error {
+			if saturation := pool.Saturation(); saturation > config.MaxPoolSaturation {
+				return HealthError.New("%d open connections saturate the pool of capacity %d", pool.Open(), pool.capacity)
+			}
+			return nil
+		})
+	}
+
+	for _, satellite := range config.Satellites {
+		url, err := storj.ParseNodeURL(satellite)
+		if err != nil {
+			return nil, HealthError.Wrap(err)
+		}
+		address := url.Address
+		h.add("satellite:"+address, func(ctx context.Context) error {
+			conn, err := new(net.Dialer).DialContext(ctx, "tcp", address)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		})
+	}
+
+	return h, nil
+}
+
+func (h *healthChecker) add(name string, check func(ctx context.Context) error) {
+	h.checks = append(h.checks, dependencyCheck{name: name, check: check})
+	h.results[name] = healthResult{Status: healthStatusUnknown}
+}
+
+// Run checks the dependencies every interval until ctx is canceled.
+func (h *healthChecker) Run(ctx context.Context) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	return sync2.NewCycle(h.config.Interval).Run(ctx, func(ctx context.Context) error {
+		h.checkAll(ctx)
+		return nil
+	})
+}
+
+// checkAll checks all dependencies concurrently.
+func (h *healthChecker) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, check := range h.checks {
+		check := check
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+			defer cancel()
+
+			h.record(check.name, check.check(checkCtx))
+		}()
+	}
+	wg.Wait()
+}
+
+func (h *healthChecker) record(name string, err error) {
+	now := h.now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	result := h.results[name]
+	result.LastChecked = &now
+	if err != nil {
+		if result.Status != healthStatusUnavailable {
+			h.log.Warn("dependency unavailable", zap.String("dependency", name), zap.Error(err))
+		}
+		mon.Counter("health_check_failed", monkit.NewSeriesTag("dependency", name)).Inc(1)
+		result.Status = healthStatusUnavailable
+		result.LastError = err.Error()
+		result.LastFailure = &now
+	} else {
+		if result.Status == healthStatusUnavailable {
+			h.log.Info("dependency available again", zap.String("dependency", name))
+		}
+		result.Status = healthStatusOK
+	}
+	h.results[name] = result
+}
+
+// report returns the results of the last checks. The gateway is ready if it
+// isn't draining and all dependencies were available at their last check.
+func (h *healthChecker) report() healthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	report := healthReport{
+		Status:       healthStatusOK,
+		Dependencies: make(map[string]healthResult, len(h.results)),
+	}
+	for name, result := range h.results {
+		if result.Status != healthStatusOK {
+			report.Status = healthStatusUnavailable
+		}
+		report.Dependencies[name] = result
+	}
+	if h.draining != nil && h.draining() {
+		report.Status = healthStatusDraining
+	}
+	return report
+}
+
+// ServeLive responds to liveness probes. The gateway is live as long as it
+// serves requests.
+func (h *healthChecker) ServeLive(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Status string `json:"status"`
+	}{Status: healthStatusOK})
+}
+
+// ServeReady responds to readiness probes with the results of the last checks
+// of the dependencies, with status 503 if any of them isn't available or the
+// gateway is draining.
+func (h *healthChecker) ServeReady(w http.ResponseWriter, r *http.Request) {
+	report := h.report()
+
+	status := http.StatusOK
+	if report.Status != healthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// connectionCounter counts the connections dialed through the connection
+// pool that are open, to tell how saturated the pool is.
+//
+// Connections to storage nodes are counted too, and a single segment upload
+// dials many of them, so the count routinely exceeds the capacity of the pool
+// (which only bounds the idle connections it caches) under normal load.
+type connectionCounter struct {
+	capacity int
+	open     int64
+}
+
+// newConnectionCounter constructs a connectionCounter for a pool of capacity.
+func newConnectionCounter(capacity int) *connectionCounter {
+	return &connectionCounter{capacity: capacity}
+}
+
+// WithContext returns ctx counting the connections dialed with it through the
+// pool.
+func (c *connectionCounter) WithContext(ctx context.Context) context.Context {
+	return rpcpool.WithDialerWrapper(ctx, c.wrap)
+}
+
+// Handler counts the connections dialed for requests next serves.
+func (c *connectionCounter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(c.WithContext(r.Context())))
+	})
+}
+
+// Open returns the number of open connections.
+func (c *connectionCounter) Open() int64 {
+	return atomic.LoadInt64(&c.open)
+}
+
+// Saturation returns the ratio of open connections to the capacity of the
+// pool.
+func (c *connectionCounter) Saturation() float64 {
+	if c.capacity <= 0 {
+		return float64(c.Open())
+	}
+	return float64(c.Open()) / float64(c.capacity)
+}
+
+func (c *connectionCounter) wrap(ctx context.Context, dial rpcpool.Dialer) rpcpool.Dialer {
+	return func(ctx context.Context) (drpc.Conn, *tls.ConnectionState, error) {
+		conn, state, err := dial(ctx)
+		if err != nil {
+			return conn, state, err
+		}
+		atomic.AddInt64(&c.open, 1)
+		return &countedConn{Conn: conn, counter: c}, state, nil
+	}
+}
+
+// countedConn is a connection counted by a connectionCounter until it's
+// closed.
+type countedConn struct {
+	drpc.Conn
+	counter *connectionCounter
+	once    sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { atomic.AddInt64(&c.counter.open, -1) })
+	return c.Conn.Close()
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/drpc"
+)
+
+func serveReady(t *testing.T, h *healthChecker) (int, healthReport) {
+	rec := httptest.NewRecorder()
+	h.ServeReady(rec, httptest.NewRequest(http.MethodGet, "/-/health/ready", nil))
+
+	var report healthReport
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	require.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	return rec.Code, report
+}
+
+func TestHealthReady(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	h, err := newHealthChecker(zaptest.NewLogger(t), HealthConfig{Timeout: time.Second}, nil, nil)
+	require.NoError(t, err)
+
+	var fail error
+	h.add("dependency", func(ctx context.Context) error { return fail })
+
+	// dependencies that weren't checked yet aren't known to be available.
+	code, report := serveReady(t, h)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, healthStatusUnknown, report.Dependencies["dependency"].Status)
+
+	h.checkAll(ctx)
+	code, report = serveReady(t, h)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, healthStatusOK, report.Status)
+	require.NotNil(t, report.Dependencies["dependency"].LastChecked)
+
+	fail = errors.New("oops")
+	h.checkAll(ctx)
+	code, report = serveReady(t, h)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, healthStatusUnavailable, report.Status)
+	require.Equal(t, healthStatusUnavailable, report.Dependencies["dependency"].Status)
+	require.Equal(t, "oops", report.Dependencies["dependency"].LastError)
+
+	// the last error is kept after the dependency recovers.
+	fail = nil
+	h.checkAll(ctx)
+	code, report = serveReady(t, h)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "oops", report.Dependencies["dependency"].LastError)
+	require.NotNil(t, report.Dependencies["dependency"].LastFailure)
+}
+
+func TestHealthSatellite(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+
+	h, err := newHealthChecker(zaptest.NewLogger(t), HealthConfig{
+		Timeout:    time.Second,
+		Satellites: []string{address},
+	}, nil, nil)
+	require.NoError(t, err)
+
+	h.checkAll(ctx)
+	require.Equal(t, healthStatusOK, h.report().Dependencies["satellite:"+address].Status)
+
+	require.NoError(t, listener.Close())
+	h.checkAll(ctx)
+	require.Equal(t, healthStatusUnavailable, h.report().Dependencies["satellite:"+address].Status)
+
+	_, err = newHealthChecker(zaptest.NewLogger(t), HealthConfig{Satellites: []string{"1@"}}, nil, nil)
+	require.Error(t, err)
+}
+
+func TestHealthLive(t *testing.T) {
+	h, err := newHealthChecker(zaptest.NewLogger(t), HealthConfig{}, nil, nil)
+	require.NoError(t, err)
+	h.add("dependency", func(ctx context.Context) error { return errors.New("oops") })
+
+	rec := httptest.NewRecorder()
+	h.ServeLive(rec, httptest.NewRequest(http.MethodGet, "/-/health/live", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+type fakeConn struct{ drpc.Conn }
+
+func (fakeConn) Close() error { return nil }
+
+func TestConnectionCounter(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	counter := newConnectionCounter(2)
+	dial := counter.wrap(ctx, func(context.Context) (drpc.Conn, *tls.ConnectionState, error) {
+		return fakeConn{}, nil, nil
+	})
+
+	first, _, err := dial(ctx)
+	require.NoError(t, err)
+	second, _, err := dial(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, counter.Open())
+	require.Equal(t, 1.0, counter.Saturation())
+
+	require.NoError(t, first.Close())
+	require.NoError(t, first.Close())
+	require.EqualValues(t, 1, counter.Open())
+	require.NoError(t, second.Close())
+	require.Zero(t, counter.Open())
+
+	failing := counter.wrap(ctx, func(context.Context) (drpc.Conn, *tls.ConnectionState, error) {
+		return nil, nil, errors.New("oops")
+	})
+	_, _, err = failing(ctx)
+	require.Error(t, err)
+	require.Zero(t, counter.Open())
+
+	h, err := newHealthChecker(zaptest.NewLogger(t), HealthConfig{Timeout: time.Second, MaxPoolSaturation: 0.5}, nil, counter)
+	require.NoError(t, err)
+
+	conn, _, err := dial(ctx)
+	require.NoError(t, err)
+	h.checkAll(ctx)
+	require.Equal(t, healthStatusOK, h.report().Dependencies["connection_pool"].Status)
+
+	_, _, err = dial(ctx)
+	require.NoError(t, err)
+	h.checkAll(ctx)
+	require.Equal(t, healthStatusUnavailable, h.report().Dependencies["connection_pool"].Status)
+	require.NoError(t, conn.Close())
+}
@@ -0,0 +1,439 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"storj.io/common/memory"
+	"storj.io/gateway-mt/pkg/server/gwlog"
+	xhttp "storj.io/minio/cmd/http"
+)
+
+// accessLogTimeFormat is the format of times in S3 server access logs.
+const accessLogTimeFormat = "[02/Jan/2006:15:04:05 -0700]"
+
+// accessLogErrorBodySize is how much of error responses is kept to find the
+// error code in.
+const accessLogErrorBodySize = 4 * memory.KiB
+
+// redacted replaces sensitive values in logs.
+const redacted = "[...]"
+
+// AccessLogConfig configures AccessLog.
+type AccessLogConfig struct {
+	Output     string        `help:"file to write S3 server access log records to, or stdout (empty to disable)" default:""`
+	MaxSize    memory.Size   `help:"size at which the access log file is rotated" default:"100MiB"`
+	MaxBackups int           `help:"number of rotated access log files kept (0 to keep all)" default:"10"`
+	MaxAge     time.Duration `help:"how long rotated access log files are kept, rounded up to days (0 to keep them forever)" default:"0"`
+	Compress   bool          `help:"compress rotated access log files with gzip" default:"false"`
+}
+
+// AccessLog writes a record for every request in the AWS S3 server access log
+// format:
+//
+//	https://docs.aws.amazon.com/AmazonS3/latest/userguide/LogFormat.html
+//
+// Object keys and query strings are redacted unless insecureLogAll is set.
+type AccessLog struct {
+	output         io.Writer
+	close          func() error
+	insecureLogAll bool
+	now            func() time.Time
+}
+
+// NewAccessLog constructs an AccessLog writing to the configured output, which
+// must not be empty.
+func NewAccessLog(config AccessLogConfig, insecureLogAll bool) *AccessLog {
+	l := &AccessLog{
+		insecureLogAll: insecureLogAll,
+		now:            time.Now,
+	}
+
+	if config.Output == "stdout" {
+		l.output = os.Stdout
+		l.close = func() error { return nil }
+		return l
+	}
+
+	file := &lumberjack.Logger{
+		Filename:   config.Output,
+		MaxSize:    int((config.MaxSize + memory.MiB - 1) / memory.MiB),
+		MaxBackups: config.MaxBackups,
+		MaxAge:     int((config.MaxAge + 24*time.Hour - 1) / (24 * time.Hour)),
+		Compress:   config.Compress,
+	}
+	l.output, l.close = file, file.Close
+	return l
+}
+
+// Close closes the output of l.
+func (l *AccessLog) Close() error {
+	return l.close()
+}
+
+// Handler writes an access log record for every request next serves. It
+// relies on a middleware earlier in the chain adding gwlog.Log to the request
+// context for the gateway to fill in.
+func (l *AccessLog) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := l.now()
+
+		body := &accessLogReader{now: l.now, lastRead: start}
+		if r.Body != nil && r.Body != http.NoBody {
+			body.ReadCloser = r.Body
+			r.Body = body
+		}
+
+		aw := &accessLogWriter{ResponseWriter: w, now: l.now}
+		next.ServeHTTP(aw, r)
+
+		gl, ok := gwlog.FromContext(r.Context())
+		if !ok {
+			gl = gwlog.New()
+		}
+
+		end := l.now()
+		if aw.firstByte.IsZero() {
+			aw.firstByte = end
+		}
+
+		// the errors of writes are ignored like those of other logs.
+		_, _ = l.output.Write([]byte(l.record(r, aw, gl, start, body.lastRead, end)))
+	})
+}
+
+// record returns the access log record of r, with a trailing newline.
+func (l *AccessLog) record(r *http.Request, aw *accessLogWriter, gl *gwlog.Log, start, lastRead, end time.Time) string {
+	status := aw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	requestID := gl.RequestID
+	if requestID == "" {
+		requestID = aw.Header().Get(xhttp.AmzRequestID)
+	}
+
+	remoteIP := gl.RemoteHost
+	if remoteIP == "" {
+		remoteIP = getRemoteIP(r)
+	}
+
+	userAgent := gl.UserAgent
+	if userAgent == "" {
+		userAgent = r.UserAgent()
+	}
+
+	key := gl.ObjectName
+	if key != "" {
+		if l.insecureLogAll {
+			key = (&url.URL{Path: key}).EscapedPath()
+		} else {
+			key = redacted
+		}
+	}
+
+	turnAround := aw.firstByte.Sub(lastRead)
+	if turnAround < 0 {
+		turnAround = 0
+	}
+
+	signatureVersion, authType := accessLogAuth(r)
+	cipherSuite, tlsVersion := accessLogTLS(r.TLS)
+
+	fields := []string{
+		"-", // bucket owner
+		orDash(gl.BucketName),
+		start.UTC().Format(accessLogTimeFormat),
+		orDash(remoteIP),
+		orDash(getEncryptionKeyHash(r)), // requester
+		orDash(requestID),
+		accessLogOperation(r.Method, gl.API),
+		orDash(key),
+		strconv.Quote(r.Method + " " + l.requestURI(r, gl.ObjectName) + " " + r.Proto),
+		strconv.Itoa(status),
+		orDash(aw.errorCode()),
+		orDashInt(aw.written),
+		orDashInt(accessLogObjectSize(r, aw, gl.API)),
+		strconv.FormatInt(end.Sub(start).Milliseconds(), 10),
+		strconv.FormatInt(turnAround.Milliseconds(), 10),
+		quoteOrDash(r.Referer()),
+		quoteOrDash(userAgent),
+		orDash(accessLogVersionID(r, aw)),
+		"-", // host id
+		signatureVersion,
+		cipherSuite,
+		authType,
+		orDash(r.Host),
+		tlsVersion,
+		"-", // access point ARN
+		"-", // ACL required
+	}
+	return strings.Join(fields, " ") + "\n"
+}
+
+// requestURI returns the request URI of r with key and the values of its
+// query redacted unless l.insecureLogAll is set.
+func (l *AccessLog) requestURI(r *http.Request, key string) string {
+	if l.insecureLogAll {
+		return r.RequestURI
+	}
+
+	path := r.URL.EscapedPath()
+	if key != "" {
+		if escaped := (&url.URL{Path: key}).EscapedPath(); strings.HasSuffix(path, "/"+escaped) {
+			path = strings.TrimSuffix(path, escaped) + redacted
+		} else if strings.HasSuffix(r.URL.Path, "/"+key) {
+			path = strings.TrimSuffix(r.URL.Path, key) + redacted
+		}
+	}
+
+	query := r.URL.Query()
+	if len(query) == 0 {
+		return path
+	}
+
+	names := make([]string, 0, len(query))
+	for name, values := range query {
+		if values[0] != "" {
+			name += "=" + redacted
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return path + "?" + strings.Join(names, "&")
+}
+
+// accessLogResources are the resources in the operations of the APIs whose
+// resource isn't their name without their verb and Bucket or Object prefix.
+var accessLogResources = map[string]string{
+	"GetObject":               "OBJECT",
+	"HeadObject":              "OBJECT",
+	"PutObject":               "OBJECT",
+	"DeleteObject":            "OBJECT",
+	"CopyObject":              "OBJECT",
+	"PostPolicyBucket":        "OBJECT",
+	"CopyObjectPart":          "PART",
+	"PutObjectPart":           "PART",
+	"HeadBucket":              "BUCKET",
+	"PutBucket":               "BUCKET",
+	"DeleteBucket":            "BUCKET",
+	"ListObjectsV1":           "BUCKET",
+	"ListObjectsV2":           "BUCKET",
+	"ListObjectsV2M":          "BUCKET",
+	"ListObjectVersions":      "BUCKETVERSIONS",
+	"ListBuckets":             "SERVICE",
+	"NewMultipartUpload":      "UPLOADS",
+	"ListMultipartUploads":    "UPLOADS",
+	"CompleteMultipartUpload": "UPLOAD",
+	"AbortMultipartUpload":    "UPLOAD",
+	"ListObjectParts":         "UPLOAD",
+	"DeleteMultipleObjects":   "MULTI_OBJECT_DELETE",
+	"SelectObjectContent":     "SELECT",
+	"RestoreObject":           "RESTORE",
+}
+
+// accessLogOperation returns the operation of a request to api, e.g.
+// REST.GET.OBJECT for GetObject.
+func accessLogOperation(method, api string) string {
+	verb := method
+	if api == "CopyObject" || api == "CopyObjectPart" {
+		verb = "COPY"
+	}
+
+	resource, ok := accessLogResources[api]
+	if !ok {
+		resource = "UNKNOWN"
+		for _, prefix := range []string{"Get", "Put", "Delete", "List", "Head", "Post"} {
+			if name := strings.TrimPrefix(api, prefix); name != api {
+				name = strings.TrimPrefix(strings.TrimPrefix(name, "Bucket"), "Object")
+				if name != "" {
+					resource = strings.ToUpper(name)
+				}
+				break
+			}
+		}
+	}
+
+	return "REST." + verb + "." + resource
+}
+
+// accessLogAuth returns the signature version and authentication type of r.
+func accessLogAuth(r *http.Request) (signatureVersion, authType string) {
+	authorization := r.Header.Get(xhttp.Authorization)
+	query := r.URL.Query()
+	switch {
+	case strings.HasPrefix(authorization, "AWS4-HMAC-SHA256"):
+		return "SigV4", "AuthHeader"
+	case strings.HasPrefix(authorization, "AWS "):
+		return "SigV2", "AuthHeader"
+	case query.Get(xhttp.AmzAlgorithm) != "":
+		return "SigV4", "QueryString"
+	case query.Get(xhttp.AmzSignatureV2) != "":
+		return "SigV2", "QueryString"
+	default:
+		return "-", "-"
+	}
+}
+
+// accessLogTLS returns the cipher suite and TLS version of a connection, or
+// dashes if it's not encrypted.
+func accessLogTLS(state *tls.ConnectionState) (cipherSuite, version string) {
+	if state == nil {
+		return "-", "-"
+	}
+
+	switch state.Version {
+	case tls.VersionTLS10:
+		version = "TLSv1"
+	case tls.VersionTLS11:
+		version = "TLSv1.1"
+	case tls.VersionTLS12:
+		version = "TLSv1.2"
+	case tls.VersionTLS13:
+		version = "TLSv1.3"
+	default:
+		version = "-"
+	}
+
+	return tls.CipherSuiteName(state.CipherSuite), version
+}
+
+// accessLogObjectSize returns the size of the object a request to api
+// uploads or downloads, or -1 if it's not known.
+func accessLogObjectSize(r *http.Request, aw *accessLogWriter, api string) int64 {
+	switch api {
+	case "PutObject", "PutObjectPart":
+		if decoded := r.Header.Get(xhttp.AmzDecodedContentLength); decoded != "" {
+			if size, err := strconv.ParseInt(decoded, 10, 64); err == nil {
+				return size
+			}
+		}
+		return r.ContentLength
+	case "GetObject", "HeadObject":
+		if contentRange := aw.Header().Get(xhttp.ContentRange); contentRange != "" {
+			if i := strings.LastIndexByte(contentRange, '/'); i >= 0 {
+				if size, err := strconv.ParseInt(contentRange[i+1:], 10, 64); err == nil {
+					return size
+				}
+			}
+		}
+		if size, err := strconv.ParseInt(aw.Header().Get(xhttp.ContentLength), 10, 64); err == nil {
+			return size
+		}
+	}
+	return -1
+}
+
+// accessLogVersionID returns the version ID a request is for, if any.
+func accessLogVersionID(r *http.Request, aw *accessLogWriter) string {
+	if versionID := r.URL.Query().Get(xhttp.VersionID); versionID != "" {
+		return versionID
+	}
+	return aw.Header().Get(xhttp.AmzVersionID)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func orDashInt(n int64) string {
+	if n < 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func quoteOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strconv.Quote(s)
+}
+
+// accessLogReader is a request body recording when it was last read from.
+type accessLogReader struct {
+	io.ReadCloser
+	now      func() time.Time
+	lastRead time.Time
+}
+
+func (r *accessLogReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.lastRead = r.now()
+	return n, err
+}
+
+// accessLogWriter is a response recording its status, size, when its first
+// byte was written and the start of its body if it's an error.
+type accessLogWriter struct {
+	http.ResponseWriter
+	now func() time.Time
+
+	status    int
+	written   int64
+	firstByte time.Time
+	errorBody bytes.Buffer
+}
+
+func (w *accessLogWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.firstByte = w.now()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status >= http.StatusBadRequest {
+		if remaining := accessLogErrorBodySize.Int() - w.errorBody.Len(); remaining > 0 {
+			if len(p) < remaining {
+				remaining = len(p)
+			}
+			w.errorBody.Write(p[:remaining])
+		}
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *accessLogWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// errorCode returns the S3 error code of the response, if it's an error.
+func (w *accessLogWriter) errorCode() string {
+	if w.errorBody.Len() == 0 {
+		return ""
+	}
+	var response struct {
+		Code string
+	}
+	if err := xml.Unmarshal(w.errorBody.Bytes(), &response); err != nil {
+		return ""
+	}
+	return response.Code
+}
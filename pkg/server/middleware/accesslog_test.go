@@ -0,0 +1,131 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/common/testcontext"
+	"storj.io/gateway-mt/pkg/server/gwlog"
+	xhttp "storj.io/minio/cmd/http"
+)
+
+// serveAccessLog serves r with an AccessLog around handler and returns the
+// record it writes.
+func serveAccessLog(t *testing.T, insecureLogAll bool, r *http.Request, handler http.HandlerFunc) string {
+	var output bytes.Buffer
+	clock := time.Date(2023, time.February, 3, 4, 5, 6, 0, time.UTC)
+	l := &AccessLog{
+		output:         &output,
+		close:          func() error { return nil },
+		insecureLogAll: insecureLogAll,
+		now: func() time.Time {
+			clock = clock.Add(10 * time.Millisecond)
+			return clock
+		},
+	}
+
+	log := gwlog.New()
+	r = r.WithContext(log.WithContext(r.Context()))
+	l.Handler(handler).ServeHTTP(httptest.NewRecorder(), r)
+
+	require.NoError(t, l.Close())
+	return output.String()
+}
+
+func getObjectHandler(w http.ResponseWriter, r *http.Request) {
+	log, _ := gwlog.FromContext(r.Context())
+	log.API = "GetObject"
+	log.BucketName = "bucket"
+	log.ObjectName = "dir/my key"
+	log.RequestID = "ABC123"
+	log.RemoteHost = "1.2.3.4"
+	log.UserAgent = "aws-cli/2.0"
+
+	w.Header().Set(xhttp.ContentLength, "5")
+	w.Header().Set(xhttp.ContentRange, "bytes 0-4/42")
+	w.WriteHeader(http.StatusPartialContent)
+	_, _ = w.Write([]byte("hello"))
+}
+
+func TestAccessLog(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/bucket/dir/my%20key?versionId=v1&partNumber=1", nil)
+	r.Header.Set(xhttp.Authorization, "AWS4-HMAC-SHA256 Credential=abc/20230203/us-east-1/s3/aws4_request")
+
+	record := serveAccessLog(t, false, r, getObjectHandler)
+	require.Equal(t, `- bucket [03/Feb/2023:04:05:06 +0000] 1.2.3.4 - ABC123 REST.GET.OBJECT [...] `+
+		`"GET /bucket/[...]?partNumber=[...]&versionId=[...] HTTP/1.1" 206 - 5 42 20 10 - "aws-cli/2.0" v1 - `+
+		`SigV4 - AuthHeader example.com - - -`+"\n", record)
+
+	r = httptest.NewRequest(http.MethodGet, "/bucket/dir/my%20key?versionId=v1&partNumber=1", nil)
+	record = serveAccessLog(t, true, r, getObjectHandler)
+	require.Contains(t, record, ` REST.GET.OBJECT dir/my%20key "GET /bucket/dir/my%20key?versionId=v1&partNumber=1 HTTP/1.1" `)
+}
+
+func TestAccessLogError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPut, "/bucket/key", strings.NewReader("data"))
+	record := serveAccessLog(t, false, r, func(w http.ResponseWriter, r *http.Request) {
+		log, _ := gwlog.FromContext(r.Context())
+		log.API = "PutObject"
+		log.BucketName = "bucket"
+		log.ObjectName = "key"
+
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set(xhttp.AmzRequestID, "DEF456")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>` +
+			`<Error><Code>AccessDenied</Code><Message>Access Denied.</Message></Error>`))
+	})
+
+	fields := strings.Fields(record)
+	require.Equal(t, "DEF456", fields[6])
+	require.Equal(t, "REST.PUT.OBJECT", fields[7])
+	require.Equal(t, "403", fields[12])
+	require.Equal(t, "AccessDenied", fields[13])
+	require.Equal(t, "4", fields[15]) // object size
+}
+
+func TestAccessLogOperation(t *testing.T) {
+	for _, tt := range []struct {
+		method, api, operation string
+	}{
+		{http.MethodGet, "GetObject", "REST.GET.OBJECT"},
+		{http.MethodPut, "CopyObject", "REST.COPY.OBJECT"},
+		{http.MethodPut, "CopyObjectPart", "REST.COPY.PART"},
+		{http.MethodGet, "ListObjectsV2", "REST.GET.BUCKET"},
+		{http.MethodPost, "NewMultipartUpload", "REST.POST.UPLOADS"},
+		{http.MethodGet, "GetBucketVersioning", "REST.GET.VERSIONING"},
+		{http.MethodPut, "PutObjectTagging", "REST.PUT.TAGGING"},
+		{http.MethodGet, "unknown", "REST.GET.UNKNOWN"},
+	} {
+		require.Equal(t, tt.operation, accessLogOperation(tt.method, tt.api), tt.api)
+	}
+}
+
+func TestAccessLogFile(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	path := filepath.Join(ctx.Dir(), "access.log")
+	l := NewAccessLog(AccessLogConfig{Output: path}, false)
+
+	r := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+	r = r.WithContext(gwlog.New().WithContext(r.Context()))
+	l.Handler(http.HandlerFunc(getObjectHandler)).ServeHTTP(httptest.NewRecorder(), r)
+	require.NoError(t, l.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), " REST.GET.OBJECT ")
+}
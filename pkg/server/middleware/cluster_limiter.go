@@ -0,0 +1,184 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/sync2"
+)
+
+// ClusterLimiterError is a class of ClusterLimiterBackend errors.
+var ClusterLimiterError = errs.Class("cluster limiter")
+
+// ClusterLimiterConfig configures ClusterLimiterBackend.
+type ClusterLimiterConfig struct {
+	Address    string        `help:"address to serve concurrency counts to other replicas on (empty to only limit concurrency per replica)" default:""`
+	Peers      []string      `help:"addresses other replicas serve concurrency counts on (comma separated)"`
+	Token      string        `help:"token replicas authenticate with to each other (required with --concurrent-cluster.address)" default:""`
+	Interval   time.Duration `help:"how often concurrency counts are fetched from other replicas" default:"250ms"`
+	Expiration time.Duration `help:"how long the concurrency counts of a replica that can't be reached are still counted" default:"2s"`
+}
+
+// clusterLimiterCount is the count of concurrent requests for a key of a
+// replica, as served to others.
+type clusterLimiterCount struct {
+	Key   []byte `json:"key"`
+	Count uint   `json:"count"`
+}
+
+// peerCounts are the counts of concurrent requests of another replica.
+type peerCounts struct {
+	counts  map[string]uint
+	updated time.Time
+}
+
+// ClusterLimiterBackend is a LimiterBackend counting the requests of other
+// replicas too. Each replica serves its own counts and fetches those of the
+// others periodically, so limits may be exceeded briefly.
+//
+// The counts of replicas that can't be reached expire, so limits degrade to
+// limits per replica if the others are all unreachable.
+type ClusterLimiterBackend struct {
+	log    *zap.Logger
+	config ClusterLimiterConfig
+	client *http.Client
+	now    func() time.Time
+
+	local *LocalLimiterBackend
+
+	mu    sync.Mutex
+	peers map[string]peerCounts
+}
+
+// NewClusterLimiterBackend constructs a ClusterLimiterBackend. config.Token
+// is required, since anyone with it can read the counts.
+func NewClusterLimiterBackend(log *zap.Logger, config ClusterLimiterConfig) (*ClusterLimiterBackend, error) {
+	if config.Token == "" {
+		return nil, ClusterLimiterError.New("a token is required")
+	}
+	return &ClusterLimiterBackend{
+		log:    log,
+		config: config,
+		client: &http.Client{Timeout: config.Expiration},
+		now:    time.Now,
+		local:  NewLocalLimiterBackend(),
+		peers:  make(map[string]peerCounts),
+	}, nil
+}
+
+// Acquire implements LimiterBackend.
+func (b *ClusterLimiterBackend) Acquire(key string, allowed uint) bool {
+	peerCount := b.peerCount(key)
+	if peerCount >= allowed {
+		return false
+	}
+	return b.local.Acquire(key, allowed-peerCount)
+}
+
+// Release implements LimiterBackend.
+func (b *ClusterLimiterBackend) Release(key string) {
+	b.local.Release(key)
+}
+
+// peerCount returns the count of concurrent requests for key of the other
+// replicas whose counts haven't expired.
+func (b *ClusterLimiterBackend) peerCount(key string) (count uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	for _, peer := range b.peers {
+		if now.Sub(peer.updated) < b.config.Expiration {
+			count += peer.counts[key]
+		}
+	}
+	return count
+}
+
+// ServeHTTP serves the counts of concurrent requests of this replica.
+func (b *ClusterLimiterBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+b.config.Token)) != 1 {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	b.local.m.Lock()
+	counts := make([]clusterLimiterCount, 0, len(b.local.counts))
+	for key, count := range b.local.counts {
+		counts = append(counts, clusterLimiterCount{Key: []byte(key), Count: count})
+	}
+	b.local.m.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(counts); err != nil {
+		b.log.Debug("failed to serve concurrency counts", zap.Error(err))
+	}
+}
+
+// Run fetches the counts of concurrent requests of the other replicas until
+// ctx is canceled.
+func (b *ClusterLimiterBackend) Run(ctx context.Context) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	return sync2.NewCycle(b.config.Interval).Run(ctx, func(ctx context.Context) error {
+		var wg sync.WaitGroup
+		for _, peer := range b.config.Peers {
+			peer := peer
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := b.fetch(ctx, peer); err != nil {
+					b.log.Debug("failed to fetch concurrency counts", zap.String("peer", peer), zap.Error(err))
+				}
+			}()
+		}
+		wg.Wait()
+		return nil
+	})
+}
+
+// fetch fetches the counts of concurrent requests of peer.
+func (b *ClusterLimiterBackend) fetch(ctx context.Context, peer string) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+peer, nil)
+	if err != nil {
+		return ClusterLimiterError.Wrap(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+b.config.Token)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return ClusterLimiterError.Wrap(err)
+	}
+	defer func() { err = errs.Combine(err, ClusterLimiterError.Wrap(resp.Body.Close())) }()
+
+	if resp.StatusCode != http.StatusOK {
+		return ClusterLimiterError.New("unexpected status: %s", resp.Status)
+	}
+
+	var counts []clusterLimiterCount
+	if err = json.NewDecoder(resp.Body).Decode(&counts); err != nil {
+		return ClusterLimiterError.Wrap(err)
+	}
+
+	updated := peerCounts{counts: make(map[string]uint, len(counts)), updated: b.now()}
+	for _, count := range counts {
+		updated.counts[string(count.Key)] = count.Count
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.peers[peer] = updated
+	return nil
+}
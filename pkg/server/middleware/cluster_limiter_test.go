@@ -0,0 +1,157 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/errs2"
+	"storj.io/common/testcontext"
+)
+
+func TestClusterLimiterBackend(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	const allowed = 3
+
+	// gateway is a replica serving requests limited with a
+	// ClusterLimiterBackend.
+	type gateway struct {
+		backend *ClusterLimiterBackend
+		counts  *httptest.Server
+		handler http.Handler
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	gateways := make([]*gateway, 3)
+	for i := range gateways {
+		gateways[i] = &gateway{}
+		gateways[i].counts = httptest.NewUnstartedServer(nil)
+	}
+	for i, g := range gateways {
+		config := ClusterLimiterConfig{
+			Token:      "token",
+			Interval:   10 * time.Millisecond,
+			Expiration: 500 * time.Millisecond,
+		}
+		for j, peer := range gateways {
+			if i != j {
+				config.Peers = append(config.Peers, peer.counts.Listener.Addr().String())
+			}
+		}
+
+		var err error
+		g.backend, err = NewClusterLimiterBackend(zaptest.NewLogger(t), config)
+		require.NoError(t, err)
+		g.counts.Config.Handler = g.backend
+		g.counts.Start()
+		defer g.counts.Close()
+
+		g.handler = NewMacaroonLimiter(allowed, g.backend, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "", http.StatusTooManyRequests)
+		}).Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+		}))
+
+		backend := g.backend
+		ctx.Go(func() error {
+			return errs2.IgnoreCanceled(backend.Run(runCtx))
+		})
+	}
+
+	creds1, creds2 := getCredentials(t), getCredentials(t)
+	key := func(creds *Credentials) string {
+		r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(WithCredentials(ctx, creds))
+		key, err := getRequestMacaroonHead(r)
+		require.NoError(t, err)
+		return key
+	}
+
+	// start starts a request to g that's served until release is closed or
+	// written to.
+	start := func(g *gateway, creds *Credentials) {
+		ctx.Go(func() error {
+			require.Equal(t, http.StatusOK, doRequest(ctx, t, creds, g.handler))
+			return nil
+		})
+		<-started
+	}
+
+	// waitForPeerCount waits until g counts count requests for creds of
+	// other gateways.
+	waitForPeerCount := func(g *gateway, creds *Credentials, count uint) {
+		require.Eventually(t, func() bool {
+			return g.backend.peerCount(key(creds)) == count
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	start(gateways[0], creds1)
+	start(gateways[0], creds1)
+	waitForPeerCount(gateways[1], creds1, 2)
+	start(gateways[1], creds1)
+	waitForPeerCount(gateways[2], creds1, 3)
+	waitForPeerCount(gateways[0], creds1, 1)
+
+	require.Equal(t, http.StatusTooManyRequests, doRequest(ctx, t, creds1, gateways[2].handler))
+	require.Equal(t, http.StatusTooManyRequests, doRequest(ctx, t, creds1, gateways[0].handler))
+	start(gateways[2], creds2)
+
+	for i := 0; i < 4; i++ {
+		release <- struct{}{}
+	}
+	waitForPeerCount(gateways[2], creds1, 0)
+	start(gateways[2], creds1)
+	release <- struct{}{}
+	waitForPeerCount(gateways[0], creds1, 0)
+
+	// the counts of gateways that can't be reached expire, so limits degrade
+	// to limits per gateway.
+	for i := 0; i < allowed; i++ {
+		start(gateways[0], creds1)
+	}
+	waitForPeerCount(gateways[2], creds1, allowed)
+	require.Equal(t, http.StatusTooManyRequests, doRequest(ctx, t, creds1, gateways[2].handler))
+
+	gateways[0].counts.CloseClientConnections()
+	gateways[0].counts.Listener.Close()
+	waitForPeerCount(gateways[2], creds1, 0)
+	start(gateways[2], creds1)
+
+	close(release)
+}
+
+func TestClusterLimiterBackendToken(t *testing.T) {
+	_, err := NewClusterLimiterBackend(zaptest.NewLogger(t), ClusterLimiterConfig{})
+	require.Error(t, err)
+
+	backend, err := NewClusterLimiterBackend(zaptest.NewLogger(t), ClusterLimiterConfig{Token: "token"})
+	require.NoError(t, err)
+	require.True(t, backend.Acquire("key", 1))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	backend.ServeHTTP(rr, r)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	r.Header.Set("Authorization", "Bearer token")
+	rr = httptest.NewRecorder()
+	backend.ServeHTTP(rr, r)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, `[{"key":"a2V5","count":1}]`, strings.TrimSpace(rr.Body.String()))
+}
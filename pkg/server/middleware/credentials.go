@@ -10,6 +10,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -53,6 +54,11 @@ var (
 	errInvalidDate              = errs.New("invalid X-Amz-Date")
 	errInvalidAlgorithm         = errs.New("invalid algorithm")
 	errInvalidDateHeader        = errs.New("invalid X-Amz-Date header")
+	errMalformedExpires         = errs.New("invalid X-Amz-Expires")
+	errNegativeExpires          = errs.New("negative X-Amz-Expires")
+	errMaximumExpires           = errs.New("X-Amz-Expires too large")
+	errRequestNotReadyYet       = errs.New("request not valid yet")
+	errExpiredPresignRequest    = errs.New("request expired")
 
 	errInvalidQuery         = errs.Class("invalid query")
 	errMissingFields        = errs.Class("missing fields")
@@ -60,7 +66,7 @@ var (
 )
 
 // AccessKey implements mux.Middlware and saves the accesskey to context.
-func AccessKey(authClient *authclient.AuthClient, trustedIPs trustedip.List, log *zap.Logger) mux.MiddlewareFunc {
+func AccessKey(authClient *authclient.AuthClient, trustedIPs *trustedip.Atomic, log *zap.Logger) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
@@ -87,7 +93,7 @@ func AccessKey(authClient *authclient.AuthClient, trustedIPs trustedip.List, log
 				return
 			}
 			var creds Credentials
-			authResponse, err := authClient.ResolveWithCache(ctx, accessKeyID, trustedip.GetClientIP(trustedIPs, r))
+			authResponse, err := authClient.ResolveWithCache(ctx, accessKeyID, trustedip.GetClientIP(trustedIPs.Load(), r))
 			if err != nil {
 				logError(log, err)
 				creds.Error = err
@@ -136,6 +142,12 @@ func GetAccess(ctx context.Context) *Credentials {
 	return creds
 }
 
+// WithCredentials returns a copy of ctx holding credentials, which GetAccess
+// returns.
+func WithCredentials(ctx context.Context, credentials *Credentials) context.Context {
+	return context.WithValue(ctx, credentialsCV{}, credentials)
+}
+
 // GetAccessKeyID returns the access key ID from the request and a signature validator.
 func GetAccessKeyID(r *http.Request) (string, error) {
 	switch {
@@ -240,6 +252,9 @@ type V4 struct {
 	ContentSHA256 string
 	Date          time.Time
 
+	// Expires is how long after Date a presigned request is valid.
+	Expires time.Duration
+
 	FromHeader bool
 }
 
@@ -378,8 +393,14 @@ func getMultipartReader(r *http.Request) (*multipart.Reader, error) {
 	return multipart.NewReader(r.Body, boundary), nil
 }
 
-// ParseV4FromQuery parses a V4 signature from the query parameters.
+// ParseV4FromQuery parses a V4 signature from the query parameters. Requests
+// outside of the time they're valid for (from X-Amz-Date for X-Amz-Expires)
+// are rejected.
 func ParseV4FromQuery(r *http.Request) (_ *V4, err error) {
+	return parseV4FromQuery(r, time.Now())
+}
+
+func parseV4FromQuery(r *http.Request, now time.Time) (_ *V4, err error) {
 	q := r.URL.Query()
 
 	algorithm := q.Get("X-Amz-Algorithm")
@@ -408,6 +429,27 @@ func ParseV4FromQuery(r *http.Request) (_ *V4, err error) {
 		return nil, ParseV4FromQueryError.Wrap(errInvalidDate)
 	}
 
+	if _, ok := q["X-Amz-Expires"]; !ok {
+		return nil, ParseV4FromQueryError.Wrap(errInvalidQuery.New("no X-Amz-Expires field"))
+	}
+	expires, err := strconv.ParseInt(q.Get("X-Amz-Expires"), 10, 64)
+	switch {
+	case err != nil:
+		return nil, ParseV4FromQueryError.Wrap(errMalformedExpires)
+	case expires < 0:
+		return nil, ParseV4FromQueryError.Wrap(errNegativeExpires)
+	case expires > int64(maxPresignedExpires/time.Second):
+		return nil, ParseV4FromQueryError.Wrap(errMaximumExpires)
+	}
+	v4.Expires = time.Duration(expires) * time.Second
+
+	switch {
+	case v4.Date.After(now.Add(maxClockSkew)):
+		return nil, ParseV4FromQueryError.Wrap(errRequestNotReadyYet)
+	case now.After(v4.Date.Add(v4.Expires)):
+		return nil, ParseV4FromQueryError.Wrap(errExpiredPresignRequest)
+	}
+
 	mon.Counter("auth",
 		monkit.NewSeriesTag("version", "4"),
 		monkit.NewSeriesTag("type", "query")).Inc(1)
@@ -477,13 +519,37 @@ func ParseV2FromQuery(r *http.Request) (_ *V2, err error) {
 
 // ParseFromForm parses V2 or V4 credentials from multipart form credentials.
 func ParseFromForm(r *http.Request) (string, error) {
-	// create a reset-able body so we don't drain the request body for later
-	const bodyBufferSize = int64(5 * memory.MiB)
-	bodyCache, err := NewBodyCache(r.Body, bodyBufferSize)
+	formValues, err := parseForm(r)
 	if err != nil {
 		return "", err
 	}
-	r.Body = bodyCache
+
+	v4, errMPV4 := ParseV4FromFormValues(formValues)
+	if errMPV4 == nil {
+		return v4.Credential.AccessKeyID, nil
+	}
+
+	v2, errMPV2 := ParseV2FromFormValues(formValues)
+	if errMPV2 == nil {
+		return v2.AccessKeyID, nil
+	}
+
+	return "", errs.Combine(errMPV4, errMPV2)
+}
+
+// parseForm returns the values of the multipart form in the body of r, which
+// can be read again afterwards.
+func parseForm(r *http.Request) (_ http.Header, err error) {
+	// create a reset-able body so we don't drain the request body for later
+	const bodyBufferSize = int64(5 * memory.MiB)
+	bodyCache, ok := r.Body.(*BodyCache)
+	if !ok {
+		bodyCache, err = NewBodyCache(r.Body, bodyBufferSize)
+		if err != nil {
+			return nil, err
+		}
+		r.Body = bodyCache
+	}
 	defer func() {
 		_, seekErr := bodyCache.Seek(0, io.SeekStart)
 		err = errs.Combine(err, seekErr)
@@ -492,30 +558,21 @@ func ParseFromForm(r *http.Request) (string, error) {
 	// now read the body
 	reader, err := getMultipartReader(r)
 	if err != nil {
-		return "", errMalformedPOSTRequest.Wrap(err)
+		return nil, errMalformedPOSTRequest.Wrap(err)
 	}
 	// Read multipart data, limiting to 5 mibyte, as Minio doees
 	form, err := reader.ReadForm(bodyBufferSize)
 	if err != nil {
-		return "", errMalformedPOSTRequest.Wrap(err)
+		return nil, errMalformedPOSTRequest.Wrap(err)
 	}
+	defer func() { err = errs.Combine(err, form.RemoveAll()) }()
+
 	// Canonicalize the form values into http.Header.
 	formValues := make(http.Header)
 	for k, v := range form.Value {
 		formValues[http.CanonicalHeaderKey(k)] = v
 	}
-
-	v4, errMPV4 := ParseV4FromFormValues(formValues)
-	if errMPV4 == nil {
-		return v4.Credential.AccessKeyID, nil
-	}
-
-	v2, errMPV2 := ParseV2FromFormValues(formValues)
-	if errMPV2 == nil {
-		return v2.AccessKeyID, nil
-	}
-
-	return "", errs.Combine(errMPV4, errMPV2)
+	return formValues, nil
 }
 
 func errToAPIErrCode(err error) cmd.APIErrorCode {
@@ -532,6 +589,16 @@ func errToAPIErrCode(err error) cmd.APIErrorCode {
 		return cmd.ErrInvalidQuerySignatureAlgo
 	case errs.Is(err, errInvalidDateHeader):
 		return cmd.ErrMissingDateHeader
+	case errs.Is(err, errMalformedExpires):
+		return cmd.ErrMalformedExpires
+	case errs.Is(err, errNegativeExpires):
+		return cmd.ErrNegativeExpires
+	case errs.Is(err, errMaximumExpires):
+		return cmd.ErrMaximumExpires
+	case errs.Is(err, errRequestNotReadyYet):
+		return cmd.ErrRequestNotReadyYet
+	case errs.Is(err, errExpiredPresignRequest):
+		return cmd.ErrExpiredPresignRequest
 	case errInvalidQuery.Has(err):
 		return cmd.ErrInvalidQueryParams
 	case errMissingFields.Has(err):
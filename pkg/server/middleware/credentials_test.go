@@ -113,7 +113,7 @@ This is some plain text.
 	})
 
 	authClient := authclient.New(authclient.Config{BaseURL: authService.URL, Token: "token", Timeout: 5 * time.Second})
-	AccessKey(authClient, trustedip.NewListTrustAll(), zap.L())(verify).ServeHTTP(nil, req)
+	AccessKey(authClient, trustedip.NewAtomic(trustedip.NewListTrustAll()), zap.L())(verify).ServeHTTP(nil, req)
 }
 
 func TestV2MultipartCredentials(t *testing.T) {
@@ -162,7 +162,7 @@ This is some plain text.
 	})
 
 	authClient := authclient.New(authclient.Config{BaseURL: authService.URL, Token: "token", Timeout: 5 * time.Second})
-	AccessKey(authClient, trustedip.NewListTrustAll(), zap.L())(verify).ServeHTTP(nil, req)
+	AccessKey(authClient, trustedip.NewAtomic(trustedip.NewListTrustAll()), zap.L())(verify).ServeHTTP(nil, req)
 }
 
 func TestAuthResponseErrorLogging(t *testing.T) {
@@ -218,7 +218,7 @@ func TestAuthResponseErrorLogging(t *testing.T) {
 			})
 
 			authClient := authclient.New(authclient.Config{BaseURL: authService.URL, Token: "token", Timeout: 5 * time.Second})
-			AccessKey(authClient, trustedip.NewListTrustAll(), observedLogger)(verify).ServeHTTP(nil, req)
+			AccessKey(authClient, trustedip.NewAtomic(trustedip.NewListTrustAll()), observedLogger)(verify).ServeHTTP(nil, req)
 
 			filteredLogs := observedLogs.FilterField(zap.String("error", fmt.Sprintf("auth service: %d %s", tc.status, http.StatusText(tc.status))))
 			require.Len(t, filteredLogs.All(), 1)
@@ -228,6 +228,8 @@ func TestAuthResponseErrorLogging(t *testing.T) {
 }
 
 func TestAuthParseResponse(t *testing.T) {
+	now := time.Now().UTC().Format(iso8601Format)
+
 	tests := []struct {
 		desc                string
 		method              string
@@ -336,7 +338,7 @@ AccessKey
 		},
 		{
 			desc:              "v4 query request",
-			url:               "?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=123/20130524/us-east-1/s3/aws4_request&X-Amz-Signature=123&X-Amz-Content-SHA256=123&X-Amz-Date=20060102T150405Z",
+			url:               "?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=123/20130524/us-east-1/s3/aws4_request&X-Amz-Signature=123&X-Amz-Content-SHA256=123&X-Amz-Expires=60&X-Amz-Date=" + now,
 			authVersion:       "4",
 			authType:          "query",
 			expectedAccessKey: "123",
@@ -344,12 +346,28 @@ AccessKey
 		},
 		{
 			desc:              "no region v4 query request",
-			url:               "?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=123/20130524//s3/aws4_request&X-Amz-Signature=123&X-Amz-Content-SHA256=123&X-Amz-Date=20060102T150405Z",
+			url:               "?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=123/20130524//s3/aws4_request&X-Amz-Signature=123&X-Amz-Content-SHA256=123&X-Amz-Expires=60&X-Amz-Date=" + now,
 			authVersion:       "4",
 			authType:          "query",
 			expectedAccessKey: "123",
 			expectedCount:     1.0,
 		},
+		{
+			desc:                "expired v4 query request",
+			url:                 "?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=123/20130524/us-east-1/s3/aws4_request&X-Amz-Signature=123&X-Amz-Content-SHA256=123&X-Amz-Expires=60&X-Amz-Date=20060102T150405Z",
+			authVersion:         "4",
+			authType:            "query",
+			expectedErrorCode:   "AccessDenied",
+			expectedErrorStatus: http.StatusForbidden,
+		},
+		{
+			desc:                "no expiry v4 query request",
+			url:                 "?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=123/20130524/us-east-1/s3/aws4_request&X-Amz-Signature=123&X-Amz-Content-SHA256=123&X-Amz-Date=" + now,
+			authVersion:         "4",
+			authType:            "query",
+			expectedErrorCode:   "AuthorizationQueryParametersError",
+			expectedErrorStatus: http.StatusBadRequest,
+		},
 		{
 			desc:                "missing fields v4 query request",
 			url:                 "?X-Amz-Credential=123/20130524/us-east-1/s3/aws4_request",
@@ -557,7 +575,7 @@ AccessKey/20000101/region/s3
 			c := monkit.Collect(monkit.ScopeNamed("storj.io/gateway-mt/pkg/server/middleware"))
 			initialCount := c[metricKey]
 
-			AccessKey(authClient, trustedip.NewListTrustAll(), zap.L())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			AccessKey(authClient, trustedip.NewAtomic(trustedip.NewListTrustAll()), zap.L())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				creds := GetAccess(r.Context())
 				if tc.expectedAccessKey == "" {
 					require.Nil(t, creds)
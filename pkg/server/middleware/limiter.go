@@ -6,14 +6,15 @@ package middleware
 import (
 	"net/http"
 	"sync"
+	"sync/atomic"
 
 	"storj.io/common/grant"
 )
 
 // NewMacaroonLimiter constructs a Limiter that limits based on macaroon credentials.
 // It relies on the AccessKey middleware being run to append credentials to the request context.
-func NewMacaroonLimiter(allowed uint, limitFunc func(w http.ResponseWriter, r *http.Request)) *Limiter {
-	return NewLimiter(allowed, getRequestMacaroonHead, limitFunc)
+func NewMacaroonLimiter(allowed uint, backend LimiterBackend, limitFunc func(w http.ResponseWriter, r *http.Request)) *Limiter {
+	return NewLimiter(allowed, backend, getRequestMacaroonHead, limitFunc)
 }
 
 // getRequestMacaroonHead gets the macaroon head corresponding to the current request.
@@ -30,27 +31,78 @@ func getRequestMacaroonHead(r *http.Request) (ip string, err error) {
 	return string(access.APIKey.Head()), err
 }
 
+// LimiterBackend keeps the counts of concurrent requests per key for Limiter.
+type LimiterBackend interface {
+	// Acquire counts a request for key if fewer than allowed are counted and
+	// returns whether it did.
+	Acquire(key string, allowed uint) bool
+	// Release stops counting a request for key that Acquire counted.
+	Release(key string)
+}
+
+// LocalLimiterBackend is a LimiterBackend counting the requests of this
+// process only.
+type LocalLimiterBackend struct {
+	counts map[string]uint
+	m      sync.Mutex
+}
+
+// NewLocalLimiterBackend constructs a LocalLimiterBackend.
+func NewLocalLimiterBackend() *LocalLimiterBackend {
+	return &LocalLimiterBackend{counts: make(map[string]uint)}
+}
+
+// Acquire implements LimiterBackend.
+func (b *LocalLimiterBackend) Acquire(key string, allowed uint) bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+	if b.counts[key] >= allowed {
+		return false
+	}
+	b.counts[key]++
+	return true
+}
+
+// Release implements LimiterBackend.
+func (b *LocalLimiterBackend) Release(key string) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.counts[key]--
+	if b.counts[key] == 0 {
+		delete(b.counts, key)
+	}
+}
+
 // Limiter imposes a limit per key.
 type Limiter struct {
-	allowed   uint // maximum concurrent allowed
+	allowed   uint64 // maximum concurrent allowed; accessed atomically
+	backend   LimiterBackend
 	keyFunc   func(*http.Request) (string, error)
 	limitFunc func(w http.ResponseWriter, r *http.Request)
-
-	limits map[string]uint
-	m      sync.RWMutex
 }
 
 // NewLimiter constructs a concurrency Limiter.  Error and Limit functions are user defined
 // in part because referencing the "minio" package here would cause an import loop.
-func NewLimiter(allowed uint, keyFunc func(*http.Request) (string, error), limitFunc func(w http.ResponseWriter, r *http.Request)) *Limiter {
+func NewLimiter(allowed uint, backend LimiterBackend, keyFunc func(*http.Request) (string, error), limitFunc func(w http.ResponseWriter, r *http.Request)) *Limiter {
 	return &Limiter{
-		allowed:   allowed,
-		limits:    make(map[string]uint),
+		allowed:   uint64(allowed),
+		backend:   backend,
 		keyFunc:   keyFunc,
 		limitFunc: limitFunc,
 	}
 }
 
+// Allowed returns the maximum number of concurrent requests allowed per key.
+func (l *Limiter) Allowed() uint {
+	return uint(atomic.LoadUint64(&l.allowed))
+}
+
+// SetAllowed changes the maximum number of concurrent requests allowed per
+// key. Requests in progress are kept.
+func (l *Limiter) SetAllowed(allowed uint) {
+	atomic.StoreUint64(&l.allowed, uint64(allowed))
+}
+
 // Limit applies per-key request concurrency limiting as an HTTP middleware.
 func (l *Limiter) Limit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -60,22 +112,11 @@ func (l *Limiter) Limit(next http.Handler) http.Handler {
 			// we do want to continue rate limiting all unauthorized users
 			key = ""
 		}
-		l.m.Lock()
-		l.limits[key]++
-		l.m.Unlock()
-		l.m.RLock()
-		if l.limits[key] > l.allowed {
-			l.m.RUnlock()
+		if !l.backend.Acquire(key, l.Allowed()) {
 			l.limitFunc(w, r)
-		} else {
-			l.m.RUnlock()
-			next.ServeHTTP(w, r)
-		}
-		l.m.Lock()
-		l.limits[key]--
-		if l.limits[key] == 0 {
-			delete(l.limits, key)
+			return
 		}
-		l.m.Unlock()
+		defer l.backend.Release(key)
+		next.ServeHTTP(w, r)
 	})
 }
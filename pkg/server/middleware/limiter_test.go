@@ -26,7 +26,7 @@ func TestNewMacaroonLimiter(t *testing.T) {
 	var next = make(chan struct{})
 	var done = make(chan struct{}, maxConncurrent*2*3)
 	var allTests = make(chan struct{})
-	rateLimiter := NewMacaroonLimiter(maxConncurrent,
+	rateLimiter := NewMacaroonLimiter(maxConncurrent, NewLocalLimiterBackend(),
 		func(w http.ResponseWriter, r *http.Request) {
 			next <- struct{}{} // create in-order results
 			http.Error(w, "", http.StatusTooManyRequests)
@@ -139,9 +139,31 @@ func benchmarkLimiter(l *Limiter) error {
 	return g.Wait()
 }
 func BenchmarkLimiter(b *testing.B) {
-	l := NewLimiter(10, simpleKeyFunc, noopHandler)
+	l := NewLimiter(10, NewLocalLimiterBackend(), simpleKeyFunc, noopHandler)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		require.NoError(b, benchmarkLimiter(l))
 	}
 }
+
+func TestLimiterSetAllowed(t *testing.T) {
+	backend := NewLocalLimiterBackend()
+	limiter := NewLimiter(1, backend, func(r *http.Request) (string, error) { return "key", nil },
+		func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTooManyRequests) })
+	require.EqualValues(t, 1, limiter.Allowed())
+
+	// a request is in progress while others are served.
+	require.True(t, backend.Acquire("key", limiter.Allowed()))
+	defer backend.Release("key")
+
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		limiter.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Code
+	}
+	require.Equal(t, http.StatusTooManyRequests, serve())
+
+	limiter.SetAllowed(2)
+	require.EqualValues(t, 2, limiter.Allowed())
+	require.Equal(t, http.StatusOK, serve())
+}
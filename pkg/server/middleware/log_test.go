@@ -130,7 +130,7 @@ func TestAccessDetailsLogged(t *testing.T) {
 
 	authClient := authclient.New(authclient.Config{BaseURL: authService.URL, Token: "token", Timeout: 5 * time.Second})
 
-	AccessKey(authClient, trustedip.NewListTrustAll(), observedLogger)(LogResponses(observedLogger, handler(), true)).ServeHTTP(rr, req)
+	AccessKey(authClient, trustedip.NewAtomic(trustedip.NewListTrustAll()), observedLogger)(LogResponses(observedLogger, handler(), true)).ServeHTTP(rr, req)
 
 	filteredLogs := observedLogs.FilterField(zap.String("encryption-key-hash", "64f74892360a5cd203e9111d2ce72dd46ee195bf3dc33a2f0dddc892529b145d"))
 	require.Len(t, filteredLogs.All(), 1)
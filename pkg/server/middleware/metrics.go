@@ -10,8 +10,10 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/jtolio/eventkit"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spacemonkeygo/monkit/v3"
 
+	"storj.io/gateway-mt/pkg/prommetrics"
 	"storj.io/gateway-mt/pkg/server/gwlog"
 )
 
@@ -125,10 +127,14 @@ func sanitizeMethod(m string) string {
 // - bytes written
 // partitioned by method, status code, API.
 //
+// The same metrics, and request counts, are recorded for Prometheus too.
+//
 // It also sends unmapped errors (in the case of Gateway-MT) through eventkit.
 //
 // TODO(artur): calculate approximate request size.
 func Metrics(prefix string, next http.Handler) http.Handler {
+	promMetrics := prommetrics.NewHTTPMetrics(prefix)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		log, ok := gwlog.FromContext(ctx)
@@ -139,21 +145,34 @@ func Metrics(prefix string, next http.Handler) http.Handler {
 
 		start := time.Now()
 
-		mf := func(name string) measureFunc {
+		promLabels := func(code int) prometheus.Labels {
+			if code == 0 { // net/http responds with 200 if nothing is written.
+				code = http.StatusOK
+			}
+			return prometheus.Labels{
+				"api":         log.API,
+				"method":      prommetrics.Method(r.Method),
+				"status_code": prommetrics.StatusCode(code),
+			}
+		}
+
+		mf := func(name string, promHistogram *prometheus.HistogramVec) measureFunc {
 			return func(code int) {
+				took := time.Since(start)
 				mon.DurationVal(
 					makeMetricName(prefix, name),
 					monkit.NewSeriesTag("api", log.API),
 					monkit.NewSeriesTag("method", sanitizeMethod(r.Method)),
 					monkit.NewSeriesTag("status_code", strconv.Itoa(code)),
-				).Observe(time.Since(start))
+				).Observe(took)
+				promHistogram.With(promLabels(code)).Observe(took.Seconds())
 			}
 		}
 
 		d := &flusherDelegator{
 			ResponseWriter:        w,
-			atWriteHeaderFunc:     mf("time_to_header"),
-			atTimeToFirstByteFunc: mf("time_to_first_byte"),
+			atWriteHeaderFunc:     mf("time_to_header", promMetrics.TimeToHeader),
+			atTimeToFirstByteFunc: mf("time_to_first_byte", promMetrics.TimeToFirstByte),
 		}
 
 		next.ServeHTTP(d, r)
@@ -169,6 +188,11 @@ func Metrics(prefix string, next http.Handler) http.Handler {
 		mon.IntVal(makeMetricName(prefix, "bytes_written"), tags...).Observe(d.written)
 		mon.FloatVal(makeMetricName(prefix, "bps_written"), tags...).Observe(float64(d.written) / took.Seconds())
 
+		labels := promLabels(d.status)
+		promMetrics.Requests.With(labels).Inc()
+		promMetrics.ResponseTime.With(labels).Observe(took.Seconds())
+		promMetrics.BytesWritten.With(labels).Add(float64(d.written))
+
 		if err := log.TagValue("error"); err != "" { // Gateway-MT-specific
 			ekMetrics.Event(makeMetricName(prefix, "unmapped_error"), eventkit.String("error", err))
 		}
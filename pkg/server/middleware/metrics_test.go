@@ -9,11 +9,14 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/spacemonkeygo/monkit/v3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"storj.io/common/testcontext"
+	"storj.io/gateway-mt/pkg/prommetrics"
 	"storj.io/gateway-mt/pkg/server/gwlog"
 )
 
@@ -63,4 +66,13 @@ func TestMetrics(t *testing.T) {
 	assert.EqualValues(t, bytesWritten, c["gmt_bytes_written,api=ListObjects,method=get,scope=storj.io/gateway-mt/pkg/server/middleware,status_code=400 recent"])
 	assert.EqualValues(t, 3*bytesWritten, c["gmt_bytes_written,api=ListObjects,method=get,scope=storj.io/gateway-mt/pkg/server/middleware,status_code=500 sum"])
 	assert.EqualValues(t, bytesWritten, c["gmt_bytes_written,api=ListObjects,method=get,scope=storj.io/gateway-mt/pkg/server/middleware,status_code=500 recent"])
+
+	promMetrics := prommetrics.NewHTTPMetrics("gmt")
+	for code, count := range map[string]float64{"200": 1, "400": 2, "500": 3} {
+		assert.Equal(t, count, testutil.ToFloat64(promMetrics.Requests.WithLabelValues("ListObjects", "GET", code)))
+		assert.Equal(t, count*bytesWritten, testutil.ToFloat64(promMetrics.BytesWritten.WithLabelValues("ListObjects", "GET", code)))
+	}
+	for _, histogram := range []*prometheus.HistogramVec{promMetrics.ResponseTime, promMetrics.TimeToHeader, promMetrics.TimeToFirstByte} {
+		assert.Equal(t, 3, testutil.CollectAndCount(histogram))
+	}
 }
@@ -0,0 +1,448 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"context"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"storj.io/common/memory"
+	"storj.io/gateway-mt/pkg/trustedip"
+	"storj.io/minio/cmd"
+)
+
+// rateLimitPruneInterval is how often buckets that are full, and so no
+// different from new ones, are removed.
+const rateLimitPruneInterval = time.Minute
+
+// rateLimitShards is the number of shards token buckets are split into, so
+// requests limited per different keys rarely wait on each other.
+const rateLimitShards = 64
+
+// rateLimitMaxBuckets is the most token buckets kept. Once a shard holds its
+// share, full buckets are pruned early and, failing that, an arbitrary bucket
+// is dropped for each new one, so clients using up many keys (e.g. IPs) can't
+// exhaust memory at the cost of limits being reset.
+const rateLimitMaxBuckets = 1 << 20
+
+// RateLimitConfig configures RateLimiter.
+type RateLimitConfig struct {
+	Macaroon RateLimits
+	IP       RateLimits
+}
+
+// RateLimits are the token-bucket limits of requests and bandwidth for a
+// single macaroon head or client IP. Zero rates are unlimited and zero bursts
+// allow one second's worth.
+type RateLimits struct {
+	Requests      float64     `help:"requests per second allowed (0 for unlimited)" default:"0"`
+	RequestsBurst int         `help:"requests allowed in a burst above the rate (0 for a second's worth)" default:"0"`
+	Ingress       memory.Size `help:"bytes per second allowed to be uploaded (0 for unlimited)" default:"0B"`
+	IngressBurst  memory.Size `help:"bytes allowed to be uploaded in a burst above the rate (0 for a second's worth)" default:"0B"`
+	Egress        memory.Size `help:"bytes per second allowed to be downloaded (0 for unlimited)" default:"0B"`
+	EgressBurst   memory.Size `help:"bytes allowed to be downloaded in a burst above the rate (0 for a second's worth)" default:"0B"`
+}
+
+// limit returns the rate and burst of resource.
+func (limits RateLimits) limit(resource rateLimitResource) (rate, burst float64) {
+	switch resource {
+	case requestsResource:
+		rate, burst = limits.Requests, float64(limits.RequestsBurst)
+	case ingressResource:
+		rate, burst = float64(limits.Ingress), float64(limits.IngressBurst)
+	case egressResource:
+		rate, burst = float64(limits.Egress), float64(limits.EgressBurst)
+	}
+	if burst <= 0 {
+		burst = math.Max(rate, 1)
+	}
+	return rate, burst
+}
+
+// rateLimitResource is what a token bucket limits.
+type rateLimitResource int
+
+const (
+	requestsResource rateLimitResource = iota
+	ingressResource
+	egressResource
+)
+
+// rateLimitScope is what a token bucket is kept per.
+type rateLimitScope int
+
+const (
+	macaroonScope rateLimitScope = iota
+	ipScope
+)
+
+// rateLimitKey identifies a token bucket.
+type rateLimitKey struct {
+	scope    rateLimitScope
+	key      string
+	resource rateLimitResource
+}
+
+// tokenBucket holds tokens refilled at a rate up to a burst. Its tokens are
+// negative while it's owed tokens taken ahead of the rate.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens accrued since the bucket was last refilled.
+func (b *tokenBucket) refill(rate, burst float64, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * rate
+		b.last = now
+	}
+	b.tokens = math.Min(b.tokens, burst)
+}
+
+// rateLimitShard holds the token buckets of the keys hashed to it.
+type rateLimitShard struct {
+	mu      sync.Mutex
+	buckets map[rateLimitKey]*tokenBucket
+}
+
+// RateLimiter limits the rate of requests and the bandwidth of their bodies
+// per macaroon head and per client IP with token buckets.
+//
+// Requests are throttled with SlowDown while their macaroon head or client IP
+// has no request tokens or owes bandwidth tokens. The bodies of the others are
+// slowed down to the bandwidth allowed.
+//
+// Client IPs are only taken from headers of requests from the trusted IPs it's
+// given if they list any, as trusting headers of any client would let clients
+// evade their limits by making up IPs.
+type RateLimiter struct {
+	trustedIPs *trustedip.Atomic
+	now        func() time.Time
+	sleep      func(context.Context, time.Duration) error
+
+	config    atomic.Value // RateLimitConfig
+	shards    [rateLimitShards]rateLimitShard
+	shardSize int
+	lastPrune int64 // Unix nanoseconds, accessed atomically
+}
+
+// NewRateLimiter constructs a RateLimiter. It relies on the AccessKey
+// middleware being run to append credentials to the request context.
+func NewRateLimiter(config RateLimitConfig, trustedIPs *trustedip.Atomic) *RateLimiter {
+	l := &RateLimiter{
+		trustedIPs: trustedIPs,
+		now:        time.Now,
+		sleep:      sleep,
+		shardSize:  rateLimitMaxBuckets / rateLimitShards,
+	}
+	l.config.Store(config)
+	for i := range l.shards {
+		l.shards[i].buckets = make(map[rateLimitKey]*tokenBucket)
+	}
+	return l
+}
+
+// Config returns the limits l applies.
+func (l *RateLimiter) Config() RateLimitConfig {
+	return l.config.Load().(RateLimitConfig)
+}
+
+// SetConfig changes the limits l applies, including to requests in progress.
+// Tokens held are kept, up to the new bursts. Bandwidth is only limited for
+// requests that started while their limits had a bandwidth limit.
+func (l *RateLimiter) SetConfig(config RateLimitConfig) {
+	l.config.Store(config)
+}
+
+// Limit applies per macaroon head and client IP rate limiting as an HTTP
+// middleware.
+func (l *RateLimiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := l.Config()
+		scopes := l.scopes(r)
+
+		if wait := l.admit(scopes); wait > 0 {
+			err := cmd.APIError{
+				Code:           "SlowDown",                 // necessary to return a RetryAfter header
+				HTTPStatusCode: http.StatusTooManyRequests, // Minio's ErrSlowDown yields a 503, but 429 seems clearer
+				Description:    "Please reduce your request rate or bandwidth.",
+			}
+			cmd.WriteErrorResponse(r.Context(), retryAfterWriter{ResponseWriter: w, wait: wait}, err, r.URL, false)
+			return
+		}
+
+		if r.Body != nil && r.Body != http.NoBody && config.limitsBandwidth(scopes, ingressResource) {
+			r.Body = &rateLimitedReader{limiter: l, ctx: r.Context(), scopes: scopes, body: r.Body}
+		}
+		if config.limitsBandwidth(scopes, egressResource) {
+			w = &rateLimitedWriter{ResponseWriter: w, limiter: l, ctx: r.Context(), scopes: scopes}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scopes returns the keys r is limited per, by scope. Requests without a
+// valid access grant are only limited per client IP.
+func (l *RateLimiter) scopes(r *http.Request) map[rateLimitScope]string {
+	scopes := map[rateLimitScope]string{ipScope: trustedip.GetClientIP(l.trustedIPs.Load().OnlyListed(), r)}
+	if head, err := getRequestMacaroonHead(r); err == nil {
+		scopes[macaroonScope] = head
+	}
+	return scopes
+}
+
+// limits returns the limits of scope.
+func (config RateLimitConfig) limits(scope rateLimitScope) RateLimits {
+	if scope == macaroonScope {
+		return config.Macaroon
+	}
+	return config.IP
+}
+
+// limitsBandwidth returns whether any of scopes has a limit of resource.
+func (config RateLimitConfig) limitsBandwidth(scopes map[rateLimitScope]string, resource rateLimitResource) bool {
+	for scope := range scopes {
+		if rate, _ := config.limits(scope).limit(resource); rate > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// shard returns the index of the shard holding the buckets of key in scope.
+func shard(scope rateLimitScope, key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte{byte(scope)})
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % rateLimitShards)
+}
+
+// lock locks the shards of scopes, in order so concurrent calls can't
+// deadlock, and returns a function unlocking them.
+func (l *RateLimiter) lock(scopes map[rateLimitScope]string) (unlock func()) {
+	seen := make(map[int]bool, len(scopes))
+	indexes := make([]int, 0, len(scopes))
+	for scope, key := range scopes {
+		if i := shard(scope, key); !seen[i] {
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		l.shards[i].mu.Lock()
+	}
+	return func() {
+		for _, i := range indexes {
+			l.shards[i].mu.Unlock()
+		}
+	}
+}
+
+// bucket returns the refilled token bucket of resource for key in scope or
+// nil if it's unlimited. It must be called with its shard locked.
+func (l *RateLimiter) bucket(config RateLimitConfig, scope rateLimitScope, key string, resource rateLimitResource, now time.Time) (_ *tokenBucket, rate float64) {
+	rate, burst := config.limits(scope).limit(resource)
+	if rate <= 0 {
+		return nil, 0
+	}
+
+	s := &l.shards[shard(scope, key)]
+	k := rateLimitKey{scope: scope, key: key, resource: resource}
+	b, ok := s.buckets[k]
+	if !ok {
+		if len(s.buckets) >= l.shardSize {
+			s.prune(config, now)
+		}
+		for evicted := range s.buckets {
+			if len(s.buckets) < l.shardSize {
+				break
+			}
+			delete(s.buckets, evicted)
+		}
+		b = &tokenBucket{tokens: burst, last: now}
+		s.buckets[k] = b
+	}
+	b.refill(rate, burst, now)
+	return b, rate
+}
+
+// admit takes a request token from the buckets of scopes. If any has none,
+// or owes bandwidth tokens, it takes nothing and returns how long until it
+// would admit the request instead.
+func (l *RateLimiter) admit(scopes map[rateLimitScope]string) (wait time.Duration) {
+	config := l.Config()
+	now := l.now()
+	l.prune(config, now)
+
+	defer l.lock(scopes)()
+
+	var admitted []*tokenBucket
+	for scope, key := range scopes {
+		if b, rate := l.bucket(config, scope, key, requestsResource, now); b != nil {
+			if b.tokens < 1 {
+				wait = maxDuration(wait, seconds((1-b.tokens)/rate))
+			}
+			admitted = append(admitted, b)
+		}
+		for _, resource := range []rateLimitResource{ingressResource, egressResource} {
+			if b, rate := l.bucket(config, scope, key, resource, now); b != nil && b.tokens < 0 {
+				wait = maxDuration(wait, seconds(-b.tokens/rate))
+			}
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	for _, b := range admitted {
+		b.tokens--
+	}
+	return 0
+}
+
+// take takes n tokens of resource from the buckets of scopes, even if they
+// don't have as many, and returns how long until they're no longer owed.
+func (l *RateLimiter) take(scopes map[rateLimitScope]string, resource rateLimitResource, n int) (wait time.Duration) {
+	config := l.Config()
+	now := l.now()
+
+	defer l.lock(scopes)()
+
+	for scope, key := range scopes {
+		if b, rate := l.bucket(config, scope, key, resource, now); b != nil {
+			b.tokens -= float64(n)
+			if b.tokens < 0 {
+				wait = maxDuration(wait, seconds(-b.tokens/rate))
+			}
+		}
+	}
+	return wait
+}
+
+// prune prunes every shard if it's been rateLimitPruneInterval since they
+// were last pruned. Only one of concurrent calls does so.
+func (l *RateLimiter) prune(config RateLimitConfig, now time.Time) {
+	last := atomic.LoadInt64(&l.lastPrune)
+	if now.Sub(time.Unix(0, last)) < rateLimitPruneInterval || !atomic.CompareAndSwapInt64(&l.lastPrune, last, now.UnixNano()) {
+		return
+	}
+
+	for i := range l.shards {
+		s := &l.shards[i]
+		s.mu.Lock()
+		s.prune(config, now)
+		s.mu.Unlock()
+	}
+}
+
+// prune removes full buckets, which are no different from new ones, and the
+// buckets of limits since removed. It must be called with s.mu held.
+func (s *rateLimitShard) prune(config RateLimitConfig, now time.Time) {
+	for k, b := range s.buckets {
+		rate, burst := config.limits(k.scope).limit(k.resource)
+		b.refill(rate, burst, now)
+		if rate <= 0 || b.tokens >= burst {
+			delete(s.buckets, k)
+		}
+	}
+}
+
+// rateLimitedReader is a request body read no faster than its limits allow.
+type rateLimitedReader struct {
+	limiter *RateLimiter
+	ctx     context.Context
+	scopes  map[rateLimitScope]string
+	body    io.ReadCloser
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if n > 0 {
+		if wait := r.limiter.take(r.scopes, ingressResource, n); wait > 0 {
+			if sleepErr := r.limiter.sleep(r.ctx, wait); sleepErr != nil {
+				return n, sleepErr
+			}
+		}
+	}
+	return n, err
+}
+
+func (r *rateLimitedReader) Close() error {
+	return r.body.Close()
+}
+
+// rateLimitedWriter is a response written no faster than its limits allow.
+type rateLimitedWriter struct {
+	http.ResponseWriter
+	limiter *RateLimiter
+	ctx     context.Context
+	scopes  map[rateLimitScope]string
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (int, error) {
+	if wait := w.limiter.take(w.scopes, egressResource, len(p)); wait > 0 {
+		if err := w.limiter.sleep(w.ctx, wait); err != nil {
+			return 0, err
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *rateLimitedWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// retryAfterWriter sets the Retry-After header of a response to wait, in
+// place of the one cmd.WriteErrorResponse sets for SlowDown errors.
+type retryAfterWriter struct {
+	http.ResponseWriter
+	wait time.Duration
+}
+
+func (w retryAfterWriter) WriteHeader(code int) {
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(w.wait.Seconds())), 10))
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w retryAfterWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// sleep waits for d or until ctx is canceled.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// seconds returns s seconds as a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// maxDuration returns the longer of a and b.
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,216 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"storj.io/gateway-mt/pkg/trustedip"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	var slept time.Duration
+
+	newLimiter := func(config RateLimitConfig) *RateLimiter {
+		l := NewRateLimiter(config, trustedip.NewAtomic(trustedip.NewListUntrustAll()))
+		l.now = func() time.Time { return now }
+		l.sleep = func(ctx context.Context, d time.Duration) error {
+			slept += d
+			now = now.Add(d)
+			return nil
+		}
+		return l
+	}
+
+	do := func(l *RateLimiter, remoteAddr string, creds *Credentials, body string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/bucket/object", strings.NewReader(body))
+		r.RemoteAddr = remoteAddr
+		if creds != nil {
+			r = r.WithContext(WithCredentials(r.Context(), creds))
+		}
+		rr := httptest.NewRecorder()
+		l.Limit(handler).ServeHTTP(rr, r)
+		return rr
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	t.Run("requests per IP", func(t *testing.T) {
+		l := newLimiter(RateLimitConfig{IP: RateLimits{Requests: 0.25, RequestsBurst: 2}})
+
+		for i := 0; i < 2; i++ {
+			require.Equal(t, http.StatusOK, do(l, "1.2.3.4:5", nil, "", ok).Code)
+		}
+		rr := do(l, "1.2.3.4:5", nil, "", ok)
+		require.Equal(t, http.StatusTooManyRequests, rr.Code)
+		require.Equal(t, "4", rr.Header().Get("Retry-After"))
+		require.Contains(t, rr.Body.String(), "<Code>SlowDown</Code>")
+
+		require.Equal(t, http.StatusOK, do(l, "5.6.7.8:5", nil, "", ok).Code)
+
+		now = now.Add(3 * time.Second)
+		rr = do(l, "1.2.3.4:5", nil, "", ok)
+		require.Equal(t, http.StatusTooManyRequests, rr.Code)
+		require.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+		now = now.Add(time.Second)
+		require.Equal(t, http.StatusOK, do(l, "1.2.3.4:5", nil, "", ok).Code)
+	})
+
+	t.Run("requests per macaroon head", func(t *testing.T) {
+		l := newLimiter(RateLimitConfig{Macaroon: RateLimits{Requests: 1}})
+		creds1, creds2 := getCredentials(t), getCredentials(t)
+
+		require.Equal(t, http.StatusOK, do(l, "1.2.3.4:5", creds1, "", ok).Code)
+		require.Equal(t, http.StatusTooManyRequests, do(l, "5.6.7.8:5", creds1, "", ok).Code)
+		require.Equal(t, http.StatusOK, do(l, "5.6.7.8:5", creds2, "", ok).Code)
+
+		// requests without an access grant aren't limited per macaroon head.
+		require.Equal(t, http.StatusOK, do(l, "5.6.7.8:5", nil, "", ok).Code)
+		require.Equal(t, http.StatusOK, do(l, "5.6.7.8:5", nil, "", ok).Code)
+	})
+
+	t.Run("ingress", func(t *testing.T) {
+		l := newLimiter(RateLimitConfig{Macaroon: RateLimits{Ingress: 1000}})
+		creds := getCredentials(t)
+
+		slept = 0
+		rr := do(l, "1.2.3.4:5", creds, strings.Repeat("a", 3000), func(w http.ResponseWriter, r *http.Request) {
+			data, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Len(t, data, 3000)
+		})
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.InDelta(t, 2*time.Second, slept, float64(time.Millisecond))
+		require.Equal(t, http.StatusOK, do(l, "1.2.3.4:5", creds, "", ok).Code)
+	})
+
+	t.Run("egress", func(t *testing.T) {
+		l := newLimiter(RateLimitConfig{IP: RateLimits{Egress: 1000, EgressBurst: 2000}})
+
+		slept = 0
+		rr := do(l, "1.2.3.4:5", nil, "", func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < 5; i++ {
+				_, err := w.Write([]byte(strings.Repeat("a", 1000)))
+				require.NoError(t, err)
+			}
+		})
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, 5000, rr.Body.Len())
+		require.InDelta(t, 3*time.Second, slept, float64(time.Millisecond))
+
+		// while bandwidth is owed, e.g. to concurrent requests, requests are
+		// throttled.
+		require.Equal(t, 3*time.Second, l.take(map[rateLimitScope]string{ipScope: "1.2.3.4"}, egressResource, 3000))
+		rr = do(l, "1.2.3.4:5", nil, "", ok)
+		require.Equal(t, http.StatusTooManyRequests, rr.Code)
+		require.Equal(t, "3", rr.Header().Get("Retry-After"))
+
+		now = now.Add(3 * time.Second)
+		require.Equal(t, http.StatusOK, do(l, "1.2.3.4:5", nil, "", ok).Code)
+	})
+
+	t.Run("set config", func(t *testing.T) {
+		l := newLimiter(RateLimitConfig{IP: RateLimits{Requests: 1}})
+
+		require.Equal(t, http.StatusOK, do(l, "1.2.3.4:5", nil, "", ok).Code)
+		require.Equal(t, http.StatusTooManyRequests, do(l, "1.2.3.4:5", nil, "", ok).Code)
+
+		config := l.Config()
+		config.IP.Requests = 0
+		l.SetConfig(config)
+		require.Equal(t, http.StatusOK, do(l, "1.2.3.4:5", nil, "", ok).Code)
+
+		config.IP.Requests = 0.5
+		l.SetConfig(config)
+		rr := do(l, "1.2.3.4:5", nil, "", ok)
+		require.Equal(t, http.StatusTooManyRequests, rr.Code)
+		require.Equal(t, "2", rr.Header().Get("Retry-After"))
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		l := newLimiter(RateLimitConfig{IP: RateLimits{Requests: 1}})
+
+		require.Equal(t, http.StatusOK, do(l, "1.2.3.4:5", nil, "", ok).Code)
+		require.Equal(t, 1, countBuckets(l))
+
+		now = now.Add(rateLimitPruneInterval)
+		require.Equal(t, http.StatusOK, do(l, "5.6.7.8:5", nil, "", ok).Code)
+		require.Equal(t, 1, countBuckets(l))
+	})
+
+	t.Run("max buckets", func(t *testing.T) {
+		l := newLimiter(RateLimitConfig{IP: RateLimits{Requests: 1}})
+		l.shardSize = 1
+
+		for i := 0; i < 3*rateLimitShards; i++ {
+			require.Equal(t, http.StatusOK, do(l, fmt.Sprintf("10.0.%d.%d:5", i/256, i%256), nil, "", ok).Code)
+		}
+		require.LessOrEqual(t, countBuckets(l), rateLimitShards)
+	})
+
+	t.Run("client IP headers", func(t *testing.T) {
+		forwarded := func(l *RateLimiter, remoteAddr, forwardedFor string) int {
+			r := httptest.NewRequest(http.MethodGet, "/bucket/object", nil)
+			r.RemoteAddr = remoteAddr
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+			rr := httptest.NewRecorder()
+			l.Limit(http.HandlerFunc(ok)).ServeHTTP(rr, r)
+			return rr.Code
+		}
+
+		// trusting the headers of any client would let them make up IPs.
+		l := NewRateLimiter(RateLimitConfig{IP: RateLimits{Requests: 1}}, trustedip.NewAtomic(trustedip.NewListTrustAll()))
+		l.now = func() time.Time { return now }
+		require.Equal(t, http.StatusOK, forwarded(l, "1.2.3.4:5", "10.0.0.1"))
+		require.Equal(t, http.StatusTooManyRequests, forwarded(l, "1.2.3.4:5", "10.0.0.2"))
+
+		l = NewRateLimiter(RateLimitConfig{IP: RateLimits{Requests: 1}}, trustedip.NewAtomic(trustedip.NewList("1.2.3.4")))
+		l.now = func() time.Time { return now }
+		require.Equal(t, http.StatusOK, forwarded(l, "1.2.3.4:5", "10.0.0.1"))
+		require.Equal(t, http.StatusOK, forwarded(l, "1.2.3.4:5", "10.0.0.2"))
+		require.Equal(t, http.StatusTooManyRequests, forwarded(l, "1.2.3.4:5", "10.0.0.1"))
+	})
+
+	t.Run("unlimited bandwidth", func(t *testing.T) {
+		l := newLimiter(RateLimitConfig{IP: RateLimits{Requests: 1}, Macaroon: RateLimits{Egress: 1000}})
+
+		// requests are only slowed down by the limits of their scopes.
+		rr := do(l, "1.2.3.4:5", nil, "body", func(w http.ResponseWriter, r *http.Request) {
+			_, wrapped := r.Body.(*rateLimitedReader)
+			require.False(t, wrapped)
+			_, wrapped = w.(*rateLimitedWriter)
+			require.False(t, wrapped)
+		})
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		rr = do(l, "5.6.7.8:5", getCredentials(t), "body", func(w http.ResponseWriter, r *http.Request) {
+			_, wrapped := r.Body.(*rateLimitedReader)
+			require.False(t, wrapped)
+			_, wrapped = w.(*rateLimitedWriter)
+			require.True(t, wrapped)
+		})
+		require.Equal(t, http.StatusOK, rr.Code)
+	})
+}
+
+// countBuckets returns the number of token buckets l holds.
+func countBuckets(l *RateLimiter) (n int) {
+	for i := range l.shards {
+		l.shards[i].mu.Lock()
+		n += len(l.shards[i].buckets)
+		l.shards[i].mu.Unlock()
+	}
+	return n
+}
@@ -0,0 +1,201 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/zeebo/errs"
+	"go.uber.org/zap"
+
+	"storj.io/common/grant"
+	"storj.io/minio/cmd"
+)
+
+// RegionError is a class of Regions errors.
+var RegionError = errs.Class("region")
+
+// routedHeader marks requests routed from the deployment of another region so
+// that they aren't routed again, e.g. if the deployments disagree about the
+// regions of satellites.
+const routedHeader = "X-Gateway-Routed-From"
+
+// RegionConfig configures the region of the deployment and routing requests
+// to the deployments of other regions.
+type RegionConfig struct {
+	Name       string   `help:"region of this deployment, which GetBucketLocation returns for accesses to satellites of no other region" default:""`
+	Satellites []string `help:"regions of satellites as region=address pairs (comma separated); the address may omit the node ID"`
+	Endpoints  []string `help:"deployments of other regions as region=url pairs (comma separated); requests with accesses to satellites of the region are routed to them"`
+	Redirect   bool     `help:"redirect requests to the deployment of their region with 307 Temporary Redirect instead of proxying them" default:"false"`
+}
+
+type regionCV struct{}
+
+// GetRegion returns the region of the satellite of the request of ctx, as set
+// by Regions.Route.
+func GetRegion(ctx context.Context) string {
+	region, _ := ctx.Value(regionCV{}).(string)
+	return region
+}
+
+// WithRegion returns a copy of ctx holding region, which GetRegion returns.
+func WithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionCV{}, region)
+}
+
+// Regions routes requests to the deployments of the regions of the
+// satellites of their accesses.
+//
+// A deployment can dial the satellites of any region, but requests are better
+// served by the deployment closest to their satellite. Requests whose access
+// is to a satellite of another region with a configured deployment are
+// proxied or redirected to it, and all others are served by this one.
+type Regions struct {
+	log        *zap.Logger
+	name       string
+	satellites map[string]string
+	endpoints  map[string]*url.URL
+	redirect   bool
+	proxy      *httputil.ReverseProxy
+}
+
+// NewRegions returns Regions routing requests as config configures.
+func NewRegions(log *zap.Logger, config RegionConfig) (*Regions, error) {
+	regions := &Regions{
+		log:        log,
+		name:       config.Name,
+		satellites: make(map[string]string),
+		endpoints:  make(map[string]*url.URL),
+		redirect:   config.Redirect,
+	}
+
+	for _, pair := range config.Satellites {
+		region, address, err := parseRegionPair(pair)
+		if err != nil {
+			return nil, err
+		}
+		regions.satellites[satelliteHost(address)] = region
+	}
+
+	for _, pair := range config.Endpoints {
+		region, endpoint, err := parseRegionPair(pair)
+		if err != nil {
+			return nil, err
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+			return nil, RegionError.New("invalid endpoint %q of region %q: must be an http(s) URL without a path", endpoint, region)
+		}
+		if region == config.Name {
+			return nil, RegionError.New("region %q is this deployment's region and can't have an endpoint", region)
+		}
+		regions.endpoints[region] = &url.URL{Scheme: u.Scheme, Host: u.Host}
+	}
+
+	regions.proxy = &httputil.ReverseProxy{
+		Director:     regions.direct,
+		ErrorHandler: regions.proxyError,
+	}
+
+	return regions, nil
+}
+
+// parseRegionPair parses a region=value pair of the configuration.
+func parseRegionPair(pair string) (region, value string, err error) {
+	region, value, ok := strings.Cut(pair, "=")
+	if !ok || region == "" || value == "" {
+		return "", "", RegionError.New("invalid pair %q: must be a region=value pair", pair)
+	}
+	return region, value, nil
+}
+
+// satelliteHost returns the host and port of a satellite address, which may
+// be prefixed with the satellite's node ID.
+func satelliteHost(address string) string {
+	if i := strings.LastIndexByte(address, '@'); i >= 0 {
+		address = address[i+1:]
+	}
+	return strings.ToLower(address)
+}
+
+// Region returns the region of the satellite with satelliteAddress, which is
+// the deployment's if it isn't configured.
+func (regions *Regions) Region(satelliteAddress string) string {
+	if region, ok := regions.satellites[satelliteHost(satelliteAddress)]; ok {
+		return region
+	}
+	return regions.name
+}
+
+// Route routes requests with an access to a satellite of another region with
+// a deployment to it and passes all others to next with their region set.
+//
+// It has to be chained after AccessKey, and before anything reading the body
+// of requests.
+func (regions *Regions) Route(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		region := regions.name
+		if credentials := GetAccess(ctx); credentials != nil && credentials.Error == nil && credentials.AccessGrant != "" {
+			if access, err := grant.ParseAccess(credentials.AccessGrant); err == nil {
+				region = regions.Region(access.SatelliteAddress)
+			}
+		}
+
+		if endpoint, ok := regions.endpoints[region]; ok && r.Header.Get(routedHeader) == "" {
+			if regions.redirect {
+				mon.Counter("region_routed", monkit.NewSeriesTag("region", region), monkit.NewSeriesTag("by", "redirect")).Inc(1)
+				location := *endpoint
+				location.Path = r.URL.Path
+				location.RawPath = r.URL.RawPath
+				location.RawQuery = r.URL.RawQuery
+				http.Redirect(w, r, location.String(), http.StatusTemporaryRedirect)
+				return
+			}
+
+			mon.Counter("region_routed", monkit.NewSeriesTag("region", region), monkit.NewSeriesTag("by", "proxy")).Inc(1)
+			regions.proxy.ServeHTTP(w, r.WithContext(WithRegion(ctx, region)))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithRegion(ctx, region)))
+	})
+}
+
+// direct directs a request proxied to the deployment of its region.
+//
+// The Host header is kept, since requests are signed for it and it can name
+// the bucket, so deployments of all regions have to serve the same domains.
+func (regions *Regions) direct(r *http.Request) {
+	endpoint := regions.endpoints[GetRegion(r.Context())]
+
+	if r.Header.Get("X-Forwarded-Proto") == "" {
+		if r.TLS != nil {
+			r.Header.Set("X-Forwarded-Proto", "https")
+		} else {
+			r.Header.Set("X-Forwarded-Proto", "http")
+		}
+	}
+	r.Header.Set(routedHeader, regions.name)
+
+	r.URL.Scheme = endpoint.Scheme
+	r.URL.Host = endpoint.Host
+}
+
+// proxyError responds to a request that failed to be proxied.
+func (regions *Regions) proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	regions.log.Error("failed to proxy request to the deployment of its region", zap.String("region", GetRegion(ctx)), zap.Error(err))
+	cmd.WriteErrorResponse(ctx, w, cmd.APIError{
+		Code:           "ServiceUnavailable",
+		Description:    "The deployment of the region of the access could not be reached. Please try again.",
+		HTTPStatusCode: http.StatusServiceUnavailable,
+	}, r.URL, false)
+}
@@ -0,0 +1,151 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"storj.io/common/grant"
+	"storj.io/common/macaroon"
+	"storj.io/gateway-mt/pkg/authclient"
+)
+
+func TestNewRegions(t *testing.T) {
+	for _, config := range []RegionConfig{
+		{Satellites: []string{"eu1"}},
+		{Satellites: []string{"=eu1.storj.io:7777"}},
+		{Endpoints: []string{"eu1=ftp://gateway.eu1.test"}},
+		{Endpoints: []string{"eu1=https://gateway.eu1.test/path"}},
+		{Endpoints: []string{"eu1=gateway.eu1.test"}},
+		{Name: "us1", Endpoints: []string{"us1=https://gateway.us1.test"}},
+	} {
+		_, err := NewRegions(zap.NewNop(), config)
+		require.Error(t, err, config)
+	}
+
+	regions, err := NewRegions(zap.NewNop(), RegionConfig{
+		Name:       "us1",
+		Satellites: []string{"us1=us1.storj.io:7777", "eu1=12EayRS2V1kEsWESU9QMRseFhdxYxKicsiFmxrsLZHeLUtdps3S@EU1.storj.io:7777"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "us1", regions.Region("12L9ZFwhzVpuEKMUNUqkaTLGzwY9G24tbiigLiXpmZWKwmcNDDs@us1.storj.io:7777"))
+	require.Equal(t, "eu1", regions.Region("eu1.storj.io:7777"))
+	require.Equal(t, "eu1", regions.Region("1SYXsAycDPUu4z2ZksJD5fh5nTDcH3vCFHnpcVye5XuL1NrYV@eu1.storj.io:7777"))
+	require.Equal(t, "us1", regions.Region("ap1.storj.io:7777"))
+}
+
+func TestRegionsRoute(t *testing.T) {
+	apiKey, err := macaroon.NewAPIKey([]byte("secret"))
+	require.NoError(t, err)
+
+	credentials := func(satelliteAddress string) *Credentials {
+		access := grant.Access{
+			SatelliteAddress: satelliteAddress,
+			APIKey:           apiKey,
+			EncAccess:        grant.NewEncryptionAccess(),
+		}
+		serialized, err := access.Serialize()
+		require.NoError(t, err)
+		return &Credentials{
+			AccessKey:           "AccessKey",
+			AuthServiceResponse: authclient.AuthServiceResponse{AccessGrant: serialized},
+		}
+	}
+
+	// the deployment of eu1 echoes what it received.
+	eu1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		w.Header().Set("Received-Host", r.Host)
+		w.Header().Set("Received-Routed-From", r.Header.Get(routedHeader))
+		w.Header().Set("Received-URI", r.RequestURI)
+		_, _ = w.Write(body)
+	}))
+	defer eu1.Close()
+
+	config := RegionConfig{
+		Name:       "us1",
+		Satellites: []string{"eu1=eu1.storj.io:7777", "ap1=ap1.storj.io:7777"},
+		Endpoints:  []string{"eu1=" + eu1.URL},
+	}
+	regions, err := NewRegions(zap.NewNop(), config)
+	require.NoError(t, err)
+
+	var region string
+	handler := regions.Route(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		region = GetRegion(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(handler http.Handler, credentials *Credentials, header http.Header) *httptest.ResponseRecorder {
+		region = ""
+		r := httptest.NewRequest(http.MethodPut, "http://bucket.gateway.test/a%2Fb?partNumber=1", strings.NewReader("body"))
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		if credentials != nil {
+			r = r.WithContext(WithCredentials(r.Context(), credentials))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// requests of this region, of regions without a deployment and without
+	// an access are served here.
+	for satelliteAddress, expected := range map[string]string{
+		"us1.storj.io:7777":   "us1",
+		"ap1.storj.io:7777":   "ap1",
+		"other.storj.io:7777": "us1",
+	} {
+		rec := serve(handler, credentials(satelliteAddress), nil)
+		require.Equal(t, http.StatusOK, rec.Code, satelliteAddress)
+		require.Equal(t, expected, region, satelliteAddress)
+	}
+	rec := serve(handler, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "us1", region)
+
+	// requests of eu1 are proxied with their host.
+	rec = serve(handler, credentials("eu1.storj.io:7777"), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, region)
+	require.Equal(t, "bucket.gateway.test", rec.Header().Get("Received-Host"))
+	require.Equal(t, "us1", rec.Header().Get("Received-Routed-From"))
+	require.Equal(t, "/a%2Fb?partNumber=1", rec.Header().Get("Received-URI"))
+	require.Equal(t, "body", rec.Body.String())
+
+	// unless they've been routed already.
+	rec = serve(handler, credentials("eu1.storj.io:7777"), http.Header{routedHeader: {"eu1"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "eu1", region)
+
+	// or are redirected.
+	config.Redirect = true
+	regions, err = NewRegions(zap.NewNop(), config)
+	require.NoError(t, err)
+
+	rec = serve(regions.Route(handler), credentials("eu1.storj.io:7777"), nil)
+	require.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+	require.Equal(t, eu1.URL+"/a%2Fb?partNumber=1", rec.Header().Get("Location"))
+	require.Empty(t, region)
+
+	// requests that can't be proxied fail.
+	eu1.Close()
+	config.Redirect = false
+	regions, err = NewRegions(zap.NewNop(), config)
+	require.NoError(t, err)
+
+	rec = serve(regions.Route(handler), credentials("eu1.storj.io:7777"), nil)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Empty(t, region)
+}
@@ -0,0 +1,820 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint: gosec // AWS Signature Version 2 uses HMAC-SHA1.
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+
+	"storj.io/common/memory"
+	"storj.io/gateway-mt/pkg/errdata"
+	"storj.io/minio/cmd"
+)
+
+const (
+	// maxClockSkew is how far the date of a signed request can be from the
+	// current time, as in S3.
+	maxClockSkew = 15 * time.Minute
+
+	// maxPresignedExpires is the longest a presigned request can be valid.
+	maxPresignedExpires = 7 * 24 * time.Hour
+
+	// maxChunkSize is the largest chunk of a streaming upload accepted, as in
+	// minio.
+	maxChunkSize = int64(16 * memory.MiB)
+
+	signV4Algorithm  = "AWS4-HMAC-SHA256"
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	emptySHA256      = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// streamingPayloadTrailer is the payload of streaming uploads with
+	// signed chunks followed by a signed trailer.
+	streamingPayloadTrailer = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	// streamingUnsignedPayloadTrailer is the payload of streaming uploads
+	// with unsigned chunks followed by an unsigned trailer.
+	streamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	// trailerSignatureKey is the trailing header holding the signature of
+	// the trailer of streaming uploads with signed chunks.
+	trailerSignatureKey = "x-amz-trailer-signature"
+)
+
+var (
+	// errChunkSignature occurs when the signature of a chunk of a streaming
+	// upload doesn't match.
+	errChunkSignature = errorResponse(cmd.ErrSignatureDoesNotMatch)
+
+	// errMalformedChunk occurs when the body of a streaming upload isn't
+	// chunk-encoded as expected.
+	errMalformedChunk = miniogo.ErrorResponse{
+		Code:       "InvalidRequest",
+		StatusCode: http.StatusBadRequest,
+		Message:    "The request body is not valid aws-chunked encoding.",
+	}
+)
+
+// v2SubResources are the query parameters included in the resource AWS
+// Signature Version 2 signs.
+var v2SubResources = []string{
+	"acl", "cors", "delete", "encryption", "legal-hold", "lifecycle",
+	"location", "logging", "notification", "partNumber", "policy",
+	"requestPayment", "response-cache-control", "response-content-disposition",
+	"response-content-encoding", "response-content-language",
+	"response-content-type", "response-expires", "retention", "select",
+	"select-type", "tagging", "torrent", "uploadId", "uploads", "versionId",
+	"versioning", "versions", "website",
+}
+
+// VerifySignature implements mux.Middleware and verifies the signature of
+// requests with credentials against their secret key. It must be chained
+// after AccessKey.
+//
+// domainNames are the domains buckets can be addressed as subdomains of,
+// which AWS Signature Version 2 signs the bucket of.
+//
+// The body of streaming uploads is decoded, with the signature of each chunk
+// verified as it's read, so the request passed on carries an unsigned
+// payload. Headers sent in the trailer of the body are set in the Trailer of
+// the request once it's read.
+func VerifySignature(domainNames []string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			credentials := GetAccess(r.Context())
+			if credentials == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			errCode := authErrCode(credentials.Error)
+			if errCode == cmd.ErrNone {
+				r, errCode = verifySignature(r, credentials.SecretKey, domainNames, time.Now())
+			}
+			if errCode != cmd.ErrNone {
+				cmd.WriteErrorResponse(r.Context(), w, cmd.GetAPIError(errCode), r.URL, false)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authErrCode returns the error code for err from resolving an access key.
+func authErrCode(err error) cmd.APIErrorCode {
+	if err == nil {
+		return cmd.ErrNone
+	}
+	if errdata.GetStatus(err, http.StatusInternalServerError) >= http.StatusInternalServerError {
+		return cmd.ErrInternalError
+	}
+	return cmd.ErrInvalidAccessKeyID
+}
+
+// verifySignature verifies the signature of r with secretKey at now. It
+// returns r with the body of streaming uploads decoded.
+func verifySignature(r *http.Request, secretKey string, domainNames []string, now time.Time) (*http.Request, cmd.APIErrorCode) {
+	switch {
+	case isRequestSignatureV4(r):
+		return verifyV4FromHeader(r, secretKey, now)
+	case isRequestPresignedSignatureV4(r):
+		return r, verifyV4FromQuery(r, secretKey, now)
+	case isRequestSignatureV2(r):
+		return r, verifyV2FromHeader(r, secretKey, domainNames, now)
+	case isRequestPresignedSignatureV2(r):
+		return r, verifyV2FromQuery(r, secretKey, domainNames, now)
+	case isRequestPostPolicySignature(r):
+		return r, verifyPostPolicy(r, secretKey)
+	default:
+		return r, cmd.ErrSignatureVersionNotSupported
+	}
+}
+
+// parseErrCode returns the error code for err from parsing a signature.
+func parseErrCode(err error) cmd.APIErrorCode {
+	if errCode := errToAPIErrCode(err); errCode != 0 {
+		return errCode
+	}
+	return cmd.ErrSignatureDoesNotMatch
+}
+
+func verifyV4FromHeader(r *http.Request, secretKey string, now time.Time) (*http.Request, cmd.APIErrorCode) {
+	v4, err := ParseV4FromHeader(r)
+	if err != nil {
+		return r, parseErrCode(err)
+	}
+	if v4.Credential.Date.Format(yyyymmdd) != v4.Date.Format(yyyymmdd) {
+		return r, cmd.ErrAuthorizationHeaderMalformed
+	}
+	if errCode := checkV4Credential(v4.Credential); errCode != cmd.ErrNone {
+		return r, errCode
+	}
+	if skewed(v4.Date, now) {
+		return r, cmd.ErrRequestTimeTooSkewed
+	}
+
+	// the payload of requests without its hash is expected to be empty,
+	// which minio then verifies like any other hash.
+	if v4.ContentSHA256 == "" {
+		v4.ContentSHA256 = emptySHA256
+		r.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	}
+
+	signature, errCode := signatureV4(r, v4, r.URL.Query(), secretKey)
+	if errCode != cmd.ErrNone {
+		return r, errCode
+	}
+	if !signaturesEqual(signature, v4.Signature) {
+		return r, cmd.ErrSignatureDoesNotMatch
+	}
+
+	switch v4.ContentSHA256 {
+	case streamingPayload, streamingPayloadTrailer, streamingUnsignedPayloadTrailer:
+		return decodeStreamingPayload(r, v4, secretKey)
+	default:
+		return r, cmd.ErrNone
+	}
+}
+
+func verifyV4FromQuery(r *http.Request, secretKey string, now time.Time) cmd.APIErrorCode {
+	v4, err := parseV4FromQuery(r, now)
+	if err != nil {
+		return parseErrCode(err)
+	}
+	if errCode := checkV4Credential(v4.Credential); errCode != cmd.ErrNone {
+		return errCode
+	}
+
+	query := r.URL.Query()
+	query.Del("X-Amz-Signature")
+	signature, errCode := signatureV4(r, v4, query, secretKey)
+	if errCode != cmd.ErrNone {
+		return errCode
+	}
+	if !signaturesEqual(signature, v4.Signature) {
+		return cmd.ErrSignatureDoesNotMatch
+	}
+	return cmd.ErrNone
+}
+
+// checkV4Credential returns the error code for a V4 credential not scoped to
+// S3.
+func checkV4Credential(credential *V4Credential) cmd.APIErrorCode {
+	if credential.Service != "s3" {
+		return cmd.ErrInvalidServiceS3
+	}
+	return cmd.ErrNone
+}
+
+// skewed returns whether date is too far from now.
+func skewed(date, now time.Time) bool {
+	return date.Before(now.Add(-maxClockSkew)) || date.After(now.Add(maxClockSkew))
+}
+
+// signatureV4 returns the AWS Signature Version 4 of r with query and
+// secretKey.
+func signatureV4(r *http.Request, v4 *V4, query url.Values, secretKey string) (string, cmd.APIErrorCode) {
+	headers, errCode := canonicalHeadersV4(r, v4.SignedHeaders)
+	if errCode != cmd.ErrNone {
+		return "", errCode
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		s3utils.EncodePath(r.URL.Path),
+		strings.ReplaceAll(query.Encode(), "+", "%20"),
+		headers,
+		strings.Join(v4.SignedHeaders, ";"),
+		v4.ContentSHA256,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		signV4Algorithm,
+		v4.Date.Format(iso8601Format),
+		scopeV4(v4.Credential),
+		hashSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(signingKeyV4(secretKey, v4.Credential), []byte(stringToSign))), cmd.ErrNone
+}
+
+// canonicalHeadersV4 returns the canonical form of the signed headers of r.
+func canonicalHeadersV4(r *http.Request, signedHeaders []string) (string, cmd.APIErrorCode) {
+	var hasHost bool
+	var b strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+		switch name {
+		case "host":
+			hasHost = true
+			values = []string{r.Host}
+		case "content-length":
+			values = []string{strconv.FormatInt(r.ContentLength, 10)}
+		case "transfer-encoding":
+			values = r.TransferEncoding
+		case "expect":
+			// Go's HTTP server removes the Expect header it handles.
+			values = r.Header.Values("Expect")
+			if len(values) == 0 {
+				values = []string{"100-continue"}
+			}
+		default:
+			values = r.Header.Values(name)
+			if len(values) == 0 {
+				values = r.URL.Query()[name]
+			}
+		}
+		if len(values) == 0 {
+			return "", cmd.ErrUnsignedHeaders
+		}
+
+		b.WriteString(name + ":")
+		for i, value := range values {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(strings.Join(strings.Fields(value), " "))
+		}
+		b.WriteString("\n")
+	}
+	if !hasHost {
+		return "", cmd.ErrUnsignedHeaders
+	}
+	return b.String(), cmd.ErrNone
+}
+
+// scopeV4 returns the scope credential signs for.
+func scopeV4(credential *V4Credential) string {
+	return strings.Join([]string{
+		credential.Date.Format(yyyymmdd),
+		credential.Region,
+		credential.Service,
+		"aws4_request",
+	}, "/")
+}
+
+// signingKeyV4 returns the key AWS Signature Version 4 signs with for
+// credential.
+func signingKeyV4(secretKey string, credential *V4Credential) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), []byte(credential.Date.Format(yyyymmdd)))
+	key = hmacSHA256(key, []byte(credential.Region))
+	key = hmacSHA256(key, []byte(credential.Service))
+	return hmacSHA256(key, []byte("aws4_request"))
+}
+
+func verifyV2FromHeader(r *http.Request, secretKey string, domainNames []string, now time.Time) cmd.APIErrorCode {
+	v2, err := ParseV2FromHeader(r)
+	if err != nil {
+		return parseErrCode(err)
+	}
+
+	date := r.Header.Get("X-Amz-Date")
+	if date == "" {
+		date = r.Header.Get("Date")
+	}
+	t, err := http.ParseTime(date)
+	if err != nil {
+		return cmd.ErrMissingDateHeader
+	}
+	if skewed(t, now) {
+		return cmd.ErrRequestTimeTooSkewed
+	}
+
+	query, errCode := unescapedQueryV2(r, nil)
+	if errCode != cmd.ErrNone {
+		return errCode
+	}
+
+	// the Date header isn't signed if there's an X-Amz-Date header, which is
+	// signed like any other header.
+	if r.Header.Get("X-Amz-Date") != "" {
+		date = ""
+	}
+
+	signature := signatureV2(r, query, date, secretKey, domainNames)
+	if !signaturesEqual(signature, v2.Signature) {
+		return cmd.ErrSignatureDoesNotMatch
+	}
+	return cmd.ErrNone
+}
+
+func verifyV2FromQuery(r *http.Request, secretKey string, domainNames []string, now time.Time) cmd.APIErrorCode {
+	v2, err := ParseV2FromQuery(r)
+	if err != nil {
+		return parseErrCode(err)
+	}
+
+	expires := r.URL.Query().Get("Expires")
+	if expires == "" {
+		return cmd.ErrInvalidQueryParams
+	}
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return cmd.ErrMalformedExpires
+	}
+	if now.After(time.Unix(expiresUnix, 0)) {
+		return cmd.ErrExpiredPresignRequest
+	}
+
+	query, errCode := unescapedQueryV2(r, []string{"AWSAccessKeyId", "Signature", "Expires"})
+	if errCode != cmd.ErrNone {
+		return errCode
+	}
+
+	signature := signatureV2(r, query, expires, secretKey, domainNames)
+	if !signaturesEqual(signature, v2.Signature) {
+		return cmd.ErrSignatureDoesNotMatch
+	}
+	return cmd.ErrNone
+}
+
+// unescapedQueryV2 returns the unescaped parameters of the query of r except
+// for the ones named in exclude.
+func unescapedQueryV2(r *http.Request, exclude []string) ([]string, cmd.APIErrorCode) {
+	var query []string
+	for _, param := range strings.Split(r.URL.RawQuery, "&") {
+		unescaped, err := url.QueryUnescape(param)
+		if err != nil {
+			return nil, cmd.ErrInvalidQueryParams
+		}
+		name, _, _ := strings.Cut(unescaped, "=")
+		if !contains(exclude, name) {
+			query = append(query, unescaped)
+		}
+	}
+	return query, cmd.ErrNone
+}
+
+// signatureV2 returns the AWS Signature Version 2 of r with its unescaped
+// query, date (or expiry) and secretKey.
+func signatureV2(r *http.Request, query []string, date, secretKey string, domainNames []string) string {
+	var amzHeaders []string
+	for name, values := range r.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") {
+			amzHeaders = append(amzHeaders, name+":"+strings.Join(values, ","))
+		}
+	}
+	sort.Strings(amzHeaders)
+
+	var canonicalHeaders string
+	if len(amzHeaders) > 0 {
+		canonicalHeaders = strings.Join(amzHeaders, "\n") + "\n"
+	}
+
+	stringToSign := strings.Join([]string{
+		r.Method,
+		r.Header.Get("Content-Md5"),
+		r.Header.Get("Content-Type"),
+		date,
+		canonicalHeaders + canonicalResourceV2(r, query, domainNames),
+	}, "\n")
+
+	hm := hmac.New(sha1.New, []byte(secretKey))
+	_, _ = hm.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(hm.Sum(nil))
+}
+
+// canonicalResourceV2 returns the resource AWS Signature Version 2 signs for
+// r with its unescaped query, which includes the bucket of virtual-hosted
+// requests and the subresources in the query.
+func canonicalResourceV2(r *http.Request, query []string, domainNames []string) string {
+	resource := r.URL.EscapedPath()
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, domain := range domainNames {
+		if bucket := strings.TrimSuffix(host, "."+domain); bucket != host {
+			resource = "/" + bucket + resource
+			break
+		}
+	}
+
+	params := make(map[string]string)
+	for _, param := range query {
+		name, value, _ := strings.Cut(param, "=")
+		params[name] = value
+	}
+
+	var subResources []string
+	for _, name := range v2SubResources {
+		value, ok := params[name]
+		switch {
+		case !ok:
+		case value == "":
+			subResources = append(subResources, name)
+		default:
+			subResources = append(subResources, name+"="+value)
+		}
+	}
+	if len(subResources) > 0 {
+		resource += "?" + strings.Join(subResources, "&")
+	}
+	return resource
+}
+
+func verifyPostPolicy(r *http.Request, secretKey string) cmd.APIErrorCode {
+	formValues, err := parseForm(r)
+	if err != nil {
+		return parseErrCode(err)
+	}
+
+	policy := formValues.Get("Policy")
+
+	if v4, err := ParseV4FromFormValues(formValues); err == nil {
+		if formValues.Get("X-Amz-Algorithm") != signV4Algorithm {
+			return cmd.ErrSignatureVersionNotSupported
+		}
+		if errCode := checkV4Credential(v4.Credential); errCode != cmd.ErrNone {
+			return errCode
+		}
+		signature := hex.EncodeToString(hmacSHA256(signingKeyV4(secretKey, v4.Credential), []byte(policy)))
+		if !signaturesEqual(signature, v4.Signature) {
+			return cmd.ErrSignatureDoesNotMatch
+		}
+		return cmd.ErrNone
+	}
+
+	v2, err := ParseV2FromFormValues(formValues)
+	if err != nil {
+		return parseErrCode(err)
+	}
+	hm := hmac.New(sha1.New, []byte(secretKey))
+	_, _ = hm.Write([]byte(policy))
+	if !signaturesEqual(base64.StdEncoding.EncodeToString(hm.Sum(nil)), v2.Signature) {
+		return cmd.ErrSignatureDoesNotMatch
+	}
+	return cmd.ErrNone
+}
+
+// decodeStreamingPayload returns r with its chunk-encoded body decoded and its
+// headers describing the decoded body.
+//
+// The headers declared in X-Amz-Trailer to be sent after the body are set in
+// the Trailer of r once they're read, which is before the last bytes of the
+// body are returned.
+func decodeStreamingPayload(r *http.Request, v4 *V4, secretKey string) (*http.Request, cmd.APIErrorCode) {
+	size, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+	if err != nil || size < 0 {
+		return r, cmd.ErrMissingContentLength
+	}
+
+	var trailer http.Header
+	if v4.ContentSHA256 != streamingPayload {
+		trailer = make(http.Header)
+		for _, key := range strings.Split(r.Header.Get("X-Amz-Trailer"), ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				trailer[http.CanonicalHeaderKey(key)] = nil
+			}
+		}
+	}
+
+	r.Body = readCloser{
+		Reader: &chunkedReader{
+			r:         bufio.NewReader(r.Body),
+			signed:    v4.ContentSHA256 != streamingUnsignedPayloadTrailer,
+			key:       signingKeyV4(secretKey, v4.Credential),
+			date:      v4.Date.Format(iso8601Format),
+			scope:     scopeV4(v4.Credential),
+			signature: v4.Signature,
+			trailer:   trailer,
+			remaining: size,
+		},
+		Closer: r.Body,
+	}
+	r.Trailer = trailer
+
+	r.ContentLength = size
+	r.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+	r.Header.Del("X-Amz-Decoded-Content-Length")
+	r.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	var encodings []string
+	for _, encoding := range strings.Split(r.Header.Get("Content-Encoding"), ",") {
+		if encoding = strings.TrimSpace(encoding); encoding != "" && encoding != "aws-chunked" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	if len(encodings) > 0 {
+		r.Header.Set("Content-Encoding", strings.Join(encodings, ","))
+	} else {
+		r.Header.Del("Content-Encoding")
+	}
+
+	return r, cmd.ErrNone
+}
+
+// chunkedReader decodes an aws-chunked body, failing with errChunkSignature
+// once a chunk (or a trailer) with a signature that doesn't match is read.
+//
+// A body that's cut short or longer than its decoded length fails too, so
+// that uploads of it aren't committed.
+type chunkedReader struct {
+	r         *bufio.Reader
+	signed    bool // whether the chunks and the trailer are signed
+	key       []byte
+	date      string
+	scope     string
+	signature string // signature of the previous chunk
+
+	trailer   http.Header // nil if the body has no trailer
+	remaining int64       // bytes of the decoded body left
+
+	chunk []byte
+	buf   []byte // rest of the current chunk
+	err   error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.err = c.readChunk()
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	c.remaining -= int64(n)
+
+	// minio stops reading once it has read the decoded length, so the last
+	// chunk and the trailer are read along with the last bytes of the body
+	// for them to be verified too.
+	if c.remaining == 0 && len(c.buf) == 0 && c.err == nil {
+		if c.err = c.readChunk(); c.err == nil {
+			c.buf, c.err = nil, errMalformedChunk
+		}
+		return n, c.err
+	}
+	return n, nil
+}
+
+// readChunk reads and verifies the next chunk, returning io.EOF after the
+// last one (and the trailer following it).
+func (c *chunkedReader) readChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(line, "\r") {
+		return errMalformedChunk
+	}
+	line = line[:len(line)-1]
+
+	sizeHex, signature := line, ""
+	if c.signed {
+		var ok bool
+		if sizeHex, signature, ok = strings.Cut(line, ";chunk-signature="); !ok {
+			return errMalformedChunk
+		}
+	}
+	size, err := strconv.ParseInt(sizeHex, 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize || size > c.remaining {
+		return errMalformedChunk
+	}
+
+	var data []byte
+	// the last chunk is directly followed by the trailer if there's one.
+	if size > 0 || c.trailer == nil {
+		if int64(cap(c.chunk)) < size+2 {
+			c.chunk = make([]byte, size+2)
+		}
+		data = c.chunk[:size+2]
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return chunkError(err)
+		}
+		if !bytes.HasSuffix(data, []byte("\r\n")) {
+			return errMalformedChunk
+		}
+		data = data[:size]
+	}
+
+	if c.signed {
+		stringToSign := strings.Join([]string{
+			signV4Algorithm + "-PAYLOAD",
+			c.date,
+			c.scope,
+			c.signature,
+			emptySHA256,
+			hashSHA256(data),
+		}, "\n")
+		expected := hex.EncodeToString(hmacSHA256(c.key, []byte(stringToSign)))
+		if !signaturesEqual(expected, signature) {
+			return errChunkSignature
+		}
+		c.signature = signature
+	}
+
+	if size > 0 {
+		c.buf = data
+		return nil
+	}
+
+	if c.remaining > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	if c.trailer != nil {
+		if err := c.readTrailer(); err != nil {
+			return err
+		}
+	}
+	return io.EOF
+}
+
+// readTrailer reads the trailer following the last chunk, verifies its
+// signature if the chunks are signed and sets the headers in it, which have
+// to be the declared ones.
+//
+// The trailer is a line for each header, followed by the signature if it's
+// signed, and ends with an empty line. Its signature is of its headers as
+// lowercase name:value lines ending with \n, however they were sent.
+func (c *chunkedReader) readTrailer() error {
+	var canonical strings.Builder
+	values := make(map[string]string)
+
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			// some clients separate the signature from the headers with an
+			// empty line.
+			if c.signed {
+				continue
+			}
+			break
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return errMalformedChunk
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		if c.signed && key == trailerSignatureKey {
+			stringToSign := strings.Join([]string{
+				signV4Algorithm + "-TRAILER",
+				c.date,
+				c.scope,
+				c.signature,
+				hashSHA256([]byte(canonical.String())),
+			}, "\n")
+			expected := hex.EncodeToString(hmacSHA256(c.key, []byte(stringToSign)))
+			if !signaturesEqual(expected, value) {
+				return errChunkSignature
+			}
+
+			if line, err = c.readLine(); err != nil {
+				return err
+			}
+			if line != "\r" {
+				return errMalformedChunk
+			}
+			break
+		}
+
+		if _, ok := c.trailer[http.CanonicalHeaderKey(key)]; !ok {
+			return errMalformedChunk
+		}
+		if _, ok := values[key]; ok {
+			return errMalformedChunk
+		}
+		values[key] = value
+		canonical.WriteString(key + ":" + value + "\n")
+	}
+
+	if len(values) != len(c.trailer) {
+		return io.ErrUnexpectedEOF
+	}
+	for key, value := range values {
+		c.trailer.Set(key, value)
+	}
+	return nil
+}
+
+// readLine reads a line, without the \n ending it.
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err != nil {
+		return "", chunkError(err)
+	}
+	return string(line[:len(line)-1]), nil
+}
+
+// chunkError returns the error for err from reading a chunk.
+func chunkError(err error) error {
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		// minio reports bodies ending early as incomplete.
+		return io.ErrUnexpectedEOF
+	case errors.Is(err, bufio.ErrBufferFull):
+		return errMalformedChunk
+	default:
+		return err
+	}
+}
+
+// readCloser combines a reader and a closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errorResponse returns the error for code, which minio reports as is when
+// reading the body of a request fails with it.
+func errorResponse(code cmd.APIErrorCode) miniogo.ErrorResponse {
+	apiErr := cmd.GetAPIError(code)
+	return miniogo.ErrorResponse{
+		Code:       apiErr.Code,
+		StatusCode: apiErr.HTTPStatusCode,
+		Message:    apiErr.Description,
+	}
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	hm := hmac.New(sha256.New, key)
+	_, _ = hm.Write(data)
+	return hm.Sum(nil)
+}
+
+func hashSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// signaturesEqual compares signatures in constant time.
+func signaturesEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
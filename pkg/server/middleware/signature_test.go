@@ -0,0 +1,429 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/gateway-mt/pkg/authclient"
+	"storj.io/gateway-mt/pkg/errdata"
+	"storj.io/minio/cmd"
+)
+
+const (
+	testAccessKey = "AccessKey"
+	testSecretKey = "SecretKey"
+	testRegion    = "us-east-1"
+)
+
+func TestVerifySignatureV4FromHeader(t *testing.T) {
+	now := time.Now()
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "http://localhost/bucket/my%20object?tagging&x=a+b", strings.NewReader("hello world"))
+		r.Header.Set("X-Amz-Content-Sha256", hashSHA256([]byte("hello world")))
+		r.Header.Set("X-Amz-Meta-Key", "  some   value ")
+		return signer.SignV4(*r, testAccessKey, testSecretKey, "", testRegion)
+	}
+
+	_, errCode := verifySignature(newRequest(), testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrNone, errCode)
+
+	_, errCode = verifySignature(newRequest(), "other", nil, now)
+	require.Equal(t, cmd.ErrSignatureDoesNotMatch, errCode)
+
+	r := newRequest()
+	r.Header.Set("X-Amz-Meta-Key", "other")
+	_, errCode = verifySignature(r, testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrSignatureDoesNotMatch, errCode)
+
+	r = newRequest()
+	r.URL.RawQuery = "tagging"
+	_, errCode = verifySignature(r, testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrSignatureDoesNotMatch, errCode)
+
+	_, errCode = verifySignature(newRequest(), testSecretKey, nil, now.Add(time.Hour))
+	require.Equal(t, cmd.ErrRequestTimeTooSkewed, errCode)
+
+	r = newRequest()
+	r.Header.Set("Authorization", strings.Replace(r.Header.Get("Authorization"), "SignedHeaders=host;", "SignedHeaders=", 1))
+	_, errCode = verifySignature(r, testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrUnsignedHeaders, errCode)
+
+	r = newRequest()
+	r.Header.Set("Authorization", strings.Replace(r.Header.Get("Authorization"), "/s3/", "/sts/", 1))
+	_, errCode = verifySignature(r, testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrInvalidServiceS3, errCode)
+
+	// requests without a payload hash are verified as having an empty payload.
+	r = httptest.NewRequest(http.MethodGet, "http://localhost/bucket/object", nil)
+	r = signer.SignV4(*r, testAccessKey, testSecretKey, "", testRegion)
+	r.Header.Del("X-Amz-Content-Sha256")
+	r, errCode = verifySignature(r, testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrSignatureDoesNotMatch, errCode)
+	require.Equal(t, emptySHA256, r.Header.Get("X-Amz-Content-Sha256"))
+}
+
+func TestVerifySignatureV4FromQuery(t *testing.T) {
+	now := time.Now()
+
+	newRequest := func(expires int64) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/bucket/object?versionId=1", nil)
+		return signer.PreSignV4(*r, testAccessKey, testSecretKey, "", testRegion, expires)
+	}
+
+	_, errCode := verifySignature(newRequest(60), testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrNone, errCode)
+
+	_, errCode = verifySignature(newRequest(60), "other", nil, now)
+	require.Equal(t, cmd.ErrSignatureDoesNotMatch, errCode)
+
+	_, errCode = verifySignature(newRequest(60), testSecretKey, nil, now.Add(time.Hour))
+	require.Equal(t, cmd.ErrExpiredPresignRequest, errCode)
+
+	_, errCode = verifySignature(newRequest(60), testSecretKey, nil, now.Add(-time.Hour))
+	require.Equal(t, cmd.ErrRequestNotReadyYet, errCode)
+
+	_, errCode = verifySignature(newRequest(8*24*60*60), testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrMaximumExpires, errCode)
+
+	r := newRequest(60)
+	r.URL.RawQuery = strings.Replace(r.URL.RawQuery, "versionId=1", "versionId=2", 1)
+	_, errCode = verifySignature(r, testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrSignatureDoesNotMatch, errCode)
+
+	r = newRequest(60)
+	r.URL.RawQuery = strings.Replace(r.URL.RawQuery, "X-Amz-Expires=60", "X-Amz-Expires=-1", 1)
+	_, errCode = verifySignature(r, testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrNegativeExpires, errCode)
+}
+
+func TestVerifySignatureV2FromHeader(t *testing.T) {
+	now := time.Now()
+	domainNames := []string{"localhost"}
+
+	newRequest := func(url string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, url, strings.NewReader("hello world"))
+		r.Header.Set("Content-Type", "text/plain")
+		r.Header.Set("X-Amz-Meta-Key", "value")
+		return signer.SignV2(*r, testAccessKey, testSecretKey, strings.Contains(url, "bucket.localhost"))
+	}
+
+	for _, url := range []string{
+		"http://localhost/bucket/object?uploadId=1&partNumber=2",
+		"http://bucket.localhost/object?uploadId=1&partNumber=2",
+	} {
+		_, errCode := verifySignature(newRequest(url), testSecretKey, domainNames, now)
+		require.Equal(t, cmd.ErrNone, errCode, url)
+
+		_, errCode = verifySignature(newRequest(url), "other", domainNames, now)
+		require.Equal(t, cmd.ErrSignatureDoesNotMatch, errCode, url)
+
+		r := newRequest(url)
+		r.Header.Set("Content-Type", "text/html")
+		_, errCode = verifySignature(r, testSecretKey, domainNames, now)
+		require.Equal(t, cmd.ErrSignatureDoesNotMatch, errCode, url)
+
+		_, errCode = verifySignature(newRequest(url), testSecretKey, domainNames, now.Add(time.Hour))
+		require.Equal(t, cmd.ErrRequestTimeTooSkewed, errCode, url)
+	}
+
+	r := newRequest("http://localhost/bucket/object")
+	r.Header.Del("Date")
+	_, errCode := verifySignature(r, testSecretKey, domainNames, now)
+	require.Equal(t, cmd.ErrMissingDateHeader, errCode)
+}
+
+func TestVerifySignatureV2FromQuery(t *testing.T) {
+	now := time.Now()
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/bucket/object?response-content-type=text%2Fplain", nil)
+		return signer.PreSignV2(*r, testAccessKey, testSecretKey, 60, false)
+	}
+
+	_, errCode := verifySignature(newRequest(), testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrNone, errCode)
+
+	_, errCode = verifySignature(newRequest(), "other", nil, now)
+	require.Equal(t, cmd.ErrSignatureDoesNotMatch, errCode)
+
+	_, errCode = verifySignature(newRequest(), testSecretKey, nil, now.Add(time.Hour))
+	require.Equal(t, cmd.ErrExpiredPresignRequest, errCode)
+}
+
+func TestVerifySignaturePostPolicy(t *testing.T) {
+	now := time.Now()
+	policy := base64.StdEncoding.EncodeToString([]byte(`{"expiration":"2100-01-01T00:00:00.000Z","conditions":[{"bucket":"bucket"}]}`))
+
+	newRequest := func(fields map[string]string) *http.Request {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for name, value := range fields {
+			require.NoError(t, writer.WriteField(name, value))
+		}
+		file, err := writer.CreateFormFile("file", "file.txt")
+		require.NoError(t, err)
+		_, err = file.Write([]byte("hello world"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		r := httptest.NewRequest(http.MethodPost, "http://localhost/bucket", &body)
+		r.Header.Set("Content-Type", writer.FormDataContentType())
+		return r
+	}
+
+	v4Fields := map[string]string{
+		"key":              "object",
+		"policy":           policy,
+		"x-amz-algorithm":  signV4Algorithm,
+		"x-amz-credential": testAccessKey + "/" + now.UTC().Format(yyyymmdd) + "/" + testRegion + "/s3/aws4_request",
+		"x-amz-date":       now.UTC().Format(iso8601Format),
+		"x-amz-signature":  signer.PostPresignSignatureV4(policy, now.UTC(), testSecretKey, testRegion),
+	}
+	r, errCode := verifySignature(newRequest(v4Fields), testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrNone, errCode)
+
+	// the body can still be read.
+	require.NoError(t, r.ParseMultipartForm(1024))
+	require.Equal(t, "object", r.MultipartForm.Value["key"][0])
+
+	_, errCode = verifySignature(newRequest(v4Fields), "other", nil, now)
+	require.Equal(t, cmd.ErrSignatureDoesNotMatch, errCode)
+
+	v2Fields := map[string]string{
+		"key":            "object",
+		"policy":         policy,
+		"AWSAccessKeyId": testAccessKey,
+		"signature":      signer.PostPresignSignatureV2(policy, testSecretKey),
+	}
+	_, errCode = verifySignature(newRequest(v2Fields), testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrNone, errCode)
+
+	_, errCode = verifySignature(newRequest(v2Fields), "other", nil, now)
+	require.Equal(t, cmd.ErrSignatureDoesNotMatch, errCode)
+}
+
+func TestVerifySignatureStreaming(t *testing.T) {
+	now := time.Now()
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+
+	newRequest := func() (*http.Request, []byte) {
+		r := httptest.NewRequest(http.MethodPut, "http://localhost/bucket/object", bytes.NewReader(data))
+		r.Header.Set("Content-Encoding", "aws-chunked,gzip")
+		r = signer.StreamingSignV4(r, testAccessKey, testSecretKey, "", testRegion, int64(len(data)), now.UTC())
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		return r, body
+	}
+
+	r, body := newRequest()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r, errCode := verifySignature(r, testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrNone, errCode)
+	require.EqualValues(t, len(data), r.ContentLength)
+	require.Equal(t, unsignedPayload, r.Header.Get("X-Amz-Content-Sha256"))
+	require.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+
+	decoded, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	require.Equal(t, data, decoded)
+
+	// tampered chunk
+	r, body = newRequest()
+	body[len(body)/2] ^= 1
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r, errCode = verifySignature(r, testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrNone, errCode)
+	_, err = io.ReadAll(r.Body)
+	require.ErrorAs(t, err, &miniogo.ErrorResponse{})
+	require.Equal(t, errChunkSignature, err)
+
+	// truncated body
+	r, body = newRequest()
+	r.Body = io.NopCloser(bytes.NewReader(body[:len(body)/2]))
+	r, errCode = verifySignature(r, testSecretKey, nil, now)
+	require.Equal(t, cmd.ErrNone, errCode)
+	_, err = io.ReadAll(r.Body)
+	require.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+func TestVerifySignatureStreamingTrailer(t *testing.T) {
+	now := time.Now()
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	checksum := "x-amz-checksum-crc32:" + base64.StdEncoding.EncodeToString(crc32.NewIEEE().Sum(nil))
+
+	// newRequest returns a request uploading data in chunks of 64KiB,
+	// followed by trailer, and its encoded body.
+	newRequest := func(payload, trailer string) (*http.Request, []byte) {
+		r := httptest.NewRequest(http.MethodPut, "http://localhost/bucket/object", nil)
+		r.Header.Set("Content-Encoding", "aws-chunked")
+		r.Header.Set("X-Amz-Content-Sha256", payload)
+		r.Header.Set("X-Amz-Decoded-Content-Length", strconv.Itoa(len(data)))
+		r.Header.Set("X-Amz-Trailer", "x-amz-checksum-crc32")
+		r = signer.SignV4(*r, testAccessKey, testSecretKey, "", testRegion)
+
+		v4, err := ParseV4FromHeader(r)
+		require.NoError(t, err)
+		key, date, scope := signingKeyV4(testSecretKey, v4.Credential), v4.Date.Format(iso8601Format), scopeV4(v4.Credential)
+		signature := v4.Signature
+
+		var body bytes.Buffer
+		for offset := 0; ; {
+			end := offset + 64*1024
+			if end > len(data) {
+				end = len(data)
+			}
+			chunk := data[offset:end]
+			offset = end
+
+			fmt.Fprintf(&body, "%x", len(chunk))
+			if payload == streamingPayloadTrailer {
+				signature = hex.EncodeToString(hmacSHA256(key, []byte(strings.Join([]string{
+					"AWS4-HMAC-SHA256-PAYLOAD", date, scope, signature, emptySHA256, hashSHA256(chunk),
+				}, "\n"))))
+				body.WriteString(";chunk-signature=" + signature)
+			}
+			body.WriteString("\r\n")
+			if len(chunk) == 0 {
+				break
+			}
+			body.Write(chunk)
+			body.WriteString("\r\n")
+		}
+
+		body.WriteString(trailer + "\r\n")
+		if payload == streamingPayloadTrailer {
+			signature = hex.EncodeToString(hmacSHA256(key, []byte(strings.Join([]string{
+				"AWS4-HMAC-SHA256-TRAILER", date, scope, signature, hashSHA256([]byte(trailer + "\n")),
+			}, "\n"))))
+			body.WriteString("x-amz-trailer-signature:" + signature + "\r\n")
+		}
+		body.WriteString("\r\n")
+
+		return r, body.Bytes()
+	}
+
+	decode := func(r *http.Request, body []byte) (*http.Request, []byte, error) {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r, errCode := verifySignature(r, testSecretKey, nil, now)
+		require.Equal(t, cmd.ErrNone, errCode)
+
+		decoded, err := io.ReadAll(r.Body)
+		return r, decoded, err
+	}
+
+	for _, payload := range []string{streamingPayloadTrailer, streamingUnsignedPayloadTrailer} {
+		t.Run(payload, func(t *testing.T) {
+			r, body := newRequest(payload, checksum)
+			r, decoded, err := decode(r, body)
+			require.NoError(t, err)
+			require.Equal(t, data, decoded)
+			require.EqualValues(t, len(data), r.ContentLength)
+			require.Equal(t, unsignedPayload, r.Header.Get("X-Amz-Content-Sha256"))
+			require.Empty(t, r.Header.Get("Content-Encoding"))
+			require.Equal(t, http.Header{"X-Amz-Checksum-Crc32": {strings.TrimPrefix(checksum, "x-amz-checksum-crc32:")}}, r.Trailer)
+
+			// the trailer is read along with the last bytes of the body,
+			// before minio stops reading.
+			r, body = newRequest(payload, checksum)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r, _ = verifySignature(r, testSecretKey, nil, now)
+			_, err = io.ReadFull(r.Body, make([]byte, len(data)))
+			require.NoError(t, err)
+			require.NotEmpty(t, r.Trailer.Get("X-Amz-Checksum-Crc32"))
+
+			// truncated body
+			r, body = newRequest(payload, checksum)
+			_, _, err = decode(r, body[:len(body)/2])
+			require.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+
+			// truncated trailer
+			r, body = newRequest(payload, checksum)
+			_, _, err = decode(r, body[:len(body)-10])
+			require.Error(t, err)
+
+			// missing trailer
+			r, body = newRequest(payload, "")
+			_, _, err = decode(r, body)
+			require.Error(t, err)
+
+			// undeclared trailer
+			r, body = newRequest(payload, "x-amz-checksum-sha1:Kq5sNclPz7QV2+lfQIuc6R7oRu0=")
+			_, _, err = decode(r, body)
+			require.Equal(t, errMalformedChunk, err)
+		})
+	}
+
+	// tampered chunk
+	r, body := newRequest(streamingPayloadTrailer, checksum)
+	body[len(body)/2] ^= 1
+	_, _, err := decode(r, body)
+	require.Equal(t, errChunkSignature, err)
+
+	// tampered trailer
+	r, body = newRequest(streamingPayloadTrailer, checksum)
+	tampered := bytes.Replace(body, []byte("x-amz-checksum-crc32:AAAAAA=="), []byte("x-amz-checksum-crc32:AAAAAB=="), 1)
+	require.NotEqual(t, body, tampered)
+	r, _, err = decode(r, tampered)
+	require.Equal(t, errChunkSignature, err)
+	require.Empty(t, r.Trailer.Get("X-Amz-Checksum-Crc32"))
+}
+
+func TestVerifySignatureMiddleware(t *testing.T) {
+	var called bool
+	handler := VerifySignature(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	serve := func(credentials *Credentials) (int, bool) {
+		called = false
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/bucket/object", nil)
+		r.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+		r = signer.SignV4(*r, testAccessKey, testSecretKey, "", testRegion)
+		if credentials != nil {
+			r = r.WithContext(WithCredentials(r.Context(), credentials))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec.Code, called
+	}
+
+	// requests without credentials are passed on as anonymous requests.
+	_, ok := serve(nil)
+	require.True(t, ok)
+
+	_, ok = serve(&Credentials{AccessKey: testAccessKey, AuthServiceResponse: authclient.AuthServiceResponse{SecretKey: testSecretKey}})
+	require.True(t, ok)
+
+	code, ok := serve(&Credentials{AccessKey: testAccessKey, AuthServiceResponse: authclient.AuthServiceResponse{SecretKey: "other"}})
+	require.False(t, ok)
+	require.Equal(t, http.StatusForbidden, code)
+
+	code, ok = serve(&Credentials{Error: errdata.WithStatus(errors.New("not found"), http.StatusUnauthorized)})
+	require.False(t, ok)
+	require.Equal(t, http.StatusForbidden, code)
+
+	code, ok = serve(&Credentials{Error: errdata.WithStatus(errors.New("unavailable"), http.StatusInternalServerError)})
+	require.False(t, ok)
+	require.Equal(t, http.StatusInternalServerError, code)
+}
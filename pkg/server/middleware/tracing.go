@@ -0,0 +1,36 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"storj.io/gateway-mt/pkg/server/gwlog"
+	"storj.io/gateway-mt/pkg/tracing"
+)
+
+// Tracing traces requests with tracing.Handler, naming their spans after the
+// S3 API they call.
+func Tracing(next http.Handler) http.Handler {
+	return tracing.Handler("gmt", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log, ok := gwlog.FromContext(ctx)
+		if !ok {
+			log = gwlog.New()
+			r = r.WithContext(log.WithContext(ctx))
+		}
+
+		next.ServeHTTP(w, r)
+
+		span := trace.SpanFromContext(ctx)
+		span.SetName("gmt." + log.API)
+		span.SetAttributes(attribute.String("s3.api", log.API))
+		if log.RequestID != "" {
+			span.SetAttributes(attribute.String("request_id", log.RequestID))
+		}
+	}))
+}
@@ -0,0 +1,57 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"storj.io/common/testcontext"
+	"storj.io/gateway-mt/pkg/server/gwlog"
+	"storj.io/gateway-mt/pkg/tracing"
+)
+
+func TestTracing(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	path := filepath.Join(ctx.Dir(), "spans.jsonl")
+	tracer, err := tracing.New(ctx, zaptest.NewLogger(t), "gateway-mt", tracing.Config{File: path, SampleRatio: 1})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/bucket", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	Tracing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log, ok := gwlog.FromContext(r.Context())
+		require.True(t, ok)
+		log.API = "ListObjects"
+		log.RequestID = "request"
+	})).ServeHTTP(httptest.NewRecorder(), r)
+
+	require.NoError(t, tracer.Close(ctx))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var span struct {
+		Name         string                 `json:"name"`
+		TraceID      string                 `json:"trace_id"`
+		ParentSpanID string                 `json:"parent_span_id"`
+		Attributes   map[string]interface{} `json:"attributes"`
+	}
+	require.NoError(t, json.Unmarshal(data, &span))
+	require.Equal(t, "gmt.ListObjects", span.Name)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID)
+	require.Equal(t, "00f067aa0ba902b7", span.ParentSpanID)
+	require.Equal(t, "ListObjects", span.Attributes["s3.api"])
+	require.Equal(t, "request", span.Attributes["request_id"])
+	require.EqualValues(t, http.StatusOK, span.Attributes["http.status_code"])
+}
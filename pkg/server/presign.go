@@ -0,0 +1,361 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"github.com/zeebo/errs"
+
+	"storj.io/common/memory"
+	"storj.io/gateway-mt/pkg/server/middleware"
+	"storj.io/minio/cmd"
+)
+
+const (
+	// maxPresignExpires is the longest a presigned URL can be valid, as in
+	// S3.
+	maxPresignExpires = 7 * 24 * time.Hour
+
+	// maxPresignRequestSize is the largest request body the presign endpoint
+	// reads.
+	maxPresignRequestSize = int64(memory.MiB)
+
+	// maxPartNumber is the highest part number of a multipart upload.
+	maxPartNumber = 10000
+
+	// defaultPresignRegion is the region URLs are presigned for if the
+	// deployment has none.
+	defaultPresignRegion = "us-east-1"
+
+	// signV4Algorithm is the algorithm of AWS Signature Version 4 signatures.
+	signV4Algorithm = "AWS4-HMAC-SHA256"
+
+	// unsignedPayload is the hash of the payload of requests signed without
+	// it.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// PresignConfig configures the endpoint issuing presigned URLs.
+type PresignConfig struct {
+	DefaultExpires time.Duration `help:"how long presigned URLs are valid for if the request doesn't say" default:"15m0s"`
+	MaxExpires     time.Duration `help:"longest presigned URLs can be valid for (at most 7 days)" default:"168h0m0s"`
+}
+
+// The operations URLs can be presigned for.
+const (
+	presignGetObject               = "GetObject"
+	presignPutObject               = "PutObject"
+	presignCreateMultipartUpload   = "CreateMultipartUpload"
+	presignUploadPart              = "UploadPart"
+	presignCompleteMultipartUpload = "CompleteMultipartUpload"
+	presignAbortMultipartUpload    = "AbortMultipartUpload"
+)
+
+// presignRequest is the body of requests to the presign endpoint.
+type presignRequest struct {
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	Operation string `json:"operation"`
+
+	// UploadID is the multipart upload UploadPart, CompleteMultipartUpload
+	// and AbortMultipartUpload URLs are for.
+	UploadID string `json:"upload_id,omitempty"`
+	// PartNumbers are the parts UploadPart URLs are issued for, one each.
+	PartNumbers []int `json:"part_numbers,omitempty"`
+
+	// Expires is how many seconds the URLs are valid for.
+	Expires int64 `json:"expires,omitempty"`
+}
+
+// presignedURL is a URL issued by the presign endpoint.
+type presignedURL struct {
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	PartNumber int    `json:"part_number,omitempty"`
+}
+
+// presignResponse is the body of responses of the presign endpoint.
+type presignResponse struct {
+	URLs      []presignedURL `json:"urls"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// presigner serves the endpoint issuing presigned URLs.
+//
+// Requests to it are authenticated like any other request, and URLs are
+// presigned with the credentials they're signed with, so they allow at most
+// what these allow. Clients that can't hold a secret key can be handed the
+// URLs instead.
+type presigner struct {
+	config      PresignConfig
+	domainNames []string
+	now         func() time.Time
+}
+
+func newPresigner(config PresignConfig, domainNames []string) *presigner {
+	if config.MaxExpires <= 0 || config.MaxExpires > maxPresignExpires {
+		config.MaxExpires = maxPresignExpires
+	}
+	if config.DefaultExpires <= 0 || config.DefaultExpires > config.MaxExpires {
+		config.DefaultExpires = config.MaxExpires
+	}
+	return &presigner{
+		config:      config,
+		domainNames: domainNames,
+		now:         time.Now,
+	}
+}
+
+// ServeHTTP issues the presigned URLs a POST request asks for.
+func (p *presigner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(cmd.ErrMethodNotAllowed), r.URL, false)
+		return
+	}
+
+	// anonymous requests have no secret key to presign URLs with.
+	credentials := middleware.GetAccess(ctx)
+	if credentials == nil || credentials.Error != nil || credentials.SecretKey == "" {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(cmd.ErrAccessDenied), r.URL, false)
+		return
+	}
+
+	body, apiErr := readSignedBody(r)
+	if apiErr != nil {
+		cmd.WriteErrorResponse(ctx, w, *apiErr, r.URL, false)
+		return
+	}
+
+	var request presignRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		cmd.WriteErrorResponse(ctx, w, cmd.GetAPIError(cmd.ErrMalformedJSON), r.URL, false)
+		return
+	}
+
+	expires, err := p.expires(request.Expires)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, presignError(err.Error()), r.URL, false)
+		return
+	}
+
+	targets, err := presignTargets(request)
+	if err != nil {
+		cmd.WriteErrorResponse(ctx, w, presignError(err.Error()), r.URL, false)
+		return
+	}
+
+	response := presignResponse{
+		URLs:      make([]presignedURL, 0, len(targets)),
+		ExpiresAt: p.now().Add(expires).UTC().Truncate(time.Second),
+	}
+
+	region := middleware.GetRegion(ctx)
+	if region == "" {
+		region = defaultPresignRegion
+	}
+
+	host, path := p.location(r.Host, request.Bucket, request.Key)
+	base := url.URL{
+		Scheme: requestScheme(r),
+		Host:   host,
+		Path:   path,
+	}
+	for _, target := range targets {
+		u := base
+		u.RawQuery = target.query.Encode()
+
+		presigned := signer.PreSignV4(http.Request{
+			Method: target.method,
+			URL:    &u,
+			Host:   host,
+			Header: make(http.Header),
+		}, credentials.AccessKey, credentials.SecretKey, "", region, int64(expires/time.Second))
+
+		response.URLs = append(response.URLs, presignedURL{
+			Method:     target.method,
+			URL:        presigned.URL.String(),
+			PartNumber: target.partNumber,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// readSignedBody returns the body of r, which middleware.VerifySignature
+// verified the signature of.
+//
+// Signatures only cover the body through the hash of the payload they're
+// signed with, which minio verifies for the requests it serves, but requests
+// to the presign endpoint don't reach it. Requests have to be signed with the
+// hash of their payload in the Authorization header, since neither unsigned
+// payloads, streaming payloads (whose chunks only minio's handlers decode) nor
+// presigned requests would keep anyone who got hold of one from asking for
+// other URLs with it.
+func readSignedBody(r *http.Request) ([]byte, *cmd.APIError) {
+	contentSHA256 := r.Header.Get("X-Amz-Content-Sha256")
+	if !strings.HasPrefix(r.Header.Get("Authorization"), signV4Algorithm) ||
+		contentSHA256 == unsignedPayload || strings.HasPrefix(contentSHA256, "STREAMING-") {
+		apiErr := presignError("requests must be signed with AWS Signature Version 4 in the Authorization header and the SHA-256 hash of their payload")
+		return nil, &apiErr
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPresignRequestSize+1))
+	if err != nil {
+		apiErr := cmd.GetAPIError(cmd.ErrIncompleteBody)
+		return nil, &apiErr
+	}
+	if int64(len(body)) > maxPresignRequestSize {
+		apiErr := cmd.GetAPIError(cmd.ErrEntityTooLarge)
+		return nil, &apiErr
+	}
+
+	sum := sha256.Sum256(body)
+	if !strings.EqualFold(contentSHA256, hex.EncodeToString(sum[:])) {
+		apiErr := cmd.GetAPIError(cmd.ErrContentSHA256Mismatch)
+		return nil, &apiErr
+	}
+
+	return body, nil
+}
+
+// location returns the host and path of URLs for key in bucket presigned for
+// a request made to host.
+//
+// If host is the virtual-hosted style host of a bucket, URLs for keys in the
+// same bucket stay on it, and URLs for other buckets are path-style URLs on
+// its domain.
+func (p *presigner) location(host, bucket, key string) (string, string) {
+	hostname, port := host, ""
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		hostname, port = host[:i], host[i:]
+	}
+
+	for _, domainName := range p.domainNames {
+		hostBucket := strings.TrimSuffix(hostname, "."+domainName)
+		if hostBucket == hostname || hostBucket == "" {
+			continue
+		}
+		if hostBucket == bucket {
+			return host, "/" + key
+		}
+		return domainName + port, "/" + bucket + "/" + key
+	}
+
+	return host, "/" + bucket + "/" + key
+}
+
+// expires returns how long URLs are valid for when a request asks for
+// seconds.
+func (p *presigner) expires(seconds int64) (time.Duration, error) {
+	switch {
+	case seconds == 0:
+		return p.config.DefaultExpires, nil
+	case seconds < 0:
+		return 0, errs.New("expires must be positive")
+	case seconds > int64(p.config.MaxExpires/time.Second):
+		return 0, errs.New("expires must be at most %d seconds", int64(p.config.MaxExpires/time.Second))
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// presignTarget is a request a URL is presigned for.
+type presignTarget struct {
+	method     string
+	query      url.Values
+	partNumber int
+}
+
+// presignTargets returns the requests to presign URLs for to serve request.
+func presignTargets(request presignRequest) ([]presignTarget, error) {
+	if err := s3utils.CheckValidBucketNameStrict(request.Bucket); err != nil {
+		return nil, errs.New("invalid bucket: %v", err)
+	}
+	if request.Key == "" {
+		return nil, errs.New("key is required")
+	}
+
+	switch request.Operation {
+	case presignUploadPart, presignCompleteMultipartUpload, presignAbortMultipartUpload:
+		if request.UploadID == "" {
+			return nil, errs.New("upload_id is required for %s", request.Operation)
+		}
+	}
+	if len(request.PartNumbers) > 0 && request.Operation != presignUploadPart {
+		return nil, errs.New("part_numbers are only allowed for %s", presignUploadPart)
+	}
+
+	uploadID := url.Values{"uploadId": {request.UploadID}}
+
+	switch request.Operation {
+	case presignGetObject:
+		return []presignTarget{{method: http.MethodGet, query: url.Values{}}}, nil
+	case presignPutObject:
+		return []presignTarget{{method: http.MethodPut, query: url.Values{}}}, nil
+	case presignCreateMultipartUpload:
+		return []presignTarget{{method: http.MethodPost, query: url.Values{"uploads": {""}}}}, nil
+	case presignCompleteMultipartUpload:
+		return []presignTarget{{method: http.MethodPost, query: uploadID}}, nil
+	case presignAbortMultipartUpload:
+		return []presignTarget{{method: http.MethodDelete, query: uploadID}}, nil
+	case presignUploadPart:
+		if len(request.PartNumbers) == 0 {
+			return nil, errs.New("part_numbers are required for %s", presignUploadPart)
+		}
+		if len(request.PartNumbers) > maxPartNumber {
+			return nil, errs.New("at most %d part_numbers are allowed", maxPartNumber)
+		}
+		targets := make([]presignTarget, 0, len(request.PartNumbers))
+		for _, partNumber := range request.PartNumbers {
+			if partNumber < 1 || partNumber > maxPartNumber {
+				return nil, errs.New("part numbers must be between 1 and %d", maxPartNumber)
+			}
+			targets = append(targets, presignTarget{
+				method: http.MethodPut,
+				query: url.Values{
+					"partNumber": {strconv.Itoa(partNumber)},
+					"uploadId":   {request.UploadID},
+				},
+				partNumber: partNumber,
+			})
+		}
+		return targets, nil
+	default:
+		return nil, errs.New("unsupported operation %q", request.Operation)
+	}
+}
+
+// requestScheme returns the scheme r was made with, as forwarded by a proxy
+// terminating TLS if there is one.
+func requestScheme(r *http.Request) string {
+	switch proto := r.Header.Get("X-Forwarded-Proto"); proto {
+	case "http", "https":
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func presignError(description string) cmd.APIError {
+	return cmd.APIError{
+		Code:           "InvalidRequest",
+		Description:    description,
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+}
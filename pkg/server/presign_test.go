@@ -0,0 +1,239 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"github.com/stretchr/testify/require"
+
+	"storj.io/gateway-mt/pkg/authclient"
+	"storj.io/gateway-mt/pkg/server/middleware"
+)
+
+func TestPresign(t *testing.T) {
+	p := newPresigner(PresignConfig{DefaultExpires: time.Minute, MaxExpires: time.Hour}, []string{"gateway.local"})
+
+	credentials := &middleware.Credentials{
+		AccessKey:           "AccessKey",
+		AuthServiceResponse: authclient.AuthServiceResponse{SecretKey: "SecretKey"},
+	}
+
+	presign := func(credentials *middleware.Credentials, body string) (*httptest.ResponseRecorder, presignResponse) {
+		r := signedPresignRequest("https://gateway.local/-/presign", body)
+		if credentials != nil {
+			r = r.WithContext(middleware.WithCredentials(r.Context(), credentials))
+		}
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, r)
+
+		var response presignResponse
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		}
+		return rec, response
+	}
+
+	// verified returns the request made with the URL if the gateway accepts
+	// it.
+	verified := func(u presignedURL) *http.Request {
+		var r *http.Request
+		handler := middleware.VerifySignature(nil)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			r = req
+		}))
+		req := httptest.NewRequest(u.Method, u.URL, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(middleware.WithCredentials(req.Context(), credentials)))
+		return r
+	}
+	verify := func(u presignedURL) *http.Request {
+		r := verified(u)
+		require.NotNil(t, r, u.URL)
+		return r
+	}
+
+	rec, response := presign(credentials, `{"bucket": "bucket", "key": "some key", "operation": "GetObject"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, response.URLs, 1)
+	require.Equal(t, http.MethodGet, response.URLs[0].Method)
+	require.WithinDuration(t, time.Now().Add(time.Minute), response.ExpiresAt, 5*time.Second)
+
+	u, err := url.Parse(response.URLs[0].URL)
+	require.NoError(t, err)
+	require.Equal(t, "https", u.Scheme)
+	require.Equal(t, "gateway.local", u.Host)
+	require.Equal(t, "/bucket/some key", u.Path)
+	require.Equal(t, "60", u.Query().Get("X-Amz-Expires"))
+	verify(response.URLs[0])
+
+	// the URL is scoped to the key.
+	tampered := response.URLs[0]
+	tampered.URL = strings.Replace(tampered.URL, "some%20key", "other%20key", 1)
+	require.Nil(t, verified(tampered))
+
+	rec, response = presign(credentials, `{"bucket": "bucket", "key": "key", "operation": "UploadPart", "upload_id": "id", "part_numbers": [1, 2], "expires": 3600}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, response.URLs, 2)
+	for i, presigned := range response.URLs {
+		require.Equal(t, http.MethodPut, presigned.Method)
+		require.Equal(t, i+1, presigned.PartNumber)
+
+		r := verify(presigned)
+		require.Equal(t, "id", r.URL.Query().Get("uploadId"))
+		require.Equal(t, strconv.Itoa(presigned.PartNumber), r.URL.Query().Get("partNumber"))
+		require.Equal(t, "3600", r.URL.Query().Get("X-Amz-Expires"))
+	}
+
+	for _, operation := range []string{"PutObject", "CreateMultipartUpload", "CompleteMultipartUpload", "AbortMultipartUpload"} {
+		rec, response = presign(credentials, `{"bucket": "bucket", "key": "key", "upload_id": "id", "operation": "`+operation+`"}`)
+		require.Equal(t, http.StatusOK, rec.Code, operation)
+		require.Len(t, response.URLs, 1)
+		verify(response.URLs[0])
+	}
+
+	for _, body := range []string{
+		`{"bucket": "bucket", "key": "key", "operation": "GetObject", "expires": 3601}`,
+		`{"bucket": "bucket", "key": "key", "operation": "GetObject", "expires": -1}`,
+		`{"bucket": "bucket", "key": "key", "operation": "DeleteBucket"}`,
+		`{"bucket": "bucket", "key": "", "operation": "GetObject"}`,
+		`{"bucket": "B", "key": "key", "operation": "GetObject"}`,
+		`{"bucket": "bucket", "key": "key", "operation": "UploadPart", "part_numbers": [1]}`,
+		`{"bucket": "bucket", "key": "key", "operation": "UploadPart", "upload_id": "id", "part_numbers": [0]}`,
+		`{"bucket": "bucket", "key": "key", "operation": "GetObject", "part_numbers": [1]}`,
+	} {
+		rec, _ = presign(credentials, body)
+		require.Equal(t, http.StatusBadRequest, rec.Code, body)
+		require.Contains(t, rec.Body.String(), "<Code>InvalidRequest</Code>", body)
+	}
+
+	rec, _ = presign(credentials, `{`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// requests without a secret key to sign with are denied.
+	for _, credentials := range []*middleware.Credentials{
+		nil,
+		{AccessKey: "anonymous", AuthServiceResponse: authclient.AuthServiceResponse{AccessGrant: "grant"}},
+		{AccessKey: "AccessKey", Error: errors.New("not found")},
+	} {
+		rec, _ = presign(credentials, `{"bucket": "bucket", "key": "key", "operation": "GetObject"}`)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/presign", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestPresignPayload(t *testing.T) {
+	p := newPresigner(PresignConfig{}, nil)
+
+	credentials := &middleware.Credentials{
+		AccessKey:           "AccessKey",
+		AuthServiceResponse: authclient.AuthServiceResponse{SecretKey: "SecretKey"},
+	}
+
+	const body = `{"bucket": "bucket", "key": "key", "operation": "GetObject"}`
+
+	presign := func(r *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, r.WithContext(middleware.WithCredentials(r.Context(), credentials)))
+		return rec
+	}
+
+	rec := presign(signedPresignRequest("https://gateway.local/-/presign", body))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// the body has to be the one the request was signed for.
+	r := signedPresignRequest("https://gateway.local/-/presign", body)
+	r.Body = io.NopCloser(strings.NewReader(`{"bucket": "bucket", "key": "other", "operation": "PutObject"}`))
+	rec = presign(r)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "<Code>XAmzContentSHA256Mismatch</Code>")
+
+	// and the signature has to cover it.
+	for _, contentSHA256 := range []string{"UNSIGNED-PAYLOAD", "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"} {
+		r := httptest.NewRequest(http.MethodPost, "https://gateway.local/-/presign", strings.NewReader(body))
+		r.Header.Set("X-Amz-Content-Sha256", contentSHA256)
+		rec = presign(signer.SignV4(*r, "AccessKey", "SecretKey", "", "us-east-1"))
+		require.Equal(t, http.StatusBadRequest, rec.Code, contentSHA256)
+		require.Contains(t, rec.Body.String(), "<Code>InvalidRequest</Code>", contentSHA256)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "https://gateway.local/-/presign", strings.NewReader(body))
+	rec = presign(signer.SignV2(*r, "AccessKey", "SecretKey", false))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	r = httptest.NewRequest(http.MethodPost, "https://gateway.local/-/presign", strings.NewReader(body))
+	rec = presign(signer.PreSignV4(*r, "AccessKey", "SecretKey", "", "us-east-1", 60))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// signedPresignRequest returns a request to the presign endpoint at url with
+// body signed with AWS Signature Version 4 and the hash of body.
+func signedPresignRequest(url, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	sum := sha256.Sum256([]byte(body))
+	r.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	return signer.SignV4(*r, "AccessKey", "SecretKey", "", "us-east-1")
+}
+
+func TestPresignVirtualHosted(t *testing.T) {
+	p := newPresigner(PresignConfig{}, []string{"gateway.local"})
+
+	for _, tt := range []struct {
+		host, bucket       string
+		expectedHost, path string
+	}{
+		{host: "gateway.local", bucket: "bucket", expectedHost: "gateway.local", path: "/bucket/key"},
+		{host: "bucket.gateway.local", bucket: "bucket", expectedHost: "bucket.gateway.local", path: "/key"},
+		{host: "bucket.gateway.local:7777", bucket: "bucket", expectedHost: "bucket.gateway.local:7777", path: "/key"},
+		{host: "other.gateway.local:7777", bucket: "bucket", expectedHost: "gateway.local:7777", path: "/bucket/key"},
+		{host: "127.0.0.1:7777", bucket: "bucket", expectedHost: "127.0.0.1:7777", path: "/bucket/key"},
+	} {
+		host, path := p.location(tt.host, tt.bucket, "key")
+		require.Equal(t, tt.expectedHost, host, tt.host)
+		require.Equal(t, tt.path, path, tt.host)
+	}
+
+	credentials := &middleware.Credentials{
+		AccessKey:           "AccessKey",
+		AuthServiceResponse: authclient.AuthServiceResponse{SecretKey: "SecretKey"},
+	}
+
+	r := signedPresignRequest("https://bucket.gateway.local/-/presign", `{"bucket": "bucket", "key": "key", "operation": "GetObject"}`)
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, r.WithContext(middleware.WithCredentials(r.Context(), credentials)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var response presignResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.URLs, 1)
+	require.True(t, strings.HasPrefix(response.URLs[0].URL, "https://bucket.gateway.local/key?"), response.URLs[0].URL)
+
+	// the URL is valid for the virtual-hosted style host.
+	verified := false
+	handler := middleware.VerifySignature([]string{"gateway.local"})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		verified = true
+	}))
+	req := httptest.NewRequest(http.MethodGet, response.URLs[0].URL, nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(middleware.WithCredentials(req.Context(), credentials)))
+	require.True(t, verified)
+}
+
+func TestNewPresignerLimits(t *testing.T) {
+	p := newPresigner(PresignConfig{DefaultExpires: 30 * 24 * time.Hour, MaxExpires: 30 * 24 * time.Hour}, nil)
+	require.Equal(t, maxPresignExpires, p.config.MaxExpires)
+	require.Equal(t, maxPresignExpires, p.config.DefaultExpires)
+}
@@ -5,6 +5,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -16,11 +17,15 @@ import (
 	mhttp "github.com/spacemonkeygo/monkit/v3/http"
 	"github.com/zeebo/errs"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 
+	"storj.io/common/errs2"
 	"storj.io/common/rpc/rpcpool"
 	"storj.io/gateway-mt/pkg/authclient"
+	"storj.io/gateway-mt/pkg/bucketconfig"
 	"storj.io/gateway-mt/pkg/httpserver"
 	"storj.io/gateway-mt/pkg/minio"
+	"storj.io/gateway-mt/pkg/notification"
 	"storj.io/gateway-mt/pkg/server/gw"
 	"storj.io/gateway-mt/pkg/server/middleware"
 	"storj.io/gateway-mt/pkg/trustedip"
@@ -50,18 +55,55 @@ type Peer struct {
 	log        *zap.Logger
 	config     Config
 	closeLayer func(context.Context) error
+
+	trustedIPs  *trustedip.Atomic
+	corsOrigins *minio.CORSOrigins
+	limiter     *middleware.Limiter
+	authClient  *authclient.AuthClient
+	rateLimiter *middleware.RateLimiter
+	accessLog   *middleware.AccessLog
+	notifier    *notification.Notifier
+	health      *healthChecker
+	drainer     *drainer
+
+	clusterLimiter  *middleware.ClusterLimiterBackend
+	clusterServer   *http.Server
+	clusterListener net.Listener
+
+	drainServer   *http.Server
+	drainListener net.Listener
 }
 
 // New returns new instance of an S3 compatible http server.
-func New(config Config, log *zap.Logger, trustedIPs trustedip.List, corsAllowedOrigins []string,
-	authClient *authclient.AuthClient, domainNames []string, concurrentAllowed uint) (*Peer, error) {
+func New(ctx context.Context, config Config, log *zap.Logger, trustedIPs trustedip.List, corsAllowedOrigins []string,
+	authClient *authclient.AuthClient, domainNames []string, concurrentAllowed uint) (_ *Peer, err error) {
 	r := mux.NewRouter()
 	r.SkipClean(true)
 	r.UseEncodedPath()
 
+	connections := newConnectionCounter(config.ConnectionPool.Capacity)
+	health, err := newHealthChecker(log.Named("health"), config.Health, authClient, connections)
+	if err != nil {
+		return nil, err
+	}
+
 	publicServices := r.PathPrefix("/-/").Subrouter()
 	publicServices.HandleFunc("/health", healthCheck)
+	publicServices.HandleFunc("/health/live", health.ServeLive)
+	publicServices.HandleFunc("/health/ready", health.ServeReady)
 	publicServices.HandleFunc("/version", versionInfo)
+	publicServices.Handle("/presign", newPresigner(config.Presign, domainNames))
+
+	regions, err := middleware.NewRegions(log.Named("region"), config.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	drainer := newDrainer(log.Named("drain"), config.Drain)
+	health.draining = drainer.Draining
+
+	r.Use(drainer.Handler)
+	r.Use(connections.Handler)
 
 	if config.EncodeInMemory {
 		r.Use(middleware.SetInMemory)
@@ -72,17 +114,75 @@ func New(config Config, log *zap.Logger, trustedIPs trustedip.List, corsAllowedO
 
 	uplinkConfig := configureUplinkConfig(config.Client)
 
-	layer, err := gw.NewMultiTenantLayer(miniogw.NewStorjGateway(config.S3Compatibility), connectionPool, uplinkConfig, config.InsecureLogAll)
+	// events are only published if there are targets to deliver them to.
+	var notifier *notification.Notifier
+	if len(config.Notification.Webhooks) > 0 || len(config.Notification.Queues) > 0 {
+		notifier, err = notification.New(log.Named("notification"), config.Notification)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bucketConfigDB, err := bucketconfig.Open(ctx, config.BucketConfig)
 	if err != nil {
-		return nil, err
+		return nil, errs.Combine(err, closeNotifier(notifier))
 	}
-	minio.RegisterAPIRouter(r, layer, domainNames, concurrentAllowed, corsAllowedOrigins)
+
+	layer, err := gw.NewMultiTenantLayer(miniogw.NewStorjGateway(config.S3Compatibility), connectionPool, uplinkConfig, config.ProjectCache, bucketConfigDB, config.BucketConfigCache, config.ObjectCache, authClient, notifier, config.InsecureLogAll)
+	if err != nil {
+		return nil, errs.Combine(err, bucketConfigDB.Close(), closeNotifier(notifier))
+	}
+	// the layer closes bucketConfigDB and the connection pool from here on.
+	defer func() {
+		if err != nil {
+			err = errs.Combine(err, layer.Shutdown(ctx))
+		}
+	}()
+
+	// concurrency is limited across replicas if they exchange their counts.
+	var limiterBackend middleware.LimiterBackend = middleware.NewLocalLimiterBackend()
+	var clusterLimiter *middleware.ClusterLimiterBackend
+	var clusterListener net.Listener
+	if config.ConcurrentCluster.Address != "" {
+		clusterLimiter, err = middleware.NewClusterLimiterBackend(log.Named("cluster limiter"), config.ConcurrentCluster)
+		if err != nil {
+			return nil, errs.Combine(err, closeNotifier(notifier))
+		}
+		clusterListener, err = net.Listen("tcp", config.ConcurrentCluster.Address)
+		if err != nil {
+			return nil, errs.Combine(Error.Wrap(err), closeNotifier(notifier))
+		}
+		limiterBackend = clusterLimiter
+	}
+
+	var drainListener net.Listener
+	if config.Drain.Address != "" {
+		drainListener, err = net.Listen("tcp", config.Drain.Address)
+		if err != nil {
+			return nil, errs.Combine(Error.Wrap(err), closeListener(clusterListener), closeNotifier(notifier))
+		}
+	}
+
+	// settings that can be changed while the server runs are held by values
+	// the handlers share.
+	reloadableTrustedIPs := trustedip.NewAtomic(trustedIPs)
+	reloadableCORSOrigins := minio.NewCORSOrigins(corsAllowedOrigins)
+	limiter := minio.NewConcurrencyLimiter(concurrentAllowed, limiterBackend)
+
+	minio.RegisterAPIRouter(r, layer, domainNames, limiter, reloadableCORSOrigins)
 
 	r.Use(func(handler http.Handler) http.Handler {
 		return mhttp.TraceHandler(handler, mon)
 	})
+	r.Use(middleware.Tracing)
 	r.Use(middleware.NewMetrics("gmt"))
-	r.Use(middleware.AccessKey(authClient, trustedIPs, log))
+	r.Use(middleware.AccessKey(authClient, reloadableTrustedIPs, log))
+	// requests are routed to the deployment of their region before anything
+	// reads their body.
+	r.Use(regions.Route)
+	r.Use(middleware.VerifySignature(domainNames))
+	r.Use(minio.PublicReadHandler(layer, reloadableTrustedIPs))
+	r.Use(minio.BucketCorsHandler(layer))
 	r.Use(middleware.CollectEvent)
 	r.Use(cmd.GlobalHandlers...)
 
@@ -92,7 +192,41 @@ func New(config Config, log *zap.Logger, trustedIPs trustedip.List, corsAllowedO
 	r.Use(middleware.NewLogRequests(log, config.InsecureLogAll))
 	r.Use(middleware.NewLogResponses(log, config.InsecureLogAll))
 
-	var handler http.Handler = minio.CriticalErrorHandler{Handler: minio.CorsHandler(corsAllowedOrigins)(r)}
+	var accessLog *middleware.AccessLog
+	if config.AccessLog.Output != "" {
+		accessLog = middleware.NewAccessLog(config.AccessLog, config.InsecureLogAll)
+		r.Use(accessLog.Handler)
+	}
+
+	// rate limiting is chained after logging so throttled requests are logged.
+	rateLimiter := middleware.NewRateLimiter(config.RateLimit, reloadableTrustedIPs)
+	r.Use(rateLimiter.Limit)
+
+	var handler http.Handler = minio.CriticalErrorHandler{Handler: minio.CorsHandler(reloadableCORSOrigins, layer, domainNames)(r)}
+
+	// bucket websites are served before the S3 API so that its middlewares,
+	// which expect requests from S3 clients, don't handle their requests.
+	if website := newWebsite(log.Named("website"), config.Website, layer, authClient, reloadableTrustedIPs); website != nil {
+		websiteRouter := mux.NewRouter()
+		websiteRouter.SkipClean(true)
+		websiteRouter.PathPrefix("/").Handler(website)
+
+		websiteRouter.Use(drainer.Handler)
+		websiteRouter.Use(connections.Handler)
+		websiteRouter.Use(func(handler http.Handler) http.Handler {
+			return mhttp.TraceHandler(handler, mon)
+		})
+		websiteRouter.Use(middleware.Tracing)
+		websiteRouter.Use(middleware.NewMetrics("gmt_website"))
+		websiteRouter.Use(middleware.NewLogRequests(log, config.InsecureLogAll))
+		websiteRouter.Use(middleware.NewLogResponses(log, config.InsecureLogAll))
+		if accessLog != nil {
+			websiteRouter.Use(accessLog.Handler)
+		}
+		websiteRouter.Use(rateLimiter.Limit)
+
+		handler = website.Handler(websiteRouter, handler)
+	}
 
 	var tlsConfig *httpserver.TLSConfig
 	if !config.InsecureDisableTLS {
@@ -106,17 +240,87 @@ func New(config Config, log *zap.Logger, trustedIPs trustedip.List, corsAllowedO
 		AddressTLS:     config.Server.AddressTLS,
 		TLSConfig:      tlsConfig,
 		TrafficLogging: false, // gateway-mt has its own logging middleware for this
+		// requests in progress are given the drain deadline to finish.
+		ShutdownTimeout: config.Drain.Deadline,
 	})
 	if err != nil {
-		return nil, err
+		return nil, errs.Combine(err, closeListener(clusterListener), closeListener(drainListener), closeNotifier(notifier))
 	}
 
-	return &Peer{
+	peer := &Peer{
 		log:        log,
 		server:     server,
 		config:     config,
 		closeLayer: layer.Shutdown,
-	}, nil
+
+		trustedIPs:  reloadableTrustedIPs,
+		corsOrigins: reloadableCORSOrigins,
+		limiter:     limiter,
+		authClient:  authClient,
+		rateLimiter: rateLimiter,
+		accessLog:   accessLog,
+		notifier:    notifier,
+		health:      health,
+		drainer:     drainer,
+
+		clusterLimiter:  clusterLimiter,
+		clusterListener: clusterListener,
+
+		drainListener: drainListener,
+	}
+	if clusterLimiter != nil {
+		peer.clusterServer = &http.Server{Handler: clusterLimiter, ReadHeaderTimeout: 5 * time.Second}
+	}
+	if drainListener != nil {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/drain", peer.serveDrain)
+		peer.drainServer = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	}
+	return peer, nil
+}
+
+// closeServer closes server and listener if server isn't nil.
+func closeServer(server *http.Server, listener net.Listener) error {
+	if server == nil {
+		return nil
+	}
+	err := server.Close()
+	// the listener is only closed with the server if it served.
+	if closeErr := listener.Close(); !errors.Is(closeErr, net.ErrClosed) {
+		err = errs.Combine(err, closeErr)
+	}
+	return err
+}
+
+// serveUntilCanceled serves server on listener in group until ctx is
+// canceled, e.g. because the main server stopped.
+func serveUntilCanceled(ctx context.Context, group *errgroup.Group, server *http.Server, listener net.Listener) {
+	group.Go(func() error {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+	group.Go(func() error {
+		<-ctx.Done()
+		return server.Close()
+	})
+}
+
+// closeListener closes listener if it's not nil.
+func closeListener(listener net.Listener) error {
+	if listener == nil {
+		return nil
+	}
+	return listener.Close()
+}
+
+// closeNotifier closes notifier if it's not nil.
+func closeNotifier(notifier *notification.Notifier) error {
+	if notifier == nil {
+		return nil
+	}
+	return notifier.Close()
 }
 
 // configureUplinkConfig configures new uplink.Config using clientConfig.
@@ -137,8 +341,8 @@ func configureUplinkConfig(clientConfig ClientConfig) uplink.Config {
 }
 
 func healthCheck(w http.ResponseWriter, r *http.Request) {
-	// TODO: should this function do any tests to confirm the server is operational before returning a 200?
 	// this function should be low-effort, in the sense that the load balancer is going to be hitting it regularly.
+	// /-/health/ready reports whether the dependencies of the gateway are available.
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -157,16 +361,112 @@ func (s *Peer) Run(ctx context.Context) (err error) {
 		minio.StartMinio(!s.config.InsecureDisableTLS)
 	})
 
-	return s.server.Run(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	var group errgroup.Group
+
+	group.Go(func() error {
+		defer cancel()
+		return s.server.Run(ctx)
+	})
+
+	group.Go(func() error {
+		return errs2.IgnoreCanceled(s.health.Run(ctx))
+	})
+
+	group.Go(func() error {
+		return errs2.IgnoreCanceled(s.drainer.Run(ctx))
+	})
+
+	if s.drainServer != nil {
+		s.log.Info("Drain server started", zap.Stringer("addr", s.drainListener.Addr()))
+		serveUntilCanceled(ctx, &group, s.drainServer, s.drainListener)
+	}
+
+	if s.clusterLimiter != nil {
+		group.Go(func() error {
+			return errs2.IgnoreCanceled(s.clusterLimiter.Run(ctx))
+		})
+
+		s.log.Info("Concurrency counts server started", zap.Stringer("addr", s.clusterListener.Addr()))
+		serveUntilCanceled(ctx, &group, s.clusterServer, s.clusterListener)
+	}
+
+	if s.notifier != nil {
+		group.Go(func() error {
+			return errs2.IgnoreCanceled(s.notifier.Run(ctx))
+		})
+	}
+
+	return group.Wait()
+}
+
+// Drain starts draining: the gateway reports it isn't ready, stops keeping
+// connections alive and rejects requests starting new multipart uploads with a
+// retryable error, while requests in progress continue. Close waits for them
+// up to the drain deadline.
+func (s *Peer) Drain() {
+	s.drainer.Drain()
+	s.server.SetKeepAlivesEnabled(false)
 }
 
-// Close shuts down the server and all underlying resources.
+// Close drains the server, shuts it down and closes all underlying resources.
 func (s *Peer) Close() error {
+	s.Drain()
+
+	// note: httpserver.Shutdown has its own configured timeout
+	err := s.server.Shutdown()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// note: httpserver.Shutdown has its own configured timeout
-	return Error.Wrap(errs.Combine(s.closeLayer(ctx), s.server.Shutdown()))
+	err = errs.Combine(err, s.closeLayer(ctx))
+	err = errs.Combine(err, closeServer(s.clusterServer, s.clusterListener))
+	err = errs.Combine(err, closeServer(s.drainServer, s.drainListener))
+	if s.accessLog != nil {
+		err = errs.Combine(err, s.accessLog.Close())
+	}
+	if s.notifier != nil {
+		err = errs.Combine(err, s.notifier.Close())
+	}
+	return Error.Wrap(err)
+}
+
+// SetRateLimits changes the rate limits the server applies without
+// restarting it.
+func (s *Peer) SetRateLimits(config middleware.RateLimitConfig) {
+	s.rateLimiter.SetConfig(config)
+}
+
+// SetTrustedIPs changes the IPs of the clients whose headers identifying the
+// IPs of the clients they forward requests of are trusted.
+func (s *Peer) SetTrustedIPs(trustedIPs trustedip.List) {
+	s.trustedIPs.Store(trustedIPs)
+}
+
+// SetCORSOrigins changes the origins requests are allowed from for buckets
+// without a CORS configuration.
+func (s *Peer) SetCORSOrigins(origins []string) {
+	s.corsOrigins.Store(origins)
+}
+
+// SetConcurrentAllowed changes the number of allowed concurrent uploads or
+// downloads per macaroon head. Requests in progress are kept.
+func (s *Peer) SetConcurrentAllowed(allowed uint) {
+	s.limiter.SetAllowed(allowed)
+}
+
+// SetAuthCacheConfig changes the configuration of the cache of the auth
+// service responses, dropping the responses cached so far.
+func (s *Peer) SetAuthCacheConfig(config authclient.AuthServiceCacheConfig) {
+	if s.authClient != nil {
+		s.authClient.SetCacheConfig(config)
+	}
+}
+
+// ReloadTLS reloads the TLS certificates from CertDir. Connections accepted
+// afterwards use the new certificates.
+func (s *Peer) ReloadTLS() error {
+	return Error.Wrap(s.server.ReloadTLS())
 }
 
 // Address returns the web address the peer is listening on.
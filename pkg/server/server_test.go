@@ -23,6 +23,7 @@ import (
 
 	"storj.io/common/pkcrypto"
 	"storj.io/common/testcontext"
+	"storj.io/gateway-mt/pkg/bucketconfig"
 	"storj.io/gateway-mt/pkg/server"
 	"storj.io/gateway-mt/pkg/trustedip"
 )
@@ -81,8 +82,9 @@ func testServer(t *testing.T, useTLS, vHostStyle bool) {
 		CertDir:        certDir,
 		InsecureLogAll: true,
 		EncodeInMemory: true,
+		BucketConfig:   bucketconfig.Config{DB: "memory://"},
 	}
-	s, err := server.New(config, zaptest.NewLogger(t), trustedip.NewListTrustAll(), []string{}, nil, []string{}, 10)
+	s, err := server.New(ctx, config, zaptest.NewLogger(t), trustedip.NewListTrustAll(), []string{}, nil, []string{}, 10)
 	require.NoError(t, err)
 
 	defer ctx.Check(s.Close)
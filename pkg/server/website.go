@@ -0,0 +1,288 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"storj.io/common/grant"
+	"storj.io/common/macaroon"
+	"storj.io/gateway-mt/pkg/authclient"
+	"storj.io/gateway-mt/pkg/errdata"
+	"storj.io/gateway-mt/pkg/server/gw"
+	"storj.io/gateway-mt/pkg/server/middleware"
+	"storj.io/gateway-mt/pkg/trustedip"
+	"storj.io/minio/cmd"
+)
+
+// WebsiteConfig configures serving buckets as websites.
+type WebsiteConfig struct {
+	DomainName string `help:"comma-separated domain suffixes to serve bucket websites on (empty disables serving them)" default:""`
+}
+
+// noLock is minio's LockType for reads that don't lock, which it doesn't
+// export.
+const noLock cmd.LockType = 0
+
+// websiteLayer is the part of gw.MultiTenancyLayer websites are served with.
+type websiteLayer interface {
+	GetBucketWebsiteConfig(ctx context.Context, bucket string) (*gw.WebsiteConfig, error)
+	GetObjectInfo(ctx context.Context, bucket, object string, opts cmd.ObjectOptions) (cmd.ObjectInfo, error)
+	GetObjectNInfo(ctx context.Context, bucket, object string, rs *cmd.HTTPRangeSpec, h http.Header, lockType cmd.LockType, opts cmd.ObjectOptions) (*cmd.GetObjectReader, error)
+}
+
+// website serves buckets with a website configuration as static websites to
+// anonymous GET and HEAD requests.
+//
+// A website is served on a subdomain of a website domain named after the
+// access key ID of a public access to its bucket, which is resolved through
+// the auth service like linksharing does, e.g. a website served by an access
+// to the bucket "site" with the access key ID "jw7..." on "website.local" is
+// at "jw7....website.local". The access has to allow reading a single bucket,
+// which is the one served.
+type website struct {
+	log         *zap.Logger
+	layer       websiteLayer
+	authClient  *authclient.AuthClient
+	trustedIPs  *trustedip.Atomic
+	domainNames []string
+}
+
+func newWebsite(log *zap.Logger, config WebsiteConfig, layer websiteLayer, authClient *authclient.AuthClient, trustedIPs *trustedip.Atomic) *website {
+	var domainNames []string
+	for _, domainName := range strings.Split(config.DomainName, ",") {
+		if domainName = strings.TrimSpace(domainName); domainName != "" {
+			domainNames = append(domainNames, domainName)
+		}
+	}
+	if len(domainNames) == 0 {
+		return nil
+	}
+
+	return &website{
+		log:         log,
+		layer:       layer,
+		authClient:  authClient,
+		trustedIPs:  trustedIPs,
+		domainNames: domainNames,
+	}
+}
+
+// accessKeyID returns the access key ID host names the website of and
+// whether host is on a website domain.
+func (s *website) accessKeyID(host string) (string, bool) {
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	host = strings.ToLower(host)
+
+	for _, domainName := range s.domainNames {
+		suffix := "." + strings.ToLower(domainName)
+		if !strings.HasSuffix(host, suffix) {
+			continue
+		}
+		if accessKeyID := strings.TrimSuffix(host, suffix); accessKeyID != "" && !strings.Contains(accessKeyID, ".") {
+			return accessKeyID, true
+		}
+	}
+	return "", false
+}
+
+// Handler returns a handler passing requests to website domains to serve and
+// all other requests to next.
+func (s *website) Handler(serve, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.accessKeyID(r.Host); !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		serve.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP serves a request for a website.
+func (s *website) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeWebsiteError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+		return
+	}
+
+	accessKeyID, _ := s.accessKeyID(r.Host)
+	ctx, bucket, resolveErr := s.resolve(ctx, accessKeyID, trustedip.GetClientIP(s.trustedIPs.Load(), r))
+	if resolveErr != nil {
+		writeWebsiteError(w, r, resolveErr.HTTPStatusCode, resolveErr.Code, resolveErr.Description)
+		return
+	}
+
+	config, err := s.layer.GetBucketWebsiteConfig(ctx, bucket)
+	if err != nil {
+		apiErr := cmd.ToAPIError(ctx, err)
+		writeWebsiteError(w, r, apiErr.HTTPStatusCode, apiErr.Code, apiErr.Description)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	scheme := requestScheme(r)
+
+	if rule := config.Route(key, 0); rule != nil {
+		location, code := rule.Location(scheme, r.Host, key)
+		http.Redirect(w, r, location, code)
+		return
+	}
+
+	reader, err := s.layer.GetObjectNInfo(ctx, bucket, config.IndexKey(key), nil, http.Header{}, noLock, cmd.ObjectOptions{})
+	if err == nil {
+		s.serveObject(w, r, reader, http.StatusOK)
+		return
+	}
+
+	apiErr := cmd.ToAPIError(ctx, err)
+
+	// like S3, requests for a "directory" without the trailing slash are
+	// redirected to it if it has an index document.
+	if apiErr.HTTPStatusCode == http.StatusNotFound && key != "" && !strings.HasSuffix(key, "/") {
+		if _, err := s.layer.GetObjectInfo(ctx, bucket, config.IndexKey(key+"/"), cmd.ObjectOptions{}); err == nil {
+			http.Redirect(w, r, "/"+key+"/", http.StatusFound)
+			return
+		}
+	}
+
+	if rule := config.Route(key, apiErr.HTTPStatusCode); rule != nil {
+		location, code := rule.Location(scheme, r.Host, key)
+		http.Redirect(w, r, location, code)
+		return
+	}
+
+	if config.ErrorDocument != nil && apiErr.HTTPStatusCode < http.StatusInternalServerError {
+		reader, err := s.layer.GetObjectNInfo(ctx, bucket, config.ErrorDocument.Key, nil, http.Header{}, noLock, cmd.ObjectOptions{})
+		if err == nil {
+			s.serveObject(w, r, reader, apiErr.HTTPStatusCode)
+			return
+		}
+	}
+
+	writeWebsiteError(w, r, apiErr.HTTPStatusCode, apiErr.Code, apiErr.Description)
+}
+
+// resolve returns ctx with the credentials of the access with accessKeyID and
+// the bucket it allows reading.
+func (s *website) resolve(ctx context.Context, accessKeyID, clientIP string) (context.Context, string, *cmd.APIError) {
+	authResponse, err := s.authClient.ResolveWithCache(ctx, accessKeyID, clientIP)
+	if err != nil {
+		if errdata.GetStatus(err, http.StatusInternalServerError) >= http.StatusInternalServerError {
+			s.log.Error("failed to resolve access key", zap.Error(err))
+			apiErr := cmd.GetAPIError(cmd.ErrInternalError)
+			return nil, "", &apiErr
+		}
+		return nil, "", &errWebsiteNotFound
+	}
+
+	// websites are served to anonymous requests, so only public accesses
+	// can serve them.
+	if !authResponse.Public {
+		return nil, "", &errWebsiteNotFound
+	}
+
+	bucket, err := websiteBucket(ctx, authResponse.AccessGrant)
+	if err != nil {
+		return nil, "", &errWebsiteNotFound
+	}
+
+	ctx = middleware.WithCredentials(ctx, &middleware.Credentials{
+		AccessKey:           accessKeyID,
+		AuthServiceResponse: authResponse,
+	})
+	return ctx, bucket, nil
+}
+
+// errWebsiteNotFound is returned for requests to websites whose access key
+// can't serve one.
+var errWebsiteNotFound = cmd.APIError{
+	Code:           "NoSuchWebsiteConfiguration",
+	Description:    "There is no website served on this domain.",
+	HTTPStatusCode: http.StatusNotFound,
+}
+
+// websiteBucket returns the bucket accessGrant allows reading if it allows
+// reading a single one.
+func websiteBucket(ctx context.Context, accessGrant string) (string, error) {
+	access, err := grant.ParseAccess(accessGrant)
+	if err != nil {
+		return "", err
+	}
+
+	allowed, err := access.APIKey.GetAllowedBuckets(ctx, macaroon.Action{Op: macaroon.ActionRead, Time: time.Now()})
+	if err != nil {
+		return "", err
+	}
+	if allowed.All || len(allowed.Buckets) != 1 {
+		return "", errors.New("access doesn't allow reading a single bucket")
+	}
+
+	for bucket := range allowed.Buckets {
+		return bucket, nil
+	}
+	return "", nil
+}
+
+// serveObject responds to r with the object read by reader and status.
+func (s *website) serveObject(w http.ResponseWriter, r *http.Request, reader *cmd.GetObjectReader, status int) {
+	defer func() { _ = reader.Close() }()
+
+	info := reader.ObjInfo
+	etag := `"` + info.ETag + `"`
+
+	if status == http.StatusOK && info.ETag != "" && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	if info.ETag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !info.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(status)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, reader); err != nil {
+		s.log.Debug("failed to serve website object", zap.Error(err))
+	}
+}
+
+// writeWebsiteError responds to r with an HTML page describing an error, as
+// websites respond to browsers rather than S3 clients.
+func writeWebsiteError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	title := fmt.Sprintf("%d %s", status, http.StatusText(status))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "<html>\n<head><title>%s</title></head>\n<body>\n<h1>%s</h1>\n<ul>\n<li>Code: %s</li>\n<li>Message: %s</li>\n</ul>\n</body>\n</html>\n",
+		title, title, html.EscapeString(code), html.EscapeString(message))
+}
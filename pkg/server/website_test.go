@@ -0,0 +1,186 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"storj.io/common/grant"
+	"storj.io/common/macaroon"
+	"storj.io/gateway-mt/pkg/authclient"
+	"storj.io/gateway-mt/pkg/server/gw"
+	"storj.io/gateway-mt/pkg/trustedip"
+	"storj.io/minio/cmd"
+)
+
+type websiteTestLayer struct {
+	bucket  string
+	config  *gw.WebsiteConfig
+	objects map[string]string
+}
+
+func (l *websiteTestLayer) GetBucketWebsiteConfig(ctx context.Context, bucket string) (*gw.WebsiteConfig, error) {
+	if bucket != l.bucket {
+		return nil, cmd.BucketNotFound{Bucket: bucket}
+	}
+	if l.config == nil {
+		return nil, gw.ErrNoSuchWebsiteConfiguration
+	}
+	return l.config, nil
+}
+
+func (l *websiteTestLayer) GetObjectInfo(ctx context.Context, bucket, object string, opts cmd.ObjectOptions) (cmd.ObjectInfo, error) {
+	data, ok := l.objects[object]
+	if bucket != l.bucket || !ok {
+		return cmd.ObjectInfo{}, cmd.ObjectNotFound{Bucket: bucket, Object: object}
+	}
+	return cmd.ObjectInfo{
+		Bucket:      bucket,
+		Name:        object,
+		Size:        int64(len(data)),
+		ETag:        "etag-" + object,
+		ContentType: "text/html",
+	}, nil
+}
+
+func (l *websiteTestLayer) GetObjectNInfo(ctx context.Context, bucket, object string, rs *cmd.HTTPRangeSpec, h http.Header, lockType cmd.LockType, opts cmd.ObjectOptions) (*cmd.GetObjectReader, error) {
+	info, err := l.GetObjectInfo(ctx, bucket, object, opts)
+	if err != nil {
+		return nil, err
+	}
+	return cmd.NewGetObjectReaderFromReader(strings.NewReader(l.objects[object]), info, opts)
+}
+
+func TestWebsite(t *testing.T) {
+	apiKey, err := macaroon.NewAPIKey([]byte("secret"))
+	require.NoError(t, err)
+
+	serialize := func(apiKey *macaroon.APIKey) string {
+		access := grant.Access{
+			SatelliteAddress: "satellite.test:7777",
+			APIKey:           apiKey,
+			EncAccess:        grant.NewEncryptionAccess(),
+		}
+		serialized, err := access.Serialize()
+		require.NoError(t, err)
+		return serialized
+	}
+
+	siteKey, err := apiKey.Restrict(macaroon.Caveat{AllowedPaths: []*macaroon.Caveat_Path{{Bucket: []byte("site")}}})
+	require.NoError(t, err)
+
+	accesses := map[string]authclient.AuthServiceResponse{
+		"site":    {AccessGrant: serialize(siteKey), Public: true},
+		"private": {AccessGrant: serialize(siteKey), SecretKey: "SecretKey"},
+		"all":     {AccessGrant: serialize(apiKey), Public: true},
+	}
+
+	authService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := accesses[strings.TrimPrefix(r.URL.Path, "/v1/access/")]
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_grant": response.AccessGrant,
+			"secret_key":   response.SecretKey,
+			"public":       response.Public,
+		}))
+	}))
+	defer authService.Close()
+
+	config, err := gw.ParseWebsiteConfig(strings.NewReader(`<WebsiteConfiguration>
+		<IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+		<ErrorDocument><Key>404.html</Key></ErrorDocument>
+		<RoutingRules>
+			<RoutingRule>
+				<Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition>
+				<Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect>
+			</RoutingRule>
+			<RoutingRule>
+				<Condition><KeyPrefixEquals>gone/</KeyPrefixEquals><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition>
+				<Redirect><HostName>example.com</HostName><HttpRedirectCode>302</HttpRedirectCode></Redirect>
+			</RoutingRule>
+		</RoutingRules>
+	</WebsiteConfiguration>`))
+	require.NoError(t, err)
+
+	layer := &websiteTestLayer{
+		bucket: "site",
+		config: config,
+		objects: map[string]string{
+			"index.html":      "home",
+			"about.html":      "about",
+			"blog/index.html": "blog",
+			"404.html":        "not found",
+		},
+	}
+
+	authClient := authclient.New(authclient.Config{BaseURL: authService.URL, Token: "token", Timeout: 5 * time.Second})
+	website := newWebsite(zap.NewNop(), WebsiteConfig{DomainName: "website.test, sites.test"}, layer, authClient, trustedip.NewAtomic(trustedip.NewListTrustAll()))
+	require.NotNil(t, website)
+
+	for _, tt := range []struct {
+		host, path, method string
+		status             int
+		body, location     string
+	}{
+		{host: "site.website.test", path: "/", status: http.StatusOK, body: "home"},
+		{host: "SITE.sites.test:8080", path: "/about.html", status: http.StatusOK, body: "about"},
+		{host: "site.website.test", path: "/about.html", method: http.MethodHead, status: http.StatusOK},
+		{host: "site.website.test", path: "/blog/", status: http.StatusOK, body: "blog"},
+		{host: "site.website.test", path: "/blog", status: http.StatusFound, location: "/blog/"},
+		{host: "site.website.test", path: "/missing.html", status: http.StatusNotFound, body: "not found"},
+		{host: "site.website.test", path: "/docs/a.html", status: http.StatusMovedPermanently, location: "http://site.website.test/documents/a.html"},
+		{host: "site.website.test", path: "/gone/a.html", status: http.StatusFound, location: "http://example.com/gone/a.html"},
+		{host: "site.website.test", path: "/", method: http.MethodPut, status: http.StatusMethodNotAllowed},
+		{host: "unknown.website.test", path: "/", status: http.StatusNotFound},
+		{host: "private.website.test", path: "/", status: http.StatusNotFound},
+		{host: "all.website.test", path: "/", status: http.StatusNotFound},
+	} {
+		method := tt.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		name := method + " " + tt.host + tt.path
+
+		r := httptest.NewRequest(method, "http://"+tt.host+tt.path, nil)
+		rec := httptest.NewRecorder()
+		website.ServeHTTP(rec, r)
+
+		require.Equal(t, tt.status, rec.Code, name)
+		if tt.body != "" {
+			require.Equal(t, tt.body, rec.Body.String(), name)
+		}
+		if tt.location != "" {
+			require.Equal(t, tt.location, rec.Header().Get("Location"), name)
+		}
+		if method == http.MethodHead {
+			require.Empty(t, rec.Body.String(), name)
+			require.Equal(t, "5", rec.Header().Get("Content-Length"), name)
+		}
+	}
+
+	// other requests are passed on.
+	var passed bool
+	handler := website.Handler(website, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		passed = true
+	}))
+	for _, host := range []string{"gateway.test", "website.test", "bucket.site.website.test", "site.website.test.evil"} {
+		passed = false
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil))
+		require.True(t, passed, host)
+	}
+
+	require.Nil(t, newWebsite(zap.NewNop(), WebsiteConfig{}, layer, authClient, nil))
+}
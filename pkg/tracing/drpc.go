@@ -0,0 +1,57 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmetadata"
+)
+
+// WithDRPCMetadata returns ctx with the traceparent and tracestate of its
+// trace added to the metadata of the DRPC calls made with it.
+func WithDRPCMetadata(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return drpcmetadata.AddPairs(ctx, carrier)
+}
+
+// DRPCHandler traces the calls handler serves with spans named after their
+// RPC, continuing the traces of the traceparent and tracestate metadata of
+// calls.
+func DRPCHandler(handler drpc.Handler) drpc.Handler {
+	return drpcHandler{handler: handler}
+}
+
+type drpcHandler struct {
+	handler drpc.Handler
+}
+
+// HandleRPC implements drpc.Handler.
+func (h drpcHandler) HandleRPC(stream drpc.Stream, rpc string) (err error) {
+	ctx := stream.Context()
+	if metadata, ok := drpcmetadata.Get(ctx); ok {
+		ctx = propagator.Extract(ctx, propagation.MapCarrier(metadata))
+	}
+
+	ctx, span := Start(ctx, rpc, trace.WithSpanKind(trace.SpanKindServer))
+	defer func() { End(span, err) }()
+
+	return h.handler.HandleRPC(tracedStream{Stream: stream, ctx: ctx}, rpc)
+}
+
+// tracedStream is a drpc.Stream with the context of its span.
+type tracedStream struct {
+	drpc.Stream
+	ctx context.Context
+}
+
+func (s tracedStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,100 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zeebo/errs"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// fileSpan is a span as FileExporter writes it.
+type fileSpan struct {
+	Name         string                 `json:"name"`
+	Service      string                 `json:"service,omitempty"`
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Kind         string                 `json:"kind"`
+	Start        time.Time              `json:"start"`
+	End          time.Time              `json:"end"`
+	Status       string                 `json:"status"`
+	Error        string                 `json:"error,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// FileExporter is a span exporter appending spans to a file as JSON lines, for
+// debugging without a collector.
+type FileExporter struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewFileExporter constructs a FileExporter appending to the file at path.
+func NewFileExporter(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, Error.Wrap(err)
+	}
+	return &FileExporter{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// ExportSpans implements sdktrace.SpanExporter.
+func (e *FileExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.file == nil {
+		return Error.New("exporter is shut down")
+	}
+
+	for _, span := range spans {
+		s := fileSpan{
+			Name:    span.Name(),
+			TraceID: span.SpanContext().TraceID().String(),
+			SpanID:  span.SpanContext().SpanID().String(),
+			Kind:    span.SpanKind().String(),
+			Start:   span.StartTime(),
+			End:     span.EndTime(),
+			Status:  span.Status().Code.String(),
+			Error:   span.Status().Description,
+		}
+		if span.Parent().IsValid() {
+			s.ParentSpanID = span.Parent().SpanID().String()
+		}
+		if service, ok := span.Resource().Set().Value(semconv.ServiceNameKey); ok {
+			s.Service = service.AsString()
+		}
+		for _, kv := range span.Attributes() {
+			if s.Attributes == nil {
+				s.Attributes = make(map[string]interface{})
+			}
+			s.Attributes[string(kv.Key)] = kv.Value.AsInterface()
+		}
+		if err := e.encoder.Encode(s); err != nil {
+			return Error.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// Shutdown implements sdktrace.SpanExporter.
+func (e *FileExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.file == nil {
+		return nil
+	}
+	err := errs.Combine(e.file.Sync(), e.file.Close())
+	e.file = nil
+	return Error.Wrap(err)
+}
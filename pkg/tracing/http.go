@@ -0,0 +1,46 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/webhelp.v1/whmon"
+)
+
+// Handler traces the requests next serves with spans named name, continuing
+// the traces of the traceparent and tracestate headers of requests.
+//
+// Paths and queries aren't recorded because they can have sensitive
+// information.
+func Handler(name string, next http.Handler) http.Handler {
+	return whmon.MonitorResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", r.Method),
+				attribute.String("http.host", r.Host),
+			))
+		defer span.End()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		code := w.(whmon.ResponseWriter).StatusCode()
+		span.SetAttributes(attribute.Int("http.status_code", code))
+		if code >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(code))
+		}
+	}))
+}
+
+// InjectHTTP sets the traceparent and tracestate headers of req to continue
+// the trace of its context.
+func InjectHTTP(req *http.Request) {
+	propagator.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
@@ -0,0 +1,254 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package tracing traces requests with OpenTelemetry, propagating traces
+// between services with the W3C traceparent and tracestate headers.
+//
+// The monkit tasks run while serving a traced request, e.g. the satellite
+// calls uplink makes, are traced as its child spans, so a slow request can be
+// broken down without instrumenting everything twice.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/zeebo/errs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+var (
+	// Error is an error class for tracing errors.
+	Error = errs.Class("tracing")
+
+	// propagator reads and writes the W3C traceparent and tracestate headers.
+	propagator = propagation.TraceContext{}
+
+	// tracer holds the tracerOf of the running Tracer, if any.
+	tracer atomic.Value
+)
+
+// tracerOf holds the trace.Tracer of a provider, so the ones of different
+// providers can be held by the same atomic.Value.
+type tracerOf struct {
+	trace.Tracer
+}
+
+func init() {
+	tracer.Store(tracerOf{trace.NewNoopTracerProvider().Tracer(instrumentationName)})
+}
+
+const instrumentationName = "storj.io/gateway-mt"
+
+// Config configures Tracer.
+type Config struct {
+	Endpoint    string  `help:"address of the OTLP/HTTP collector to export spans to, e.g. localhost:4318 (empty to disable)" default:""`
+	Insecure    bool    `help:"export spans to the collector over plain HTTP" default:"false"`
+	File        string  `help:"path of a file to append spans to as JSON lines for offline debugging (empty to disable)" default:""`
+	SampleRatio float64 `help:"ratio of traces that are sampled" default:"0.01"`
+
+	TrustRemoteSampling bool `help:"keep the sampling decisions of callers instead of sampling their traces at the ratio; only enable it if all callers are trusted, since any can otherwise have all of its requests sampled" default:"false"`
+}
+
+// Tracer exports the spans of traced requests. While no Tracer is running,
+// traces are still propagated, but no spans are recorded.
+type Tracer struct {
+	provider   *sdktrace.TracerProvider
+	stopMonkit func()
+}
+
+// New constructs a Tracer exporting the spans of service as configured and
+// starts tracing with it. Only one Tracer may run at a time.
+func New(ctx context.Context, log *zap.Logger, service string, config Config) (_ *Tracer, err error) {
+	var exporters []sdktrace.SpanExporter
+	defer func() {
+		if err != nil {
+			for _, exporter := range exporters {
+				_ = exporter.Shutdown(ctx)
+			}
+		}
+	}()
+
+	if config.Endpoint != "" {
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, Error.Wrap(err)
+		}
+		exporters = append(exporters, exporter)
+		log.Info("exporting spans", zap.String("endpoint", config.Endpoint))
+	}
+
+	if config.File != "" {
+		exporter, err := NewFileExporter(config.File)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, exporter)
+		log.Info("writing spans", zap.String("file", config.File))
+	}
+
+	if len(exporters) == 0 {
+		return &Tracer{}, nil
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(newSampler(config)),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(service))),
+	}
+	for _, exporter := range exporters {
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	return newTracer(sdktrace.NewTracerProvider(options...)), nil
+}
+
+// newSampler returns the sampler of traces configured by config.
+//
+// Spans of traces continued from callers are sampled independently of the
+// caller's decision and trace ID unless config trusts the callers, since both
+// are chosen by the caller.
+func newSampler(config Config) sdktrace.Sampler {
+	root := sdktrace.TraceIDRatioBased(config.SampleRatio)
+	if config.TrustRemoteSampling {
+		return sdktrace.ParentBased(root)
+	}
+	remote := randomSampler{ratio: config.SampleRatio}
+	return sdktrace.ParentBased(root,
+		sdktrace.WithRemoteParentSampled(remote),
+		sdktrace.WithRemoteParentNotSampled(remote))
+}
+
+// randomSampler samples spans at random with a ratio.
+type randomSampler struct {
+	ratio float64
+}
+
+// ShouldSample implements sdktrace.Sampler.
+func (s randomSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	decision := sdktrace.Drop
+	if rand.Float64() < s.ratio {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+// Description implements sdktrace.Sampler.
+func (s randomSampler) Description() string {
+	return fmt.Sprintf("RandomSampler{%g}", s.ratio)
+}
+
+// newTracer starts tracing requests and monkit tasks with provider.
+func newTracer(provider *sdktrace.TracerProvider) *Tracer {
+	t := &Tracer{provider: provider}
+	tracer.Store(tracerOf{provider.Tracer(instrumentationName)})
+	t.stopMonkit = monkit.Default.ObserveTraces(func(trace *monkit.Trace) {
+		trace.ObserveSpansCtx(monkitObserver{})
+	})
+	return t
+}
+
+// Close exports the spans not yet exported and stops exporting spans.
+func (t *Tracer) Close(ctx context.Context) error {
+	if t.provider == nil {
+		return nil
+	}
+	t.stopMonkit()
+	tracer.Store(tracerOf{trace.NewNoopTracerProvider().Tracer(instrumentationName)})
+	return Error.Wrap(t.provider.Shutdown(ctx))
+}
+
+// Start starts a span named name as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return tracer.Load().(tracerOf).Start(ctx, name, opts...)
+}
+
+// End ends span, recording err if it's not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		setError(span, err)
+	}
+	span.End()
+}
+
+// setError records err on span by its name only. Error messages are never
+// exported, since they can hold access key IDs, object keys and URLs.
+func setError(span trace.Span, err error) {
+	name := errorName(err)
+	span.SetAttributes(attribute.String("error.name", name))
+	span.SetStatus(codes.Error, name)
+}
+
+// errorName returns the name of the class of err, or its type if it has none.
+func errorName(err error) string {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if namer, ok := e.(errs.Namer); ok { //nolint: errorlint // custom unwrap loop.
+			if name, ok := namer.Name(); ok {
+				return name
+			}
+		}
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "Canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Timeout"
+	}
+
+	for next := errors.Unwrap(err); next != nil; next = errors.Unwrap(err) {
+		err = next
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+// monkitSpanKey is the context key of the monkit span an OpenTelemetry span
+// was started for by monkitObserver.
+type monkitSpanKey struct{}
+
+// monkitObserver traces the monkit tasks run under a recording span as its
+// child spans.
+type monkitObserver struct{}
+
+// Start implements monkit.SpanCtxObserver.
+func (monkitObserver) Start(ctx context.Context, s *monkit.Span) context.Context {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx
+	}
+	ctx, _ = Start(ctx, s.Func().FullName(), trace.WithTimestamp(s.Start()))
+	return context.WithValue(ctx, monkitSpanKey{}, s)
+}
+
+// Finish implements monkit.SpanCtxObserver.
+func (monkitObserver) Finish(ctx context.Context, s *monkit.Span, err error, panicked bool, finish time.Time) {
+	if ctx.Value(monkitSpanKey{}) != s {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	if panicked {
+		span.SetAttributes(attribute.Bool("panicked", true))
+	}
+	if err != nil {
+		setError(span, err)
+	}
+	span.End(trace.WithTimestamp(finish))
+}
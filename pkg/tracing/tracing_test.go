@@ -0,0 +1,243 @@
+// Copyright (C) 2023 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spacemonkeygo/monkit/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"storj.io/common/testcontext"
+	"storj.io/drpc"
+	"storj.io/drpc/drpcmetadata"
+)
+
+var mon = monkit.Package()
+
+const (
+	traceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentSpanID = "00f067aa0ba902b7"
+	traceparent  = "00-" + traceID + "-" + parentSpanID + "-01"
+)
+
+func newTestTracer(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := newTracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { require.NoError(t, tracer.Close(context.Background())) })
+	return recorder
+}
+
+// serveOutgoing serves r with Handler and returns the request handling it
+// makes to another service.
+func serveOutgoing(r *http.Request) (*httptest.ResponseRecorder, *http.Request) {
+	var outgoing *http.Request
+	rr := httptest.NewRecorder()
+	Handler("test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outgoing = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(r.Context())
+		InjectHTTP(outgoing)
+		w.WriteHeader(http.StatusNotFound)
+	})).ServeHTTP(rr, r)
+	return rr, outgoing
+}
+
+func TestHandler(t *testing.T) {
+	recorder := newTestTracer(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+	r.Header.Set("traceparent", traceparent)
+	r.Header.Set("tracestate", "vendor=value")
+
+	rr, outgoing := serveOutgoing(r)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	require.Equal(t, "test", span.Name())
+	require.Equal(t, trace.SpanKindServer, span.SpanKind())
+	require.Equal(t, traceID, span.Parent().TraceID().String())
+	require.Equal(t, parentSpanID, span.Parent().SpanID().String())
+	require.Contains(t, span.Attributes(), attribute.Int("http.status_code", http.StatusNotFound))
+
+	// the trace is continued with the span of the handler as the parent.
+	require.Equal(t, "00-"+traceID+"-"+span.SpanContext().SpanID().String()+"-01", outgoing.Header.Get("traceparent"))
+	require.Equal(t, "vendor=value", outgoing.Header.Get("tracestate"))
+
+	// requests without a traceparent start new traces.
+	_, outgoing = serveOutgoing(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NotEmpty(t, outgoing.Header.Get("traceparent"))
+	require.NotContains(t, outgoing.Header.Get("traceparent"), traceID)
+}
+
+func TestHandlerWithoutTracer(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("traceparent", traceparent)
+
+	// without a Tracer, traces are propagated as they are.
+	_, outgoing := serveOutgoing(r)
+	require.Equal(t, traceparent, outgoing.Header.Get("traceparent"))
+
+	_, outgoing = serveOutgoing(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Empty(t, outgoing.Header.Get("traceparent"))
+}
+
+func TestMonkitTasks(t *testing.T) {
+	recorder := newTestTracer(t)
+
+	task := func(ctx context.Context) (err error) {
+		defer mon.Task()(&ctx)(&err)
+		return context.Canceled
+	}
+
+	// monkit tasks aren't traced outside of traced requests.
+	require.Error(t, task(context.Background()))
+	require.Empty(t, recorder.Ended())
+
+	ctx, root := Start(context.Background(), "root")
+	require.Error(t, task(ctx))
+	root.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "storj.io/gateway-mt/pkg/tracing.TestMonkitTasks.func1", spans[0].Name())
+	require.Equal(t, root.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	require.Equal(t, "Canceled", spans[0].Status().Description)
+}
+
+func TestErrorsAreNamed(t *testing.T) {
+	recorder := newTestTracer(t)
+
+	authError := errs.Class("authclient")
+	_, span := Start(context.Background(), "span")
+	End(span, authError.Wrap(fmt.Errorf("GET https://auth.test/v1/access/jwaohtj3dhixxfpzhwj522x7z3pb: %w", context.DeadlineExceeded)))
+
+	_, other := Start(context.Background(), "other")
+	End(other, fmt.Errorf("sj://bucket/secret/key: %w", os.ErrNotExist))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	// only the names of errors are recorded, never their messages.
+	require.Equal(t, "authclient", spans[0].Status().Description)
+	require.Contains(t, spans[0].Attributes(), attribute.String("error.name", "authclient"))
+	require.Empty(t, spans[0].Events())
+	require.Equal(t, "*errors.errorString", spans[1].Status().Description)
+	for _, span := range spans {
+		for _, attr := range span.Attributes() {
+			require.NotContains(t, attr.Value.Emit(), "jwaohtj3dhixxfpzhwj522x7z3pb")
+			require.NotContains(t, attr.Value.Emit(), "secret")
+		}
+	}
+}
+
+func TestRemoteSampling(t *testing.T) {
+	sampled := func(config Config, traceparent string) bool {
+		recorder := tracetest.NewSpanRecorder()
+		tracer := newTracer(sdktrace.NewTracerProvider(sdktrace.WithSampler(newSampler(config)), sdktrace.WithSpanProcessor(recorder)))
+		defer func() { require.NoError(t, tracer.Close(context.Background())) }()
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if traceparent != "" {
+			r.Header.Set("traceparent", traceparent)
+		}
+		serveOutgoing(r)
+		return len(recorder.Ended()) > 0
+	}
+
+	// callers can't have their traces sampled, by their decision or their
+	// trace ID, unless they're trusted.
+	lowTraceID := "00-00000000000000000000000000000001-" + parentSpanID + "-01"
+	require.False(t, sampled(Config{SampleRatio: 0}, traceparent))
+	require.False(t, sampled(Config{SampleRatio: 1e-9}, lowTraceID))
+	require.True(t, sampled(Config{SampleRatio: 0, TrustRemoteSampling: true}, traceparent))
+
+	require.True(t, sampled(Config{SampleRatio: 1}, traceparent))
+	require.True(t, sampled(Config{SampleRatio: 1}, ""))
+	require.False(t, sampled(Config{SampleRatio: 0}, ""))
+}
+
+type testStream struct {
+	drpc.Stream
+	ctx context.Context
+}
+
+func (s testStream) Context() context.Context { return s.ctx }
+
+type testHandler func(stream drpc.Stream, rpc string) error
+
+func (h testHandler) HandleRPC(stream drpc.Stream, rpc string) error { return h(stream, rpc) }
+
+func TestDRPC(t *testing.T) {
+	recorder := newTestTracer(t)
+
+	ctx, client := Start(context.Background(), "client")
+	metadata, ok := drpcmetadata.Get(WithDRPCMetadata(ctx))
+	require.True(t, ok)
+	client.End()
+
+	// the server receives the metadata in its context.
+	stream := testStream{ctx: drpcmetadata.AddPairs(context.Background(), metadata)}
+	err := DRPCHandler(testHandler(func(stream drpc.Stream, rpc string) error {
+		require.True(t, trace.SpanFromContext(stream.Context()).IsRecording())
+		return nil
+	})).HandleRPC(stream, "/service/Method")
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "/service/Method", spans[1].Name())
+	require.Equal(t, client.SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+	require.Equal(t, client.SpanContext().SpanID(), spans[1].Parent().SpanID())
+}
+
+func TestFileExporter(t *testing.T) {
+	ctx := testcontext.New(t)
+	defer ctx.Cleanup()
+
+	path := filepath.Join(ctx.Dir(), "spans.jsonl")
+	exporter, err := NewFileExporter(path)
+	require.NoError(t, err)
+
+	tracer := newTracer(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	parentCtx, parent := Start(ctx, "parent")
+	_, child := Start(parentCtx, "child")
+	child.End()
+	parent.End()
+	require.NoError(t, tracer.Close(ctx))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer ctx.Check(file.Close)
+
+	var spans []fileSpan
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var span fileSpan
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans = append(spans, span)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, spans, 2)
+	require.Equal(t, "child", spans[0].Name)
+	require.Equal(t, "parent", spans[1].Name)
+	require.Equal(t, spans[1].TraceID, spans[0].TraceID)
+	require.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	require.Empty(t, spans[1].ParentSpanID)
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"sync/atomic"
 )
 
 // List is a list of trusted IPs for conveniently verifying if an IP is trusted.
@@ -55,6 +56,38 @@ func (l List) IsTrusted(ip string) bool {
 	return ok
 }
 
+// OnlyListed returns a List trusting only the IPs l lists, which trusts no IP
+// instead of any if l lists none. It's for uses where a client able to spoof
+// its IP is worse than having the IP of a proxy.
+func (l List) OnlyListed() List {
+	if l.untrustAll || len(l.ips) == 0 {
+		return NewListUntrustAll()
+	}
+	return l
+}
+
+// Atomic holds a List that can be replaced while it's in use.
+type Atomic struct {
+	list atomic.Value
+}
+
+// NewAtomic creates a new Atomic initially holding list.
+func NewAtomic(list List) *Atomic {
+	a := &Atomic{}
+	a.Store(list)
+	return a
+}
+
+// Load returns the List held by a.
+func (a *Atomic) Load() List {
+	return a.list.Load().(List)
+}
+
+// Store replaces the List held by a.
+func (a *Atomic) Store(list List) {
+	a.list.Store(list)
+}
+
 // GetClientIP gets the IP of the client from the 'Forwarded',
 // 'X-Forwarded-For', or 'X-Real-Ip' headers if r.RemoteAddr is a trusted IP and
 // returning it from the first header which are checked in that specific order.
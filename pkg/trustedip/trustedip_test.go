@@ -260,6 +260,19 @@ func TestGetClientIP(t *testing.T) {
 	}
 }
 
+func TestListOnlyListed(t *testing.T) {
+	r := &http.Request{
+		RemoteAddr: "10.5.2.23:5",
+		Header:     map[string][]string{"X-Forwarded-For": {"172.17.5.10"}},
+	}
+
+	assert.Equal(t, "172.17.5.10", trustedip.GetClientIP(trustedip.NewListTrustAll(), r))
+	assert.Equal(t, "10.5.2.23", trustedip.GetClientIP(trustedip.NewListTrustAll().OnlyListed(), r))
+	assert.Equal(t, "10.5.2.23", trustedip.GetClientIP(trustedip.NewListUntrustAll().OnlyListed(), r))
+	assert.Equal(t, "172.17.5.10", trustedip.GetClientIP(trustedip.NewList("10.5.2.23").OnlyListed(), r))
+	assert.Equal(t, "10.5.2.23", trustedip.GetClientIP(trustedip.NewList("192.168.5.2").OnlyListed(), r))
+}
+
 func TestGetClientIPFromHeaders(t *testing.T) {
 	testCases := []struct {
 		desc string
@@ -4,16 +4,22 @@
 package server_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -59,9 +65,11 @@ func TestUploadDownload(t *testing.T) {
 		gwConfig.Server.Address = "127.0.0.1:0"
 		gwConfig.Auth.BaseURL = "http://" + authSvcAddr
 		gwConfig.InsecureLogAll = true
+		gwConfig.Notification.Queues = []string{"ingest=" + ctx.File("events.jsonl")}
+		gwConfig.Notification.SpoolDir = ctx.Dir("notifications")
 		authClient := authclient.New(gwConfig.Auth)
 
-		gateway, err := server.New(gwConfig, zaptest.NewLogger(t).Named("gateway"), trustedip.NewListTrustAll(), []string{}, authClient, []string{}, 10)
+		gateway, err := server.New(ctx, gwConfig, zaptest.NewLogger(t).Named("gateway"), trustedip.NewListTrustAll(), []string{}, authClient, []string{}, 10)
 		require.NoError(t, err)
 
 		defer ctx.Check(gateway.Close)
@@ -239,7 +247,882 @@ func TestUploadDownload(t *testing.T) {
 			require.Contains(t, res, "attributionTest")
 			require.Contains(t, res, "testAttribution")
 		}
+		{ // object versions
+			newSession, err := session.NewSession(&aws.Config{
+				Credentials:      credentials.NewStaticCredentials(s3Credentials.AccessKeyID, s3Credentials.SecretKey, ""),
+				Endpoint:         aws.String("http://" + gateway.Address()),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+			})
+			require.NoError(t, err)
+			s3Client := s3.New(newSession)
+
+			bucket := "bucket-versions"
+
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			for _, key := range []string{"a", "b", "c"} {
+				_, err = s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+					Bucket: aws.String(bucket),
+					Key:    aws.String(key),
+					Body:   strings.NewReader(key),
+				})
+				require.NoError(t, err)
+			}
+
+			versioning, err := s3Client.GetBucketVersioningWithContext(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+			require.Nil(t, versioning.Status)
+
+			_, err = s3Client.PutBucketVersioningWithContext(ctx, &s3.PutBucketVersioningInput{
+				Bucket: aws.String(bucket),
+				VersioningConfiguration: &s3.VersioningConfiguration{
+					Status: aws.String(s3.BucketVersioningStatusEnabled),
+				},
+			})
+			require.Error(t, err)
+
+			versions, err := s3Client.ListObjectVersionsWithContext(ctx, &s3.ListObjectVersionsInput{
+				Bucket:  aws.String(bucket),
+				MaxKeys: aws.Int64(2),
+			})
+			require.NoError(t, err)
+			require.True(t, *versions.IsTruncated)
+			require.Len(t, versions.Versions, 2)
+			for _, v := range versions.Versions {
+				require.Equal(t, "null", *v.VersionId)
+				require.True(t, *v.IsLatest)
+			}
+			require.Equal(t, "b", *versions.NextKeyMarker)
+			require.Equal(t, "null", *versions.NextVersionIdMarker)
+
+			versions, err = s3Client.ListObjectVersionsWithContext(ctx, &s3.ListObjectVersionsInput{
+				Bucket:          aws.String(bucket),
+				KeyMarker:       versions.NextKeyMarker,
+				VersionIdMarker: versions.NextVersionIdMarker,
+			})
+			require.NoError(t, err)
+			require.False(t, *versions.IsTruncated)
+			require.Len(t, versions.Versions, 1)
+			require.Equal(t, "c", *versions.Versions[0].Key)
+
+			head, err := s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+				Bucket:    aws.String(bucket),
+				Key:       aws.String("a"),
+				VersionId: aws.String("null"),
+			})
+			require.NoError(t, err)
+			require.Equal(t, "null", *head.VersionId)
+
+			_, err = s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+				Bucket:    aws.String(bucket),
+				Key:       aws.String("a"),
+				VersionId: aws.String("c8b0d8b6-3b8e-4b8a-9d8a-6f4d0b8d1a2e"),
+			})
+			require.Error(t, err)
+
+			_, err = s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+				Bucket:    aws.String(bucket),
+				Key:       aws.String("a"),
+				VersionId: aws.String("null"),
+			})
+			require.NoError(t, err)
+
+			_, err = s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String("a"),
+			})
+			require.Error(t, err)
+		}
+		{ // server-side copy and part copy
+			newSession, err := session.NewSession(&aws.Config{
+				Credentials:      credentials.NewStaticCredentials(s3Credentials.AccessKeyID, s3Credentials.SecretKey, ""),
+				Endpoint:         aws.String("http://" + gateway.Address()),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+			})
+			require.NoError(t, err)
+			s3Client := s3.New(newSession)
+
+			bucket := "bucket-copy"
+			data := testrand.Bytes(10 * memory.KiB)
+
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			put, err := s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String("src"),
+				Body:   bytes.NewReader(data),
+			})
+			require.NoError(t, err)
+
+			_, err = s3Client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+				Bucket:            aws.String(bucket),
+				Key:               aws.String("dst"),
+				CopySource:        aws.String(bucket + "/src"),
+				CopySourceIfMatch: aws.String(`"mismatch"`),
+			})
+			var reqErr awserr.RequestFailure
+			require.ErrorAs(t, err, &reqErr)
+			require.Equal(t, http.StatusPreconditionFailed, reqErr.StatusCode())
+
+			_, err = s3Client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+				Bucket:            aws.String(bucket),
+				Key:               aws.String("dst"),
+				CopySource:        aws.String(bucket + "/src"),
+				CopySourceIfMatch: put.ETag,
+			})
+			require.NoError(t, err)
+
+			get, err := s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String("dst"),
+			})
+			require.NoError(t, err)
+			copied, err := io.ReadAll(get.Body)
+			require.NoError(t, err)
+			require.NoError(t, get.Body.Close())
+			require.Equal(t, data, copied)
+
+			upload, err := s3Client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String("dst-multipart"),
+			})
+			require.NoError(t, err)
+
+			partCopy, err := s3Client.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+				Bucket:          aws.String(bucket),
+				Key:             aws.String("dst-multipart"),
+				UploadId:        upload.UploadId,
+				PartNumber:      aws.Int64(1),
+				CopySource:      aws.String(bucket + "/src"),
+				CopySourceRange: aws.String("bytes=1024-2047"),
+			})
+			require.NoError(t, err)
+
+			_, err = s3Client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+				Bucket:   aws.String(bucket),
+				Key:      aws.String("dst-multipart"),
+				UploadId: upload.UploadId,
+				MultipartUpload: &s3.CompletedMultipartUpload{
+					Parts: []*s3.CompletedPart{{ETag: partCopy.CopyPartResult.ETag, PartNumber: aws.Int64(1)}},
+				},
+			})
+			require.NoError(t, err)
+
+			get, err = s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String("dst-multipart"),
+			})
+			require.NoError(t, err)
+			copied, err = io.ReadAll(get.Body)
+			require.NoError(t, err)
+			require.NoError(t, get.Body.Close())
+			require.Equal(t, data[1024:2048], copied)
+		}
+		{ // object lock
+			newSession, err := session.NewSession(&aws.Config{
+				Credentials:      credentials.NewStaticCredentials(s3Credentials.AccessKeyID, s3Credentials.SecretKey, ""),
+				Endpoint:         aws.String("http://" + gateway.Address()),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+			})
+			require.NoError(t, err)
+			s3Client := s3.New(newSession)
+
+			bucket := "bucket-object-lock"
+
+			// the network can't keep objects from being deleted or
+			// overwritten yet, so object lock is refused.
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{
+				Bucket:                     aws.String(bucket),
+				ObjectLockEnabledForBucket: aws.Bool(true),
+			})
+			var reqErr awserr.RequestFailure
+			require.ErrorAs(t, err, &reqErr)
+			require.Equal(t, http.StatusNotImplemented, reqErr.StatusCode())
+
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			_, err = s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String("log"),
+				Body:   strings.NewReader("log"),
+			})
+			require.NoError(t, err)
+
+			for name, request := range map[string]func() error{
+				"PutObjectLockConfiguration": func() error {
+					_, err := s3Client.PutObjectLockConfigurationWithContext(ctx, &s3.PutObjectLockConfigurationInput{
+						Bucket:                  aws.String(bucket),
+						ObjectLockConfiguration: &s3.ObjectLockConfiguration{ObjectLockEnabled: aws.String(s3.ObjectLockEnabledEnabled)},
+					})
+					return err
+				},
+				"GetObjectLockConfiguration": func() error {
+					_, err := s3Client.GetObjectLockConfigurationWithContext(ctx, &s3.GetObjectLockConfigurationInput{Bucket: aws.String(bucket)})
+					return err
+				},
+				"PutObjectRetention": func() error {
+					_, err := s3Client.PutObjectRetentionWithContext(ctx, &s3.PutObjectRetentionInput{
+						Bucket: aws.String(bucket),
+						Key:    aws.String("log"),
+						Retention: &s3.ObjectLockRetention{
+							Mode:            aws.String(s3.ObjectLockRetentionModeCompliance),
+							RetainUntilDate: aws.Time(time.Now().Add(24 * time.Hour)),
+						},
+					})
+					return err
+				},
+				"GetObjectRetention": func() error {
+					_, err := s3Client.GetObjectRetentionWithContext(ctx, &s3.GetObjectRetentionInput{Bucket: aws.String(bucket), Key: aws.String("log")})
+					return err
+				},
+				"PutObjectLegalHold": func() error {
+					_, err := s3Client.PutObjectLegalHoldWithContext(ctx, &s3.PutObjectLegalHoldInput{
+						Bucket:    aws.String(bucket),
+						Key:       aws.String("log"),
+						LegalHold: &s3.ObjectLockLegalHold{Status: aws.String(s3.ObjectLockLegalHoldStatusOn)},
+					})
+					return err
+				},
+				"GetObjectLegalHold": func() error {
+					_, err := s3Client.GetObjectLegalHoldWithContext(ctx, &s3.GetObjectLegalHoldInput{Bucket: aws.String(bucket), Key: aws.String("log")})
+					return err
+				},
+			} {
+				err := request()
+				require.ErrorAs(t, err, &reqErr, name)
+				require.Equal(t, http.StatusNotImplemented, reqErr.StatusCode(), name)
+			}
+
+			_, err = s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String("log")})
+			require.NoError(t, err)
+
+			_, err = s3Client.DeleteBucketWithContext(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+		}
+		{ // lifecycle
+			newSession, err := session.NewSession(&aws.Config{
+				Credentials:      credentials.NewStaticCredentials(s3Credentials.AccessKeyID, s3Credentials.SecretKey, ""),
+				Endpoint:         aws.String("http://" + gateway.Address()),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+			})
+			require.NoError(t, err)
+			s3Client := s3.New(newSession)
+
+			bucket := "bucket-lifecycle"
+
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			_, err = s3Client.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(bucket)})
+			var reqErr awserr.RequestFailure
+			require.ErrorAs(t, err, &reqErr)
+			require.Equal(t, "NoSuchLifecycleConfiguration", reqErr.Code())
+
+			_, err = s3Client.PutBucketLifecycleConfigurationWithContext(ctx, &s3.PutBucketLifecycleConfigurationInput{
+				Bucket: aws.String(bucket),
+				LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
+					Rules: []*s3.LifecycleRule{
+						{
+							ID:         aws.String("scratch"),
+							Status:     aws.String(s3.ExpirationStatusEnabled),
+							Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String("scratch/")},
+							Expiration: &s3.LifecycleExpiration{Days: aws.Int64(1)},
+							AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{
+								DaysAfterInitiation: aws.Int64(1),
+							},
+						},
+					},
+				},
+			})
+			require.NoError(t, err)
+
+			lifecycle, err := s3Client.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+			require.Len(t, lifecycle.Rules, 1)
+			require.Equal(t, "scratch", *lifecycle.Rules[0].ID)
+			require.Equal(t, "scratch/", *lifecycle.Rules[0].Filter.Prefix)
+			require.EqualValues(t, 1, *lifecycle.Rules[0].Expiration.Days)
+			require.EqualValues(t, 1, *lifecycle.Rules[0].AbortIncompleteMultipartUpload.DaysAfterInitiation)
+
+			for _, key := range []string{"scratch/object", "object"} {
+				_, err = s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+					Bucket: aws.String(bucket),
+					Key:    aws.String(key),
+					Body:   strings.NewReader("data"),
+				})
+				require.NoError(t, err)
+			}
+
+			head, err := s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String("scratch/object")})
+			require.NoError(t, err)
+			expires, err := time.Parse(time.RFC3339, *head.Metadata["Storj-Expires"])
+			require.NoError(t, err)
+			require.WithinDuration(t, time.Now().Add(48*time.Hour), expires, 24*time.Hour)
+
+			head, err = s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String("object")})
+			require.NoError(t, err)
+			require.NotContains(t, head.Metadata, "Storj-Expires")
+
+			_, err = s3Client.DeleteBucketLifecycleWithContext(ctx, &s3.DeleteBucketLifecycleInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			_, err = s3Client.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(bucket)})
+			require.ErrorAs(t, err, &reqErr)
+			require.Equal(t, "NoSuchLifecycleConfiguration", reqErr.Code())
+		}
+		{ // CORS
+			newSession, err := session.NewSession(&aws.Config{
+				Credentials:      credentials.NewStaticCredentials(s3Credentials.AccessKeyID, s3Credentials.SecretKey, ""),
+				Endpoint:         aws.String("http://" + gateway.Address()),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+			})
+			require.NoError(t, err)
+			s3Client := s3.New(newSession)
+
+			bucket := "bucket-cors"
+
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			// without a configuration, the global allowed origins apply.
+			cors, err := s3Client.GetBucketCorsWithContext(ctx, &s3.GetBucketCorsInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+			require.Equal(t, "*", *cors.CORSRules[0].AllowedOrigins[0])
+
+			_, err = s3Client.PutBucketCorsWithContext(ctx, &s3.PutBucketCorsInput{
+				Bucket: aws.String(bucket),
+				CORSConfiguration: &s3.CORSConfiguration{
+					CORSRules: []*s3.CORSRule{{
+						AllowedOrigins: aws.StringSlice([]string{"https://app.example.com"}),
+						AllowedMethods: aws.StringSlice([]string{http.MethodGet}),
+						MaxAgeSeconds:  aws.Int64(60),
+					}},
+				},
+			})
+			require.NoError(t, err)
+
+			cors, err = s3Client.GetBucketCorsWithContext(ctx, &s3.GetBucketCorsInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+			require.Len(t, cors.CORSRules, 1)
+			require.Equal(t, []string{"https://app.example.com"}, aws.StringValueSlice(cors.CORSRules[0].AllowedOrigins))
+			require.EqualValues(t, 60, *cors.CORSRules[0].MaxAgeSeconds)
+
+			preflight := func(origin, method string) *http.Response {
+				req, err := http.NewRequestWithContext(ctx, http.MethodOptions, "http://"+gateway.Address()+"/"+bucket+"/object", nil)
+				require.NoError(t, err)
+				req.Header.Set("Origin", origin)
+				req.Header.Set("Access-Control-Request-Method", method)
+
+				resp, err := http.DefaultClient.Do(req)
+				require.NoError(t, err)
+				require.NoError(t, resp.Body.Close())
+				return resp
+			}
+
+			resp := preflight("https://app.example.com", http.MethodGet)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+
+			resp = preflight("https://other.example.com", http.MethodGet)
+			require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+			resp = preflight("https://app.example.com", http.MethodPut)
+			require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+			_, err = s3Client.DeleteBucketCorsWithContext(ctx, &s3.DeleteBucketCorsInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			resp = preflight("https://other.example.com", http.MethodPut)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+		{ // S3 Select
+			newSession, err := session.NewSession(&aws.Config{
+				Credentials:      credentials.NewStaticCredentials(s3Credentials.AccessKeyID, s3Credentials.SecretKey, ""),
+				Endpoint:         aws.String("http://" + gateway.Address()),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+			})
+			require.NoError(t, err)
+			s3Client := s3.New(newSession)
+
+			bucket := "bucket-select"
+
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			var gzipped bytes.Buffer
+			zw := gzip.NewWriter(&gzipped)
+			_, err = zw.Write([]byte("name;size\na;1\nb;20\nc;300\n"))
+			require.NoError(t, err)
+			require.NoError(t, zw.Close())
+
+			for key, body := range map[string][]byte{
+				"data.csv":    []byte("name;size\na;1\nb;20\nc;300\n"),
+				"data.csv.gz": gzipped.Bytes(),
+				"data.json":   []byte(`{"name":"a","size":1}` + "\n" + `{"name":"b","size":20}` + "\n" + `{"name":"c","size":300}` + "\n"),
+			} {
+				_, err = s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+					Bucket: aws.String(bucket),
+					Key:    aws.String(key),
+					Body:   bytes.NewReader(body),
+				})
+				require.NoError(t, err)
+			}
+
+			csvInput := &s3.InputSerialization{
+				CSV: &s3.CSVInput{
+					FileHeaderInfo:  aws.String(s3.FileHeaderInfoUse),
+					FieldDelimiter:  aws.String(";"),
+					RecordDelimiter: aws.String("\n"),
+				},
+			}
+
+			selectObject := func(key, expression string, input *s3.InputSerialization) (records string, stats *s3.Stats) {
+				resp, err := s3Client.SelectObjectContentWithContext(ctx, &s3.SelectObjectContentInput{
+					Bucket:              aws.String(bucket),
+					Key:                 aws.String(key),
+					Expression:          aws.String(expression),
+					ExpressionType:      aws.String(s3.ExpressionTypeSql),
+					InputSerialization:  input,
+					OutputSerialization: &s3.OutputSerialization{CSV: &s3.CSVOutput{}},
+					RequestProgress:     &s3.RequestProgress{Enabled: aws.Bool(true)},
+				})
+				require.NoError(t, err)
+				defer func() { require.NoError(t, resp.EventStream.Close()) }()
+
+				var b strings.Builder
+				for event := range resp.EventStream.Events() {
+					switch e := event.(type) {
+					case *s3.RecordsEvent:
+						b.Write(e.Payload)
+					case *s3.StatsEvent:
+						stats = e.Details
+					}
+				}
+				require.NoError(t, resp.EventStream.Err())
+				require.NotNil(t, stats)
+
+				return b.String(), stats
+			}
+
+			records, stats := selectObject("data.csv", "SELECT s.name FROM S3Object s WHERE CAST(s.size AS INT) > 10", csvInput)
+			require.Equal(t, "b\nc\n", records)
+			require.EqualValues(t, 25, *stats.BytesScanned)
+
+			gzipInput := *csvInput
+			gzipInput.CompressionType = aws.String(s3.CompressionTypeGzip)
+			records, stats = selectObject("data.csv.gz", "SELECT s.name FROM S3Object s WHERE CAST(s.size AS INT) > 10", &gzipInput)
+			require.Equal(t, "b\nc\n", records)
+			require.EqualValues(t, 25, *stats.BytesProcessed)
+
+			records, _ = selectObject("data.json", "SELECT s.name, s.size FROM S3Object s WHERE s.size < 100", &s3.InputSerialization{
+				JSON: &s3.JSONInput{Type: aws.String(s3.JSONTypeLines)},
+			})
+			require.Equal(t, "a,1\nb,20\n", records)
+
+			_, err = s3Client.SelectObjectContentWithContext(ctx, &s3.SelectObjectContentInput{
+				Bucket:              aws.String(bucket),
+				Key:                 aws.String("data.csv"),
+				Expression:          aws.String("SELECT FROM WHERE"),
+				ExpressionType:      aws.String(s3.ExpressionTypeSql),
+				InputSerialization:  csvInput,
+				OutputSerialization: &s3.OutputSerialization{CSV: &s3.CSVOutput{}},
+			})
+			var reqErr awserr.RequestFailure
+			require.ErrorAs(t, err, &reqErr)
+			require.Equal(t, http.StatusBadRequest, reqErr.StatusCode())
+		}
+		{ // bucket tagging and policy
+			newSession, err := session.NewSession(&aws.Config{
+				Credentials:      credentials.NewStaticCredentials(s3Credentials.AccessKeyID, s3Credentials.SecretKey, ""),
+				Endpoint:         aws.String("http://" + gateway.Address()),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+			})
+			require.NoError(t, err)
+			s3Client := s3.New(newSession)
+
+			bucket := "bucket-policy"
+
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			_, err = s3Client.GetBucketTaggingWithContext(ctx, &s3.GetBucketTaggingInput{Bucket: aws.String(bucket)})
+			require.Error(t, err)
+
+			_, err = s3Client.PutBucketTaggingWithContext(ctx, &s3.PutBucketTaggingInput{
+				Bucket:  aws.String(bucket),
+				Tagging: &s3.Tagging{TagSet: []*s3.Tag{{Key: aws.String("team"), Value: aws.String("storage")}}},
+			})
+			require.NoError(t, err)
+
+			tagging, err := s3Client.GetBucketTaggingWithContext(ctx, &s3.GetBucketTaggingInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+			require.Len(t, tagging.TagSet, 1)
+			require.Equal(t, "team", *tagging.TagSet[0].Key)
+			require.Equal(t, "storage", *tagging.TagSet[0].Value)
+
+			_, err = s3Client.DeleteBucketTaggingWithContext(ctx, &s3.DeleteBucketTaggingInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			_, err = s3Client.GetBucketTaggingWithContext(ctx, &s3.GetBucketTaggingInput{Bucket: aws.String(bucket)})
+			require.Error(t, err)
+
+			for _, key := range []string{"public/object", "private/object"} {
+				_, err = s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+					Bucket: aws.String(bucket),
+					Key:    aws.String(key),
+					Body:   bytes.NewReader([]byte("data")),
+				})
+				require.NoError(t, err)
+			}
+
+			// policies can only allow anonymous reads.
+			_, err = s3Client.PutBucketPolicyWithContext(ctx, &s3.PutBucketPolicyInput{
+				Bucket: aws.String(bucket),
+				Policy: aws.String(fmt.Sprintf(`{
+					"Version": "2012-10-17",
+					"Statement": [
+						{"Effect": "Allow", "Principal": "*", "Action": "s3:PutObject", "Resource": "arn:aws:s3:::%s/public/*"}
+					]
+				}`, bucket)),
+			})
+			var reqErr awserr.RequestFailure
+			require.ErrorAs(t, err, &reqErr)
+			require.Equal(t, http.StatusBadRequest, reqErr.StatusCode())
+
+			_, err = s3Client.PutBucketPolicyWithContext(ctx, &s3.PutBucketPolicyInput{
+				Bucket: aws.String(bucket),
+				Policy: aws.String(fmt.Sprintf(`{
+					"Version": "2012-10-17",
+					"Statement": [
+						{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::%[1]s/public/*"},
+						{"Effect": "Deny", "Principal": {"AWS": ["%[2]s"]}, "Action": "s3:DeleteObject", "Resource": "arn:aws:s3:::%[1]s/*"}
+					]
+				}`, bucket, s3Credentials.AccessKeyID)),
+			})
+			require.NoError(t, err)
+
+			bucketPolicy, err := s3Client.GetBucketPolicyWithContext(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+			require.Contains(t, *bucketPolicy.Policy, "s3:DeleteObject")
+
+			anonymousGet := func(key string) int {
+				resp, err := http.Get("http://" + gateway.Address() + "/" + bucket + "/" + key)
+				require.NoError(t, err)
+				require.NoError(t, resp.Body.Close())
+				return resp.StatusCode
+			}
+
+			require.Equal(t, http.StatusOK, anonymousGet("public/object"))
+			require.Equal(t, http.StatusForbidden, anonymousGet("private/object"))
+
+			_, err = s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("private/object")})
+			require.NoError(t, err)
+
+			_, err = s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String("private/object")})
+			require.ErrorAs(t, err, &reqErr)
+			require.Equal(t, http.StatusForbidden, reqErr.StatusCode())
+
+			_, err = s3Client.DeleteBucketPolicyWithContext(ctx, &s3.DeleteBucketPolicyInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusForbidden, anonymousGet("public/object"))
+
+			_, err = s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String("private/object")})
+			require.NoError(t, err)
+		}
+		{ // conditional writes
+			newSession, err := session.NewSession(&aws.Config{
+				Credentials:      credentials.NewStaticCredentials(s3Credentials.AccessKeyID, s3Credentials.SecretKey, ""),
+				Endpoint:         aws.String("http://" + gateway.Address()),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+			})
+			require.NoError(t, err)
+			s3Client := s3.New(newSession)
+
+			bucket := "bucket-conditional"
+
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			putObject := func(data, header, value string) (string, int) {
+				req, out := s3Client.PutObjectRequest(&s3.PutObjectInput{
+					Bucket: aws.String(bucket),
+					Key:    aws.String("object"),
+					Body:   bytes.NewReader([]byte(data)),
+				})
+				req.SetContext(ctx)
+				if header != "" {
+					req.HTTPRequest.Header.Set(header, value)
+				}
+				if err := req.Send(); err != nil {
+					var reqErr awserr.RequestFailure
+					require.ErrorAs(t, err, &reqErr)
+					return "", reqErr.StatusCode()
+				}
+				return *out.ETag, http.StatusOK
+			}
+
+			// conditional writes can't be honored, so they're refused.
+			_, status := putObject("first", "If-None-Match", "*")
+			require.Equal(t, http.StatusNotImplemented, status)
+
+			_, status = putObject("first", "If-Match", "*")
+			require.Equal(t, http.StatusNotImplemented, status)
+
+			_, status = putObject("first", "", "")
+			require.Equal(t, http.StatusOK, status)
 
+			upload, err := s3Client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String("object"),
+			})
+			require.NoError(t, err)
+
+			part, err := s3Client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+				Bucket:     aws.String(bucket),
+				Key:        aws.String("object"),
+				UploadId:   upload.UploadId,
+				PartNumber: aws.Int64(1),
+				Body:       bytes.NewReader([]byte("multipart")),
+			})
+			require.NoError(t, err)
+
+			completeMultipartUpload := func(header, value string) int {
+				req, _ := s3Client.CompleteMultipartUploadRequest(&s3.CompleteMultipartUploadInput{
+					Bucket:   aws.String(bucket),
+					Key:      aws.String("object"),
+					UploadId: upload.UploadId,
+					MultipartUpload: &s3.CompletedMultipartUpload{
+						Parts: []*s3.CompletedPart{{ETag: part.ETag, PartNumber: aws.Int64(1)}},
+					},
+				})
+				req.SetContext(ctx)
+				if header != "" {
+					req.HTTPRequest.Header.Set(header, value)
+				}
+				if err := req.Send(); err != nil {
+					var reqErr awserr.RequestFailure
+					require.ErrorAs(t, err, &reqErr)
+					return reqErr.StatusCode()
+				}
+				return http.StatusOK
+			}
+
+			require.Equal(t, http.StatusNotImplemented, completeMultipartUpload("If-None-Match", "*"))
+			require.Equal(t, http.StatusNotImplemented, completeMultipartUpload("If-Match", "*"))
+			require.Equal(t, http.StatusOK, completeMultipartUpload("", ""))
+
+			object, err := s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("object")})
+			require.NoError(t, err)
+			data, err := io.ReadAll(object.Body)
+			require.NoError(t, err)
+			require.NoError(t, object.Body.Close())
+			require.Equal(t, "multipart", string(data))
+		}
+		{ // checksums
+			newSession, err := session.NewSession(&aws.Config{
+				Credentials:      credentials.NewStaticCredentials(s3Credentials.AccessKeyID, s3Credentials.SecretKey, ""),
+				Endpoint:         aws.String("http://" + gateway.Address()),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+			})
+			require.NoError(t, err)
+			s3Client := s3.New(newSession)
+
+			bucket := "bucket-checksum"
+
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			putObject := func(data, checksum string) (http.Header, error) {
+				req, _ := s3Client.PutObjectRequest(&s3.PutObjectInput{
+					Bucket: aws.String(bucket),
+					Key:    aws.String("object"),
+					Body:   bytes.NewReader([]byte(data)),
+				})
+				req.SetContext(ctx)
+				req.HTTPRequest.Header.Set("X-Amz-Checksum-Crc32", checksum)
+				err := req.Send()
+				if req.HTTPResponse == nil {
+					return nil, err
+				}
+				return req.HTTPResponse.Header, err
+			}
+
+			header, err := putObject("hello world", "DUoRhQ==")
+			require.NoError(t, err)
+			require.Equal(t, "DUoRhQ==", header.Get("X-Amz-Checksum-Crc32"))
+
+			_, err = putObject("hello there", "DUoRhQ==")
+			var reqErr awserr.RequestFailure
+			require.ErrorAs(t, err, &reqErr)
+			require.Equal(t, http.StatusBadRequest, reqErr.StatusCode())
+			require.Equal(t, "BadDigest", reqErr.Code())
+
+			headObject := func(checksumMode string) http.Header {
+				req, _ := s3Client.HeadObjectRequest(&s3.HeadObjectInput{
+					Bucket: aws.String(bucket),
+					Key:    aws.String("object"),
+				})
+				req.SetContext(ctx)
+				if checksumMode != "" {
+					req.HTTPRequest.Header.Set("X-Amz-Checksum-Mode", checksumMode)
+				}
+				require.NoError(t, req.Send())
+				return req.HTTPResponse.Header
+			}
+
+			require.Empty(t, headObject("").Get("X-Amz-Checksum-Crc32"))
+			require.Equal(t, "DUoRhQ==", headObject("ENABLED").Get("X-Amz-Checksum-Crc32"))
+
+			// the failed upload didn't replace the object.
+			object, err := s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("object")})
+			require.NoError(t, err)
+			data, err := io.ReadAll(object.Body)
+			require.NoError(t, err)
+			require.NoError(t, object.Body.Close())
+			require.Equal(t, "hello world", string(data))
+		}
+
+		{ // signatures
+			newClient := func(accessKeyID, secretKey string) *s3.S3 {
+				newSession, err := session.NewSession(&aws.Config{
+					Credentials:      credentials.NewStaticCredentials(accessKeyID, secretKey, ""),
+					Endpoint:         aws.String("http://" + gateway.Address()),
+					Region:           aws.String("us-east-1"),
+					S3ForcePathStyle: aws.Bool(true),
+				})
+				require.NoError(t, err)
+				return s3.New(newSession)
+			}
+			s3Client := newClient(s3Credentials.AccessKeyID, s3Credentials.SecretKey)
+
+			bucket := "bucket-signatures"
+
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			_, err = s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String("object"),
+				Body:   strings.NewReader("hello world"),
+			})
+			require.NoError(t, err)
+
+			_, err = newClient(s3Credentials.AccessKeyID, "wrong-secret-key").ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
+			var reqErr awserr.RequestFailure
+			require.ErrorAs(t, err, &reqErr)
+			require.Equal(t, "SignatureDoesNotMatch", reqErr.Code())
+
+			_, err = newClient("unknownaccesskeyid", s3Credentials.SecretKey).ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
+			require.ErrorAs(t, err, &reqErr)
+			require.Equal(t, "InvalidAccessKeyId", reqErr.Code())
+
+			req, _ := s3Client.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("object")})
+			url, err := req.Presign(time.Minute)
+			require.NoError(t, err)
+
+			resp, err := http.Get(url) //nolint: gosec // the URL is the gateway's.
+			require.NoError(t, err)
+			data, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "hello world", string(data))
+
+			resp, err = http.Get(url + "0") //nolint: gosec // the URL is the gateway's.
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, http.StatusForbidden, resp.StatusCode)
+		}
+
+		{ // bucket notifications
+			newSession, err := session.NewSession(&aws.Config{
+				Credentials:      credentials.NewStaticCredentials(s3Credentials.AccessKeyID, s3Credentials.SecretKey, ""),
+				Endpoint:         aws.String("http://" + gateway.Address()),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+			})
+			require.NoError(t, err)
+			s3Client := s3.New(newSession)
+
+			bucket := "bucket-notifications"
+
+			_, err = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+
+			_, err = s3Client.PutBucketNotificationConfigurationWithContext(ctx, &s3.PutBucketNotificationConfigurationInput{
+				Bucket: aws.String(bucket),
+				NotificationConfiguration: &s3.NotificationConfiguration{
+					QueueConfigurations: []*s3.QueueConfiguration{{
+						Id:       aws.String("unknown"),
+						QueueArn: aws.String("arn:minio:sqs::unknown:queue"),
+						Events:   aws.StringSlice([]string{"s3:ObjectCreated:*"}),
+					}},
+				},
+			})
+			var reqErr awserr.RequestFailure
+			require.ErrorAs(t, err, &reqErr)
+			require.Equal(t, "InvalidArgument", reqErr.Code())
+
+			_, err = s3Client.PutBucketNotificationConfigurationWithContext(ctx, &s3.PutBucketNotificationConfigurationInput{
+				Bucket: aws.String(bucket),
+				NotificationConfiguration: &s3.NotificationConfiguration{
+					QueueConfigurations: []*s3.QueueConfiguration{{
+						Id:       aws.String("ingest"),
+						QueueArn: aws.String("arn:minio:sqs::ingest:queue"),
+						Events:   aws.StringSlice([]string{"s3:ObjectCreated:*", "s3:ObjectRemoved:*"}),
+						Filter: &s3.NotificationConfigurationFilter{Key: &s3.KeyFilter{FilterRules: []*s3.FilterRule{{
+							Name:  aws.String("prefix"),
+							Value: aws.String("incoming/"),
+						}}}},
+					}},
+				},
+			})
+			require.NoError(t, err)
+
+			notifications, err := s3Client.GetBucketNotificationConfigurationWithContext(ctx, &s3.GetBucketNotificationConfigurationRequest{Bucket: aws.String(bucket)})
+			require.NoError(t, err)
+			require.Len(t, notifications.QueueConfigurations, 1)
+			require.Equal(t, "arn:minio:sqs::ingest:queue", *notifications.QueueConfigurations[0].QueueArn)
+
+			for _, key := range []string{"incoming/object", "other/object"} {
+				_, err = s3Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+					Bucket: aws.String(bucket),
+					Key:    aws.String(key),
+					Body:   strings.NewReader("hello world"),
+				})
+				require.NoError(t, err)
+			}
+
+			_, err = s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String("incoming/object")})
+			require.NoError(t, err)
+
+			var events []string
+			require.Eventually(t, func() bool {
+				data, err := os.ReadFile(ctx.File("events.jsonl"))
+				if err != nil {
+					return false
+				}
+				events = strings.Split(strings.TrimSpace(string(data)), "\n")
+				return len(events) == 2
+			}, 10*time.Second, 100*time.Millisecond)
+
+			require.Contains(t, events[0], `"eventName":"s3:ObjectCreated:Put"`)
+			require.Contains(t, events[0], `"key":"incoming%2Fobject","size":11`)
+			require.Contains(t, events[1], `"eventName":"s3:ObjectRemoved:Delete"`)
+		}
 	})
 }
 